@@ -0,0 +1,139 @@
+package clients
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/daite/tspider/common"
+)
+
+// DefaultAria2URL is the JSON-RPC endpoint of a local aria2 started with
+// --enable-rpc
+const DefaultAria2URL = "http://127.0.0.1:6800/jsonrpc"
+
+func init() {
+	Register("aria2", func(cfg common.ClientConfig) Client { return NewAria2(cfg) })
+}
+
+// Aria2 hands magnets to aria2 through its JSON-RPC interface
+type Aria2 struct {
+	URL string
+	// Secret is the --rpc-secret of aria2, empty when it has none
+	Secret string
+	client *http.Client
+}
+
+// NewAria2 returns a client for the aria2 RPC endpoint at cfg.URL,
+// authenticating with cfg.Secret
+func NewAria2(cfg common.ClientConfig) *Aria2 {
+	u := cfg.URL
+	if u == "" {
+		u = DefaultAria2URL
+	}
+	return &Aria2{
+		URL:    u,
+		Secret: cfg.Secret,
+		client: &http.Client{Timeout: timeout()},
+	}
+}
+
+// call runs the RPC method with params, prefixed by the secret token
+func (a *Aria2) call(method string, params ...interface{}) (json.RawMessage, error) {
+	if a.Secret != "" {
+		params = append([]interface{}{"token:" + a.Secret}, params...)
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      "tspider",
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := a.client.Post(a.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("cannot reach aria2 at %s: %w", a.URL, err)
+	}
+	defer resp.Body.Close()
+	var reply struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return nil, fmt.Errorf("aria2 %s: %s", method, resp.Status)
+	}
+	if reply.Error != nil {
+		if reply.Error.Message == "Unauthorized" {
+			return nil, fmt.Errorf("aria2 refused the secret; check clients.aria2.secret")
+		}
+		return nil, fmt.Errorf("aria2 %s: %s", method, reply.Error.Message)
+	}
+	return reply.Result, nil
+}
+
+// AddMagnet adds magnet as a download, in opts.SavePath when set. aria2 has
+// no categories, so opts.Category is an error.
+func (a *Aria2) AddMagnet(magnet string, opts AddOptions) error {
+	if opts.Category != "" {
+		return fmt.Errorf("aria2 has no categories; drop --category")
+	}
+	options := map[string]string{}
+	if opts.SavePath != "" {
+		options["dir"] = opts.SavePath
+	}
+	_, err := a.call("aria2.addUri", []string{magnet}, options)
+	return err
+}
+
+// TestConnection asks for the aria2 version
+func (a *Aria2) TestConnection() error {
+	_, err := a.call("aria2.getVersion")
+	return err
+}
+
+// ListActive returns the active downloads. aria2 reports numbers as
+// strings, and names only torrents whose metadata has arrived.
+func (a *Aria2) ListActive() ([]Torrent, error) {
+	reply, err := a.call("aria2.tellActive", []string{"gid", "status", "infoHash", "totalLength", "completedLength", "downloadSpeed", "bittorrent"})
+	if err != nil {
+		return nil, err
+	}
+	var downloads []struct {
+		GID             string `json:"gid"`
+		Status          string `json:"status"`
+		InfoHash        string `json:"infoHash"`
+		TotalLength     string `json:"totalLength"`
+		CompletedLength string `json:"completedLength"`
+		DownloadSpeed   string `json:"downloadSpeed"`
+		BitTorrent      struct {
+			Info struct {
+				Name string `json:"name"`
+			} `json:"info"`
+		} `json:"bittorrent"`
+	}
+	if err := json.Unmarshal(reply, &downloads); err != nil {
+		return nil, fmt.Errorf("aria2 tellActive: %w", err)
+	}
+	torrents := make([]Torrent, len(downloads))
+	for i, d := range downloads {
+		t := Torrent{Name: d.BitTorrent.Info.Name, Hash: d.InfoHash, State: d.Status}
+		if t.Name == "" {
+			t.Name = d.GID
+		}
+		total, _ := strconv.ParseFloat(d.TotalLength, 64)
+		done, _ := strconv.ParseFloat(d.CompletedLength, 64)
+		if total > 0 {
+			t.Progress = done / total
+		}
+		t.DownloadRate, _ = strconv.ParseInt(d.DownloadSpeed, 10, 64)
+		torrents[i] = t
+	}
+	return torrents, nil
+}
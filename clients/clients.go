@@ -0,0 +1,114 @@
+package clients
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/daite/tspider/common"
+)
+
+// AddOptions are the options of torrents sent to a torrent client
+type AddOptions struct {
+	// Category files the torrents under a client category or label
+	Category string
+	// SavePath is the download directory, the client's default when empty
+	SavePath string
+}
+
+// Torrent is a torrent a client is working on
+type Torrent struct {
+	Name string `json:"name"`
+	Hash string `json:"hash"`
+	// Progress is the downloaded share, from 0 to 1
+	Progress float64 `json:"progress"`
+	// State is what the client says the torrent is doing, in its own words
+	State string `json:"state"`
+	// DownloadRate is in bytes per second
+	DownloadRate int64 `json:"download_rate"`
+}
+
+// Client is a torrent client that magnets can be sent to
+type Client interface {
+	// AddMagnet adds magnet with opts, authenticating first if needed
+	AddMagnet(magnet string, opts AddOptions) error
+	// TestConnection checks that the client is reachable and accepts the
+	// configured credentials
+	TestConnection() error
+	// ListActive returns the torrents the client is downloading or seeding
+	ListActive() ([]Torrent, error)
+}
+
+// Factory returns a client for the settings of its section of the config
+type Factory func(cfg common.ClientConfig) Client
+
+// registry maps client names to their factories
+var registry = map[string]Factory{}
+
+// Register makes a client available under name. Each client registers
+// itself from an init function.
+func Register(name string, f Factory) {
+	if _, dup := registry[name]; dup {
+		panic("clients: " + name + " registered twice")
+	}
+	registry[name] = f
+}
+
+// Names returns the names of the registered clients
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New returns the client called name, configured from clients.<name> in the
+// config
+func New(name string) (Client, error) {
+	f, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown client %q (want %s)", name, strings.Join(Names(), ", "))
+	}
+	return f(common.GetConfig().Clients[name]), nil
+}
+
+// AddMagnets adds each of magnets to c with opts. An error on the first
+// magnet, such as an unreachable client or bad credentials, stops there;
+// later failures are collected and reported together.
+func AddMagnets(c Client, magnets []string, opts AddOptions) error {
+	var failed []string
+	err := AddEach(c, magnets, opts, func(i int, err error) {
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s (%v)", magnets[i], err))
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d magnet(s) not added: %s", len(failed), strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// AddEach adds each of magnets to c with opts and calls report with the
+// index of each magnet added or not, and its error. An error on the first
+// magnet stops there and is returned instead.
+func AddEach(c Client, magnets []string, opts AddOptions, report func(i int, err error)) error {
+	for i, m := range magnets {
+		err := c.AddMagnet(m, opts)
+		if err != nil && i == 0 {
+			return err
+		}
+		report(i, err)
+	}
+	return nil
+}
+
+// timeout is the request timeout of the clients, timeout_seconds
+func timeout() time.Duration {
+	return time.Duration(common.GetConfig().Timeout) * time.Second
+}
@@ -0,0 +1,157 @@
+package clients
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+
+	"github.com/daite/tspider/common"
+)
+
+// DefaultQBittorrentURL is where a local qBittorrent serves its WebUI
+const DefaultQBittorrentURL = "http://127.0.0.1:8080"
+
+func init() {
+	Register("qbittorrent", func(cfg common.ClientConfig) Client { return NewQBittorrent(cfg) })
+}
+
+// QBittorrent sends magnets to a qBittorrent instance through its WebUI API
+type QBittorrent struct {
+	URL      string
+	Username string
+	Password string
+	client   *http.Client
+	loggedIn bool
+}
+
+// NewQBittorrent returns a client for the qBittorrent WebUI at cfg.URL,
+// logging in with cfg.Username and cfg.Password
+func NewQBittorrent(cfg common.ClientConfig) *QBittorrent {
+	u := strings.TrimRight(cfg.URL, "/")
+	if u == "" {
+		u = DefaultQBittorrentURL
+	}
+	jar, _ := cookiejar.New(nil)
+	return &QBittorrent{
+		URL:      u,
+		Username: cfg.Username,
+		Password: cfg.Password,
+		client:   &http.Client{Timeout: timeout(), Jar: jar},
+	}
+}
+
+// do sends form to the API method path and returns the response text. Forms
+// go in the body of a POST and in the query of a GET.
+func (q *QBittorrent) do(method, path string, form url.Values) (string, error) {
+	var req *http.Request
+	var err error
+	if method == "GET" {
+		req, err = http.NewRequest(method, q.URL+path+"?"+form.Encode(), nil)
+	} else {
+		req, err = http.NewRequest(method, q.URL+path, strings.NewReader(form.Encode()))
+		if req != nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	// The WebUI rejects requests whose Referer does not match its host
+	req.Header.Set("Referer", q.URL)
+	resp, err := q.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("cannot reach qBittorrent at %s: %w", q.URL, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	text := strings.TrimSpace(string(body))
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return text, nil
+	case http.StatusForbidden:
+		return "", fmt.Errorf("qBittorrent refused %s: log in failed or the IP is banned", path)
+	}
+	return "", fmt.Errorf("qBittorrent %s: %s %s", path, resp.Status, text)
+}
+
+// Login starts a WebUI session once. Without a username it does nothing,
+// for instances that skip authentication for local clients.
+func (q *QBittorrent) Login() error {
+	if q.Username == "" || q.loggedIn {
+		return nil
+	}
+	text, err := q.do("POST", "/api/v2/auth/login", url.Values{"username": {q.Username}, "password": {q.Password}})
+	if err != nil {
+		return err
+	}
+	if text != "Ok." {
+		return fmt.Errorf("qBittorrent login failed for %s; check clients.qbittorrent.username and password", q.Username)
+	}
+	q.loggedIn = true
+	return nil
+}
+
+// AddMagnet logs in and adds magnet with opts
+func (q *QBittorrent) AddMagnet(magnet string, opts AddOptions) error {
+	if err := q.Login(); err != nil {
+		return err
+	}
+	form := url.Values{"urls": {magnet}}
+	if opts.Category != "" {
+		form.Set("category", opts.Category)
+	}
+	if opts.SavePath != "" {
+		form.Set("savepath", opts.SavePath)
+	}
+	text, err := q.do("POST", "/api/v2/torrents/add", form)
+	if err != nil {
+		return err
+	}
+	if text != "Ok." {
+		return fmt.Errorf("qBittorrent did not add the torrent: %s", text)
+	}
+	return nil
+}
+
+// TestConnection logs in and asks for the qBittorrent version
+func (q *QBittorrent) TestConnection() error {
+	if err := q.Login(); err != nil {
+		return err
+	}
+	_, err := q.do("GET", "/api/v2/app/version", nil)
+	return err
+}
+
+// ListActive logs in and returns the torrents qBittorrent counts as active,
+// those transferring data
+func (q *QBittorrent) ListActive() ([]Torrent, error) {
+	if err := q.Login(); err != nil {
+		return nil, err
+	}
+	text, err := q.do("GET", "/api/v2/torrents/info", url.Values{"filter": {"active"}})
+	if err != nil {
+		return nil, err
+	}
+	var infos []struct {
+		Name     string  `json:"name"`
+		Hash     string  `json:"hash"`
+		Progress float64 `json:"progress"`
+		State    string  `json:"state"`
+		DLSpeed  int64   `json:"dlspeed"`
+	}
+	if err := json.Unmarshal([]byte(text), &infos); err != nil {
+		return nil, fmt.Errorf("qBittorrent torrent list: %w", err)
+	}
+	torrents := make([]Torrent, len(infos))
+	for i, t := range infos {
+		torrents[i] = Torrent{Name: t.Name, Hash: t.Hash, Progress: t.Progress, State: t.State, DownloadRate: t.DLSpeed}
+	}
+	return torrents, nil
+}
@@ -0,0 +1,154 @@
+package clients
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/daite/tspider/common"
+)
+
+// DefaultTransmissionURL is the RPC endpoint of a local Transmission
+const DefaultTransmissionURL = "http://127.0.0.1:9091/transmission/rpc"
+
+// transmissionSessionHeader carries the CSRF token Transmission hands out
+// with a 409 reply and expects on every request
+const transmissionSessionHeader = "X-Transmission-Session-Id"
+
+// transmissionStates names the torrent status codes of the RPC API
+var transmissionStates = []string{"stopped", "check pending", "checking", "download pending", "downloading", "seed pending", "seeding"}
+
+func init() {
+	Register("transmission", func(cfg common.ClientConfig) Client { return NewTransmission(cfg) })
+}
+
+// Transmission sends magnets to a Transmission daemon through its JSON-RPC API
+type Transmission struct {
+	URL       string
+	Username  string
+	Password  string
+	client    *http.Client
+	sessionID string
+}
+
+// NewTransmission returns a client for the Transmission RPC endpoint at
+// cfg.URL, authenticating with cfg.Username and cfg.Password
+func NewTransmission(cfg common.ClientConfig) *Transmission {
+	u := cfg.URL
+	if u == "" {
+		u = DefaultTransmissionURL
+	}
+	return &Transmission{
+		URL:      u,
+		Username: cfg.Username,
+		Password: cfg.Password,
+		client:   &http.Client{Timeout: timeout()},
+	}
+}
+
+// call runs the RPC method with arguments and returns the arguments of the
+// reply. A 409 reply is retried once with the session id it carries.
+func (t *Transmission) call(method string, arguments interface{}) (json.RawMessage, error) {
+	body, err := json.Marshal(map[string]interface{}{"method": method, "arguments": arguments})
+	if err != nil {
+		return nil, err
+	}
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest("POST", t.URL, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if t.sessionID != "" {
+			req.Header.Set(transmissionSessionHeader, t.sessionID)
+		}
+		if t.Username != "" {
+			req.SetBasicAuth(t.Username, t.Password)
+		}
+		resp, err := t.client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("cannot reach Transmission at %s: %w", t.URL, err)
+		}
+		switch {
+		case resp.StatusCode == http.StatusConflict && attempt == 0:
+			resp.Body.Close()
+			t.sessionID = resp.Header.Get(transmissionSessionHeader)
+			continue
+		case resp.StatusCode == http.StatusUnauthorized:
+			resp.Body.Close()
+			return nil, fmt.Errorf("Transmission refused the credentials; check clients.transmission.username and password")
+		case resp.StatusCode != http.StatusOK:
+			resp.Body.Close()
+			return nil, fmt.Errorf("Transmission %s: %s", method, resp.Status)
+		}
+		var reply struct {
+			Result    string          `json:"result"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&reply)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Transmission %s: %w", method, err)
+		}
+		if reply.Result != "success" {
+			return nil, fmt.Errorf("Transmission %s: %s", method, reply.Result)
+		}
+		return reply.Arguments, nil
+	}
+}
+
+// AddMagnet adds magnet with opts. Category becomes a torrent label, which
+// needs Transmission 4.0 or later. A torrent Transmission already has is
+// not an error.
+func (t *Transmission) AddMagnet(magnet string, opts AddOptions) error {
+	args := map[string]interface{}{"filename": magnet}
+	if opts.SavePath != "" {
+		args["download-dir"] = opts.SavePath
+	}
+	if opts.Category != "" {
+		args["labels"] = []string{opts.Category}
+	}
+	_, err := t.call("torrent-add", args)
+	return err
+}
+
+// TestConnection asks for the session settings
+func (t *Transmission) TestConnection() error {
+	_, err := t.call("session-get", map[string]interface{}{"fields": []string{"version"}})
+	return err
+}
+
+// ListActive returns the torrents that are not stopped
+func (t *Transmission) ListActive() ([]Torrent, error) {
+	reply, err := t.call("torrent-get", map[string]interface{}{
+		"fields": []string{"name", "hashString", "percentDone", "status", "rateDownload"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var list struct {
+		Torrents []struct {
+			Name         string  `json:"name"`
+			HashString   string  `json:"hashString"`
+			PercentDone  float64 `json:"percentDone"`
+			Status       int     `json:"status"`
+			RateDownload int64   `json:"rateDownload"`
+		} `json:"torrents"`
+	}
+	if err := json.Unmarshal(reply, &list); err != nil {
+		return nil, fmt.Errorf("Transmission torrent-get: %w", err)
+	}
+	var torrents []Torrent
+	for _, t := range list.Torrents {
+		if t.Status == 0 {
+			continue
+		}
+		state := fmt.Sprintf("status %d", t.Status)
+		if t.Status < len(transmissionStates) {
+			state = transmissionStates[t.Status]
+		}
+		torrents = append(torrents, Torrent{Name: t.Name, Hash: t.HashString, Progress: t.PercentDone, State: state, DownloadRate: t.RateDownload})
+	}
+	return torrents, nil
+}
@@ -1,47 +1,126 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
+	"github.com/daite/tspider/clients"
 	"github.com/daite/tspider/common"
+	"github.com/daite/tspider/etorrent"
 	"github.com/daite/tspider/jtorrent"
 	"github.com/daite/tspider/ktorrent"
+	"github.com/daite/tspider/metadata"
+	"github.com/daite/tspider/notifiers"
+	"github.com/daite/tspider/server"
+	"github.com/daite/tspider/torznab"
 	"github.com/urfave/cli/v2"
 )
 
 var version = "1.0.0"
 
+// commit and buildDate are set at build time with
+// -ldflags "-X main.commit=... -X main.buildDate=..."
+var (
+	commit    = ""
+	buildDate = ""
+)
+
+const (
+	// exitSitesDown is the exit code of doctor when the site it diagnosed,
+	// or every enabled site of a language it checked, is down
+	exitSitesDown = 2
+	// exitDeadline is the exit code used when --deadline cuts a run short
+	exitDeadline = 3
+	// exitInterrupted is the exit code used when Ctrl-C cuts a run short,
+	// as shells report a command killed by SIGINT
+	exitInterrupted = 130
+)
+
+// replaying is set while replay re-runs a recorded search so it is not recorded again
+var replaying bool
+
 func main() {
-	app := &cli.App{
+	// Ctrl-C cancels the context of the command, aborting its requests; a
+	// second Ctrl-C kills tspider at once
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	if err := newApp().RunContext(ctx, os.Args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func newApp() *cli.App {
+	// cancelDeadline releases the --deadline timer once the command is done
+	cancelDeadline := func() {}
+	return &cli.App{
 		Name:    "tspider",
 		Usage:   "search torrent magnet links",
 		Version: version,
+		// --deadline bounds the whole command, whichever it is: the
+		// commands run with the context set here
+		Before: func(c *cli.Context) error {
+			if d := c.Duration("deadline"); d > 0 {
+				c.Context, cancelDeadline = context.WithTimeout(c.Context, d)
+			}
+			return nil
+		},
+		After: func(c *cli.Context) error {
+			cancelDeadline()
+			return nil
+		},
 		Commands: []*cli.Command{
 			searchCommand(),
+			suggestCommand(),
+			replayCommand(),
+			historyCommand(),
+			favCommand(),
 			doctorCommand(),
 			configCommand(),
+			versionCommand(),
+			sendCommand(),
+			serveCommand(),
+			watchCommand(),
+			daemonCommand(),
 		},
-		Flags: []cli.Flag{
+		Flags: append([]cli.Flag{
 			&cli.StringFlag{
 				Name:    "lang",
 				Aliases: []string{"l"},
-				Usage:   "choose torrent sites (kr or jp)",
+				Usage:   "choose torrent sites (kr, jp or en)",
 			},
-		},
+			&cli.DurationFlag{
+				Name:  "deadline",
+				Usage: "hard upper bound on total run time (e.g. 30s, 2m); partial results are printed when it fires",
+			},
+		}, searchFlags()...),
 		Action: func(c *cli.Context) error {
-			// Default action: search if keyword provided
-			if c.NArg() == 0 {
+			// Default action: search if a keyword or an uploader is provided
+			if c.NArg() == 0 && c.String("uploader") == "" {
 				return cli.ShowAppHelp(c)
 			}
 			return doSearch(c)
 		},
 	}
-
-	if err := app.Run(os.Args); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
-	}
 }
 
 func searchCommand() *cli.Command {
@@ -50,188 +129,2665 @@ func searchCommand() *cli.Command {
 		Aliases:   []string{"s"},
 		Usage:     "search for torrents",
 		ArgsUsage: "<keyword>",
-		Flags: []cli.Flag{
+		Flags: append([]cli.Flag{
 			&cli.StringFlag{
 				Name:    "lang",
 				Aliases: []string{"l"},
-				Usage:   "language filter: kr (Korean) or jp (Japanese)",
+				Usage:   "language filter: kr (Korean), jp (Japanese) or en (English)",
 			},
-		},
+		}, searchFlags()...),
 		Action: func(c *cli.Context) error {
-			if c.NArg() == 0 {
-				return fmt.Errorf("please provide a search keyword")
+			if c.NArg() == 0 && c.String("uploader") == "" {
+				return fmt.Errorf("please provide a search keyword or --uploader")
 			}
 			return doSearch(c)
 		},
 	}
 }
 
-func doctorCommand() *cli.Command {
+// searchFlags returns the search options accepted both before and after the search command
+func searchFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    "profile",
+			Aliases: []string{"p"},
+			Usage:   "search only the sites of a named profile (see 'config profile')",
+		},
+		&cli.StringSliceFlag{
+			Name:  "exclude",
+			Usage: "drop results whose title matches this regex (repeatable), in addition to the blocklist",
+		},
+		&cli.BoolFlag{
+			Name:  "no-blocklist",
+			Usage: "ignore the persistent blocklist for this search",
+		},
+		&cli.StringFlag{
+			Name:  "sort",
+			Value: "relevance",
+			Usage: "order results by relevance, title or seeders",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Value: "table",
+			Usage: "print results as a table, as json, as csv, as ndjson (result lines as sites finish, like --stream) or as ndjson-results ({site, result} lines)",
+		},
+		&cli.BoolFlag{
+			Name:  "json",
+			Usage: "print results as JSON, the same as --format json",
+		},
+		&cli.BoolFlag{
+			Name:    "interactive",
+			Aliases: []string{"i"},
+			Usage:   "browse the results: page, filter, show details, copy or open magnets (needs a terminal)",
+		},
+		&cli.BoolFlag{
+			Name:  "pick",
+			Usage: "choose one result with a fuzzy finder (fzf when installed) and print only its magnet",
+		},
+		&cli.BoolFlag{
+			Name:  "copy",
+			Usage: "copy the magnet of the first result to the clipboard; with --pick, of the chosen result instead of printing it",
+		},
+		&cli.BoolFlag{
+			Name:  "open",
+			Usage: "open the magnet of the first result in the default torrent client; with --pick, of the chosen result",
+		},
+		&cli.BoolFlag{
+			Name:  "magnets",
+			Usage: "print only the magnet links, one per line, e.g. for xargs aria2c",
+		},
+		&cli.BoolFlag{
+			Name:  "stream",
+			Usage: "print each result as a line of JSON as soon as its site finishes",
+		},
+		&cli.StringFlag{
+			Name:  "template",
+			Usage: "print each result with a Go `TEMPLATE` over its fields, e.g. '{{.Title}}\\t{{.Magnet}}' (\\t and \\n are unescaped)",
+		},
+		&cli.BoolFlag{
+			Name:  "no-cache",
+			Usage: "search the sites again instead of reusing results of the same search from the last minutes",
+		},
+		&cli.BoolFlag{
+			Name:  "exact",
+			Usage: "search the keyword as an exact phrase and drop titles that do not contain it",
+		},
+		&cli.BoolFlag{
+			Name:  "raw-keyword",
+			Usage: "search the keyword exactly as typed, skipping all keyword transforms",
+		},
+		&cli.BoolFlag{
+			Name:  "pager",
+			Usage: "page results through $PAGER (or less) when writing to a terminal",
+		},
+		&cli.StringFlag{
+			Name:  "uploader",
+			Usage: "only search the uploads of this Nyaa/SuKeBe user; the keyword becomes optional",
+		},
+		&cli.BoolFlag{
+			Name:  "tor",
+			Usage: "send every request through the local Tor SOCKS proxy (127.0.0.1:9050); circuits rotate only per site, through the stream isolation of --tor-isolate",
+		},
+		&cli.BoolFlag{
+			Name:  "tor-new-circuit",
+			Usage: "with --tor, ask Tor for a new circuit before searching (needs the Tor control port)",
+		},
+		&cli.BoolFlag{
+			Name:  "tor-isolate",
+			Usage: "with --tor, rotate circuits per site: each site gets its own SOCKS username, which Tor isolates on a separate circuit and so a different exit (socks5 proxies only)",
+		},
+		&cli.BoolFlag{
+			Name:  "fresh-check",
+			Usage: "probe every site now instead of reusing availability checks from the last minute",
+		},
+		&cli.BoolFlag{
+			Name:  "stream-parse",
+			Usage: "scan search pages with a streaming tokenizer instead of building the whole DOM (lower memory, ignores list_selector overrides)",
+		},
+		&cli.BoolFlag{
+			Name:  "summary",
+			Usage: "print how many duplicate results were merged across sites and which requests were retried",
+		},
+		&cli.BoolFlag{
+			Name:  "live",
+			Usage: "print each site's results as soon as it finishes (terminal only)",
+		},
+		&cli.BoolFlag{
+			Name:  "group-by-size",
+			Usage: "group results into size buckets (size_buckets in the config, default <500MiB, 500MiB–2GiB, >2GiB)",
+		},
+		&cli.BoolFlag{
+			Name:  "freshness",
+			Usage: "add a freshness column estimated from upload date and seeders",
+		},
+		&cli.BoolFlag{
+			Name:  "verify-metadata",
+			Usage: "fetch torrent metadata over the DHT and flag results whose name does not match (slow)",
+		},
+		&cli.StringFlag{
+			Name:  "save",
+			Usage: "also save the results as JSON to `FILE` (for a later --diff)",
+		},
+		&cli.StringFlag{
+			Name:  "diff",
+			Usage: "show only results added since a run saved with --save to `FILE`",
+		},
+		&cli.StringFlag{
+			Name:  "html",
+			Usage: "also write the results as an HTML report with site favicons to `FILE`",
+		},
+	}
+}
+
+func replayCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "replay",
+		Usage:     "re-run a recent search with the same keyword and options",
+		ArgsUsage: "[N]",
+		Description: "Re-runs the most recent search, or the Nth most recent one, " +
+			"exactly as it was invoked.",
+		Flags: []cli.Flag{
+			&cli.DurationFlag{
+				Name:  "watch",
+				Usage: "repeat the replay every `INTERVAL` until interrupted",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			n := 1
+			if c.NArg() > 0 {
+				var err error
+				if n, err = strconv.Atoi(c.Args().First()); err != nil {
+					return fmt.Errorf("N must be a number: %v", err)
+				}
+			}
+			entry, err := common.RecentSearch(n)
+			if err != nil {
+				fmt.Printf("[!] %v\n", err)
+				return nil
+			}
+			replaying = true
+			args := append([]string{os.Args[0]}, entry.Args...)
+			for {
+				fmt.Printf("[*] Replaying: tspider %s\n", strings.Join(entry.Args, " "))
+				if err := newApp().RunContext(c.Context, args); err != nil {
+					return err
+				}
+				interval := c.Duration("watch")
+				if interval <= 0 {
+					return nil
+				}
+				select {
+				case <-time.After(interval):
+				case <-c.Context.Done():
+					return stopError(c.Context)
+				}
+			}
+		},
+	}
+}
+
+func suggestCommand() *cli.Command {
 	return &cli.Command{
-		Name:    "doctor",
-		Aliases: []string{"d"},
-		Usage:   "check availability of all torrent sites",
+		Name:      "suggest",
+		Usage:     "list the titles sites suggest for the start of a keyword, on the sites that offer suggestions",
+		ArgsUsage: "<prefix>",
 		Flags: []cli.Flag{
 			&cli.StringFlag{
 				Name:    "lang",
 				Aliases: []string{"l"},
-				Usage:   "check only sites for language: kr or jp",
+				Usage:   "ask the sites for language: kr, jp or en (default jp)",
+			},
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "print the suggestions of each site as JSON",
 			},
 		},
 		Action: func(c *cli.Context) error {
-			fmt.Println("[*] Checking torrent site availability...")
-			statuses := common.Doctor(c.String("lang"))
-			common.PrintDoctorStatus(statuses)
+			prefix := strings.TrimSpace(strings.Join(c.Args().Slice(), " "))
+			if prefix == "" {
+				return fmt.Errorf("please provide the start of a keyword")
+			}
+			lang := c.String("lang")
+			if lang == "" {
+				lang = "jp"
+			}
+			if !common.ValidLanguage(lang) {
+				return fmt.Errorf("unknown language %q (want %s)", lang, common.LanguageList())
+			}
+			found := common.Suggest(c.Context, langSites(lang, "", false, nil), prefix)
+			if len(found) == 0 {
+				return fmt.Errorf("no %s site offers search suggestions", lang)
+			}
+			if c.Bool("json") {
+				return printJSON(found)
+			}
+			// The same title suggested by several sites is printed once
+			seen := map[string]bool{}
+			for _, s := range found {
+				if s.Error != "" {
+					fmt.Fprintf(os.Stderr, "[!] %s: %s\n", s.Site, s.Error)
+					continue
+				}
+				for _, suggestion := range s.Suggestions {
+					if !seen[suggestion] {
+						seen[suggestion] = true
+						fmt.Println(suggestion)
+					}
+				}
+			}
+			if len(seen) == 0 {
+				fmt.Fprintf(os.Stderr, "[*] No suggestions for %q\n", prefix)
+			}
 			return nil
 		},
 	}
 }
 
-func configCommand() *cli.Command {
+func historyCommand() *cli.Command {
 	return &cli.Command{
-		Name:    "config",
-		Aliases: []string{"c"},
-		Usage:   "manage site configuration",
+		Name:  "history",
+		Usage: "revisit past searches and their results without searching again",
 		Subcommands: []*cli.Command{
 			{
 				Name:  "list",
-				Usage: "list all configured sites",
-				Action: func(c *cli.Context) error {
-					common.ListSites()
-					return nil
+				Usage: "list recorded searches, most recent first",
+				Flags: []cli.Flag{
+					&cli.IntFlag{
+						Name:  "limit",
+						Value: 20,
+						Usage: "show at most `N` searches (0 for all)",
+					},
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "print the searches as JSON",
+					},
 				},
-			},
-			{
-				Name:      "set-url",
-				Usage:     "update a site's URL",
-				ArgsUsage: "<site> <new-url>",
 				Action: func(c *cli.Context) error {
-					if c.NArg() < 2 {
-						return fmt.Errorf("usage: angel config set-url <site> <new-url>")
-					}
-					site := c.Args().Get(0)
-					url := c.Args().Get(1)
-					if err := common.SetSiteURL(site, url); err != nil {
+					entries, err := common.LoadHistory()
+					if err != nil {
 						return err
 					}
-					fmt.Printf("[+] Updated %s URL to: %s\n", site, url)
+					if n := c.Int("limit"); n > 0 && len(entries) > n {
+						entries = entries[len(entries)-n:]
+					}
+					// Most recent first
+					for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+						entries[i], entries[j] = entries[j], entries[i]
+					}
+					if c.Bool("json") {
+						if entries == nil {
+							entries = []common.HistoryEntry{}
+						}
+						return printJSON(entries)
+					}
+					if len(entries) == 0 {
+						fmt.Println("[*] No search history yet")
+						return nil
+					}
+					common.PrintHistory(os.Stdout, entries)
 					return nil
 				},
 			},
 			{
-				Name:      "add",
-				Usage:     "add a new site",
-				ArgsUsage: "<name> <url> <language>",
+				Name:      "show",
+				Usage:     "print the results a recorded search found",
+				ArgsUsage: "<id>",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "print the results as JSON",
+					},
+					&cli.BoolFlag{
+						Name:  "magnets",
+						Usage: "print only the magnet links, one per line",
+					},
+				},
 				Action: func(c *cli.Context) error {
-					if c.NArg() < 3 {
-						return fmt.Errorf("usage: angel config add <name> <url> <language>\n  language: kr or jp")
+					if c.NArg() == 0 {
+						return fmt.Errorf("please provide a search id; see 'tspider history list'")
 					}
-					name := c.Args().Get(0)
-					url := c.Args().Get(1)
-					lang := c.Args().Get(2)
-					if lang != "kr" && lang != "jp" {
-						return fmt.Errorf("language must be 'kr' or 'jp'")
+					id, err := strconv.Atoi(strings.TrimPrefix(c.Args().First(), "#"))
+					if err != nil {
+						return fmt.Errorf("id must be a number: %v", err)
 					}
-					if err := common.AddSite(name, url, lang); err != nil {
+					entry, err := common.HistoryByID(id)
+					if err != nil {
 						return err
 					}
-					fmt.Printf("[+] Added site: %s (%s)\n", name, url)
+					results, err := common.LoadHistoryResults(id)
+					if err != nil {
+						return err
+					}
+					switch {
+					case c.Bool("json"):
+						return printJSON(results)
+					case c.Bool("magnets"):
+						for _, r := range results {
+							fmt.Println(r.Magnet)
+						}
+						return nil
+					}
+					lang := entry.Lang
+					if lang == "" {
+						lang = "jp"
+					}
+					fmt.Printf("[*] #%d %q on %s sites, %s: %d result(s)\n", entry.ID, entry.Keyword, lang, entry.Time.Format("2006-01-02 15:04"), len(results))
+					if lang == "kr" {
+						common.PrintData(os.Stdout, results)
+					} else {
+						common.PrintDataEx(os.Stdout, results)
+					}
 					return nil
 				},
 			},
+		},
+	}
+}
+
+func favCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "fav",
+		Usage: "bookmark results to get their magnets later without searching again",
+		Subcommands: []*cli.Command{
 			{
-				Name:      "remove",
-				Usage:     "remove a site",
-				ArgsUsage: "<site>",
+				Name:      "add",
+				Usage:     "bookmark the Nth result of the last search, or a result by info hash",
+				ArgsUsage: "<N|infohash> [N|infohash...]",
 				Action: func(c *cli.Context) error {
 					if c.NArg() == 0 {
-						return fmt.Errorf("please provide a site name")
+						return fmt.Errorf("please provide a result number or an info hash")
 					}
-					name := c.Args().First()
-					if err := common.RemoveSite(name); err != nil {
-						return err
+					for _, ref := range c.Args().Slice() {
+						fav, err := common.ResolveFavorite(ref)
+						if err != nil {
+							return err
+						}
+						added, err := common.AddFavorite(fav)
+						if err != nil {
+							return err
+						}
+						if added {
+							fmt.Printf("[+] Added favorite: %s\n", fav.Title)
+						} else {
+							fmt.Printf("[*] Already a favorite: %s\n", fav.Title)
+						}
 					}
-					fmt.Printf("[+] Removed site: %s\n", name)
 					return nil
 				},
 			},
 			{
-				Name:      "enable",
-				Usage:     "enable a site",
-				ArgsUsage: "<site>",
+				Name:  "list",
+				Usage: "list favorites",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "print the favorites as JSON",
+					},
+					&cli.BoolFlag{
+						Name:  "magnets",
+						Usage: "print only the magnet links, one per line",
+					},
+				},
 				Action: func(c *cli.Context) error {
-					if c.NArg() == 0 {
-						return fmt.Errorf("please provide a site name")
-					}
-					if err := common.EnableSite(c.Args().First(), true); err != nil {
+					favs, err := common.LoadFavorites()
+					if err != nil {
 						return err
 					}
-					fmt.Printf("[+] Enabled: %s\n", c.Args().First())
+					switch {
+					case c.Bool("json"):
+						if favs == nil {
+							favs = []common.Favorite{}
+						}
+						return printJSON(favs)
+					case c.Bool("magnets"):
+						for _, f := range favs {
+							fmt.Println(f.Magnet)
+						}
+						return nil
+					case len(favs) == 0:
+						fmt.Println("[*] No favorites yet; add one with 'tspider fav add N' after a search")
+						return nil
+					}
+					common.PrintFavorites(os.Stdout, favs)
 					return nil
 				},
 			},
 			{
-				Name:      "disable",
-				Usage:     "disable a site",
-				ArgsUsage: "<site>",
+				Name:      "remove",
+				Usage:     "remove the Nth favorite, as listed, or a favorite by info hash",
+				ArgsUsage: "<N|infohash>",
 				Action: func(c *cli.Context) error {
 					if c.NArg() == 0 {
-						return fmt.Errorf("please provide a site name")
+						return fmt.Errorf("please provide a favorite number or an info hash")
 					}
-					if err := common.EnableSite(c.Args().First(), false); err != nil {
+					fav, err := common.RemoveFavorite(c.Args().First())
+					if err != nil {
 						return err
 					}
-					fmt.Printf("[+] Disabled: %s\n", c.Args().First())
+					fmt.Printf("[+] Removed favorite: %s\n", fav.Title)
 					return nil
 				},
 			},
-			{
-				Name:  "path",
-				Usage: "show config file path",
-				Action: func(c *cli.Context) error {
-					fmt.Println(common.GetConfigPath())
-					return nil
-				},
+		},
+	}
+}
+
+func doctorCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "doctor",
+		Aliases:   []string{"d"},
+		Usage:     "check availability of all torrent sites, or diagnose one in detail",
+		ArgsUsage: "[site]",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "lang",
+				Aliases: []string{"l"},
+				Usage:   "check only sites for language: kr, jp or en",
+			},
+			&cli.BoolFlag{
+				Name:  "deep",
+				Usage: "also search each reachable site for its health keyword and flag sites without results as DEGRADED",
+			},
+			&cli.BoolFlag{
+				Name:  "self",
+				Usage: "check the tspider installation (config file, sites, environment) instead of the sites",
+			},
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "print the report as JSON",
+			},
+			&cli.BoolFlag{
+				Name:    "quiet",
+				Aliases: []string{"q"},
+				Usage:   "do not print the recommended mirror per language",
+			},
+			&cli.BoolFlag{
+				Name:  "discover",
+				Usage: "look for the new address of each site that is down, on its hubs and nearby numbered domains (torrenttop153.com for torrenttop152.com)",
 			},
+			&cli.BoolFlag{
+				Name:  "apply",
+				Usage: "with --discover, make the addresses found the URLs of their sites",
+			},
+			&cli.BoolFlag{
+				Name:  "fix",
+				Usage: "make the address each site redirects to its URL, after confirmation",
+			},
+			&cli.BoolFlag{
+				Name:    "yes",
+				Aliases: []string{"y"},
+				Usage:   "with --fix, update the config without asking",
+			},
+			&cli.BoolFlag{
+				Name:  "watch",
+				Usage: "check the sites again every --interval until interrupted, redrawing the table",
+			},
+			&cli.DurationFlag{
+				Name:  "interval",
+				Value: 5 * time.Minute,
+				Usage: "with --watch, time between checks",
+			},
+			&cli.StringFlag{
+				Name:  "log",
+				Usage: "append each check to `FILE`, one JSON record per site and check",
+			},
+			&cli.BoolFlag{
+				Name:  "history",
+				Usage: "show the latency trends of the site, or of every site, recorded by earlier checks",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if c.Bool("apply") && !c.Bool("discover") {
+				return fmt.Errorf("--apply needs --discover")
+			}
+			if c.Bool("fix") && c.Bool("json") && !c.Bool("yes") {
+				return fmt.Errorf("--fix with --json needs --yes, as it cannot ask")
+			}
+			if c.IsSet("interval") && !c.Bool("watch") {
+				return fmt.Errorf("--interval needs --watch")
+			}
+			if c.Bool("history") {
+				for _, flag := range []string{"deep", "self", "discover", "fix", "watch"} {
+					if c.Bool(flag) {
+						return fmt.Errorf("--%s cannot be combined with --history", flag)
+					}
+				}
+				return latencyHistory(c, c.Args().First())
+			}
+			if c.Bool("watch") {
+				for _, flag := range []string{"self", "json", "discover", "fix"} {
+					if c.Bool(flag) {
+						return fmt.Errorf("--%s cannot be combined with --watch", flag)
+					}
+				}
+				if c.NArg() > 0 {
+					return fmt.Errorf("--watch checks every site; leave out the site name")
+				}
+				return watchDoctor(c)
+			}
+			if c.NArg() > 0 {
+				for _, flag := range []string{"deep", "self", "discover", "fix"} {
+					if c.Bool(flag) {
+						return fmt.Errorf("--%s checks every site; leave out the site name", flag)
+					}
+				}
+				return diagnoseSite(c, c.Args().First())
+			}
+			if c.Bool("self") {
+				report := common.SelfCheck(implementedSites())
+				if c.Bool("json") {
+					return printJSON(report)
+				}
+				common.PrintSelfReport(os.Stdout, report)
+				return nil
+			}
+			if !c.Bool("json") {
+				fmt.Println("[*] Checking torrent site availability...")
+			}
+			statuses, err := checkSites(c)
+			if err != nil {
+				return err
+			}
+			var found []common.Discovery
+			if c.Bool("discover") {
+				var down []string
+				for _, s := range statuses {
+					if !s.Available {
+						down = append(down, s.Name)
+					}
+				}
+				found = common.DiscoverMirrors(c.Context, down)
+				if err := deadlineError(c.Context); err != nil {
+					return err
+				}
+				if c.Bool("apply") {
+					if err := common.ApplyDiscoveries(found); err != nil {
+						return err
+					}
+				}
+			}
+			langs := common.Languages
+			if lang := c.String("lang"); lang != "" {
+				langs = []string{lang}
+			}
+			if c.Bool("json") {
+				if c.Bool("fix") {
+					if err := common.ApplyDiscoveries(common.Redirects(statuses)); err != nil {
+						return err
+					}
+				}
+				var err error
+				if c.Bool("discover") {
+					err = printJSON(map[string]any{"sites": statuses, "discoveries": found})
+				} else {
+					err = printJSON(statuses)
+				}
+				if err != nil {
+					return err
+				}
+				return sitesDownError(statuses, langs)
+			}
+			common.PrintDoctorStatus(os.Stdout, statuses)
+			if c.Bool("discover") {
+				fmt.Println()
+				common.PrintDiscoveries(os.Stdout, found, c.Bool("apply"))
+			}
+			if c.Bool("fix") {
+				if err := fixRedirects(statuses, c.Bool("yes")); err != nil {
+					return err
+				}
+			}
+			if !c.Bool("quiet") {
+				fmt.Println()
+				common.PrintRecommendations(os.Stdout, implementedStatuses(statuses), langs)
+			}
+			return sitesDownError(statuses, langs)
 		},
 	}
 }
 
-func doSearch(c *cli.Context) error {
-	keyword := c.Args().First()
-	if keyword == "" {
-		return fmt.Errorf("please provide a search keyword")
+// diagnoseSite prints the detailed check of the site called name, exiting
+// with exitSitesDown when it is down
+func diagnoseSite(c *cli.Context, name string) error {
+	if !c.Bool("json") {
+		fmt.Printf("[*] Checking %s...\n", name)
+	}
+	d, err := common.DiagnoseSite(c.Context, name)
+	if err != nil {
+		return err
+	}
+	if err := deadlineError(c.Context); err != nil {
+		return err
+	}
+	if c.Bool("json") {
+		if err := printJSON(d); err != nil {
+			return err
+		}
+	} else {
+		common.PrintDiagnosis(os.Stdout, d)
 	}
+	if !d.Available {
+		return cli.Exit(fmt.Sprintf("%s is down", name), exitSitesDown)
+	}
+	return nil
+}
 
-	lang := c.String("lang")
+// sitesDownError returns an exit error carrying exitSitesDown when every
+// enabled site of one of langs is down, for monitoring scripts
+func sitesDownError(statuses []common.SiteStatus, langs []string) error {
+	down := common.DownLanguages(implementedStatuses(statuses), langs)
+	if len(down) == 0 {
+		return nil
+	}
+	return cli.Exit(fmt.Sprintf("all enabled %s sites are down", strings.ToUpper(strings.Join(down, ", "))), exitSitesDown)
+}
+
+// checkSites checks the sites for doctor, deeply with --deep, records their
+// latencies in the latency history and appends the check to the --log file
+func checkSites(c *cli.Context) ([]common.SiteStatus, error) {
+	var statuses []common.SiteStatus
+	if c.Bool("deep") {
+		statuses = common.DeepDoctor(c.Context, c.String("lang"), allSites())
+	} else {
+		statuses = common.Doctor(c.Context, c.String("lang"))
+	}
+	if err := deadlineError(c.Context); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if err := common.UpdateLatencyHistory(func(h *common.LatencyHistory) error {
+		h.Record(now, statuses)
+		return nil
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "[!] failed to save latency history: %v\n", err)
+	}
+	if path := c.String("log"); path != "" {
+		if err := common.AppendAvailabilityHistory(path, now, statuses); err != nil {
+			return nil, fmt.Errorf("log: %w", err)
+		}
+	}
+	return statuses, nil
+}
 
-	if lang == "kr" {
-		sites := []common.Scraping{
-			&ktorrent.TorrentTop{},
+// latencyHistory prints the latency trends of the site called name, or of
+// every site when name is ""
+func latencyHistory(c *cli.Context, name string) error {
+	if _, ok := common.GetConfig().Sites[name]; name != "" && !ok {
+		return fmt.Errorf("site '%s' not found", name)
+	}
+	history, err := common.LoadLatencyHistory()
+	if err != nil {
+		return err
+	}
+	trends := history.Trends(name)
+	if c.Bool("json") {
+		if trends == nil {
+			trends = []common.LatencyTrend{}
 		}
-		sites, spinner := common.GetAvailableSites(sites)
-		if len(sites) == 0 {
-			spinner.Stop()
-			fmt.Println("[!] No available sites. Use 'angel doctor' to check status.")
-			return nil
+		return printJSON(trends)
+	}
+	common.PrintLatencyTrends(os.Stdout, trends)
+	return nil
+}
+
+// watchDoctor checks the sites every --interval until interrupted. On a
+// terminal each check replaces the table of the last; otherwise the tables
+// follow each other.
+func watchDoctor(c *cli.Context) error {
+	interval := c.Duration("interval")
+	if interval < 10*time.Second {
+		return fmt.Errorf("--interval must be at least 10s")
+	}
+	langs := common.Languages
+	if lang := c.String("lang"); lang != "" {
+		langs = []string{lang}
+	}
+	live := common.IsTerminal(os.Stdout)
+	for {
+		if !live {
+			fmt.Println("[*] Checking torrent site availability...")
 		}
-		data := common.CollectData(sites, keyword, spinner)
-		spinner.StopWithMessage(fmt.Sprintf("Found %d result(s) from %d site(s)", len(data), len(sites)))
-		common.PrintData(data)
-	} else {
-		sites := []common.ScrapingEx{
-			&jtorrent.Nyaa{},
-			&jtorrent.SuKeBe{},
-		}
-		sites, spinner := common.GetAvailableSitesEx(sites)
-		if len(sites) == 0 {
-			spinner.Stop()
-			fmt.Println("[!] No available sites. Use 'angel doctor' to check status.")
+		statuses, err := checkSites(c)
+		if c.Context.Err() == context.Canceled {
 			return nil
 		}
-		data := common.CollectDataEx(sites, keyword, spinner)
-		spinner.StopWithMessage(fmt.Sprintf("Found %d result(s) from %d site(s)", len(data), len(sites)))
-		common.PrintDataEx(data)
+		if err != nil {
+			return err
+		}
+		if live {
+			// Move home and clear the screen
+			fmt.Print("\033[H\033[2J")
+		}
+		fmt.Printf("[*] Checked at %s, next check in %s (Ctrl-C to stop)\n", time.Now().Format("15:04:05"), interval)
+		common.PrintDoctorStatus(os.Stdout, statuses)
+		if !c.Bool("quiet") {
+			fmt.Println()
+			common.PrintRecommendations(os.Stdout, implementedStatuses(statuses), langs)
+		}
+		select {
+		case <-time.After(interval):
+		case <-c.Context.Done():
+			return stopError(c.Context)
+		}
+	}
+}
+
+// fixRedirects makes the address each site of statuses redirects to its URL,
+// asking first unless yes is set
+func fixRedirects(statuses []common.SiteStatus, yes bool) error {
+	moved := common.Redirects(statuses)
+	fmt.Println()
+	if len(moved) == 0 {
+		fmt.Println("[+] No site redirects to a new address")
+		return nil
+	}
+	for _, d := range moved {
+		fmt.Printf("  %s: %s -> %s\n", d.Site, d.Current, d.Found)
+	}
+	if !yes && !confirm(fmt.Sprintf("Update the URL of %d site(s)?", len(moved)), false) {
+		fmt.Println("[*] Nothing changed")
+		return nil
+	}
+	if err := common.ApplyDiscoveries(moved); err != nil {
+		return err
+	}
+	fmt.Printf("[+] Updated %s\n", common.GetConfigPath())
+	return nil
+}
+
+// versionInfo describes this build for tooling
+type versionInfo struct {
+	Version   string `json:"version"`
+	GoVersion string `json:"go_version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+}
+
+// buildVersionInfo fills commit and build date from the ldflags, then from
+// the VCS stamp Go embeds in module builds, and reports "unknown" otherwise
+func buildVersionInfo() versionInfo {
+	v := versionInfo{
+		Version:   version,
+		GoVersion: runtime.Version(),
+		Commit:    commit,
+		BuildDate: buildDate,
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			switch {
+			case s.Key == "vcs.revision" && v.Commit == "":
+				v.Commit = s.Value
+			case s.Key == "vcs.time" && v.BuildDate == "":
+				v.BuildDate = s.Value
+			}
+		}
+	}
+	if v.Commit == "" {
+		v.Commit = "unknown"
+	}
+	if v.BuildDate == "" {
+		v.BuildDate = "unknown"
+	}
+	return v
+}
+
+func sendCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "send",
+		Usage:     "send magnets to a torrent client, from the arguments or one per line on stdin",
+		ArgsUsage: "[magnet...]",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "client",
+				Value: "qbittorrent",
+				Usage: "torrent client to send to: qbittorrent, transmission or aria2",
+			},
+			&cli.StringFlag{
+				Name:  "category",
+				Usage: "file the torrents under this client category (a label in Transmission; not supported by aria2)",
+			},
+			&cli.StringFlag{
+				Name:  "save-path",
+				Usage: "download the torrents to this directory instead of the client's default",
+			},
+			&cli.StringFlag{
+				Name:  "select",
+				Usage: "send only the magnets at these positions of the list, such as 1,3,5-7",
+			},
+			&cli.BoolFlag{
+				Name:  "test",
+				Usage: "only check that the client is reachable and accepts the configured credentials",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			name := c.String("client")
+			client, err := clients.New(name)
+			if err != nil {
+				return err
+			}
+			if c.Bool("test") {
+				if err := client.TestConnection(); err != nil {
+					return err
+				}
+				fmt.Printf("[+] Connected to %s\n", name)
+				return nil
+			}
+			magnets, err := magnetsToSend(c)
+			if err != nil {
+				return err
+			}
+			if c.IsSet("select") {
+				rows, err := common.ParseSelection(c.String("select"), len(magnets))
+				if err != nil {
+					return err
+				}
+				selected := make([]string, len(rows))
+				for i, row := range rows {
+					selected[i] = magnets[row]
+				}
+				magnets = selected
+			}
+			opts := clients.AddOptions{Category: c.String("category"), SavePath: c.String("save-path")}
+			failed := 0
+			err = clients.AddEach(client, magnets, opts, func(i int, err error) {
+				if err != nil {
+					failed++
+					fmt.Printf("[!] %s: %v\n", magnetLabel(magnets[i]), err)
+					return
+				}
+				fmt.Printf("[+] %s\n", magnetLabel(magnets[i]))
+			})
+			if err != nil {
+				return err
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d of %d magnet(s) not sent to %s", failed, len(magnets), name)
+			}
+			fmt.Printf("[+] Sent %d magnet(s) to %s\n", len(magnets), name)
+			return nil
+		},
+	}
+}
+
+func serveCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "serve",
+		Usage: "serve a search web UI, and searches, site checks and the site list as a JSON API, over HTTP",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "addr",
+				Value: "127.0.0.1:8118",
+				Usage: "address to listen on; use :8118 to accept other machines",
+			},
+			&cli.BoolFlag{
+				Name:  "torznab",
+				Usage: "also serve a Torznab indexer at /torznab/api for Sonarr, Radarr and the like",
+			},
+			&cli.StringFlag{
+				Name:  "api-key",
+				Usage: "API key clients must send; none when empty",
+			},
+			&cli.StringFlag{
+				Name:    "lang",
+				Aliases: []string{"l"},
+				Value:   "jp",
+				Usage:   "sites searched when a request does not choose (kr, jp or en)",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			lang := c.String("lang")
+			if !common.ValidLanguage(lang) {
+				return fmt.Errorf("language must be %s", common.LanguageList())
+			}
+			// Progress has no terminal to draw on
+			common.SpinnerOutput = io.Discard
+			api := &server.API{Search: searchSites, Stream: streamSites, Doctor: doctorSites, APIKey: c.String("api-key"), Lang: lang}
+			mux := http.NewServeMux()
+			mux.Handle("/api/", api.Handler())
+			mux.Handle("/", server.WebHandler())
+			mux.Handle("/metrics", server.MetricsHandler())
+			fmt.Fprintf(os.Stderr, "[*] Web UI at http://%s/, JSON API at /api/ (search, doctor, sites), Prometheus metrics at /metrics\n", c.String("addr"))
+			if c.Bool("torznab") {
+				mux.Handle("/torznab/api", &torznab.Handler{Search: searchSites, APIKey: c.String("api-key"), Lang: lang})
+				fmt.Fprintf(os.Stderr, "[*] Torznab indexer at http://%s/torznab (API path /api)\n", c.String("addr"))
+			}
+			srv := &http.Server{Addr: c.String("addr"), Handler: mux}
+			go func() {
+				<-c.Context.Done()
+				shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				srv.Shutdown(shutdown)
+			}()
+			if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+				return err
+			}
+			return stopError(c.Context)
+		},
+	}
+}
+
+func watchCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "watch",
+		Usage:     "search repeatedly and report only torrents not seen before",
+		ArgsUsage: "<keyword>",
+		Description: "Searches every --interval until interrupted and prints the results found for the " +
+			"first time. The first watch of a keyword records what is already listed without " +
+			"reporting it; seen results are remembered across runs, next to the config file.",
+		Flags: []cli.Flag{
+			&cli.DurationFlag{
+				Name:  "interval",
+				Value: 30 * time.Minute,
+				Usage: "time between searches",
+			},
+			&cli.StringFlag{
+				Name:    "lang",
+				Aliases: []string{"l"},
+				Value:   "jp",
+				Usage:   "choose torrent sites (kr, jp or en)",
+			},
+			&cli.BoolFlag{
+				Name:  "reset",
+				Usage: "forget the results seen for this keyword first",
+			},
+			&cli.BoolFlag{
+				Name:  "notify",
+				Usage: "show a desktop notification for new results, besides the configured notifiers",
+			},
+			&cli.BoolFlag{
+				Name:  "copy-on-click",
+				Usage: "with --notify, copy the magnets when the notification is clicked",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if c.NArg() == 0 {
+				return fmt.Errorf("please provide a keyword")
+			}
+			keyword := strings.Join(c.Args().Slice(), " ")
+			lang := c.String("lang")
+			if !common.ValidLanguage(lang) {
+				return fmt.Errorf("language must be %s", common.LanguageList())
+			}
+			interval := c.Duration("interval")
+			if interval < time.Minute {
+				return fmt.Errorf("--interval must be at least 1m")
+			}
+			store, err := common.LoadSeen()
+			if err != nil {
+				return err
+			}
+			key := watchKey(keyword, lang)
+			if c.Bool("reset") {
+				store.Forget(key)
+			}
+			all, err := notifiers.Configured()
+			if err != nil {
+				return err
+			}
+			if c.Bool("notify") || c.Bool("copy-on-click") {
+				all["--notify"] = notifiers.NewDesktop(common.NotifierConfig{CopyOnClick: c.Bool("copy-on-click")})
+			}
+			common.SpinnerOutput = io.Discard
+			fmt.Printf("[*] Watching %q on %s sites every %s\n", keyword, lang, interval)
+			for {
+				added := reportNew(c.Context, store, key, keyword, keyword, lang)
+				notifyNew(all, keyword, keyword, lang, added)
+				sendDueDigests(all)
+				select {
+				case <-time.After(interval):
+				case <-c.Context.Done():
+					return stopError(c.Context)
+				}
+			}
+		},
+	}
+}
+
+// watchKey is the seen-results key of a watched search; unlike schedule
+// names it cannot be chosen, so it is made of the search itself
+func watchKey(keyword, lang string) string {
+	return "watch:" + lang + ":" + strings.ToLower(strings.TrimSpace(keyword))
+}
+
+func daemonCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "daemon",
+		Usage: "run the configured schedules and report the results they have not found before",
+		Description: "Runs each search of 'tspider config schedule' whenever its cron schedule fires " +
+			"and prints the results it finds for the first time. The first run of a schedule " +
+			"records what is already listed without reporting it. Seen results are kept next to the config file.",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "once",
+				Usage: "run every schedule now and exit",
+			},
+			&cli.BoolFlag{
+				Name:  "test-notify",
+				Usage: "send a notification without results to each notifier and exit",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			all, err := notifiers.Configured()
+			if err != nil {
+				return err
+			}
+			if c.Bool("test-notify") {
+				if len(all) == 0 {
+					return fmt.Errorf("no notifiers configured")
+				}
+				n := notifiers.Notification{Search: "test", Keyword: "test", Lang: "jp", Time: time.Now(), Results: []common.SearchResult{}}
+				if err := notifiers.NotifyAll(all, n); err != nil {
+					return err
+				}
+				// Digests would only mail the test later
+				if err := notifiers.FlushDigests(all, n.Time); err != nil {
+					return err
+				}
+				fmt.Printf("[+] Notified %d notifier(s)\n", len(all))
+				return nil
+			}
+			schedules := common.GetConfig().Schedules
+			names := common.ScheduleNames()
+			if len(names) == 0 {
+				return fmt.Errorf("no schedules configured; add one with 'tspider config schedule add'")
+			}
+			crons := make(map[string]*common.Cron, len(names))
+			for _, name := range names {
+				cron, err := common.ParseCron(schedules[name].Cron)
+				if err != nil {
+					return fmt.Errorf("schedule %s: %w", name, err)
+				}
+				crons[name] = cron
+			}
+			store, err := common.LoadSeen()
+			if err != nil {
+				return err
+			}
+			common.SpinnerOutput = io.Discard
+			if c.Bool("once") {
+				for _, name := range names {
+					runSchedule(c.Context, store, all, name, schedules[name])
+				}
+				sendDueDigests(all)
+				return nil
+			}
+
+			next := make(map[string]time.Time, len(names))
+			now := time.Now()
+			for _, name := range names {
+				if t := crons[name].Next(now); !t.IsZero() {
+					next[name] = t
+					fmt.Printf("%s [*] %s: next run %s\n", daemonTime(now), name, daemonTime(t))
+				} else {
+					fmt.Printf("%s [!] %s: %q never fires\n", daemonTime(now), name, schedules[name].Cron)
+				}
+			}
+			for len(next) > 0 {
+				due := notifiers.NextDigest(all)
+				for _, t := range next {
+					if due.IsZero() || t.Before(due) {
+						due = t
+					}
+				}
+				select {
+				case <-time.After(time.Until(due)):
+				case <-c.Context.Done():
+					return stopError(c.Context)
+				}
+				for _, name := range names {
+					if t, ok := next[name]; ok && !t.After(due) {
+						runSchedule(c.Context, store, all, name, schedules[name])
+						next[name] = crons[name].Next(time.Now())
+					}
+				}
+				sendDueDigests(all)
+			}
+			return nil
+		},
+	}
+}
+
+// runSchedule runs one search of the daemon, prints the results store has
+// not seen for it and passes them to the notifiers
+func runSchedule(ctx context.Context, store *common.SeenStore, all map[string]notifiers.Notifier, name string, s common.Schedule) {
+	added := reportNew(ctx, store, name, name, s.Keyword, s.Language())
+	notifyNew(all, name, s.Keyword, s.Language(), added)
+}
+
+// reportNew searches lang for keyword and prints, under label, the results
+// store has not seen for the search key, which it returns. The first run of
+// a key records the results as a baseline without printing or returning
+// them. Failures are printed rather than returned so that a repeated search
+// goes on with its next run.
+func reportNew(ctx context.Context, store *common.SeenStore, key, label, keyword, lang string) []common.SearchResult {
+	// Log in again on each run, as a session may expire between runs
+	common.ResetLogins()
+	results, err := crawlSites(ctx, keyword, lang, false, nil)
+	now := time.Now()
+	if err != nil {
+		fmt.Printf("%s [!] %s: %v\n", daemonTime(now), label, err)
+		return nil
+	}
+	if ctx.Err() != nil {
+		// An interrupted search is partial; its missing results would be
+		// reported as new by the next run
+		return nil
+	}
+	baseline := !store.Known(key)
+	added := store.Add(key, results, now)
+	if err := store.Save(); err != nil {
+		fmt.Printf("%s [!] %s: %v\n", daemonTime(now), label, err)
+	}
+	switch {
+	case baseline:
+		fmt.Printf("%s [*] %s: recorded %d existing result(s)\n", daemonTime(now), label, len(added))
+		return nil
+	case len(added) == 0:
+		fmt.Printf("%s [*] %s: nothing new\n", daemonTime(now), label)
+	}
+	for _, r := range added {
+		fmt.Printf("%s [+] %s: %s [%s]\n    %s\n", daemonTime(now), label, r.Title, r.Site, r.Magnet)
+	}
+	return added
+}
+
+// notifyNew passes the new results of a search to the notifiers, unless
+// there are none
+func notifyNew(all map[string]notifiers.Notifier, search, keyword, lang string, added []common.SearchResult) {
+	if len(added) == 0 || len(all) == 0 {
+		return
+	}
+	n := notifiers.Notification{Search: search, Keyword: keyword, Lang: lang, Time: time.Now(), Results: added}
+	if err := notifiers.NotifyAll(all, n); err != nil {
+		fmt.Printf("%s [!] %s: %v\n", daemonTime(n.Time), search, err)
+	}
+}
+
+// sendDueDigests sends the notifier digests that are due, printing failures
+func sendDueDigests(all map[string]notifiers.Notifier) {
+	now := time.Now()
+	if err := notifiers.SendDueDigests(all, now); err != nil {
+		fmt.Printf("%s [!] %v\n", daemonTime(now), err)
+	}
+}
+
+// daemonTime formats t for daemon output
+func daemonTime(t time.Time) string {
+	return t.Format("2006-01-02 15:04:05")
+}
+
+// doctorSites checks the sites of lang, all when it is empty, like doctor
+// and doctor --deep
+func doctorSites(ctx context.Context, lang string, deep bool) []common.SiteStatus {
+	if deep {
+		return common.DeepDoctor(ctx, lang, allSites())
+	}
+	return common.Doctor(ctx, lang)
+}
+
+// searchSites searches the available sites of lang for keyword, without
+// progress output or search flags, and drops the results the blocklist
+// excludes. Identical searches share the crawl cache with the command line.
+func searchSites(ctx context.Context, keyword, lang string) ([]common.SearchResult, error) {
+	return streamSites(ctx, keyword, lang, nil)
+}
+
+// streamSites searches like searchSites and, unless send is nil, passes it
+// the progress and the filtered results of each site as they arrive
+func streamSites(ctx context.Context, keyword, lang string, send func(server.Event)) ([]common.SearchResult, error) {
+	// The server runs for days; log in again on each search, as a session
+	// may expire between searches
+	common.ResetLogins()
+	return crawlSites(ctx, keyword, lang, true, send)
+}
+
+// crawlSites is streamSites, reusing a recent identical crawl only when
+// reuse is set. Repeated searches looking for new results, such as those of
+// watch and daemon, do not, or they would miss what was uploaded since.
+func crawlSites(ctx context.Context, keyword, lang string, reuse bool, send func(server.Event)) ([]common.SearchResult, error) {
+	if !common.ValidLanguage(lang) {
+		return nil, fmt.Errorf("unknown language %q (want %s)", lang, common.LanguageList())
+	}
+	excludes, err := common.CompilePatterns(common.GetConfig().Blocklist)
+	if err != nil {
+		return nil, err
+	}
+	keyword, negatives := common.ParseNegatives(common.TransformKeyword(keyword))
+	filter := func(results []common.SearchResult) []common.SearchResult {
+		return common.ExcludeResults(common.ExcludeTerms(results, negatives), excludes)
+	}
+	key := common.NewCrawlKey(keyword, lang, "", false)
+	if lang == "jp" {
+		key.Negatives = negatives
+	}
+	cache := common.Crawls()
+	if cache != nil && reuse {
+		if data, _, ok := cache.Get(key); ok {
+			fmt.Fprintf(os.Stderr, "[*] Reusing the results of an identical recent search for %q\n", keyword)
+			data = filter(data)
+			if send != nil {
+				sendBySite(data, send)
+			}
+			return data, nil
+		}
+	}
+	scrapers := langSites(lang, "", false, negatives)
+	sites, spinner := common.GetAvailableSites(ctx, scrapers)
+	if len(sites) == 0 {
+		spinner.Stop()
+		return nil, fmt.Errorf("no available sites")
+	}
+	if send != nil {
+		spinner.OnProgress(func(site string, done, total int) {
+			send(server.Event{Type: "progress", Site: site, Done: done, Total: total})
+		})
+		spinner.OnResults(func(site string, results []common.SearchResult) {
+			if results = filter(results); len(results) > 0 {
+				send(server.Event{Type: "results", Site: site, Results: results})
+			}
+		})
+	}
+	data, stats := common.CollectData(ctx, sites, keyword, spinner)
+	spinner.Stop()
+	if cache != nil && ctx.Err() == nil {
+		cache.Put(key, data, stats)
+	}
+	return filter(data), nil
+}
+
+// sendBySite replays results, such as cached ones, to send as one results
+// event per site
+func sendBySite(results []common.SearchResult, send func(server.Event)) {
+	var sites []string
+	bySite := map[string][]common.SearchResult{}
+	for _, r := range results {
+		if _, seen := bySite[r.Site]; !seen {
+			sites = append(sites, r.Site)
+		}
+		bySite[r.Site] = append(bySite[r.Site], r)
+	}
+	for i, site := range sites {
+		send(server.Event{Type: "progress", Site: site, Done: i + 1, Total: len(sites)})
+		send(server.Event{Type: "results", Site: site, Results: bySite[site]})
+	}
+}
+
+// magnetsToSend returns the magnets given as arguments or, without
+// arguments, read one per line from stdin, as printed by --magnets
+func magnetsToSend(c *cli.Context) ([]string, error) {
+	lines := c.Args().Slice()
+	if len(lines) == 0 {
+		sc := bufio.NewScanner(os.Stdin)
+		for sc.Scan() {
+			lines = append(lines, sc.Text())
+		}
+		if err := sc.Err(); err != nil {
+			return nil, err
+		}
+	}
+	var magnets []string
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+		case common.IsValidMagnet(line):
+			magnets = append(magnets, line)
+		default:
+			fmt.Fprintf(os.Stderr, "[!] skipping %q: not a magnet link\n", line)
+		}
+	}
+	if len(magnets) == 0 {
+		return nil, fmt.Errorf("no magnets to send")
+	}
+	return magnets, nil
+}
+
+// magnetLabel names a magnet in the summary of send: its display name, or
+// else its info hash
+func magnetLabel(magnet string) string {
+	if q, err := url.ParseQuery(strings.TrimPrefix(magnet, "magnet:?")); err == nil && q.Get("dn") != "" {
+		return q.Get("dn")
+	}
+	if h := common.InfoHash(magnet); h != "" {
+		return h
+	}
+	return magnet
+}
+
+func versionCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "version",
+		Usage: "show version and build information",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "print as JSON",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			v := buildVersionInfo()
+			if c.Bool("json") {
+				return printJSON(v)
+			}
+			fmt.Printf("tspider %s (commit %s, built %s, %s, %s/%s)\n",
+				v.Version, v.Commit, v.BuildDate, v.GoVersion, v.OS, v.Arch)
+			return nil
+		},
+	}
+}
+
+func configCommand() *cli.Command {
+	return &cli.Command{
+		Name:    "config",
+		Aliases: []string{"c"},
+		Usage:   "manage site configuration",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "list",
+				Usage: "list all configured sites",
+				Action: func(c *cli.Context) error {
+					common.ListSites()
+					return nil
+				},
+			},
+			{
+				Name:      "set-url",
+				Usage:     "update a site's URL",
+				ArgsUsage: "<site> <new-url>",
+				Action: func(c *cli.Context) error {
+					if c.NArg() < 2 {
+						return fmt.Errorf("usage: tspider config set-url <site> <new-url>")
+					}
+					site := c.Args().Get(0)
+					url := c.Args().Get(1)
+					if err := common.SetSiteURL(site, url); err != nil {
+						return err
+					}
+					fmt.Printf("[+] Updated %s URL to: %s\n", site, url)
+					return nil
+				},
+			},
+			{
+				Name:      "add",
+				Usage:     "add a new site, with --torznab a Jackett or Prowlarr Torznab endpoint, or with --search-path a board searched by its selectors",
+				ArgsUsage: "<name> <url> <language>",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "torznab",
+						Usage: "the URL is a Torznab endpoint searched through its API",
+					},
+					&cli.StringFlag{
+						Name:  "api-key",
+						Usage: "API key of the Torznab endpoint",
+					},
+					&cli.StringFlag{
+						Name:  "search-path",
+						Usage: "the site is a board whose search page is at this path, with {keyword} where the keyword goes",
+					},
+					&cli.StringFlag{
+						Name:  "layout",
+						Usage: "layout of the board, whose selectors it uses: gnuboard or topic",
+					},
+					&cli.StringFlag{
+						Name:  "list-selector",
+						Usage: "CSS selector of the result links on the board's search page",
+					},
+					&cli.StringFlag{
+						Name:  "title-attr",
+						Usage: "attribute of the board's result links holding their title, when it is not their text",
+					},
+					&cli.StringFlag{
+						Name:  "magnet-selector",
+						Usage: "CSS selector of the magnet link, or of an element inside it or next to it, on the board's detail pages",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if c.NArg() < 3 {
+						return fmt.Errorf("usage: tspider config add <name> <url> <language>\n  language: %s", common.LanguageList())
+					}
+					name := c.Args().Get(0)
+					url := c.Args().Get(1)
+					lang := c.Args().Get(2)
+					if !common.ValidLanguage(lang) {
+						return fmt.Errorf("language must be %s", common.LanguageList())
+					}
+					if c.Bool("torznab") {
+						if err := common.AddTorznabSite(name, url, lang, c.String("api-key")); err != nil {
+							return err
+						}
+					} else if c.IsSet("search-path") {
+						board := common.SiteConfig{
+							URL:            url,
+							Language:       lang,
+							SearchPath:     c.String("search-path"),
+							Layout:         c.String("layout"),
+							ListSelector:   c.String("list-selector"),
+							TitleAttr:      c.String("title-attr"),
+							MagnetSelector: c.String("magnet-selector"),
+						}
+						if err := common.AddBoardSite(name, board); err != nil {
+							return err
+						}
+					} else if err := common.AddSite(name, url, lang); err != nil {
+						return err
+					}
+					fmt.Printf("[+] Added site: %s (%s)\n", name, url)
+					return nil
+				},
+			},
+			{
+				Name:      "remove",
+				Usage:     "remove a site",
+				ArgsUsage: "<site>",
+				Action: func(c *cli.Context) error {
+					if c.NArg() == 0 {
+						return fmt.Errorf("please provide a site name")
+					}
+					name := c.Args().First()
+					if err := common.RemoveSite(name); err != nil {
+						return err
+					}
+					fmt.Printf("[+] Removed site: %s\n", name)
+					return nil
+				},
+			},
+			{
+				Name:      "enable",
+				Usage:     "enable a site",
+				ArgsUsage: "<site>",
+				Action: func(c *cli.Context) error {
+					if c.NArg() == 0 {
+						return fmt.Errorf("please provide a site name")
+					}
+					if err := common.EnableSite(c.Args().First(), true); err != nil {
+						return err
+					}
+					fmt.Printf("[+] Enabled: %s\n", c.Args().First())
+					return nil
+				},
+			},
+			{
+				Name:      "disable",
+				Usage:     "disable a site",
+				ArgsUsage: "<site>",
+				Action: func(c *cli.Context) error {
+					if c.NArg() == 0 {
+						return fmt.Errorf("please provide a site name")
+					}
+					if err := common.EnableSite(c.Args().First(), false); err != nil {
+						return err
+					}
+					fmt.Printf("[+] Disabled: %s\n", c.Args().First())
+					return nil
+				},
+			},
+			{
+				Name:      "reset-state",
+				Usage:     "forget the failed checks of sites, checking snoozed sites again; all sites when none is given",
+				ArgsUsage: "[site...]",
+				Action: func(c *cli.Context) error {
+					for _, name := range c.Args().Slice() {
+						if _, ok := common.GetConfig().Sites[name]; !ok {
+							return fmt.Errorf("site '%s' not found", name)
+						}
+					}
+					if err := common.UpdateSiteState(func(s *common.SiteState) error {
+						s.Reset(c.Args().Slice()...)
+						return nil
+					}); err != nil {
+						return err
+					}
+					if c.NArg() == 0 {
+						fmt.Println("[+] Reset the state of all sites")
+					} else {
+						fmt.Printf("[+] Reset the state of: %s\n", strings.Join(c.Args().Slice(), ", "))
+					}
+					return nil
+				},
+			},
+			{
+				Name:      "set-selector",
+				Usage:     "override a site's built-in CSS selector (list or magnet); an empty selector restores the default",
+				ArgsUsage: "<site> <list|magnet> <selector>",
+				Action: func(c *cli.Context) error {
+					if c.NArg() < 3 {
+						return fmt.Errorf("usage: tspider config set-selector <site> <list|magnet> <selector>")
+					}
+					site, key, sel := c.Args().Get(0), c.Args().Get(1), c.Args().Get(2)
+					if err := common.SetSelector(site, key, sel); err != nil {
+						return err
+					}
+					if sel == "" {
+						fmt.Printf("[+] Restored default %s selector of %s\n", key, site)
+						return nil
+					}
+					fmt.Printf("[+] Set %s selector of %s: %s\n", key, site, sel)
+					return nil
+				},
+			},
+			{
+				Name:      "login",
+				Usage:     "store the credentials of a site that needs an account: a username and password posted to its login form, or a browser cookie",
+				ArgsUsage: "<site>",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "username",
+						Usage: "account name; the password is prompted for unless --password is given",
+					},
+					&cli.StringFlag{
+						Name:  "password",
+						Usage: "account password, for scripts only: it shows in the shell history and the process list (without it the password is prompted for, hidden, or read from stdin when piped)",
+					},
+					&cli.StringFlag{
+						Name:  "url",
+						Usage: "address of the login form, relative to the site URL, such as /login.php",
+					},
+					&cli.StringFlag{
+						Name:  "cookie",
+						Usage: `session cookie copied from a browser, such as "uid=1; pass=abc"`,
+					},
+					&cli.BoolFlag{
+						Name:  "clear",
+						Usage: "forget the site's credentials",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if c.NArg() != 1 {
+						return fmt.Errorf("usage: tspider config login (--username NAME [--url PATH] | --cookie COOKIE | --clear) <site>")
+					}
+					site := c.Args().First()
+					if c.Bool("clear") {
+						if err := common.SetLogin(site, "", "", "", ""); err != nil {
+							return err
+						}
+						fmt.Printf("[+] Cleared the credentials of %s\n", site)
+						return nil
+					}
+					username, password, cookie := c.String("username"), c.String("password"), c.String("cookie")
+					if (username == "") == (cookie == "") {
+						return fmt.Errorf("give either --username or --cookie")
+					}
+					if username != "" && !c.IsSet("password") {
+						p, err := common.ReadPassword(fmt.Sprintf("Password for %s on %s: ", username, site), os.Stdin, os.Stdout)
+						if err != nil {
+							return fmt.Errorf("no password given: %w", err)
+						}
+						password = p
+					}
+					if err := common.SetLogin(site, username, password, c.String("url"), cookie); err != nil {
+						return err
+					}
+					if cookie != "" {
+						fmt.Printf("[+] Stored the session cookie of %s\n", site)
+					} else {
+						fmt.Printf("[+] Stored the login of %s for %s\n", username, site)
+					}
+					return nil
+				},
+			},
+			{
+				Name:  "validate",
+				Usage: "check the config file for errors",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "dedupe",
+						Usage: "merge sites sharing a URL into one before validating",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					path := common.GetConfigPath()
+					if c.Bool("dedupe") {
+						merged, err := common.DedupeSites()
+						if err != nil {
+							return err
+						}
+						removed := make([]string, 0, len(merged))
+						for name := range merged {
+							removed = append(removed, name)
+						}
+						sort.Strings(removed)
+						for _, name := range removed {
+							fmt.Printf("[+] Merged %s into %s\n", name, merged[name])
+						}
+					}
+					if !validateConfigFile(path) {
+						return cli.Exit(fmt.Sprintf("%s is invalid", path), 1)
+					}
+					fmt.Printf("[+] %s is valid\n", path)
+					return nil
+				},
+			},
+			{
+				Name:  "edit",
+				Usage: "open the config file in $EDITOR and validate it afterwards",
+				Action: func(c *cli.Context) error {
+					path := common.GetConfigPath()
+					for {
+						if err := common.EditFile(path); err != nil {
+							return err
+						}
+						if validateConfigFile(path) {
+							fmt.Printf("[+] %s is valid\n", path)
+							return nil
+						}
+						if !confirm("The config is invalid. Reopen the editor?", true) {
+							return cli.Exit(fmt.Sprintf("%s was left invalid", path), 1)
+						}
+					}
+				},
+			},
+			{
+				Name:      "add-ua",
+				Usage:     "add a User-Agent to the pool requests rotate through",
+				ArgsUsage: "<user-agent>",
+				Action: func(c *cli.Context) error {
+					if c.NArg() == 0 {
+						return fmt.Errorf("usage: tspider config add-ua <user-agent>")
+					}
+					if err := common.AddUserAgent(c.Args().First()); err != nil {
+						return err
+					}
+					fmt.Printf("[+] Added User-Agent: %s\n", c.Args().First())
+					return nil
+				},
+			},
+			{
+				Name:  "list-ua",
+				Usage: "list the User-Agent pool",
+				Action: func(c *cli.Context) error {
+					common.ListUserAgents()
+					return nil
+				},
+			},
+			pruneCommand(),
+			profileCommand(),
+			scheduleCommand(),
+			blockCommand(),
+			{
+				Name:  "path",
+				Usage: "show config file path",
+				Action: func(c *cli.Context) error {
+					fmt.Println(common.GetConfigPath())
+					return nil
+				},
+			},
+		},
+	}
+}
+
+// confirm asks a yes/no question on stdin, returning def on an empty answer
+func confirm(question string, def bool) bool {
+	hint := "[y/N]"
+	if def {
+		hint = "[Y/n]"
+	}
+	fmt.Printf("%s %s ", question, hint)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "":
+		return def
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// validateConfigFile prints the problems of the config file and reports
+// whether it is valid
+func validateConfigFile(path string) bool {
+	problems, err := common.ValidateConfigFile(path)
+	if err != nil {
+		fmt.Printf("[!] %v\n", err)
+		return false
+	}
+	for _, p := range problems {
+		fmt.Printf("[!] %s\n", p)
+	}
+	return len(problems) == 0
+}
+
+func pruneCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "prune",
+		Usage: "disable (or remove) sites that are unreachable right now",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "remove",
+				Usage: "remove unreachable sites instead of disabling them",
+			},
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "only show what would change",
+			},
+			&cli.BoolFlag{
+				Name:    "yes",
+				Aliases: []string{"y"},
+				Usage:   "apply without asking",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			remove := c.Bool("remove")
+			action := "Disable"
+			if remove {
+				action = "Remove"
+			}
+			fmt.Println("[*] Checking torrent site availability...")
+			statuses := common.Doctor(c.Context, "")
+			// Sites whose check was interrupted would look unreachable
+			if err := deadlineError(c.Context); err != nil {
+				return err
+			}
+			errs := make(map[string]string, len(statuses))
+			for _, s := range statuses {
+				errs[s.Name] = s.Error
+			}
+			names := common.PrunePlan(statuses, remove)
+			if len(names) == 0 {
+				fmt.Println("[+] Nothing to prune")
+				return nil
+			}
+			for _, name := range names {
+				fmt.Printf("  %s %s (%s)\n", action, name, errs[name])
+			}
+			if c.Bool("dry-run") {
+				fmt.Printf("[*] Dry run: %d site(s) would change\n", len(names))
+				return nil
+			}
+			if !c.Bool("yes") && !confirm(fmt.Sprintf("%s %d site(s)?", action, len(names)), false) {
+				fmt.Println("[*] Nothing changed")
+				return nil
+			}
+			if err := common.PruneSites(names, remove); err != nil {
+				return err
+			}
+			fmt.Printf("[+] %sd: %s\n", action, strings.Join(names, ", "))
+			return nil
+		},
+	}
+}
+
+func profileCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "profile",
+		Usage: "manage named site sets used with --profile",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "list",
+				Usage: "list all profiles",
+				Action: func(c *cli.Context) error {
+					common.ListProfiles()
+					return nil
+				},
+			},
+			{
+				Name:      "add",
+				Usage:     "create or replace a profile",
+				ArgsUsage: "<name> <site> [site...]",
+				Action: func(c *cli.Context) error {
+					if c.NArg() < 2 {
+						return fmt.Errorf("usage: tspider config profile add <name> <site> [site...]")
+					}
+					name := c.Args().First()
+					sites := c.Args().Tail()
+					if err := common.AddProfile(name, sites); err != nil {
+						return err
+					}
+					fmt.Printf("[+] Saved profile %s: %s\n", name, strings.Join(sites, ", "))
+					return nil
+				},
+			},
+			{
+				Name:      "remove",
+				Usage:     "remove a profile",
+				ArgsUsage: "<name>",
+				Action: func(c *cli.Context) error {
+					if c.NArg() == 0 {
+						return fmt.Errorf("please provide a profile name")
+					}
+					if err := common.RemoveProfile(c.Args().First()); err != nil {
+						return err
+					}
+					fmt.Printf("[+] Removed profile: %s\n", c.Args().First())
+					return nil
+				},
+			},
+		},
+	}
+}
+
+func scheduleCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "schedule",
+		Usage: "manage the searches run by tspider daemon",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "list",
+				Usage: "list all schedules",
+				Action: func(c *cli.Context) error {
+					common.ListSchedules()
+					return nil
+				},
+			},
+			{
+				Name:      "add",
+				Usage:     "create or replace a schedule",
+				ArgsUsage: "<name> <keyword>",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "cron",
+						Usage:    "when to search: a cron expression such as \"0 */6 * * *\", @hourly, @daily or \"@every 30m\"",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:    "lang",
+						Aliases: []string{"l"},
+						Value:   "jp",
+						Usage:   "sites to search (kr, jp or en)",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if c.NArg() < 2 {
+						return fmt.Errorf("usage: tspider config schedule add --cron SPEC <name> <keyword>")
+					}
+					name := c.Args().First()
+					s := common.Schedule{
+						Keyword: strings.Join(c.Args().Tail(), " "),
+						Lang:    c.String("lang"),
+						Cron:    c.String("cron"),
+					}
+					if err := common.AddSchedule(name, s); err != nil {
+						return err
+					}
+					fmt.Printf("[+] Saved schedule %s: %q on %s sites, %s\n", name, s.Keyword, s.Language(), s.Cron)
+					return nil
+				},
+			},
+			{
+				Name:      "remove",
+				Usage:     "remove a schedule and forget the results it has seen",
+				ArgsUsage: "<name>",
+				Action: func(c *cli.Context) error {
+					if c.NArg() == 0 {
+						return fmt.Errorf("please provide a schedule name")
+					}
+					name := c.Args().First()
+					if err := common.RemoveSchedule(name); err != nil {
+						return err
+					}
+					store, err := common.LoadSeen()
+					if err != nil {
+						return err
+					}
+					store.Forget(name)
+					if err := store.Save(); err != nil {
+						return err
+					}
+					fmt.Printf("[+] Removed schedule: %s\n", name)
+					return nil
+				},
+			},
+		},
+	}
+}
+
+func blockCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "block",
+		Usage: "manage title patterns excluded from every search",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "list",
+				Usage: "list blocked patterns",
+				Action: func(c *cli.Context) error {
+					common.ListBlocks()
+					return nil
+				},
+			},
+			{
+				Name:      "add",
+				Usage:     "block titles matching a regex",
+				ArgsUsage: "<regex>",
+				Action: func(c *cli.Context) error {
+					if c.NArg() == 0 {
+						return fmt.Errorf("usage: tspider config block add <regex>")
+					}
+					if err := common.AddBlock(c.Args().First()); err != nil {
+						return err
+					}
+					fmt.Printf("[+] Blocked pattern: %s\n", c.Args().First())
+					return nil
+				},
+			},
+			{
+				Name:      "remove",
+				Usage:     "unblock a pattern",
+				ArgsUsage: "<regex>",
+				Action: func(c *cli.Context) error {
+					if c.NArg() == 0 {
+						return fmt.Errorf("please provide a pattern")
+					}
+					if err := common.RemoveBlock(c.Args().First()); err != nil {
+						return err
+					}
+					fmt.Printf("[+] Removed pattern: %s\n", c.Args().First())
+					return nil
+				},
+			},
+		},
+	}
+}
+
+// krSites maps Korean site names to their scrapers
+func krSites() map[string]common.Scraper {
+	sites := map[string]common.Scraper{
+		"torrenttop":  &ktorrent.TorrentTop{},
+		"torrentmax":  &ktorrent.TorrentMax{},
+		"torrentqq":   &ktorrent.TorrentQQ{},
+		"torrentrj":   &ktorrent.TorrentRJ{},
+		"torrentsir":  &ktorrent.TorrentSir{},
+		"torrentsome": &ktorrent.TorrentSome{},
+		"torrentwiz":  &ktorrent.TorrentWiz{},
+	}
+	addTorznabSites(sites, "kr")
+	addBoardSites(sites, "kr")
+	return sites
+}
+
+// addTorznabSites adds the Torznab endpoints configured for lang to sites
+func addTorznabSites(sites map[string]common.Scraper, lang string) {
+	for name, site := range common.GetConfig().Sites {
+		if site.Type == common.SiteTypeTorznab && site.Language == lang {
+			sites[name] = &torznab.Indexer{Name: name}
+		}
+	}
+}
+
+// addBoardSites adds the board sites configured for lang to sites, leaving
+// out with a warning those that cannot be searched
+func addBoardSites(sites map[string]common.Scraper, lang string) {
+	for name, site := range common.GetConfig().Sites {
+		if site.Type != common.SiteTypeBoard || site.Language != lang {
+			continue
+		}
+		board, err := ktorrent.NewBoardScraper(name, site)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[!] skipping board: %v\n", err)
+			continue
+		}
+		sites[name] = board
+	}
+}
+
+// allSites maps the names of all sites tspider has a scraper for to their
+// scrapers, with default search options
+func allSites() map[string]common.Scraper {
+	sites := krSites()
+	for name, s := range jpSites("", false, nil) {
+		sites[name] = s
+	}
+	for name, s := range enSites() {
+		sites[name] = s
+	}
+	return sites
+}
+
+// implementedSites returns the names of all sites tspider has a scraper for
+func implementedSites() []string {
+	var names []string
+	for name := range allSites() {
+		names = append(names, name)
+	}
+	return names
+}
+
+// implementedStatuses returns the statuses of the sites tspider has a scraper for
+func implementedStatuses(statuses []common.SiteStatus) []common.SiteStatus {
+	implemented := map[string]bool{}
+	for _, name := range implementedSites() {
+		implemented[name] = true
+	}
+	var kept []common.SiteStatus
+	for _, s := range statuses {
+		if implemented[s.Name] {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
+
+// printJSON writes v to stdout as indented JSON
+func printJSON(v interface{}) error {
+	return common.WriteJSON(os.Stdout, v)
+}
+
+// jpSites maps Japanese site names to their scrapers, searching only the
+// uploads of uploader when it is set, the keyword as a phrase when exact and
+// excluding the negative terms of exclude
+func jpSites(uploader string, exact bool, exclude []string) map[string]common.Scraper {
+	sites := map[string]common.Scraper{
+		"nyaa":   &jtorrent.Nyaa{Uploader: uploader, Exact: exact, Exclude: exclude},
+		"sukebe": &jtorrent.SuKeBe{Uploader: uploader, Exact: exact, Exclude: exclude},
+	}
+	// AniDex, Tokyo Toshokan, Torznab and board searches cannot be limited
+	// to the uploads of one uploader
+	if uploader == "" {
+		sites["anidex"] = &jtorrent.AniDex{}
+		sites["tokyotosho"] = &jtorrent.TokyoTosho{}
+		addTorznabSites(sites, "jp")
+		addBoardSites(sites, "jp")
+	}
+	return sites
+}
+
+// enSites maps English site names to their scrapers
+func enSites() map[string]common.Scraper {
+	sites := map[string]common.Scraper{
+		"1337x": &etorrent.X1337{},
+	}
+	addTorznabSites(sites, "en")
+	addBoardSites(sites, "en")
+	return sites
+}
+
+// langSites maps the names of the sites of lang to their scrapers, passing
+// uploader, exact and exclude to the Japanese ones, see jpSites. Any lang
+// but kr and en means the Japanese sites.
+func langSites(lang, uploader string, exact bool, exclude []string) map[string]common.Scraper {
+	switch lang {
+	case "kr":
+		return krSites()
+	case "en":
+		return enSites()
+	}
+	return jpSites(uploader, exact, exclude)
+}
+
+func doSearch(c *cli.Context) error {
+	keyword := c.Args().First()
+	uploader := c.String("uploader")
+	if keyword == "" && uploader == "" {
+		return fmt.Errorf("please provide a search keyword or --uploader")
+	}
+
+	var negatives []string
+	if !c.Bool("raw-keyword") {
+		keyword, negatives = common.ParseNegatives(common.TransformKeyword(keyword))
+		if keyword == "" && uploader == "" {
+			return fmt.Errorf("please provide a search keyword besides the excluded terms")
+		}
+	}
+
+	excludes, err := exclusionPatterns(c)
+	if err != nil {
+		return err
+	}
+	// Reject an unknown --sort or --format before any request is made
+	if err := common.SortResults(nil, c.String("sort"), keyword); err != nil {
+		return err
+	}
+	if c.Bool("json") && c.IsSet("format") && c.String("format") != "json" {
+		return fmt.Errorf("--json cannot be combined with --format %s", c.String("format"))
+	}
+	selected := 0
+	for _, set := range []bool{c.Bool("json") || c.IsSet("format"), c.String("template") != "", c.Bool("magnets"), c.Bool("stream"), c.Bool("pick")} {
+		if set {
+			selected++
+		}
+	}
+	if selected > 1 {
+		return fmt.Errorf("only one of --format, --json, --template, --magnets, --stream and --pick can be used")
+	}
+	if c.Bool("pick") && !common.IsTerminal(os.Stdin) {
+		return fmt.Errorf("--pick needs a terminal to choose a result")
+	}
+	if c.String("template") != "" {
+		if _, err := common.ParseResultTemplate(c.String("template")); err != nil {
+			return err
+		}
+	}
+	switch outputFormat(c) {
+	case "table":
+	case "magnets":
+		// Progress would only get in the way of a pipe into a torrent client
+		common.SpinnerOutput = io.Discard
+		if common.IsTerminal(os.Stdout) {
+			common.SpinnerOutput = os.Stderr
+		}
+	case "json", "csv", "template", "pick", "ndjson", "ndjson-results":
+		common.SpinnerOutput = os.Stderr
+	default:
+		return fmt.Errorf("unknown format %q (want table, json, csv, ndjson or ndjson-results)", c.String("format"))
+	}
+	if f := outputFormat(c); (f == "csv" || f == "template" || f == "magnets" || f == "pick") && c.Bool("group-by-size") {
+		return fmt.Errorf("--group-by-size cannot be used with --format csv, --template, --magnets or --pick")
+	}
+	if f := outputFormat(c); (f == "ndjson-results" || f == "ndjson") && c.String("diff") != "" {
+		return fmt.Errorf("--diff needs the complete result set and cannot be used with --format ndjson-results or --stream")
+	}
+	filter := func(results []common.SearchResult) []common.SearchResult {
+		if c.Bool("exact") {
+			results = common.FilterPhrase(results, keyword)
+		}
+		results = common.ExcludeTerms(results, negatives)
+		return common.ExcludeResults(results, excludes)
+	}
+
+	lang := c.String("lang")
+	if lang != "" && !common.ValidLanguage(lang) {
+		return fmt.Errorf("unknown language %q (want %s)", lang, common.LanguageList())
+	}
+	if uploader != "" && (lang == "kr" || lang == "en") {
+		return fmt.Errorf("--uploader is only supported by sites with per-user listings (nyaa, sukebe); the %s sites have none", lang)
+	}
+	if profile := c.String("profile"); profile != "" {
+		if err := common.UseProfile(profile); err != nil {
+			return err
+		}
+	}
+
+	if err := setupTor(c); err != nil {
+		return err
+	}
+	if c.Bool("fresh-check") {
+		common.Availability().Reset()
+	}
+	common.StreamParse = c.Bool("stream-parse")
+	common.Retries().Reset()
+
+	ctx := c.Context
+
+	columns := common.DataExColumns
+	siteLang := "jp"
+	switch lang {
+	case "kr":
+		columns = common.DataColumns
+		siteLang = "kr"
+	case "en":
+		siteLang = "en"
+	}
+	key := common.NewCrawlKey(keyword, siteLang, uploader, c.Bool("exact"))
+	if siteLang == "jp" {
+		key.Negatives = negatives
+	}
+	cache := common.Crawls()
+	var (
+		data     []common.SearchResult
+		stats    common.DedupStats
+		cached   bool
+		streamed bool
+	)
+	if cache != nil && !c.Bool("no-cache") {
+		data, stats, cached = cache.Get(key)
+		if cached {
+			fmt.Fprintln(os.Stderr, "[*] Reusing the results of an identical recent search (--no-cache to search again)")
+		}
+	}
+	if !cached {
+		var ok bool
+		data, stats, ok = crawl(ctx, c, keyword, lang, negatives, columns, filter)
+		if !ok {
+			// Sites whose check was interrupted are not known to be down
+			if ctx.Err() != context.Canceled {
+				fmt.Fprintln(messages(c), "[!] No available sites. Use 'tspider doctor' to check status.")
+			}
+			return deadlineError(ctx)
+		}
+		streamed = liveOutput(c) || outputFormat(c) == "ndjson-results" || outputFormat(c) == "ndjson"
+		// A crawl cut short by the deadline is partial and not worth reusing
+		if cache != nil && ctx.Err() == nil {
+			if err := cache.Put(key, data, stats); err != nil {
+				fmt.Fprintf(os.Stderr, "[!] failed to cache results: %v\n", err)
+			}
+		}
+	}
+	data = filter(data)
+	common.SortResults(data, c.String("sort"), keyword)
+	recordSearch(keyword, lang, data)
+	if err := render(ctx, c, keyword, data, columns, streamed, stats); err != nil {
+		return err
+	}
+	// With --format json the summary is part of the JSON document
+	if c.Bool("summary") && outputFormat(c) != "json" {
+		fmt.Fprintf(messages(c), "[*] Dedup: %s\n", stats)
+		common.PrintRetryReport(messages(c), common.Retries().Report())
+	}
+	return deadlineError(ctx)
+}
+
+// crawl searches the available sites of lang and merges their results,
+// sending negatives to the sites that support them. It returns false when no
+// site is available.
+func crawl(ctx context.Context, c *cli.Context, keyword, lang string, negatives []string, columns []common.Column, filter func([]common.SearchResult) []common.SearchResult) ([]common.SearchResult, common.DedupStats, bool) {
+	scrapers := langSites(lang, c.String("uploader"), c.Bool("exact"), negatives)
+	sites, spinner := common.GetAvailableSites(ctx, scrapers)
+	if len(sites) == 0 {
+		spinner.Stop()
+		return nil, common.DedupStats{}, false
+	}
+	streamResults(c, spinner, columns, filter)
+	streamNDJSON(c, spinner, keyword, filter)
+	data, stats := common.CollectData(ctx, sites, keyword, spinner)
+	stopSpinner(ctx, spinner, len(data), len(sites))
+	return data, stats, true
+}
+
+// setupTor routes this run through Tor when --tor, --tor-new-circuit or
+// --tor-isolate is set
+func setupTor(c *cli.Context) error {
+	if !c.Bool("tor") && !c.Bool("tor-new-circuit") && !c.Bool("tor-isolate") {
+		return nil
+	}
+	if c.Bool("verify-metadata") {
+		return fmt.Errorf("--verify-metadata talks to the DHT and peers directly over UDP/TCP and cannot run over Tor")
+	}
+	if err := common.UseTor(c.Bool("tor-isolate")); err != nil {
+		return err
+	}
+	if c.Bool("tor-new-circuit") {
+		return common.NewTorCircuit()
+	}
+	return nil
+}
+
+// withExtraColumns appends the optional columns requested by flags
+func withExtraColumns(c *cli.Context, columns []common.Column) []common.Column {
+	if c.Bool("freshness") {
+		columns = append(columns[:len(columns):len(columns)], common.FreshnessColumn)
+	}
+	return columns
+}
+
+// liveOutput reports whether results are printed per site as they arrive.
+// It needs a terminal and is off when the output is paged, diffed, grouped
+// by size or JSON, which only make sense for the complete result set.
+func liveOutput(c *cli.Context) bool {
+	return c.Bool("live") && outputFormat(c) == "table" && !interactive(c) && !c.Bool("group-by-size") && !c.Bool("pager") && c.String("diff") == "" && common.IsTerminal(os.Stdout)
+}
+
+// streamResults prints each site's filtered results above the spinner as
+// soon as the site finishes when --live is in effect, leaving out torrents
+// an earlier site already printed
+func streamResults(c *cli.Context, spinner *common.Spinner, columns []common.Column, filter func([]common.SearchResult) []common.SearchResult) {
+	if !liveOutput(c) {
+		return
+	}
+	columns = withExtraColumns(c, columns)
+	var seen common.StreamDedup
+	spinner.OnResults(func(site string, results []common.SearchResult) {
+		results = seen.Fresh(filter(results))
+		if len(results) == 0 {
+			return
+		}
+		var buf bytes.Buffer
+		fmt.Fprintf(&buf, "[%s] %d result(s)\n", site, len(results))
+		common.PrintTable(&buf, columns, results)
+		spinner.PrintAbove(buf.String())
+	})
+}
+
+// streamNDJSON writes each site's filtered results to stdout as soon as the
+// site finishes: as {site, result} lines with --format ndjson-results, as
+// result lines with --stream. A torrent an earlier site already reported is
+// not written again.
+func streamNDJSON(c *cli.Context, spinner *common.Spinner, keyword string, filter func([]common.SearchResult) []common.SearchResult) {
+	format := outputFormat(c)
+	if format != "ndjson-results" && format != "ndjson" {
+		return
+	}
+	var (
+		mu   sync.Mutex
+		seen common.StreamDedup
+	)
+	spinner.OnResults(func(site string, results []common.SearchResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		results = seen.Fresh(filter(results))
+		common.SortResults(results, c.String("sort"), keyword)
+		var err error
+		if format == "ndjson" {
+			err = common.WriteNDJSON(os.Stdout, results)
+		} else {
+			err = common.WriteEnvelopes(os.Stdout, site, results)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[!] %v\n", err)
+		}
+	})
+}
+
+// pickMagnet lets the user choose one of results, in fzf when it is
+// installed, and prints its magnet to w or, with --copy or --open, uses it
+func pickMagnet(c *cli.Context, w io.Writer, results []common.SearchResult) error {
+	if len(results) == 0 {
+		return fmt.Errorf("no results to pick from")
+	}
+	r, err := common.Pick(results, os.Stdin, os.Stderr)
+	if err != nil {
+		return err
+	}
+	if !c.Bool("copy") && !c.Bool("open") {
+		fmt.Fprintln(w, r.Magnet)
+		return nil
+	}
+	return useMagnet(c, r)
+}
+
+// useMagnet copies the magnet of r to the clipboard with --copy and opens it
+// in the default torrent client with --open
+func useMagnet(c *cli.Context, r common.SearchResult) error {
+	if c.Bool("copy") {
+		if err := common.CopyToClipboard(r.Magnet); err != nil {
+			return err
+		}
+		fmt.Fprintf(messages(c), "[+] Magnet of %s %s\n", r.Title, common.CopyResult())
+	}
+	if c.Bool("open") {
+		if err := common.OpenLink(r.Magnet); err != nil {
+			return err
+		}
+		fmt.Fprintf(messages(c), "[+] Opened the magnet of %s\n", r.Title)
+	}
+	return nil
+}
+
+// interactive reports whether results are browsed with -i rather than
+// printed, which needs a table format and a terminal
+func interactive(c *cli.Context) bool {
+	return c.Bool("interactive") && outputFormat(c) == "table" && common.IsTerminal(os.Stdin) && common.IsTerminal(os.Stdout)
+}
+
+// outputFormat returns the format results are printed in: json with --json,
+// template with --template, magnets with --magnets, ndjson with --stream,
+// pick with --pick, else the value of --format
+func outputFormat(c *cli.Context) string {
+	if c.Bool("json") {
+		return "json"
+	}
+	if c.Bool("magnets") {
+		return "magnets"
+	}
+	if c.Bool("stream") {
+		return "ndjson"
+	}
+	if c.Bool("pick") {
+		return "pick"
+	}
+	if c.String("template") != "" {
+		return "template"
+	}
+	return c.String("format")
+}
+
+// messages returns where status messages go: stdout for tables, stderr for
+// machine-readable formats so that they do not mix with the results
+func messages(c *cli.Context) io.Writer {
+	if outputFormat(c) != "table" {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// exclusionPatterns compiles the blocklist, unless --no-blocklist is set,
+// together with the --exclude patterns of this run
+func exclusionPatterns(c *cli.Context) ([]*regexp.Regexp, error) {
+	var patterns []string
+	if !c.Bool("no-blocklist") {
+		patterns = append(patterns, common.GetConfig().Blocklist...)
+	}
+	patterns = append(patterns, c.StringSlice("exclude")...)
+	return common.CompilePatterns(patterns)
+}
+
+// render prints the results of a search and writes the requested reports.
+// The table is skipped when streamed, as --live already printed it per site.
+// With --format json and --summary, the results are wrapped in an object
+// together with stats and the retry report.
+func render(ctx context.Context, c *cli.Context, keyword string, data []common.SearchResult, columns []common.Column, streamed bool, stats common.DedupStats) error {
+	verified := verifyMetadata(ctx, c, data)
+	shown := data
+	var summary string
+	if path := c.String("diff"); path != "" {
+		previous, err := common.LoadResults(path)
+		if err != nil {
+			return err
+		}
+		added, removed := common.DiffResults(previous, data)
+		summary = fmt.Sprintf("%d new, %d gone since %s\n", len(added), len(removed), path)
+		shown = added
+	}
+
+	w, done := output(c)
+	if outputFormat(c) == "table" {
+		fmt.Fprint(w, summary)
+	} else {
+		fmt.Fprint(messages(c), summary)
+	}
+	switch {
+	case outputFormat(c) == "json":
+		var v interface{} = shown
+		if c.Bool("group-by-size") {
+			v = common.BucketBySize(shown, common.SizeBuckets())
+		}
+		if c.Bool("summary") {
+			v = map[string]interface{}{
+				"results": v,
+				"dedup":   stats,
+				"retries": common.Retries().Report(),
+			}
+		}
+		if err := common.WriteJSON(w, v); err != nil {
+			done()
+			return err
+		}
+	case outputFormat(c) == "csv":
+		if err := common.WriteCSV(w, shown); err != nil {
+			done()
+			return err
+		}
+	case outputFormat(c) == "magnets":
+		for _, r := range shown {
+			if common.IsValidMagnet(r.Magnet) {
+				fmt.Fprintln(w, r.Magnet)
+			}
+		}
+	case outputFormat(c) == "pick":
+		if err := pickMagnet(c, w, shown); err != nil {
+			done()
+			return err
+		}
+	case outputFormat(c) == "template":
+		tmpl, err := common.ParseResultTemplate(c.String("template"))
+		if err == nil {
+			err = common.WriteTemplate(w, tmpl, shown)
+		}
+		if err != nil {
+			done()
+			return err
+		}
+	case outputFormat(c) == "ndjson":
+		// Results were written as sites finished, unless they came from the cache
+		if !streamed {
+			if err := common.WriteNDJSON(w, shown); err != nil {
+				done()
+				return err
+			}
+		}
+	case outputFormat(c) == "ndjson-results":
+		// Results were written as sites finished, unless they came from the cache
+		if !streamed {
+			if err := writeEnvelopes(w, shown); err != nil {
+				done()
+				return err
+			}
+		}
+	case interactive(c):
+		b := &common.Browser{Results: shown, Columns: withExtraColumns(c, columns), In: os.Stdin, Out: os.Stdout}
+		if err := b.Run(); err != nil {
+			done()
+			return err
+		}
+	case c.Bool("group-by-size"):
+		common.PrintBySize(w, withExtraColumns(c, columns), shown, common.SizeBuckets())
+	case !streamed:
+		common.PrintTable(w, withExtraColumns(c, columns), shown)
+	}
+	if verified != nil {
+		metadata.PrintReport(w, verified)
+	}
+	done()
+
+	if err := writeHTML(c, keyword, data); err != nil {
+		return err
+	}
+	if path := c.String("save"); path != "" {
+		if err := common.SaveResults(path, data); err != nil {
+			return err
+		}
+		fmt.Fprintf(messages(c), "[+] Results saved to %s\n", path)
+	}
+	// --pick uses the chosen result itself
+	if (c.Bool("copy") || c.Bool("open")) && outputFormat(c) != "pick" {
+		for _, r := range shown {
+			if common.IsValidMagnet(r.Magnet) {
+				return useMagnet(c, r)
+			}
+		}
+		return fmt.Errorf("no result with a magnet to copy or open")
+	}
+	return nil
+}
+
+// writeEnvelopes writes results as {site, result} lines, site by site in
+// the order sites first appear
+func writeEnvelopes(w io.Writer, results []common.SearchResult) error {
+	var sites []string
+	bySite := map[string][]common.SearchResult{}
+	for _, r := range results {
+		if _, ok := bySite[r.Site]; !ok {
+			sites = append(sites, r.Site)
+		}
+		bySite[r.Site] = append(bySite[r.Site], r)
+	}
+	for _, site := range sites {
+		if err := common.WriteEnvelopes(w, site, bySite[site]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordSearch appends the current invocation and its results to the
+// search history
+func recordSearch(keyword, lang string, results []common.SearchResult) {
+	if replaying {
+		return
+	}
+	_, err := common.RecordHistory(common.HistoryEntry{
+		Time:    time.Now(),
+		Keyword: keyword,
+		Lang:    lang,
+		Args:    os.Args[1:],
+	}, results)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[!] %v\n", err)
+	}
+}
+
+// verifyMetadata checks results against their DHT metadata when --verify-metadata is set
+func verifyMetadata(ctx context.Context, c *cli.Context, data []common.SearchResult) []metadata.Result {
+	if !c.Bool("verify-metadata") {
+		return nil
+	}
+	var valid []metadata.Torrent
+	for _, r := range data {
+		if common.IsValidMagnet(r.Magnet) {
+			valid = append(valid, metadata.Torrent{Title: r.Title, Magnet: r.Magnet})
+		}
+	}
+	if len(valid) == 0 {
+		return nil
+	}
+	spinner := common.NewSpinner(fmt.Sprintf("Verifying metadata of %d torrent(s)", len(valid)))
+	spinner.Start()
+	results := metadata.Verify(ctx, valid)
+	spinner.Stop()
+	return results
+}
+
+// writeHTML writes the HTML report when --html is set
+func writeHTML(c *cli.Context, keyword string, data []common.SearchResult) error {
+	path := c.String("html")
+	if path == "" {
+		return nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create HTML report: %w", err)
+	}
+	defer f.Close()
+	if err := common.WriteHTML(f, keyword, data); err != nil {
+		return fmt.Errorf("failed to write HTML report: %w", err)
+	}
+	fmt.Fprintf(messages(c), "[+] HTML report written to %s\n", path)
+	return nil
+}
+
+// output returns the writer results are rendered to and a function that releases it.
+// With --pager on a terminal the writer feeds the pager; otherwise, and for the
+// machine-readable formats, it is stdout.
+func output(c *cli.Context) (io.Writer, func()) {
+	if !c.Bool("pager") || interactive(c) || outputFormat(c) != "table" || !common.IsTerminal(os.Stdout) {
+		return os.Stdout, func() {}
+	}
+	pager, err := common.NewPager()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[!] %v\n", err)
+		return os.Stdout, func() {}
+	}
+	return pager, func() { pager.Close() }
+}
+
+// stopSpinner stops the spinner with a summary noting whether the deadline cut
+// the search short, sites were abandoned over their budget or sites were
+// degraded, their remaining pages skipped after a failed request
+func stopSpinner(ctx context.Context, spinner *common.Spinner, results, sites int) {
+	if ctx.Err() == context.DeadlineExceeded {
+		spinner.StopWithMessage(fmt.Sprintf("Deadline reached, %d partial result(s) from %d site(s)", results, sites))
+		return
+	}
+	abandoned := spinner.Abandoned()
+	msg := fmt.Sprintf("Found %d result(s) from %d site(s)", results, sites-len(abandoned))
+	if len(abandoned) > 0 {
+		msg += fmt.Sprintf(", %d abandoned over budget (%s)", len(abandoned), strings.Join(abandoned, ", "))
+	}
+	if degraded := spinner.Degraded(); len(degraded) > 0 {
+		msg += fmt.Sprintf(", %d degraded (%s)", len(degraded), strings.Join(degraded, ", "))
+	}
+	spinner.StopWithMessage(msg)
+}
+
+// stopError returns the error of a command running until stopped: none when
+// Ctrl-C stopped it, as that is how it is meant to end, and the deadline
+// exit error when --deadline did
+func stopError(ctx context.Context) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return deadlineError(ctx)
+	}
+	return nil
+}
+
+// deadlineError returns an exit error carrying exitDeadline if ctx hit its
+// deadline, or exitInterrupted if it was canceled by Ctrl-C
+func deadlineError(ctx context.Context) error {
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		return cli.Exit("deadline exceeded", exitDeadline)
+	case context.Canceled:
+		return cli.Exit("interrupted", exitInterrupted)
 	}
 	return nil
 }
@@ -0,0 +1,130 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// defaultAvailabilityTTL is how long a site probe is trusted when the config
+// does not set availability_ttl_seconds
+const defaultAvailabilityTTL = 60 * time.Second
+
+type availabilityEntry struct {
+	Up      bool      `json:"up"`
+	Checked time.Time `json:"checked"`
+}
+
+// AvailabilityCache remembers recent up/down probes per site URL so that
+// back-to-back searches skip redundant checks. It is safe for concurrent use.
+type AvailabilityCache struct {
+	// TTL is how long a probe stays valid
+	TTL time.Duration
+	// Now returns the current time; tests replace it with a fake clock
+	Now func() time.Time
+
+	path    string
+	mu      sync.Mutex
+	entries map[string]availabilityEntry
+}
+
+var (
+	availability     *AvailabilityCache
+	availabilityOnce sync.Once
+)
+
+// NewAvailabilityCache returns a cache with the given TTL. When path is not
+// empty, probes are loaded from and saved to that file so that they survive
+// between runs.
+func NewAvailabilityCache(ttl time.Duration, path string) *AvailabilityCache {
+	a := &AvailabilityCache{TTL: ttl, Now: time.Now, path: path, entries: map[string]availabilityEntry{}}
+	if path != "" {
+		if data, err := os.ReadFile(path); err == nil {
+			json.Unmarshal(data, &a.entries)
+		}
+	}
+	return a
+}
+
+// Availability returns the cache used by GetAvailableSites, persisted under
+// the user cache directory
+func Availability() *AvailabilityCache {
+	availabilityOnce.Do(func() {
+		ttl := defaultAvailabilityTTL
+		if s := GetConfig().AvailabilityTTL; s > 0 {
+			ttl = time.Duration(s) * time.Second
+		}
+		path := ""
+		if dir, err := os.UserCacheDir(); err == nil {
+			path = filepath.Join(dir, "tspider", "availability.json")
+		}
+		availability = NewAvailabilityCache(ttl, path)
+	})
+	return availability
+}
+
+// Get returns the cached probe of url, with ok false when there is none or
+// it is older than TTL
+func (a *AvailabilityCache) Get(url string) (up, ok bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	e, exists := a.entries[url]
+	if !exists || a.Now().Sub(e.Checked) >= a.TTL {
+		return false, false
+	}
+	return e.Up, true
+}
+
+// Set records a probe of url made now
+func (a *AvailabilityCache) Set(url string, up bool) {
+	a.mu.Lock()
+	a.entries[url] = availabilityEntry{Up: up, Checked: a.Now()}
+	a.mu.Unlock()
+}
+
+// Reset forgets every probe, so the next checks hit the network
+func (a *AvailabilityCache) Reset() {
+	a.mu.Lock()
+	a.entries = map[string]availabilityEntry{}
+	a.mu.Unlock()
+}
+
+// Save writes the unexpired probes to the cache file, if the cache has one
+func (a *AvailabilityCache) Save() error {
+	if a.path == "" {
+		return nil
+	}
+	a.mu.Lock()
+	live := make(map[string]availabilityEntry, len(a.entries))
+	for url, e := range a.entries {
+		if a.Now().Sub(e.Checked) < a.TTL {
+			live[url] = e
+		}
+	}
+	a.mu.Unlock()
+	data, err := json.Marshal(live)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(a.path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(a.path, data, 0644)
+}
+
+// checkAvailability probes url unless a recent probe is cached, and reports
+// whether it probed it. A probe cut short by ctx is not cached.
+func checkAvailability(ctx context.Context, url string) (up, probed bool) {
+	cache := Availability()
+	if up, ok := cache.Get(url); ok {
+		return up, false
+	}
+	up = CheckNetWorkFromURL(ctx, url)
+	if ctx.Err() == nil {
+		cache.Set(url, up)
+	}
+	return up, ctx.Err() == nil
+}
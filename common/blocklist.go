@@ -0,0 +1,88 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// AddBlock adds a title pattern to the persistent blocklist. The pattern is
+// a regular expression and is rejected if it does not compile.
+func AddBlock(pattern string) error {
+	if _, err := regexp.Compile(pattern); err != nil {
+		return fmt.Errorf("invalid pattern '%s': %w", pattern, err)
+	}
+	return UpdateConfig(func(c *Config) error {
+		for _, p := range c.Blocklist {
+			if p == pattern {
+				return fmt.Errorf("pattern '%s' is already blocked", pattern)
+			}
+		}
+		c.Blocklist = append(c.Blocklist, pattern)
+		return nil
+	})
+}
+
+// RemoveBlock removes a pattern from the persistent blocklist
+func RemoveBlock(pattern string) error {
+	return UpdateConfig(func(c *Config) error {
+		for i, p := range c.Blocklist {
+			if p == pattern {
+				c.Blocklist = append(c.Blocklist[:i:i], c.Blocklist[i+1:]...)
+				return nil
+			}
+		}
+		return fmt.Errorf("pattern '%s' not found. Use 'tspider config block list' to see patterns", pattern)
+	})
+}
+
+// ListBlocks prints the persistent blocklist
+func ListBlocks() {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Pattern"})
+	for _, p := range GetConfig().Blocklist {
+		table.Append([]string{p})
+	}
+	table.Render()
+}
+
+// CompilePatterns compiles title exclusion patterns, failing on the first
+// invalid one
+func CompilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern '%s': %w", p, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// ExcludeResults drops the results whose title matches any of patterns
+func ExcludeResults(results []SearchResult, patterns []*regexp.Regexp) []SearchResult {
+	if len(patterns) == 0 {
+		return results
+	}
+	kept := results[:0:0]
+	for _, r := range results {
+		if !matchesAny(r.Title, patterns) {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
+
+// matchesAny also tries the displayed form of title, since that is the one
+// users copy patterns from
+func matchesAny(title string, patterns []*regexp.Regexp) bool {
+	for _, re := range patterns {
+		if re.MatchString(title) || re.MatchString(displayTitle(title)) {
+			return true
+		}
+	}
+	return false
+}
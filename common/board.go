@@ -0,0 +1,66 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SiteTypeBoard is the SiteConfig type of the boards searched by the
+// generic board scraper, described by their selectors instead of a scraper
+// of their own
+const SiteTypeBoard = "board"
+
+// Layouts of the board sites, naming the selectors of a family of boards
+// that a board site starts from
+const (
+	BoardLayoutGnuboard = "gnuboard"
+	BoardLayoutTopic    = "topic"
+)
+
+// BoardLayoutNames are the layouts a board site can name
+var BoardLayoutNames = []string{BoardLayoutGnuboard, BoardLayoutTopic}
+
+// KeywordPlaceholder is replaced in the search path of a board site by the
+// escaped keyword
+const KeywordPlaceholder = "{keyword}"
+
+// boardProblem returns why the board site s cannot be searched, or "" when
+// it can: its search path must hold the keyword, and it needs a layout or
+// both a list and a magnet selector
+func (s SiteConfig) boardProblem() string {
+	if !strings.Contains(s.SearchPath, KeywordPlaceholder) {
+		return fmt.Sprintf("search_path %q does not contain %s", s.SearchPath, KeywordPlaceholder)
+	}
+	if s.Layout != "" && !containsString(BoardLayoutNames, s.Layout) {
+		return fmt.Sprintf("layout %q is not one of %v", s.Layout, BoardLayoutNames)
+	}
+	if s.Layout == "" && (s.ListSelector == "" || s.MagnetSelector == "") {
+		return "a board without a layout needs a list_selector and a magnet_selector"
+	}
+	return ""
+}
+
+// AddBoardSite adds the board site, searched by the generic board scraper
+// through its URL, SearchPath, Layout, TitleAttr and selectors, as name
+func AddBoardSite(name string, site SiteConfig) error {
+	site.Type = SiteTypeBoard
+	site.Enabled = true
+	if problem := site.boardProblem(); problem != "" {
+		return fmt.Errorf("site '%s': %s", name, problem)
+	}
+	for _, sel := range []string{site.ListSelector, site.MagnetSelector} {
+		if sel != "" {
+			if err := ValidateSelector(sel); err != nil {
+				return err
+			}
+		}
+	}
+	return UpdateConfig(func(c *Config) error {
+		if _, exists := c.Sites[name]; exists {
+			return fmt.Errorf("site '%s' already exists. Use 'tspider config set-url' to update URL", name)
+		}
+		warnDuplicateURL(c, name, site.URL)
+		c.Sites[name] = site
+		return nil
+	})
+}
@@ -0,0 +1,51 @@
+package common
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+)
+
+// breaker is the circuit breaker of a site during a search: once a request
+// to the site fails for good with a timeout, a connection error, a 5xx or a
+// 429, the requests still to come are skipped instead of each waiting for
+// its own timeout. Pages that are simply missing (other 4xx) do not trip it.
+type breaker struct {
+	open atomic.Bool
+}
+
+type breakerKey struct{}
+
+// withBreaker returns ctx carrying a new breaker for the requests made
+// with it
+func withBreaker(ctx context.Context) (context.Context, *breaker) {
+	b := &breaker{}
+	return context.WithValue(ctx, breakerKey{}, b), b
+}
+
+// breakerFrom returns the breaker carried by ctx, or nil
+func breakerFrom(ctx context.Context) *breaker {
+	b, _ := ctx.Value(breakerKey{}).(*breaker)
+	return b
+}
+
+// tripsBreaker reports whether a request that failed with resp, nil when
+// no response came, says the site is in trouble
+func tripsBreaker(resp *http.Response) bool {
+	return resp == nil || resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+}
+
+// degrade records that the breaker of site tripped during the search
+func (s *Spinner) degrade(site string) {
+	s.mu.Lock()
+	s.degraded = append(s.degraded, site)
+	s.mu.Unlock()
+}
+
+// Degraded returns the sites whose remaining requests were skipped after
+// one failed during the search
+func (s *Spinner) Degraded() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.degraded...)
+}
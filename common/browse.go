@@ -0,0 +1,246 @@
+package common
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// browseHelp lists the commands of a Browser
+const browseHelp = `Commands:
+  n, p       next or previous page
+  /TEXT      show only titles containing TEXT; / alone clears the filter
+  d N        show the details of result N
+  c ROWS     copy the magnets of ROWS to the clipboard, one per line
+  o ROWS     open the magnets of ROWS in their registered application
+             ROWS is a row, or a list and ranges such as 1,3,5-7, or all
+  ?          show this help
+  q          quit
+`
+
+// Browser lets the user page through, filter and act on search results with
+// line commands read from In
+type Browser struct {
+	Results  []SearchResult
+	Columns  []Column
+	In       io.Reader
+	Out      io.Writer
+	PageSize int
+	// Copy and Open act on a magnet; they default to CopyToClipboard and OpenLink
+	Copy func(string) error
+	Open func(string) error
+
+	// copied ends the message of a successful Copy, see CopyResult
+	copied string
+	filter string
+	shown  []SearchResult
+	page   int
+}
+
+// Run shows the first page and executes commands until q or the end of In
+func (b *Browser) Run() error {
+	if b.PageSize <= 0 {
+		b.PageSize = 20
+	}
+	b.copied = "copied to the clipboard"
+	if b.Copy == nil {
+		b.Copy = CopyToClipboard
+		b.copied = CopyResult()
+	}
+	if b.Open == nil {
+		b.Open = OpenLink
+	}
+	b.applyFilter("")
+	b.printPage()
+	sc := bufio.NewScanner(b.In)
+	for {
+		fmt.Fprint(b.Out, "> ")
+		if !sc.Scan() {
+			fmt.Fprintln(b.Out)
+			return sc.Err()
+		}
+		if quit := b.execute(strings.TrimSpace(sc.Text())); quit {
+			return nil
+		}
+	}
+}
+
+// execute runs one command and reports whether it was q
+func (b *Browser) execute(line string) bool {
+	switch {
+	case line == "":
+	case line == "q":
+		return true
+	case line == "?":
+		fmt.Fprint(b.Out, browseHelp)
+	case line == "n":
+		if (b.page+1)*b.PageSize < len(b.shown) {
+			b.page++
+		}
+		b.printPage()
+	case line == "p":
+		if b.page > 0 {
+			b.page--
+		}
+		b.printPage()
+	case strings.HasPrefix(line, "/"):
+		b.applyFilter(strings.TrimSpace(line[1:]))
+		b.printPage()
+	default:
+		cmd, arg, _ := strings.Cut(line, " ")
+		if cmd != "d" && cmd != "c" && cmd != "o" {
+			fmt.Fprintf(b.Out, "[!] unknown command %q, ? for help\n", line)
+			break
+		}
+		if cmd == "d" {
+			r, err := b.result(arg)
+			if err != nil {
+				fmt.Fprintf(b.Out, "[!] %v\n", err)
+				break
+			}
+			printDetails(b.Out, r)
+			break
+		}
+		rows, err := ParseSelection(arg, len(b.shown))
+		if err != nil {
+			fmt.Fprintf(b.Out, "[!] %v\n", err)
+			break
+		}
+		if cmd == "c" {
+			b.copyRows(rows)
+		} else {
+			b.openRows(rows)
+		}
+	}
+	return false
+}
+
+// copyRows copies the magnets of the rows at indices to the clipboard, one
+// per line, and reports each row left out
+func (b *Browser) copyRows(indices []int) {
+	var magnets []string
+	for _, i := range indices {
+		r := b.shown[i]
+		if !IsValidMagnet(r.Magnet) {
+			fmt.Fprintf(b.Out, "[!] %d %s: no magnet\n", i+1, r.Title)
+			continue
+		}
+		magnets = append(magnets, r.Magnet)
+	}
+	if len(magnets) == 0 {
+		fmt.Fprintln(b.Out, "[!] Nothing copied")
+		return
+	}
+	if err := b.Copy(strings.Join(magnets, "\n")); err != nil {
+		fmt.Fprintf(b.Out, "[!] %v\n", err)
+		return
+	}
+	if len(indices) == 1 {
+		fmt.Fprintf(b.Out, "[+] Magnet %s\n", b.copied)
+		return
+	}
+	fmt.Fprintf(b.Out, "[+] %d of %d magnets %s\n", len(magnets), len(indices), b.copied)
+}
+
+// openRows opens the magnets of the rows at indices one by one, reporting
+// how each went
+func (b *Browser) openRows(indices []int) {
+	if len(indices) == 1 {
+		if err := b.Open(b.shown[indices[0]].Magnet); err != nil {
+			fmt.Fprintf(b.Out, "[!] %v\n", err)
+			return
+		}
+		fmt.Fprintln(b.Out, "[+] Magnet opened")
+		return
+	}
+	opened := 0
+	for _, i := range indices {
+		r := b.shown[i]
+		if err := b.Open(r.Magnet); err != nil {
+			fmt.Fprintf(b.Out, "[!] %d %s: %v\n", i+1, r.Title, err)
+			continue
+		}
+		opened++
+		fmt.Fprintf(b.Out, "[+] %d %s: opened\n", i+1, r.Title)
+	}
+	fmt.Fprintf(b.Out, "[+] %d of %d magnets opened\n", opened, len(indices))
+}
+
+// result returns the result numbered arg in the current list
+func (b *Browser) result(arg string) (SearchResult, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(arg))
+	if err != nil || n < 1 || n > len(b.shown) {
+		return SearchResult{}, fmt.Errorf("no result %q; pick a number from 1 to %d", strings.TrimSpace(arg), len(b.shown))
+	}
+	return b.shown[n-1], nil
+}
+
+// applyFilter keeps the results whose title contains filter, ignoring case,
+// and goes back to the first page
+func (b *Browser) applyFilter(filter string) {
+	b.filter = filter
+	b.page = 0
+	b.shown = b.shown[:0]
+	for _, r := range b.Results {
+		if strings.Contains(strings.ToLower(r.Title), strings.ToLower(filter)) {
+			b.shown = append(b.shown, r)
+		}
+	}
+}
+
+// printPage prints the current page as a table numbered across pages
+func (b *Browser) printPage() {
+	if len(b.shown) == 0 {
+		fmt.Fprintf(b.Out, "[!] No result matches %q\n", b.filter)
+		return
+	}
+	first := b.page * b.PageSize
+	last := first + b.PageSize
+	if last > len(b.shown) {
+		last = len(b.shown)
+	}
+	table := tablewriter.NewWriter(b.Out)
+	headers := []string{"#"}
+	for _, col := range b.Columns {
+		headers = append(headers, col.Header)
+	}
+	table.SetHeader(headers)
+	for i, r := range b.shown[first:last] {
+		row := []string{strconv.Itoa(first + i + 1)}
+		for _, col := range b.Columns {
+			row = append(row, col.Value(r))
+		}
+		table.Append(row)
+	}
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.Render()
+	pages := (len(b.shown) + b.PageSize - 1) / b.PageSize
+	fmt.Fprintf(b.Out, "Page %d/%d, %d result(s). ? for help\n", b.page+1, pages, len(b.shown))
+}
+
+// printDetails prints every known field of r
+func printDetails(w io.Writer, r SearchResult) {
+	fmt.Fprintf(w, "Title:    %s\n", r.Title)
+	fmt.Fprintf(w, "Site:     %s\n", r.Site)
+	fmt.Fprintf(w, "Magnet:   %s\n", r.Magnet)
+	if r.Uploader != "" {
+		fmt.Fprintf(w, "Uploader: %s\n", r.Uploader)
+	}
+	if r.Category != "" {
+		fmt.Fprintf(w, "Category: %s\n", r.Category)
+	}
+	if r.Size != "" {
+		fmt.Fprintf(w, "Size:     %s\n", r.Size)
+		fmt.Fprintf(w, "Peers:    %d seeders, %d leechers, %d snatches\n", r.Seeders, r.Leechers, r.Snatches)
+	}
+	if !r.Date.IsZero() {
+		fmt.Fprintf(w, "Date:     %s\n", r.Date.Format("2006-01-02 15:04"))
+	}
+	if r.DetailURL != "" {
+		fmt.Fprintf(w, "Page:     %s\n", r.DetailURL)
+	}
+}
@@ -0,0 +1,49 @@
+package common
+
+import (
+	"context"
+	"time"
+)
+
+// SiteBudget returns the soft time budget of site, set by its
+// timeout_seconds in the config, or 0 when it has none
+func SiteBudget(site string) time.Duration {
+	return time.Duration(GetConfig().Sites[site].Timeout * float64(time.Second))
+}
+
+// crawlWithin runs crawl and waits for it at most until ctx is done or the
+// budget of site runs out. It returns false if crawl did not finish in time;
+// crawl, whose context is then done, keeps running in the background until
+// it notices and its outcome is ignored.
+func crawlWithin(ctx context.Context, site string, crawl func(ctx context.Context)) bool {
+	if budget := SiteBudget(site); budget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, budget)
+		defer cancel()
+	}
+	done := make(chan struct{})
+	go func() {
+		crawl(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// abandon records that site was given up on after its budget
+func (s *Spinner) abandon(site string) {
+	s.mu.Lock()
+	s.abandoned = append(s.abandoned, site)
+	s.mu.Unlock()
+}
+
+// Abandoned returns the sites given up on because they exceeded their budget
+func (s *Spinner) Abandoned() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.abandoned...)
+}
@@ -0,0 +1,98 @@
+package common
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// certWarnDays is how many days before its certificate expires a site is
+// warned about; mirrors about to be abandoned often let it lapse
+const certWarnDays = 14
+
+// checkCertificate fills the certificate fields of status from the
+// response of an https site, or from the error of a request whose
+// certificate was rejected
+func checkCertificate(status *SiteStatus, resp *http.Response, err error, now time.Time) {
+	var cert *x509.Certificate
+	var hostErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	var authorityErr x509.UnknownAuthorityError
+	switch {
+	case err == nil && resp != nil && resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0:
+		cert = resp.TLS.PeerCertificates[0]
+	case errors.As(err, &hostErr):
+		cert = hostErr.Certificate
+		status.CertWarning = fmt.Sprintf("certificate is for %s, not %s", certNames(cert), hostErr.Host)
+	case errors.As(err, &invalidErr):
+		cert = invalidErr.Cert
+		if invalidErr.Reason != x509.Expired {
+			status.CertWarning = "invalid certificate: " + invalidErr.Error()
+		}
+	case errors.As(err, &authorityErr):
+		cert = authorityErr.Cert
+		status.CertWarning = "certificate signed by an unknown authority"
+	}
+	if cert == nil {
+		return
+	}
+	status.CertIssuer = certIssuer(cert)
+	days := int(cert.NotAfter.Sub(now).Hours() / 24)
+	status.CertDaysLeft = &days
+	if status.CertWarning != "" {
+		return
+	}
+	switch {
+	case now.After(cert.NotAfter):
+		status.CertWarning = fmt.Sprintf("certificate expired on %s", cert.NotAfter.Format("2006-01-02"))
+	case days < certWarnDays:
+		status.CertWarning = fmt.Sprintf("certificate expires in %d days", days)
+	}
+}
+
+// printCertWarnings lists the sites of statuses with a certificate warning
+func printCertWarnings(w io.Writer, statuses []SiteStatus) {
+	header := false
+	for _, s := range statuses {
+		if s.CertWarning == "" {
+			continue
+		}
+		if !header {
+			fmt.Fprintln(w, "\nCertificate warnings:")
+			header = true
+		}
+		issuer := ""
+		if s.CertIssuer != "" {
+			issuer = " (issued by " + s.CertIssuer + ")"
+		}
+		fmt.Fprintf(w, "  %s: %s%s\n", s.Name, s.CertWarning, issuer)
+	}
+}
+
+// certIssuer names the issuer of cert by its organization and common name
+func certIssuer(cert *x509.Certificate) string {
+	issuer := cert.Issuer.CommonName
+	if len(cert.Issuer.Organization) > 0 && cert.Issuer.Organization[0] != issuer {
+		issuer = strings.TrimSpace(cert.Issuer.Organization[0] + " " + issuer)
+	}
+	return issuer
+}
+
+// certNames lists the host names cert is valid for
+func certNames(cert *x509.Certificate) string {
+	if cert == nil {
+		return "another host"
+	}
+	names := cert.DNSNames
+	if len(names) == 0 && cert.Subject.CommonName != "" {
+		names = []string{cert.Subject.CommonName}
+	}
+	if len(names) > 3 {
+		names = append(names[:3:3], "...")
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,70 @@
+package common
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// clipboardCommands are the commands tried in order to copy text, which they
+// read from stdin
+func clipboardCommands() [][]string {
+	switch runtime.GOOS {
+	case "darwin":
+		return [][]string{{"pbcopy"}}
+	case "windows":
+		return [][]string{{"clip"}}
+	}
+	return [][]string{{"wl-copy"}, {"xclip", "-selection", "clipboard"}, {"xsel", "--clipboard", "--input"}}
+}
+
+// OSC52 returns the terminal escape sequence that asks the terminal to put
+// text on the clipboard. Most terminals honor it, also over SSH.
+func OSC52(text string) string {
+	return "\033]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\a"
+}
+
+// clipboardCommand returns the path and arguments of the first clipboard
+// command found, or "" without one
+func clipboardCommand() (string, []string) {
+	for _, args := range clipboardCommands() {
+		if path, err := exec.LookPath(args[0]); err == nil {
+			return path, args
+		}
+	}
+	return "", nil
+}
+
+// CopyToClipboard copies text to the system clipboard with the first
+// clipboard command found: pbcopy on macOS, clip on Windows, and wl-copy,
+// xclip or xsel elsewhere. Without one, it falls back to OSC52 when stderr
+// is a terminal, which cannot tell whether the terminal complied.
+func CopyToClipboard(text string) error {
+	if path, args := clipboardCommand(); path != "" {
+		cmd := exec.Command(path, args[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("%s: %w", args[0], err)
+		}
+		return nil
+	}
+	if IsTerminal(os.Stderr) {
+		_, err := io.WriteString(os.Stderr, OSC52(text))
+		return err
+	}
+	return fmt.Errorf("no clipboard command found (install wl-copy, xclip or xsel)")
+}
+
+// CopyResult says what a successful CopyToClipboard did: "copied to the
+// clipboard", or "sent to the terminal" when it fell back to OSC52, as the
+// terminal may have ignored it
+func CopyResult() string {
+	if path, _ := clipboardCommand(); path == "" {
+		return "sent to the terminal"
+	}
+	return "copied to the clipboard"
+}
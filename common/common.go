@@ -1,35 +1,58 @@
 package common
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"io"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/mattn/go-runewidth"
 	"github.com/olekukonko/tablewriter"
 )
 
 // Spinner for progress animation
 type Spinner struct {
-	frames  []string
-	current int
-	message string
-	start   time.Time
-	total   int32
-	done    int32
-	stop    chan struct{}
-	stopped chan struct{}
-	mu      sync.Mutex
+	frames   []string
+	current  int
+	message  string
+	start    time.Time
+	total    int32
+	done     int32
+	stop     chan struct{}
+	stopped  chan struct{}
+	mu       sync.Mutex
+	warnings []string
+	// abandoned lists the sites that exceeded their budget
+	abandoned []string
+	// degraded lists the sites whose breaker tripped
+	degraded []string
+	// out serializes writes to w so results printed with PrintAbove never
+	// interleave with a repaint; status is the last line painted
+	out    sync.Mutex
+	w      io.Writer
+	status string
+	// onSite is called under sites, so that once ended is set by Stop no
+	// call is in progress and none follows
+	sites  sync.Mutex
+	ended  bool
+	onSite func(site string, results []SearchResult)
+	onStep func(site string, done, total int)
 }
 
+// SpinnerOutput is where new spinners draw; machine-readable output formats
+// point it at stderr to keep stdout clean
+var SpinnerOutput io.Writer = os.Stdout
+
 // NewSpinner creates a new spinner
 func NewSpinner(message string) *Spinner {
 	return &Spinner{
@@ -38,9 +61,72 @@ func NewSpinner(message string) *Spinner {
 		start:   time.Now(),
 		stop:    make(chan struct{}),
 		stopped: make(chan struct{}),
+		w:       SpinnerOutput,
+	}
+}
+
+// SetOutput makes the spinner draw to w instead of stdout. Call it before Start.
+func (s *Spinner) SetOutput(w io.Writer) {
+	s.w = w
+}
+
+// OnResults registers fn to be called with each site's results as soon as
+// that site finishes, while collection is still running. Calls are
+// serialized, and none is made once the spinner stops.
+func (s *Spinner) OnResults(fn func(site string, results []SearchResult)) {
+	s.sites.Lock()
+	s.onSite = fn
+	s.sites.Unlock()
+}
+
+func (s *Spinner) siteDone(site string, results []SearchResult) {
+	s.sites.Lock()
+	defer s.sites.Unlock()
+	if s.onSite != nil && !s.ended {
+		s.onSite(site, results)
 	}
 }
 
+// endResults waits for a call of the OnResults function in progress and
+// prevents any later one
+func (s *Spinner) endResults() {
+	s.sites.Lock()
+	s.ended = true
+	s.sites.Unlock()
+}
+
+// OnProgress registers fn to be called as each site's search finishes,
+// whether it found results, failed or was abandoned, with the number of
+// sites finished so far and in total
+func (s *Spinner) OnProgress(fn func(site string, done, total int)) {
+	s.mu.Lock()
+	s.onStep = fn
+	s.mu.Unlock()
+}
+
+// siteFinished counts site as finished and reports the progress
+func (s *Spinner) siteFinished(site string) {
+	done := atomic.AddInt32(&s.done, 1)
+	s.mu.Lock()
+	fn := s.onStep
+	s.mu.Unlock()
+	if fn != nil {
+		fn(site, int(done), int(atomic.LoadInt32(&s.total)))
+	}
+}
+
+// PrintAbove prints text above the spinner line: the status line is erased,
+// text is written, and the status is repainted below it. Safe to call from
+// any goroutine while the spinner runs.
+func (s *Spinner) PrintAbove(text string) {
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	s.out.Lock()
+	defer s.out.Unlock()
+	fmt.Fprint(s.w, "\r\033[K"+text+s.status)
+}
+
 // SetTotal sets the total number of tasks
 func (s *Spinner) SetTotal(total int) {
 	atomic.StoreInt32(&s.total, int32(total))
@@ -58,6 +144,29 @@ func (s *Spinner) UpdateMessage(msg string) {
 	s.mu.Unlock()
 }
 
+// Warn queues a warning to be printed once the spinner stops
+func (s *Spinner) Warn(msg string) {
+	s.mu.Lock()
+	s.warnings = append(s.warnings, msg)
+	s.mu.Unlock()
+}
+
+// Warnings returns the queued warnings
+func (s *Spinner) Warnings() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.warnings...)
+}
+
+func (s *Spinner) printWarnings() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, w := range s.warnings {
+		fmt.Fprintf(os.Stderr, "[!] %s\n", w)
+	}
+	s.warnings = nil
+}
+
 // Start begins the spinner animation
 func (s *Spinner) Start() {
 	go func() {
@@ -106,23 +215,36 @@ func (s *Spinner) render() {
 			frame, msg, elapsedStr)
 	}
 
-	fmt.Print(status)
+	s.out.Lock()
+	s.status = status
+	fmt.Fprint(s.w, status)
+	s.out.Unlock()
 }
 
 // Stop stops the spinner
 func (s *Spinner) Stop() {
+	s.endResults()
 	close(s.stop)
 	<-s.stopped
 	// Clear line
-	fmt.Print("\r                                                              \r")
+	s.out.Lock()
+	s.status = ""
+	fmt.Fprint(s.w, "\r                                                              \r")
+	s.out.Unlock()
+	s.printWarnings()
 }
 
 // StopWithMessage stops and prints final message
 func (s *Spinner) StopWithMessage(msg string) {
+	s.endResults()
 	close(s.stop)
 	<-s.stopped
 	elapsed := formatDuration(time.Since(s.start))
-	fmt.Printf("\r✓ %s (%s)                                    \n", msg, elapsed)
+	s.out.Lock()
+	s.status = ""
+	fmt.Fprintf(s.w, "\r✓ %s (%s)                                    \n", msg, elapsed)
+	s.out.Unlock()
+	s.printWarnings()
 }
 
 func formatDuration(d time.Duration) string {
@@ -135,39 +257,253 @@ func formatDuration(d time.Duration) string {
 	return fmt.Sprintf("%dm%ds", int(d.Minutes()), int(d.Seconds())%60)
 }
 
-// Scraping interface is for web scraping
-type Scraping interface {
-	Crawl(string) map[string]string
+// Scraper interface is for web scraping: Crawl returns the torrents a site
+// lists for a keyword, or nil when the site could not be searched
+type Scraper interface {
+	Crawl(ctx context.Context, keyword string) []SearchResult
 }
 
-// ScrapingEx interface is for web scraping
-type ScrapingEx interface {
-	Crawl(string) map[string][]string
+// Suggester is implemented by scrapers whose site offers search
+// suggestions; Suggest returns the completions the site lists for prefix
+type Suggester interface {
+	Suggest(ctx context.Context, prefix string) ([]string, error)
 }
 
 // SiteConfig holds configuration for a single torrent site
 type SiteConfig struct {
-	URL      string `json:"url"`
-	Enabled  bool   `json:"enabled"`
-	Language string `json:"language"` // "kr" or "jp"
+	URL string `json:"url"`
+	// URLs are mirrors of the site, tried in order when URL is down
+	URLs []string `json:"urls,omitempty"`
+	// Hubs are pages that redirect to the current address of the site or
+	// link to it, used by doctor --discover when the site moves
+	Hubs []string `json:"hubs,omitempty"`
+	// ExpectSelector and ExpectText are a CSS selector and a text the
+	// site's page must show to be up, so that a parked domain answering
+	// 200 is not taken for the site
+	ExpectSelector string `json:"expect_selector,omitempty"`
+	ExpectText     string `json:"expect_text,omitempty"`
+	Enabled        bool   `json:"enabled"`
+	Language       string `json:"language"` // one of Languages
+	// ListSelector and MagnetSelector override the scraper's built-in CSS
+	// selectors until a broken one is fixed in a release
+	ListSelector   string `json:"list_selector,omitempty"`
+	MagnetSelector string `json:"magnet_selector,omitempty"`
+	// Timeout is a soft budget in seconds: a search gives up on the site
+	// when it has not finished by then and goes on with the others
+	Timeout float64 `json:"timeout_seconds,omitempty"`
+	// Type is empty for the sites tspider scrapes and SiteTypeTorznab for a
+	// Torznab endpoint, such as a Jackett or Prowlarr indexer, searched
+	// through its API with APIKey. SiteTypeBoard is a board searched by the
+	// generic board scraper at SearchPath, with the selectors of Layout
+	// overridden by ListSelector, MagnetSelector and TitleAttr.
+	Type   string `json:"type,omitempty"`
+	APIKey string `json:"api_key,omitempty"`
+	// SearchPath is the path of a board's search page, relative to URL,
+	// with KeywordPlaceholder where the keyword goes
+	SearchPath string `json:"search_path,omitempty"`
+	// Layout names the family of a board, one of BoardLayoutNames, whose
+	// selectors it uses by default
+	Layout string `json:"layout,omitempty"`
+	// TitleAttr is the attribute of a board's result links holding their
+	// title, when it is not their text
+	TitleAttr string `json:"title_attr,omitempty"`
+	// RenderJS fetches the site's pages with a headless browser, for sites
+	// that build their results with JavaScript. Without a browser the plain
+	// HTTP client is used.
+	RenderJS bool `json:"render_js,omitempty"`
+	// Username and Password log in to a site that needs an account before
+	// it can be searched, by posting them to LoginURL as the form fields
+	// UserField and PasswordField (default "username" and "password").
+	// Cookie, a "name=value; ..." string copied from a browser session, is
+	// sent instead when set. See LoginSite.
+	Username      string `json:"username,omitempty"`
+	Password      string `json:"password,omitempty"`
+	LoginURL      string `json:"login_url,omitempty"`
+	UserField     string `json:"user_field,omitempty"`
+	PasswordField string `json:"password_field,omitempty"`
+	Cookie        string `json:"cookie,omitempty"`
+	// Headers are set on every request to the site, replacing tspider's
+	// own, such as {"Referer": "https://example.com/"}
+	Headers map[string]string `json:"headers,omitempty"`
+	// TLSFingerprint set to TLSFingerprintChrome makes the TLS handshake
+	// with the site send the ClientHello of Chrome, for mirrors that block
+	// the handshake of Go clients. It has no effect through a proxy.
+	TLSFingerprint string `json:"tls_fingerprint,omitempty"`
+}
+
+// TLSFingerprintChrome is the SiteConfig TLS fingerprint of Chrome
+const TLSFingerprintChrome = "chrome"
+
+// Mirrors returns the URL of the site followed by its other mirrors
+func (s SiteConfig) Mirrors() []string {
+	mirrors := []string{s.URL}
+	for _, u := range s.URLs {
+		if u != "" && !containsString(mirrors, u) {
+			mirrors = append(mirrors, u)
+		}
+	}
+	return mirrors
+}
+
+// servesHost reports whether host is the host of one of the site's mirrors
+func (s SiteConfig) servesHost(host string) bool {
+	for _, m := range s.Mirrors() {
+		if u, err := url.Parse(m); err == nil && strings.EqualFold(u.Host, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// SiteTypeTorznab is the SiteConfig type of Torznab endpoints
+const SiteTypeTorznab = "torznab"
+
+// Prober is implemented by scrapers whose site is not checked by fetching
+// its URL, such as APIs that need a key; ProbeURL returns the URL to fetch
+type Prober interface {
+	ProbeURL() string
+}
+
+// ClientConfig locates a torrent client; fields the client does not use
+// are ignored
+type ClientConfig struct {
+	URL      string `json:"url,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// Secret is the RPC token of clients that authenticate with one (aria2)
+	Secret string `json:"secret,omitempty"`
+}
+
+// NotifierConfig configures a notifier; fields its type does not use are
+// ignored
+type NotifierConfig struct {
+	// Type is the kind of notifier, such as "webhook"
+	Type string `json:"type"`
+	URL  string `json:"url,omitempty"`
+	// Headers are added to webhook requests, e.g. for an Authorization token
+	Headers map[string]string `json:"headers,omitempty"`
+	// CopyOnClick makes clicking a desktop notification copy its magnets
+	CopyOnClick bool `json:"copy_on_click,omitempty"`
+	// SMTPHost is the host:port of the mail server of email notifiers,
+	// which log in with Username and Password when set
+	SMTPHost string   `json:"smtp_host,omitempty"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from,omitempty"`
+	To       []string `json:"to,omitempty"`
+	// Digest is a schedule, such as @daily (see ParseCron), on which an
+	// email notifier sends the results collected since its last email;
+	// without it each notification is mailed at once
+	Digest string `json:"digest,omitempty"`
 }
 
 // Config holds the application configuration
 type Config struct {
-	Sites     map[string]SiteConfig `json:"sites"`
-	UserAgent string                `json:"user_agent"`
-	Timeout   int                   `json:"timeout_seconds"`
+	// Version is the schema version, see ConfigVersion
+	Version  int                   `json:"version,omitempty"`
+	Sites    map[string]SiteConfig `json:"sites"`
+	Profiles map[string][]string   `json:"profiles,omitempty"`
+	// Schedules are the searches tspider daemon runs, by name
+	Schedules map[string]Schedule `json:"schedules,omitempty"`
+	// Blocklist holds title regexes excluded from every search
+	Blocklist []string `json:"blocklist,omitempty"`
+	UserAgent string   `json:"user_agent"`
+	// UserAgents is a pool of User-Agent strings requests rotate through,
+	// in turn or, with UserAgentRotation "random", at random; UserAgent is
+	// used when it is empty
+	UserAgents        []string `json:"user_agents,omitempty"`
+	UserAgentRotation string   `json:"user_agent_rotation,omitempty"`
+	Timeout           int      `json:"timeout_seconds"`
+	// MaxTotalConns caps open connections across all sites (default 32)
+	MaxTotalConns int `json:"max_total_conns,omitempty"`
+	// MaxConnsPerHost caps open connections to a single site (default 8)
+	MaxConnsPerHost int `json:"max_conns_per_host,omitempty"`
+	// DetailConcurrency caps the detail pages fetched at once across all
+	// scrapers (default 16)
+	DetailConcurrency int `json:"detail_concurrency,omitempty"`
+	// MaxBodyMB caps the size of a page read from a site, in megabytes
+	// (default 5, negative to disable)
+	MaxBodyMB int `json:"max_body_mb,omitempty"`
+	// MagnetFailThreshold is the share of a site's results without a usable
+	// magnet above which a broken-selector warning is shown (default 0.8)
+	MagnetFailThreshold float64 `json:"magnet_fail_threshold,omitempty"`
+	// MaxRetries is how many times a page request failing with a timeout,
+	// 5xx or 429 is retried (default 0, none)
+	MaxRetries int `json:"max_retries,omitempty"`
+	// RetryDelay is the wait in milliseconds before the first retry, which
+	// doubles with every further retry (default 500)
+	RetryDelay int `json:"retry_delay_ms,omitempty"`
+	// RetryJitter randomizes each wait by up to this share of it, so that
+	// requests failing together are not retried together (default 0.2,
+	// negative to disable)
+	RetryJitter float64 `json:"retry_jitter,omitempty"`
+	// AvailabilityTTL is how many seconds a site up/down probe is reused
+	// by later searches (default 60)
+	AvailabilityTTL int `json:"availability_ttl_seconds,omitempty"`
+	// SnoozeAfter is how many availability checks in a row a site fails
+	// before searches skip it for SnoozeHours (defaults 3 and 24; negative
+	// SnoozeAfter never snoozes)
+	SnoozeAfter int `json:"snooze_after_failures,omitempty"`
+	SnoozeHours int `json:"snooze_hours,omitempty"`
+	// MaxSites caps how many sites a search queries, keeping the healthiest
+	// ones by their recorded checks (see RankSites); 0 queries all of them
+	MaxSites int `json:"max_sites_per_search,omitempty"`
+	// CrawlTTL is how many seconds the results of a search are reused by
+	// identical searches, whatever their output format (default 300)
+	CrawlTTL int `json:"crawl_cache_ttl_seconds,omitempty"`
+	// HTTPCacheDays is how many days pages served with an ETag or
+	// Last-Modified header are kept on disk for revalidation after their
+	// last use (default 14, negative to disable)
+	HTTPCacheDays int `json:"http_cache_days,omitempty"`
+	// HistoryMaxEntries caps how many searches the history keeps, dropping
+	// the oldest ones with their recorded results (default 500, negative to
+	// keep them all)
+	HistoryMaxEntries int `json:"history_max_entries,omitempty"`
+	// FaviconDays is how many days a site favicon fetched for --html reports
+	// is kept on disk before it is fetched again (default 30)
+	FaviconDays int `json:"favicon_cache_days,omitempty"`
+	// SizeBuckets are the bounds of the --group-by-size buckets, such as
+	// ["500MiB", "2GiB"]
+	SizeBuckets []string `json:"size_buckets,omitempty"`
+	// HealthKeyword is searched by doctor --deep to check that scraping
+	// works; HealthKeywords overrides it per language ("kr", "jp", "en")
+	HealthKeyword  string            `json:"health_keyword,omitempty"`
+	HealthKeywords map[string]string `json:"health_keywords,omitempty"`
+	// Proxy is a proxy URL (http://, https:// or socks5://) for all requests,
+	// overriding the *_PROXY environment variables
+	Proxy string `json:"proxy,omitempty"`
+	// TorSocksAddr, TorControlAddr and TorControlPassword locate the Tor
+	// daemon used by --tor (defaults 127.0.0.1:9050 and 127.0.0.1:9051)
+	TorSocksAddr       string `json:"tor_socks_addr,omitempty"`
+	TorControlAddr     string `json:"tor_control_addr,omitempty"`
+	TorControlPassword string `json:"tor_control_password,omitempty"`
+	// TorIsolateSites gives each site its own SOCKS credentials, which Tor
+	// (IsolateSOCKSAuth, on by default) routes over separate circuits, so
+	// every site sees a different exit
+	TorIsolateSites bool `json:"tor_isolate_sites,omitempty"`
+	// BrowserPath is the Chrome or Chromium executable fetching the pages of
+	// render_js sites (default: the first found on PATH)
+	BrowserPath string `json:"browser_path,omitempty"`
+	// BrowserNoSandbox runs the browser without its sandbox, which Chrome
+	// needs when run as root, as in most containers. It is off by default:
+	// the sandbox is what contains a compromised renderer.
+	BrowserNoSandbox bool `json:"browser_no_sandbox,omitempty"`
+	// Clients configures the torrent clients used by send, keyed by client
+	// name (qbittorrent, transmission, aria2)
+	Clients map[string]ClientConfig `json:"clients,omitempty"`
+	// Notifiers are told about the new results of daemon and watch
+	// searches, keyed by a name of your choice
+	Notifiers map[string]NotifierConfig `json:"notifiers,omitempty"`
 }
 
 var (
-	// TorrentURL is the map of site names to URLs (loaded from config)
-	TorrentURL = map[string]string{}
 	// UserAgent for HTTP requests
 	UserAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
-	// config holds the loaded configuration
-	config     *Config
-	configOnce sync.Once
-	configPath string
+	// config holds the loaded configuration, swapped under configValueMu
+	config        *Config
+	configValueMu sync.RWMutex
+	configOnce    sync.Once
+	configPath    string
 )
 
 // GetConfigPath returns the config file path
@@ -177,7 +513,7 @@ func GetConfigPath() string {
 	}
 	home, err := os.UserHomeDir()
 	if err != nil {
-		return "angel.json"
+		return ".tspider.json"
 	}
 	return filepath.Join(home, ".tspider.json")
 }
@@ -185,6 +521,7 @@ func GetConfigPath() string {
 // DefaultConfig returns the default configuration
 func DefaultConfig() *Config {
 	return &Config{
+		Version: ConfigVersion,
 		Sites: map[string]SiteConfig{
 			// Korean sites
 			"torrenttop":    {URL: "https://torrenttop152.com", Enabled: true, Language: "kr"},
@@ -205,115 +542,228 @@ func DefaultConfig() *Config {
 			"torrentview":   {URL: "https://torrentview.com", Enabled: false, Language: "kr"},
 			"ttobogo":       {URL: "https://ttobogo.com", Enabled: false, Language: "kr"},
 			// Japanese sites
-			"nyaa":   {URL: "https://nyaa.si", Enabled: true, Language: "jp"},
-			"sukebe": {URL: "https://sukebei.nyaa.si", Enabled: true, Language: "jp"},
+			"anidex":     {URL: "https://anidex.info", Enabled: true, Language: "jp"},
+			"nyaa":       {URL: "https://nyaa.si", Enabled: true, Language: "jp"},
+			"sukebe":     {URL: "https://sukebei.nyaa.si", Enabled: true, Language: "jp"},
+			"tokyotosho": {URL: "https://www.tokyotosho.info", Enabled: true, Language: "jp"},
+			// English sites
+			"1337x": {URL: "https://1337x.to", Enabled: true, Language: "en"},
 		},
 		UserAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
 		Timeout:   10,
 	}
 }
 
-// LoadConfig loads the configuration from file or creates default
+// LoadConfig loads the configuration from file or creates default. It runs
+// once, on first use rather than when the package is initialized, so that
+// HOME can be set before the config file is located.
 func LoadConfig() *Config {
 	configOnce.Do(func() {
 		path := GetConfigPath()
 		data, err := os.ReadFile(path)
 		if err != nil {
 			// File doesn't exist, create default
-			config = DefaultConfig()
-			SaveConfig(config)
+			c := DefaultConfig()
+			setConfig(c)
+			SaveConfig(c)
 			return
 		}
-		config = &Config{}
-		if err := json.Unmarshal(data, config); err != nil {
-			config = DefaultConfig()
-		}
-		// Update TorrentURL map
-		for name, site := range config.Sites {
-			if site.Enabled {
-				TorrentURL[name] = site.URL
-			}
+		c := &Config{}
+		if err := json.Unmarshal(data, c); err != nil {
+			c = DefaultConfig()
 		}
-		UserAgent = config.UserAgent
+		setConfig(c)
+		setSiteURLs(enabledSiteURLs(c))
+		UserAgent = c.UserAgent
 	})
-	return config
+	return currentConfig()
 }
 
-// SaveConfig saves the configuration to file
+// SaveConfig saves the configuration to file, replacing it atomically.
+// The file holds passwords, so only the user may read it. Changes to the
+// config file go through UpdateConfig, which locks it.
 func SaveConfig(c *Config) error {
 	data, err := json.MarshalIndent(c, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 	path := GetConfigPath()
-	if err := os.WriteFile(path, data, 0644); err != nil {
+	if err := writeFileAtomicMode(path, data, 0600); err != nil {
 		return fmt.Errorf("failed to write config: %w", err)
 	}
-	config = c
-	// Update TorrentURL map
-	TorrentURL = make(map[string]string)
-	for name, site := range c.Sites {
-		if site.Enabled {
-			TorrentURL[name] = site.URL
-		}
-	}
+	setConfig(c)
+	setSiteURLs(enabledSiteURLs(c))
 	return nil
 }
 
 // GetConfig returns the current configuration
 func GetConfig() *Config {
-	if config == nil {
-		LoadConfig()
+	if c := currentConfig(); c != nil {
+		return c
 	}
+	return LoadConfig()
+}
+
+// currentConfig returns the loaded configuration, nil before LoadConfig
+func currentConfig() *Config {
+	configValueMu.RLock()
+	defer configValueMu.RUnlock()
 	return config
 }
 
+// setConfig makes c the current configuration
+func setConfig(c *Config) {
+	configValueMu.Lock()
+	config = c
+	configValueMu.Unlock()
+}
+
 // SetSiteURL updates a site's URL
 func SetSiteURL(name, url string) error {
-	c := GetConfig()
-	site, exists := c.Sites[name]
-	if !exists {
-		return fmt.Errorf("site '%s' not found. Use 'angel config add' to add new sites", name)
-	}
-	site.URL = url
-	c.Sites[name] = site
-	return SaveConfig(c)
+	return UpdateConfig(func(c *Config) error {
+		site, exists := c.Sites[name]
+		if !exists {
+			return fmt.Errorf("site '%s' not found. Use 'tspider config add' to add new sites", name)
+		}
+		warnDuplicateURL(c, name, url)
+		site.URL = url
+		c.Sites[name] = site
+		return nil
+	})
 }
 
 // AddSite adds a new site configuration
 func AddSite(name, url, language string) error {
-	c := GetConfig()
-	if _, exists := c.Sites[name]; exists {
-		return fmt.Errorf("site '%s' already exists. Use 'angel config set-url' to update URL", name)
-	}
-	c.Sites[name] = SiteConfig{
-		URL:      url,
-		Enabled:  true,
-		Language: language,
-	}
-	return SaveConfig(c)
+	return UpdateConfig(func(c *Config) error {
+		if _, exists := c.Sites[name]; exists {
+			return fmt.Errorf("site '%s' already exists. Use 'tspider config set-url' to update URL", name)
+		}
+		warnDuplicateURL(c, name, url)
+		c.Sites[name] = SiteConfig{
+			URL:      url,
+			Enabled:  true,
+			Language: language,
+		}
+		return nil
+	})
+}
+
+// AddTorznabSite adds the Torznab endpoint at url, authenticated with
+// apiKey, as the site name
+func AddTorznabSite(name, url, language, apiKey string) error {
+	return UpdateConfig(func(c *Config) error {
+		if _, exists := c.Sites[name]; exists {
+			return fmt.Errorf("site '%s' already exists. Use 'tspider config set-url' to update URL", name)
+		}
+		c.Sites[name] = SiteConfig{
+			URL:      url,
+			Enabled:  true,
+			Language: language,
+			Type:     SiteTypeTorznab,
+			APIKey:   apiKey,
+		}
+		return nil
+	})
 }
 
 // EnableSite enables or disables a site
 func EnableSite(name string, enabled bool) error {
-	c := GetConfig()
+	return UpdateConfig(func(c *Config) error {
+		return enableSite(c, name, enabled)
+	})
+}
+
+func enableSite(c *Config, name string, enabled bool) error {
 	site, exists := c.Sites[name]
 	if !exists {
 		return fmt.Errorf("site '%s' not found", name)
 	}
 	site.Enabled = enabled
 	c.Sites[name] = site
-	return SaveConfig(c)
+	return nil
 }
 
 // RemoveSite removes a site from configuration
 func RemoveSite(name string) error {
-	c := GetConfig()
+	return UpdateConfig(func(c *Config) error {
+		return removeSite(c, name)
+	})
+}
+
+func removeSite(c *Config, name string) error {
 	if _, exists := c.Sites[name]; !exists {
 		return fmt.Errorf("site '%s' not found", name)
 	}
 	delete(c.Sites, name)
-	return SaveConfig(c)
+	return nil
+}
+
+// AddProfile creates or replaces a named set of sites
+func AddProfile(name string, sites []string) error {
+	if len(sites) == 0 {
+		return fmt.Errorf("profile '%s' needs at least one site", name)
+	}
+	return UpdateConfig(func(c *Config) error {
+		for _, site := range sites {
+			if _, exists := c.Sites[site]; !exists {
+				return fmt.Errorf("site '%s' not found. Use 'tspider config list' to see configured sites", site)
+			}
+		}
+		if c.Profiles == nil {
+			c.Profiles = make(map[string][]string)
+		}
+		c.Profiles[name] = sites
+		return nil
+	})
+}
+
+// RemoveProfile removes a named profile
+func RemoveProfile(name string) error {
+	return UpdateConfig(func(c *Config) error {
+		if _, exists := c.Profiles[name]; !exists {
+			return fmt.Errorf("profile '%s' not found", name)
+		}
+		delete(c.Profiles, name)
+		return nil
+	})
+}
+
+// UseProfile makes the sites of a profile the active sites for this run,
+// regardless of their enabled flags. The config file is not modified.
+func UseProfile(name string) error {
+	c := GetConfig()
+	sites, exists := c.Profiles[name]
+	if !exists {
+		return fmt.Errorf("profile '%s' not found. Use 'tspider config profile list' to see profiles", name)
+	}
+	urls := make(map[string]string)
+	for _, site := range sites {
+		s, exists := c.Sites[site]
+		if !exists {
+			return fmt.Errorf("profile '%s' refers to unknown site '%s'", name, site)
+		}
+		urls[site] = s.URL
+	}
+	setSiteURLs(urls)
+	return nil
+}
+
+// ListProfiles prints all configured profiles
+func ListProfiles() {
+	c := GetConfig()
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Profile", "Sites"})
+
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		table.Append([]string{name, strings.Join(c.Profiles[name], ", ")})
+	}
+	table.Render()
 }
 
 // GetEnabledSites returns all enabled sites for a language
@@ -330,17 +780,35 @@ func GetEnabledSites(language string) map[string]SiteConfig {
 
 // SiteStatus represents the health status of a site
 type SiteStatus struct {
-	Name      string
-	URL       string
-	Available bool
-	Latency   time.Duration
-	Error     string
-	Language  string
-	Enabled   bool
+	Name      string        `json:"name"`
+	URL       string        `json:"url"`
+	Available bool          `json:"available"`
+	Latency   time.Duration `json:"latency_ns"`
+	Error     string        `json:"error,omitempty"`
+	Language  string        `json:"language"`
+	Enabled   bool          `json:"enabled"`
+	// Redirect is the address of another host the site redirects to, when
+	// that address is up: the site has most likely moved there
+	Redirect string `json:"redirect,omitempty"`
+	// CertIssuer and CertDaysLeft describe the certificate of an https
+	// site: who issued it and the days until it expires. CertWarning tells
+	// of one that expires soon, has expired or is for another host.
+	CertIssuer   string `json:"cert_issuer,omitempty"`
+	CertDaysLeft *int   `json:"cert_days_left,omitempty"`
+	CertWarning  string `json:"cert_warning,omitempty"`
+	// RedirectChain are the URLs that redirected on the way from URL to
+	// FinalURL, set when the site redirects
+	RedirectChain []RedirectHop `json:"redirect_chain,omitempty"`
+	FinalURL      string        `json:"final_url,omitempty"`
+	// Results and Degraded are only set by DeepDoctor: the number of usable
+	// results for the health keyword, and whether that number was zero
+	Results  int  `json:"results,omitempty"`
+	Degraded bool `json:"degraded,omitempty"`
 }
 
-// Doctor checks all configured sites and returns their status
-func Doctor(language string) []SiteStatus {
+// Doctor checks all configured sites and returns their status. Sites still
+// being checked when ctx is done report its error.
+func Doctor(ctx context.Context, language string) []SiteStatus {
 	c := GetConfig()
 	var (
 		wg      sync.WaitGroup
@@ -362,8 +830,7 @@ func Doctor(language string) []SiteStatus {
 				Enabled:  s.Enabled,
 			}
 
-			client := &http.Client{Timeout: time.Duration(c.Timeout) * time.Second}
-			req, err := http.NewRequest("GET", s.URL, nil)
+			req, err := http.NewRequestWithContext(ctx, "GET", s.URL, nil)
 			if err != nil {
 				status.Error = err.Error()
 				mu.Lock()
@@ -371,20 +838,28 @@ func Doctor(language string) []SiteStatus {
 				mu.Unlock()
 				return
 			}
-			req.Header.Set("User-Agent", c.UserAgent)
+			req.Header.Set("User-Agent", NextUserAgent())
 
+			redirects := recordRedirects(HTTPClient())
 			start := time.Now()
-			resp, err := client.Do(req)
+			resp, err := redirects.client.Do(req)
 			status.Latency = time.Since(start)
+			checkCertificate(&status, resp, err, time.Now())
+			status.RedirectChain, status.FinalURL = redirects.hops, redirects.last
 
 			if err != nil {
 				status.Error = err.Error()
 			} else {
 				defer resp.Body.Close()
-				if resp.StatusCode == http.StatusOK {
-					status.Available = true
-				} else {
+				if resp.StatusCode != http.StatusOK {
 					status.Error = fmt.Sprintf("HTTP %d", resp.StatusCode)
+				} else if problem := pageProblem(s, resp.Body); problem != "" {
+					status.Error = problem
+				} else {
+					status.Available = true
+					if final := resp.Request.URL; !strings.EqualFold(final.Host, req.URL.Host) {
+						status.Redirect = final.Scheme + "://" + final.Host
+					}
 				}
 			}
 
@@ -398,21 +873,38 @@ func Doctor(language string) []SiteStatus {
 	return results
 }
 
+// padRight pads s with spaces to width terminal columns, truncating it with
+// "..." when it is wider. Widths are display widths, so wide (CJK) characters
+// count as two columns.
+func padRight(s string, width int) string {
+	s = runewidth.Truncate(s, width, "...")
+	return s + strings.Repeat(" ", width-runewidth.StringWidth(s))
+}
+
 // PrintDoctorStatus prints the doctor status in a formatted way
-func PrintDoctorStatus(statuses []SiteStatus) {
-	fmt.Println()
-	fmt.Printf("%-15s %-40s %-8s %-8s %-10s %s\n", "SITE", "URL", "STATUS", "ENABLED", "LATENCY", "ERROR")
-	fmt.Println(strings.Repeat("─", 100))
+func PrintDoctorStatus(w io.Writer, statuses []SiteStatus) {
+	row := func(name, url, status, enabled, latency, errMsg string) {
+		fmt.Fprintf(w, "%s %s %s %s %s %s\n", padRight(name, 15), padRight(url, 40),
+			padRight(status, 8), padRight(enabled, 8), padRight(latency, 10), runewidth.Truncate(errMsg, 25, "..."))
+	}
+
+	fmt.Fprintln(w)
+	row("SITE", "URL", "STATUS", "ENABLED", "LATENCY", "ERROR")
+	fmt.Fprintln(w, strings.Repeat("─", 100))
 
 	// Sort by name
 	sort.Slice(statuses, func(i, j int) bool {
 		return statuses[i].Name < statuses[j].Name
 	})
 
-	available := 0
+	available, degraded := 0, 0
 	for _, s := range statuses {
 		status := "DOWN"
-		if s.Available {
+		switch {
+		case s.Degraded:
+			status = "DEGRADED"
+			degraded++
+		case s.Available:
 			status = "OK"
 			available++
 		}
@@ -422,18 +914,21 @@ func PrintDoctorStatus(statuses []SiteStatus) {
 		}
 		latency := fmt.Sprintf("%dms", s.Latency.Milliseconds())
 		errMsg := s.Error
-		if len(errMsg) > 25 {
-			errMsg = errMsg[:22] + "..."
+		if s.Redirect != "" {
+			errMsg = "moved to " + s.Redirect
 		}
-		urlStr := s.URL
-		if len(urlStr) > 38 {
-			urlStr = urlStr[:35] + "..."
-		}
-		fmt.Printf("%-15s %-40s %-8s %-8s %-10s %s\n", s.Name, urlStr, status, enabled, latency, errMsg)
+		row(s.Name, runewidth.Truncate(s.URL, 38, "..."), status, enabled, latency, errMsg)
 	}
 
-	fmt.Println(strings.Repeat("─", 100))
-	fmt.Printf("Total: %d sites, %d available, %d down\n", len(statuses), available, len(statuses)-available)
+	fmt.Fprintln(w, strings.Repeat("─", 100))
+	defer printCertWarnings(w, statuses)
+	defer printRedirects(w, statuses)
+	if degraded > 0 {
+		fmt.Fprintf(w, "Total: %d sites, %d available, %d degraded, %d down\n",
+			len(statuses), available, degraded, len(statuses)-available-degraded)
+		return
+	}
+	fmt.Fprintf(w, "Total: %d sites, %d available, %d down\n", len(statuses), available, len(statuses)-available)
 }
 
 // ListSites prints all configured sites
@@ -462,213 +957,379 @@ func ListSites() {
 	table.Render()
 }
 
-// GetResponseFromURL returns *http.Response from url
-func GetResponseFromURL(url string) (resp *http.Response, ok bool) {
-	c := GetConfig()
-	client := &http.Client{Timeout: time.Duration(c.Timeout) * time.Second}
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return resp, false
+// GetResponseFromURL returns *http.Response from url, fetched by the Fetcher
+// of its site (see FetcherFor). Canceling ctx aborts the request.
+// When ok is false the body has already been closed. During CollectData, a
+// request to a site whose breaker has tripped fails at once.
+func GetResponseFromURL(ctx context.Context, url string) (resp *http.Response, ok bool) {
+	b := breakerFrom(ctx)
+	if b != nil && b.open.Load() {
+		return nil, false
 	}
-	req.Header.Set("User-Agent", c.UserAgent)
-	resp, err = client.Do(req)
-	if err != nil {
-		return resp, false
+	resp, ok = FetcherFor(url).Fetch(ctx, url)
+	if !ok {
+		if b != nil && ctx.Err() == nil && tripsBreaker(resp) {
+			b.open.Store(true)
+		}
+		return nil, false
 	}
-	if resp.StatusCode != 200 {
-		return resp, false
+	return limitBody(resp, maxBodySize())
+}
+
+// waitContext waits for wg and reports whether it finished before ctx was done
+func waitContext(ctx context.Context, wg *sync.WaitGroup) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
 	}
-	return resp, true
 }
 
-// CollectData function executes web scraping based on each scrapper
-func CollectData(s []Scraping, keyword string, spinner *Spinner) map[string]string {
-	spinner.UpdateMessage("Searching")
-	spinner.SetTotal(len(s))
-	atomic.StoreInt32(&spinner.done, 0)
+// IsValidMagnet reports whether m is a usable magnet link rather than a failure sentinel
+func IsValidMagnet(m string) bool {
+	return strings.HasPrefix(m, "magnet:?") && !strings.HasSuffix(m, "btih:")
+}
 
-	var wg sync.WaitGroup
-	ch := make(chan map[string]string, len(s))
-	for _, i := range s {
-		wg.Add(1)
-		go func(v Scraping) {
-			defer wg.Done()
-			r := v.Crawl(keyword)
-			spinner.IncrDone()
-			if r == nil {
-				return
-			}
-			ch <- r
-		}(i)
+// magnetFailThreshold returns the configured broken-selector threshold
+func magnetFailThreshold() float64 {
+	if t := GetConfig().MagnetFailThreshold; t > 0 {
+		return t
 	}
-	wg.Wait()
-	close(ch)
-	m := map[string]string{}
-	for elem := range ch {
-		for k, v := range elem {
-			k = strings.Replace(k, " ", "_", -1)
-			if v == "no magnet" {
-				continue
-			}
-			m[k] = v
+	return 0.8
+}
+
+// checkMagnets warns through the spinner when most of a site's magnets failed,
+// which usually means the detail-page selector is outdated
+func checkMagnets(site string, results []SearchResult, spinner *Spinner) {
+	if len(results) == 0 {
+		return
+	}
+	failed := 0
+	for _, r := range results {
+		if !IsValidMagnet(r.Magnet) {
+			failed++
 		}
 	}
-	return m
+	if float64(failed)/float64(len(results)) > magnetFailThreshold() {
+		spinner.Warn(fmt.Sprintf("%s: %d of %d results have no usable magnet; the magnet selector may be outdated",
+			site, failed, len(results)))
+	}
 }
 
-// CollectDataEx function executes web scraping based on each scrapper
-func CollectDataEx(s []ScrapingEx, keyword string, spinner *Spinner) map[string][]string {
+// CollectData function executes web scraping based on each scrapper and returns
+// the deduplicated results with statistics on what was merged.
+// If ctx is done before every scrapper finishes, the results gathered so far are returned.
+// A site that exceeds its SiteBudget is abandoned and reported by spinner.Abandoned.
+// Once a request to a site fails for good, its remaining requests are skipped
+// and the site is reported by spinner.Degraded.
+func CollectData(ctx context.Context, s map[string]Scraper, keyword string, spinner *Spinner) ([]SearchResult, DedupStats) {
 	spinner.UpdateMessage("Searching")
 	spinner.SetTotal(len(s))
 	atomic.StoreInt32(&spinner.done, 0)
 
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
 	var wg sync.WaitGroup
-	ch := make(chan map[string][]string, len(s))
-	for _, i := range s {
+	ch := make(chan []SearchResult, len(s))
+	// The healthiest sites start first, and so queue their detail pages first
+	for _, name := range RankSites(names) {
+		i := s[name]
 		wg.Add(1)
-		go func(v ScrapingEx) {
+		go func(n string, v Scraper) {
 			defer wg.Done()
-			r := v.Crawl(keyword)
-			spinner.IncrDone()
+			ctx, b := withBreaker(ctx)
+			if err := LoginSite(ctx, n, v); err != nil {
+				spinner.siteFinished(n)
+				spinner.Warn(err.Error())
+				Metrics().searched(n, 0, false)
+				return
+			}
+			var r []SearchResult
+			finished := crawlWithin(ctx, n, func(ctx context.Context) { r = v.Crawl(ctx, keyword) })
+			spinner.siteFinished(n)
+			if !finished {
+				if ctx.Err() == nil {
+					spinner.abandon(n)
+				}
+				Metrics().searched(n, 0, false)
+				return
+			}
+			Metrics().searched(n, len(r), r != nil)
+			if b.open.Load() && r != nil {
+				spinner.degrade(n)
+				warning := n + ": skipped the rest of its pages after a failed request"
+				if failure := Retries().Failure(n); failure != "" {
+					warning += ": " + failure
+				}
+				spinner.Warn(warning)
+			}
 			if r == nil {
+				// Say why, when a request to the site failed for good
+				if failure := Retries().Failure(n); failure != "" {
+					spinner.Warn(fmt.Sprintf("%s: search failed: %s", n, failure))
+				}
 				return
 			}
-			ch <- r
-		}(i)
-	}
-	wg.Wait()
-	close(ch)
-	m := map[string][]string{}
-	for elem := range ch {
-		for k, v := range elem {
-			k = strings.Replace(k, " ", "_", -1)
-			m[k] = v
-		}
+			if ctx.Err() != nil {
+				// Finished as the deadline passed, too late to be shown
+				return
+			}
+			results := siteResults(n, r)
+			checkMagnets(n, results, spinner)
+			spinner.siteDone(n, results)
+			ch <- results
+		}(name, i)
 	}
-	return m
+	waitContext(ctx, &wg)
+	return mergeResults(ch)
 }
 
-// PrintData function prints scraped data to console
-func PrintData(data map[string]string) {
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"Title", "Magnet"})
-	matrix := [][]string{}
-	for k, v := range data {
-		matrix = append(matrix, []string{k, v})
+// Column is one column of a results table
+type Column struct {
+	Header string
+	Value  func(SearchResult) string
+}
+
+var (
+	// DataColumns are the columns printed by PrintData
+	DataColumns = []Column{
+		{"Title", func(r SearchResult) string { return displayTitle(r.Title) }},
+		{"Magnet", func(r SearchResult) string { return r.Magnet }},
 	}
-	sort.SliceStable(matrix, func(i, j int) bool { return matrix[i][0] > matrix[j][0] })
-	for _, v := range matrix {
-		table.Append(v)
+	// DataExColumns are the columns printed by PrintDataEx
+	DataExColumns = []Column{
+		{"Title", func(r SearchResult) string { return displayTitle(r.Title) }},
+		{"Uploader", func(r SearchResult) string { return r.Uploader }},
+		{"Seeder", func(r SearchResult) string { return strconv.Itoa(r.Seeders) }},
+		{"Leecher", func(r SearchResult) string { return strconv.Itoa(r.Leechers) }},
+		{"Snatch", func(r SearchResult) string { return strconv.Itoa(r.Snatches) }},
+		{"FileSize", func(r SearchResult) string { return r.Size }},
+		{"Magnet", func(r SearchResult) string { return r.Magnet }},
+		{"Folder", func(r SearchResult) string {
+			if r.Folder {
+				return "Yes"
+			}
+			return "No"
+		}},
 	}
-	table.SetAlignment(tablewriter.ALIGN_LEFT)
-	table.Render()
-}
+	// FreshnessColumn shows FreshnessScore
+	FreshnessColumn = Column{"Freshness", FreshnessScore}
+)
 
-// PrintDataEx function prints scraped data to console
-func PrintDataEx(data map[string][]string) {
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{
-		"Title", "Uploader", "Seeder", "Leecher",
-		"Snatch", "FileSize", "Magnet", "Folder",
-	})
-	for k, v := range data {
-		m := make([]string, 0)
-		m = append(m, k)
-		for _, i := range v {
-			m = append(m, i)
+// PrintTable prints data to w with the given columns
+func PrintTable(w io.Writer, columns []Column, data []SearchResult) {
+	table := tablewriter.NewWriter(w)
+	headers := make([]string, len(columns))
+	for i, col := range columns {
+		headers[i] = col.Header
+	}
+	table.SetHeader(headers)
+	for _, r := range data {
+		row := make([]string, len(columns))
+		for i, col := range columns {
+			row[i] = col.Value(r)
 		}
-		table.Append(m)
+		table.Append(row)
 	}
 	table.SetAlignment(tablewriter.ALIGN_LEFT)
 	table.Render()
 }
 
-// URLJoin function join baseURL and relURL
-func URLJoin(baseURL string, relURL string) string {
+// PrintData function prints scraped data to w
+func PrintData(w io.Writer, data []SearchResult) {
+	PrintTable(w, DataColumns, data)
+}
+
+// PrintDataEx function prints scraped data to w
+func PrintDataEx(w io.Writer, data []SearchResult) {
+	PrintTable(w, DataExColumns, data)
+}
+
+// URLJoin function join baseURL and relURL, resolving relURL the way a
+// browser does on a page at baseURL. Pass the page URL, or a directory
+// ending in '/' such as SiteURL(site) + "/bbs/". It fails when either URL
+// does not parse, as a malformed href scraped from a page may not.
+func URLJoin(baseURL string, relURL string) (string, error) {
 	u, err := url.Parse(relURL)
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
-	base, err := url.Parse(baseURL + "/bbs/")
+	base, err := url.Parse(baseURL)
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
-	return base.ResolveReference(u).String()
+	return base.ResolveReference(u).String(), nil
 }
 
-// CheckNetWorkFromURL function checks network status
-func CheckNetWorkFromURL(url string) bool {
-	c := GetConfig()
-	client := &http.Client{Timeout: time.Duration(c.Timeout) * time.Second}
-	req, err := http.NewRequest("GET", url, nil)
+// CheckNetWorkFromURL function checks network status. The pages of sites
+// fetched with a browser are probed with it, as the plain client may be
+// blocked. A page answering 200 must also be a page of its site, see
+// pageProblem.
+func CheckNetWorkFromURL(ctx context.Context, url string) bool {
+	site, _ := siteConfigForURL(url)
+	return checkPage(ctx, url, site)
+}
+
+// checkPage reports whether the page at url is up and a page of site
+func checkPage(ctx context.Context, url string, site SiteConfig) bool {
+	if f := FetcherFor(url); f != DefaultFetcher {
+		resp, ok := f.Fetch(ctx, url)
+		if !ok {
+			return false
+		}
+		defer resp.Body.Close()
+		return pageProblem(site, resp.Body) == ""
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return false
 	}
-	req.Header.Set("User-Agent", c.UserAgent)
-	resp, err := client.Do(req)
+	req.Header.Set("User-Agent", NextUserAgent())
+	start := time.Now()
+	resp, err := HTTPClient().Do(req)
+	Metrics().request(siteForURL(url), time.Since(start), err == nil && resp.StatusCode == 200)
 	if err != nil {
 		return false
 	}
 	defer resp.Body.Close()
-	return resp.StatusCode == 200
+	return resp.StatusCode == 200 && pageProblem(site, resp.Body) == ""
 }
 
-// GetAvailableSites function gets available torrent sites
-func GetAvailableSites(oldItems []Scraping) ([]Scraping, *Spinner) {
+// GetAvailableSites function gets available torrent sites.
+// sites maps site names to their scrapers; only sites active for this run (see SiteURL) are checked,
+// and recent probes in the Availability cache are reused. The mirrors of a
+// site are tried in order, and the first one up becomes its URL in
+// UseSiteURL for the rest of the run. Sites snoozed after failing too many
+// checks in a row are skipped, see SiteState. The probes are recorded in the
+// latency history, and with max_sites_per_search only the healthiest sites
+// up are returned, see RankSites.
+func GetAvailableSites(ctx context.Context, sites map[string]Scraper) (map[string]Scraper, *Spinner) {
+	now := time.Now()
+	state, err := LoadSiteState()
+	if err != nil {
+		state = &SiteState{path: GetSiteStatePath(), Sites: map[string]SiteHealth{}}
+	}
+	items := make([]string, 0, len(sites))
+	mirrors := make(map[string][]string, len(sites))
+	var snoozed []string
+	for name := range sites {
+		u, ok := LookupSiteURL(name)
+		if !ok {
+			continue
+		}
+		if state.Snoozed(name, now) {
+			snoozed = append(snoozed, name)
+			continue
+		}
+		items = append(items, name)
+		site, exists := GetConfig().Sites[name]
+		if p, ok := sites[name].(Prober); ok {
+			mirrors[name] = []string{p.ProbeURL()}
+		} else if exists && site.URL == u {
+			mirrors[name] = site.Mirrors()
+		} else {
+			mirrors[name] = []string{u}
+		}
+	}
+	sort.Strings(items)
+	sort.Strings(snoozed)
+
 	spinner := NewSpinner("Checking sites")
-	spinner.SetTotal(len(oldItems))
+	spinner.SetTotal(len(items))
 	spinner.Start()
+	if len(snoozed) > 0 {
+		spinner.Warn(fmt.Sprintf("skipped %s after repeated failed checks; 'tspider config reset-state' checks them again", strings.Join(snoozed, ", ")))
+	}
 
-	newItems := make([]Scraping, 0)
-	items := []string{
-		"torrenttop",
+	// checked is the outcome of a site's check: the URL found up, if any,
+	// and the mirrors probed rather than looked up in the cache
+	type checked struct {
+		name, url string
+		probes    []SiteStatus
 	}
-	ch := make(chan int, len(items))
+	ch := make(chan checked, len(items))
 	var wg sync.WaitGroup
-	for n, title := range items {
+	for _, title := range items {
 		wg.Add(1)
-		go func(i int, t string) {
+		go func(t string, urls []string) {
 			defer wg.Done()
-			ok := CheckNetWorkFromURL(TorrentURL[t])
-			spinner.IncrDone()
-			if ok {
-				ch <- i
+			defer spinner.IncrDone()
+			result := checked{name: t}
+			for _, u := range urls {
+				start := time.Now()
+				up, probed := checkAvailability(ctx, u)
+				if probed {
+					result.probes = append(result.probes, SiteStatus{Name: t, URL: u, Available: up, Latency: time.Since(start)})
+				}
+				if up {
+					result.url = u
+					break
+				}
+				if ctx.Err() != nil {
+					return
+				}
 			}
-		}(n, title)
+			ch <- result
+		}(title, mirrors[title])
 	}
-	wg.Wait()
-	close(ch)
-	for v := range ch {
-		newItems = append(newItems, oldItems[v])
+	waitContext(ctx, &wg)
+	Availability().Save()
+	// Sites still being checked when ctx ended are left out
+	var results []checked
+	probed := false
+drain:
+	for {
+		select {
+		case v := <-ch:
+			results = append(results, v)
+			probed = probed || len(v.probes) > 0
+		default:
+			break drain
+		}
 	}
-	return newItems, spinner
-}
-
-// GetAvailableSitesEx function gets available torrent sites
-func GetAvailableSitesEx(oldItems []ScrapingEx) ([]ScrapingEx, *Spinner) {
-	spinner := NewSpinner("Checking sites")
-	spinner.SetTotal(len(oldItems))
-	spinner.Start()
-
-	newItems := make([]ScrapingEx, 0)
-	items := []string{"nyaa", "sukebe"}
-	ch := make(chan int, len(items))
-	var wg sync.WaitGroup
-	for n, title := range items {
-		wg.Add(1)
-		go func(i int, t string) {
-			defer wg.Done()
-			ok := CheckNetWorkFromURL(TorrentURL[t])
-			spinner.IncrDone()
-			if ok {
-				ch <- i
+	if probed {
+		UpdateLatencyHistory(func(h *LatencyHistory) error {
+			for _, v := range results {
+				h.Record(now, v.probes)
 			}
-		}(n, title)
+			return nil
+		})
 	}
-	wg.Wait()
-	close(ch)
-	for v := range ch {
-		newItems = append(newItems, oldItems[v])
+	UpdateSiteState(func(s *SiteState) error {
+		for _, v := range results {
+			if v.url != "" {
+				s.RecordSuccess(v.name)
+			} else if len(v.probes) > 0 && s.RecordFailure(v.name, now) {
+				spinner.Warn(fmt.Sprintf("%s failed %d checks in a row and is skipped until %s", v.name, s.Sites[v.name].Failures+1, s.Sites[v.name].SnoozedUntil.Format("Jan 2 15:04")))
+			}
+		}
+		return nil
+	})
+	var up []string
+	for _, v := range results {
+		if v.url == "" {
+			continue
+		}
+		up = append(up, v.name)
+		if _, probed := sites[v.name].(Prober); !probed {
+			UseSiteURL(v.name, v.url)
+		}
+	}
+	ranked := RankSites(up)
+	if limit := maxSitesPerSearch(); limit > 0 && len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	newItems := make(map[string]Scraper, len(ranked))
+	for _, name := range ranked {
+		newItems[name] = sites[name]
 	}
 	return newItems, spinner
 }
@@ -687,8 +1348,3 @@ func RemoveNonAscII(s string) string {
 	}
 	return result.String()
 }
-
-func init() {
-	// Load config on package initialization
-	LoadConfig()
-}
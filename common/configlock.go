@@ -0,0 +1,83 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// configMu serializes config updates within this process; lockFile does the
+// same across processes
+var configMu sync.Mutex
+
+// lockConfig takes the config lock, both within this process and across
+// processes, and returns a function that releases it. The state files kept
+// next to the config file are updated under the same lock.
+func lockConfig() (func(), error) {
+	configMu.Lock()
+	unlock, err := lockFile(GetConfigPath() + ".lock")
+	if err != nil {
+		configMu.Unlock()
+		return nil, err
+	}
+	return func() {
+		unlock()
+		configMu.Unlock()
+	}, nil
+}
+
+// UpdateConfig applies fn to the config file under an advisory lock and saves
+// the result, so that concurrent tspider processes never lose each other's
+// changes. fn sees the file as it is on disk at that moment, not the config
+// loaded at startup, and nothing is saved when it returns an error.
+func UpdateConfig(fn func(c *Config) error) error {
+	unlock, err := lockConfig()
+	if err != nil {
+		return fmt.Errorf("failed to lock config: %w", err)
+	}
+	defer unlock()
+	path := GetConfigPath()
+
+	c, err := ReadConfigFile(path)
+	if os.IsNotExist(err) {
+		c, err = DefaultConfig(), nil
+	}
+	if err != nil {
+		// Never overwrite a config file that failed to parse
+		return fmt.Errorf("config file is invalid, fix it with 'tspider config edit': %w", err)
+	}
+	if c.Sites == nil {
+		c.Sites = map[string]SiteConfig{}
+	}
+	if err := fn(c); err != nil {
+		return err
+	}
+	return SaveConfig(c)
+}
+
+// WriteFileAtomic writes data to a temporary file next to path and renames
+// it over path, so readers never see a partially written file
+func WriteFileAtomic(path string, data []byte) error {
+	return writeFileAtomicMode(path, data, 0644)
+}
+
+// writeFileAtomicMode is WriteFileAtomic with the permissions of the file
+func writeFileAtomicMode(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
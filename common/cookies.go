@@ -0,0 +1,149 @@
+package common
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// CookieJar is a cookie jar that keeps the cookies of each site in a file of
+// its own, readable only by the user, so session and anti-bot cookies set on
+// a first visit are sent again by later runs instead of being re-challenged
+type CookieJar struct {
+	dir   string
+	jar   *cookiejar.Jar
+	mu    sync.Mutex
+	sites map[string]map[string]storedCookie
+}
+
+// storedCookie is a cookie with the URL that set it, which scopes it again
+// when it is loaded
+type storedCookie struct {
+	URL    string       `json:"url"`
+	Cookie *http.Cookie `json:"cookie"`
+}
+
+// GetCookiesDir returns the directory holding the cookies of each site, in
+// a file named after the site
+func GetCookiesDir() string {
+	return filepath.Join(filepath.Dir(GetConfigPath()), ".tspider_cookies.d")
+}
+
+// NewCookieJar returns a jar keeping its cookies in dir, loaded with the
+// unexpired cookies saved there by earlier runs
+func NewCookieJar(dir string) *CookieJar {
+	jar, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	j := &CookieJar{dir: dir, jar: jar, sites: map[string]map[string]storedCookie{}}
+	j.load()
+	return j
+}
+
+// Cookies returns the cookies to send to u
+func (j *CookieJar) Cookies(u *url.URL) []*http.Cookie {
+	return j.jar.Cookies(u)
+}
+
+// SetCookies stores the cookies u set and saves those of its site
+func (j *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.jar.SetCookies(u, cookies)
+	site := siteForURL(u.String())
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	stored := j.sites[site]
+	if stored == nil {
+		stored = map[string]storedCookie{}
+		j.sites[site] = stored
+	}
+	now := time.Now()
+	for _, c := range cookies {
+		key := cookieKey(u, c)
+		if c.MaxAge < 0 || (!c.Expires.IsZero() && c.Expires.Before(now)) {
+			delete(stored, key)
+			continue
+		}
+		c := *c
+		if c.MaxAge > 0 {
+			// Max-Age counts from now; keep the expiry it means
+			c.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+			c.MaxAge = 0
+		}
+		c.Raw = ""
+		stored[key] = storedCookie{URL: u.String(), Cookie: &c}
+	}
+	j.save(site, stored)
+}
+
+// cookieKey identifies a cookie the way browsers do, by domain, path and name
+func cookieKey(u *url.URL, c *http.Cookie) string {
+	domain := c.Domain
+	if domain == "" {
+		domain = u.Hostname()
+	}
+	return domain + ";" + c.Path + ";" + c.Name
+}
+
+// unsafeFileChars are replaced in site names used as file names
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+func (j *CookieJar) path(site string) string {
+	return filepath.Join(j.dir, unsafeFileChars.ReplaceAllString(site, "_")+".json")
+}
+
+// save writes the cookies of site, or removes its file when none is left.
+// Failures are ignored: the cookies still work for this run.
+func (j *CookieJar) save(site string, stored map[string]storedCookie) {
+	if len(stored) == 0 {
+		os.Remove(j.path(site))
+		return
+	}
+	cookies := make([]storedCookie, 0, len(stored))
+	for _, c := range stored {
+		cookies = append(cookies, c)
+	}
+	data, err := json.MarshalIndent(cookies, "", "  ")
+	if err != nil || os.MkdirAll(j.dir, 0700) != nil {
+		return
+	}
+	writeFileAtomicMode(j.path(site), data, 0600)
+}
+
+func (j *CookieJar) load() {
+	entries, err := os.ReadDir(j.dir)
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(j.dir, e.Name()))
+		if err != nil {
+			continue
+		}
+		var cookies []storedCookie
+		if json.Unmarshal(data, &cookies) != nil {
+			continue
+		}
+		for _, sc := range cookies {
+			u, err := url.Parse(sc.URL)
+			if err != nil || sc.Cookie == nil || (!sc.Cookie.Expires.IsZero() && sc.Cookie.Expires.Before(now)) {
+				continue
+			}
+			j.jar.SetCookies(u, []*http.Cookie{sc.Cookie})
+			site := siteForURL(sc.URL)
+			if j.sites[site] == nil {
+				j.sites[site] = map[string]storedCookie{}
+			}
+			j.sites[site][cookieKey(u, sc.Cookie)] = sc
+		}
+	}
+}
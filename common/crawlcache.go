@@ -0,0 +1,124 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// defaultCrawlTTL is how long crawled results are reused when the config
+// does not set crawl_cache_ttl_seconds
+const defaultCrawlTTL = 5 * time.Minute
+
+// CrawlKey holds the options that change what a search fetches from the
+// sites. Options that only change how the results are filtered, ordered or
+// printed (format, sort, exclude, blocklist, pager, live, freshness, summary,
+// save, diff, html, verify-metadata) are not part of it, so searches that
+// differ only in those share a crawl.
+type CrawlKey struct {
+	// Keyword is the keyword after transforms
+	Keyword  string `json:"keyword"`
+	Lang     string `json:"lang"`
+	Uploader string `json:"uploader,omitempty"`
+	// Exact changes the query sent to phrase-aware sites
+	Exact bool `json:"exact,omitempty"`
+	// Negatives are the negative terms sent in the query to NegativeSites;
+	// elsewhere they are filtered locally and not part of the key
+	Negatives []string `json:"negatives,omitempty"`
+	// Sites are the sites of Lang searched this run as "name=url", which
+	// captures profiles and URL changes
+	Sites []string `json:"sites"`
+}
+
+// NewCrawlKey returns the key of a search for keyword on the sites of lang
+// active for this run, that is the enabled sites or those of the active profile
+func NewCrawlKey(keyword, lang, uploader string, exact bool) CrawlKey {
+	k := CrawlKey{Keyword: keyword, Lang: lang, Uploader: uploader, Exact: exact}
+	sites := GetConfig().Sites
+	for name, url := range SiteURLs() {
+		if sites[name].Language == lang {
+			k.Sites = append(k.Sites, name+"="+url)
+		}
+	}
+	return k
+}
+
+// String returns a stable digest of the key, usable as a file name
+func (k CrawlKey) String() string {
+	sites := append([]string(nil), k.Sites...)
+	sort.Strings(sites)
+	k.Sites = sites
+	data, _ := json.Marshal(k)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+type crawlEntry struct {
+	Crawled time.Time      `json:"crawled"`
+	Results []SearchResult `json:"results"`
+	Stats   DedupStats     `json:"stats"`
+}
+
+// CrawlCache stores the merged results of recent crawls on disk, one file
+// per CrawlKey, so repeated searches render without hitting the sites
+type CrawlCache struct {
+	// TTL is how long a crawl stays valid
+	TTL time.Duration
+	// Now returns the current time; tests replace it with a fake clock
+	Now func() time.Time
+
+	dir string
+}
+
+// NewCrawlCache returns a cache storing crawls under dir
+func NewCrawlCache(ttl time.Duration, dir string) *CrawlCache {
+	return &CrawlCache{TTL: ttl, Now: time.Now, dir: dir}
+}
+
+// Crawls returns the crawl cache under the user cache directory, or nil
+// when there is no such directory
+func Crawls() *CrawlCache {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return nil
+	}
+	ttl := defaultCrawlTTL
+	if s := GetConfig().CrawlTTL; s > 0 {
+		ttl = time.Duration(s) * time.Second
+	}
+	return NewCrawlCache(ttl, filepath.Join(dir, "tspider", "crawl"))
+}
+
+func (cc *CrawlCache) path(key CrawlKey) string {
+	return filepath.Join(cc.dir, key.String()+".json")
+}
+
+// Get returns the cached crawl of key, with ok false when there is none or
+// it is older than TTL
+func (cc *CrawlCache) Get(key CrawlKey) (results []SearchResult, stats DedupStats, ok bool) {
+	data, err := os.ReadFile(cc.path(key))
+	if err != nil {
+		return nil, DedupStats{}, false
+	}
+	var e crawlEntry
+	if err := json.Unmarshal(data, &e); err != nil || cc.Now().Sub(e.Crawled) >= cc.TTL {
+		return nil, DedupStats{}, false
+	}
+	return e.Results, e.Stats, true
+}
+
+// Put records a crawl of key made now
+func (cc *CrawlCache) Put(key CrawlKey, results []SearchResult, stats DedupStats) error {
+	data, err := json.Marshal(crawlEntry{Crawled: cc.Now(), Results: results, Stats: stats})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(cc.dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(cc.path(key), data, 0644)
+}
@@ -0,0 +1,167 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cron is a parsed schedule: a five-field cron expression or a fixed
+// interval
+type Cron struct {
+	// minute, hour, dom, month and dow hold the allowed values of each field
+	minute, hour, dom, month, dow map[int]bool
+	// domAny and dowAny are set when the field starts with *, for the cron rule that
+	// a day matches either restricted day field
+	domAny, dowAny bool
+	// every is the interval of @every, zero for cron expressions
+	every time.Duration
+}
+
+// cronField is the range of one cron field
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 6},
+}
+
+// cronMacros are the shorthands ParseCron accepts for common schedules
+var cronMacros = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+}
+
+// ParseCron parses a schedule: five cron fields (minute, hour, day of month,
+// month, day of week) each holding *, a value, a range a-b or a list of them,
+// optionally stepped with /n; @hourly, @daily, @weekly or @monthly; or
+// @every DURATION, such as "@every 30m". Day of week 7 is Sunday, like 0.
+func ParseCron(spec string) (*Cron, error) {
+	spec = strings.TrimSpace(spec)
+	if rest, ok := strings.CutPrefix(spec, "@every "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil || d < time.Minute {
+			return nil, fmt.Errorf("invalid schedule %q: @every needs a duration of at least 1m", spec)
+		}
+		return &Cron{every: d}, nil
+	}
+	if expr, ok := cronMacros[spec]; ok {
+		spec = expr
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("invalid schedule %q: want 5 fields (minute hour day-of-month month day-of-week), @hourly, @daily, @weekly, @monthly or @every DURATION", spec)
+	}
+	sets := make([]map[int]bool, len(fields))
+	for i, f := range fields {
+		set, err := parseCronField(f, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %w", spec, err)
+		}
+		sets[i] = set
+	}
+	// Sunday may be written 7
+	if sets[4][7] {
+		sets[4][0] = true
+	}
+	return &Cron{
+		minute: sets[0], hour: sets[1], dom: sets[2], month: sets[3], dow: sets[4],
+		domAny: strings.HasPrefix(fields[2], "*"), dowAny: strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+// parseCronField returns the values a field allows
+func parseCronField(field string, f cronField) (map[int]bool, error) {
+	max := f.max
+	if f.name == "day of week" {
+		max = 7
+	}
+	set := map[int]bool{}
+	for _, part := range strings.Split(field, ",") {
+		rng, stepText, stepped := strings.Cut(part, "/")
+		step := 1
+		if stepped {
+			n, err := strconv.Atoi(stepText)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("%s: invalid step %q", f.name, stepText)
+			}
+			step = n
+		}
+		lo, hi := f.min, max
+		if rng != "*" {
+			a, b, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(a); err != nil {
+				return nil, fmt.Errorf("%s: invalid value %q", f.name, a)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(b); err != nil {
+					return nil, fmt.Errorf("%s: invalid value %q", f.name, b)
+				}
+			} else if stepped {
+				// a/n means from a to the end of the range
+				hi = max
+			}
+		}
+		if lo < f.min || hi > max || lo > hi {
+			return nil, fmt.Errorf("%s: %q is outside %d-%d", f.name, part, f.min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			set[v] = true
+		}
+	}
+	return set, nil
+}
+
+// Next returns the first time after t the schedule fires, to the minute.
+// It returns the zero time when the schedule never fires, such as on
+// February 30th.
+func (c *Cron) Next(t time.Time) time.Time {
+	if c.every > 0 {
+		return t.Add(c.every)
+	}
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	// Every schedule that fires at all does so within four years
+	limit := t.AddDate(4, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case !c.month[int(t.Month())]:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !c.hour[t.Hour()]:
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		case !c.minute[t.Minute()]:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// dayMatches applies the cron day rule: when both day fields are
+// restricted, a day matching either one fires
+func (c *Cron) dayMatches(t time.Time) bool {
+	dom, dow := c.dom[t.Day()], c.dow[int(t.Weekday())]
+	switch {
+	case c.domAny && c.dowAny:
+		return true
+	case c.domAny:
+		return dow
+	case c.dowAny:
+		return dom
+	}
+	return dom || dow
+}
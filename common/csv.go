@@ -0,0 +1,37 @@
+package common
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+)
+
+// CSVHeader is the header row written by WriteCSV
+var CSVHeader = []string{"site", "title", "magnet", "seeders", "leechers", "size", "uploader", "date", "detail_url"}
+
+// WriteCSV writes results to w as CSV with a CSVHeader row, for import into
+// spreadsheets. Fields a site does not provide are empty, except seeders and
+// leechers, which are 0.
+func WriteCSV(w io.Writer, results []SearchResult) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(CSVHeader); err != nil {
+		return err
+	}
+	for _, r := range results {
+		date := ""
+		if !r.Date.IsZero() {
+			date = r.Date.Format(time.RFC3339)
+		}
+		record := []string{
+			r.Site, r.Title, r.Magnet,
+			strconv.Itoa(r.Seeders), strconv.Itoa(r.Leechers),
+			r.Size, r.Uploader, date, r.DetailURL,
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,85 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// defaultHealthKeywords are broad terms that return results on every site of
+// a language; Config.HealthKeyword and Config.HealthKeywords override them
+var defaultHealthKeywords = map[string]string{
+	"kr": "720p",
+	"jp": "1080p",
+	"en": "1080p",
+}
+
+// HealthKeywordFor returns the keyword used to check that the sites of
+// language actually return results: the per-language keyword from the
+// config, then the config-wide one, then the built-in default
+func HealthKeywordFor(language string) string {
+	c := GetConfig()
+	if k := c.HealthKeywords[language]; k != "" {
+		return k
+	}
+	if c.HealthKeyword != "" {
+		return c.HealthKeyword
+	}
+	if k := defaultHealthKeywords[language]; k != "" {
+		return k
+	}
+	return "1080p"
+}
+
+// DeepDoctor runs Doctor and then searches every reachable, enabled site that
+// has a scraper for its health keyword. A site that answers but yields no
+// result with a usable magnet is marked Degraded. Searches still running when
+// ctx is done are reported as unchecked.
+func DeepDoctor(ctx context.Context, language string, sites map[string]Scraper) []SiteStatus {
+	type found struct{ index, results int }
+	statuses := Doctor(ctx, language)
+	ch := make(chan found, len(statuses))
+	pending := map[int]bool{}
+	var wg sync.WaitGroup
+	for i, s := range statuses {
+		if !s.Available || !s.Enabled {
+			continue
+		}
+		keyword := HealthKeywordFor(s.Language)
+		scraper, ok := sites[s.Name]
+		if !ok {
+			statuses[i].Error = "no scraper, not searched"
+			continue
+		}
+		pending[i] = true
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			n := 0
+			for _, r := range siteResults(name, scraper.Crawl(ctx, keyword)) {
+				if IsValidMagnet(r.Magnet) {
+					n++
+				}
+			}
+			ch <- found{i, n}
+		}(i, s.Name)
+	}
+	waitContext(ctx, &wg)
+	for {
+		select {
+		case f := <-ch:
+			delete(pending, f.index)
+			s := &statuses[f.index]
+			s.Results = f.results
+			if f.results == 0 {
+				s.Degraded = true
+				s.Error = fmt.Sprintf("no results for %q", HealthKeywordFor(s.Language))
+			}
+		default:
+			for i := range pending {
+				statuses[i].Error = "search timed out"
+			}
+			return statuses
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package common
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SiteDiagnosis is the detailed check of a single site by DiagnoseSite
+type SiteDiagnosis struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// Addresses are the IP addresses the site's host resolved to, empty
+	// when a proxy resolves it or the URL holds an address
+	Addresses []string      `json:"addresses,omitempty"`
+	DNSTime   time.Duration `json:"dns_ns,omitempty"`
+	DNSError  string        `json:"dns_error,omitempty"`
+	// TLS describes the connection to an https site; empty when there was
+	// no handshake
+	TLS        *TLSInfo      `json:"tls,omitempty"`
+	StatusCode int           `json:"status_code,omitempty"`
+	Status     string        `json:"status,omitempty"`
+	Latency    time.Duration `json:"latency_ns"`
+	// RedirectChain are the URLs that redirected on the way to FinalURL
+	RedirectChain []RedirectHop `json:"redirect_chain,omitempty"`
+	// FinalURL is where the redirects of the site led
+	FinalURL  string `json:"final_url,omitempty"`
+	Available bool   `json:"available"`
+	Error     string `json:"error,omitempty"`
+}
+
+// TLSInfo describes a TLS connection and the certificate it presented
+type TLSInfo struct {
+	Version     string    `json:"version"`
+	CipherSuite string    `json:"cipher_suite"`
+	Subject     string    `json:"subject,omitempty"`
+	Issuer      string    `json:"issuer,omitempty"`
+	Expires     time.Time `json:"expires"`
+}
+
+// DiagnoseSite checks the configured site called name like Doctor, and
+// reports how its host resolved, its TLS connection and where its
+// redirects led. The steps are those of the first request, to the site's
+// own host.
+func DiagnoseSite(ctx context.Context, name string) (SiteDiagnosis, error) {
+	site, exists := GetConfig().Sites[name]
+	if !exists {
+		return SiteDiagnosis{}, fmt.Errorf("site '%s' not found", name)
+	}
+	d := SiteDiagnosis{Name: name, URL: site.URL}
+
+	var (
+		mu       sync.Mutex
+		dnsStart time.Time
+		traced   bool
+	)
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			mu.Lock()
+			if !traced {
+				dnsStart = time.Now()
+			}
+			mu.Unlock()
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			if traced || dnsStart.IsZero() {
+				return
+			}
+			d.DNSTime = time.Since(dnsStart)
+			if info.Err != nil {
+				d.DNSError = info.Err.Error()
+			}
+			for _, a := range info.Addrs {
+				d.Addresses = append(d.Addresses, a.String())
+			}
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if traced || err != nil {
+				return
+			}
+			d.TLS = tlsInfo(state)
+		},
+		GotFirstResponseByte: func() {
+			mu.Lock()
+			traced = true
+			mu.Unlock()
+		},
+	}
+
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), "GET", site.URL, nil)
+	if err != nil {
+		d.Error = err.Error()
+		return d, nil
+	}
+	req.Header.Set("User-Agent", NextUserAgent())
+	redirects := recordRedirects(HTTPClient())
+	start := time.Now()
+	resp, err := redirects.client.Do(req)
+	d.Latency = time.Since(start)
+	// Hooks of connections still being set up may fire late
+	mu.Lock()
+	defer mu.Unlock()
+	d.RedirectChain, d.FinalURL = redirects.hops, redirects.last
+	if err != nil {
+		d.Error = err.Error()
+		return d, nil
+	}
+	defer resp.Body.Close()
+	d.StatusCode, d.Status = resp.StatusCode, resp.Status
+	d.FinalURL = resp.Request.URL.String()
+	d.Available = resp.StatusCode == http.StatusOK
+	if !d.Available {
+		d.Error = fmt.Sprintf("HTTP %d", resp.StatusCode)
+	} else if problem := pageProblem(site, resp.Body); problem != "" {
+		d.Available, d.Error = false, problem
+	}
+	return d, nil
+}
+
+func tlsInfo(state tls.ConnectionState) *TLSInfo {
+	info := &TLSInfo{
+		Version:     tls.VersionName(state.Version),
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+	}
+	if len(state.PeerCertificates) > 0 {
+		cert := state.PeerCertificates[0]
+		info.Subject = cert.Subject.CommonName
+		info.Issuer = cert.Issuer.CommonName
+		info.Expires = cert.NotAfter
+	}
+	return info
+}
+
+// PrintDiagnosis prints the check of a single site, one step per line
+func PrintDiagnosis(w io.Writer, d SiteDiagnosis) {
+	row := func(label, value string) {
+		fmt.Fprintf(w, "%s %s\n", padRight(label+":", 11), value)
+	}
+	fmt.Fprintln(w)
+	row("Site", d.Name)
+	row("URL", d.URL)
+	switch {
+	case d.DNSError != "":
+		row("DNS", "failed: "+d.DNSError)
+	case len(d.Addresses) > 0:
+		row("DNS", fmt.Sprintf("%s (%dms)", strings.Join(d.Addresses, ", "), d.DNSTime.Milliseconds()))
+	default:
+		row("DNS", "not resolved here (an IP address, or resolved by the proxy)")
+	}
+	if d.TLS != nil {
+		tlsLine := d.TLS.Version + ", " + d.TLS.CipherSuite
+		if d.TLS.Subject != "" {
+			tlsLine += fmt.Sprintf(", certificate for %s by %s, expires %s", d.TLS.Subject, d.TLS.Issuer, d.TLS.Expires.Format("2006-01-02"))
+		}
+		row("TLS", tlsLine)
+	} else if strings.HasPrefix(d.URL, "https:") {
+		row("TLS", "no handshake")
+	}
+	if d.Status != "" {
+		row("HTTP", d.Status)
+	}
+	row("Latency", fmt.Sprintf("%dms", d.Latency.Milliseconds()))
+	for _, hop := range d.RedirectChain {
+		row("Redirect", fmt.Sprintf("%s (%d)", hop.URL, hop.Status))
+	}
+	if d.FinalURL != "" {
+		row("Final URL", d.FinalURL)
+	}
+	status := "DOWN"
+	if d.Available {
+		status = "OK"
+	}
+	row("Status", status)
+	if d.Error != "" {
+		row("Error", d.Error)
+	}
+}
@@ -0,0 +1,239 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const (
+	// discoverAhead and discoverBehind are how many numbers above and below
+	// its own the variants of a numbered domain go
+	discoverAhead  = 10
+	discoverBehind = 2
+)
+
+// numberedHost matches hosts like torrenttop152.com: a name, the number
+// the site increments when a domain is blocked, and the rest of the host
+var numberedHost = regexp.MustCompile(`^([a-z][a-z-]*?)(\d+)(\..+)$`)
+
+// Discovery is a new address found for a site that is down
+type Discovery struct {
+	Site    string `json:"site"`
+	Current string `json:"current"`
+	Found   string `json:"found"`
+	// Source is how it was found: "redirect" when the site redirects to
+	// it, "hub" for a redirect or link on one of the site's hubs,
+	// "numbered" for a nearby numbered domain
+	Source string `json:"source"`
+}
+
+// MirrorCandidates returns the nearby numbered variants of rawURL, nearest
+// first with the higher number before the lower, or nil when its host is
+// not numbered
+func MirrorCandidates(rawURL string) []string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+	m := numberedHost.FindStringSubmatch(strings.ToLower(u.Host))
+	if m == nil {
+		return nil
+	}
+	n, err := strconv.Atoi(m[2])
+	if err != nil {
+		return nil
+	}
+	variant := func(i int) string {
+		v := *u
+		v.Host = m[1] + strconv.Itoa(i) + m[3]
+		v.Path, v.RawQuery, v.Fragment = "", "", ""
+		return v.String()
+	}
+	var candidates []string
+	for d := 1; d <= discoverAhead; d++ {
+		candidates = append(candidates, variant(n+d))
+		if d <= discoverBehind && n-d >= 0 {
+			candidates = append(candidates, variant(n-d))
+		}
+	}
+	return candidates
+}
+
+// sameSite reports whether host is a numbered variant of the host of
+// rawURL, such as torrenttop155.com for https://torrenttop152.com
+func sameSite(rawURL, host string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	a := numberedHost.FindStringSubmatch(strings.ToLower(u.Host))
+	b := numberedHost.FindStringSubmatch(strings.ToLower(host))
+	return a != nil && b != nil && a[1] == b[1] && a[3] == b[3]
+}
+
+// DiscoverMirrors looks for a new address of each of the named sites. The
+// hubs of a site are fetched first: a hub that redirects to another host,
+// or links to a numbered variant of the site, points at its address. The
+// nearby numbered variants of its URL are probed next, and the highest
+// that is up is taken. Sites for which nothing is found are left out.
+func DiscoverMirrors(ctx context.Context, names []string) []Discovery {
+	c := GetConfig()
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		found []Discovery
+	)
+	for _, name := range names {
+		site, exists := c.Sites[name]
+		if !exists || site.Type == SiteTypeTorznab {
+			continue
+		}
+		wg.Add(1)
+		go func(name string, site SiteConfig) {
+			defer wg.Done()
+			d, source := fromHubs(ctx, site), "hub"
+			if d == "" {
+				d, source = probeNumbered(ctx, site), "numbered"
+			}
+			if d == "" {
+				return
+			}
+			mu.Lock()
+			found = append(found, Discovery{Site: name, Current: site.URL, Found: d, Source: source})
+			mu.Unlock()
+		}(name, site)
+	}
+	wg.Wait()
+	sort.Slice(found, func(i, j int) bool { return found[i].Site < found[j].Site })
+	return found
+}
+
+// fromHubs returns the address of the site given by the first of its hubs
+// that is up and points elsewhere, or "" when none does
+func fromHubs(ctx context.Context, site SiteConfig) string {
+	for _, hub := range site.Hubs {
+		resp, ok := GetResponseFromURL(ctx, hub)
+		if !ok {
+			continue
+		}
+		final := resp.Request.URL
+		hubURL, _ := url.Parse(hub)
+		current, _ := url.Parse(site.URL)
+		if hubURL != nil && !strings.EqualFold(final.Host, hubURL.Host) &&
+			(current == nil || !strings.EqualFold(final.Host, current.Host)) {
+			resp.Body.Close()
+			return final.Scheme + "://" + final.Host
+		}
+		link := linkedVariant(resp.Body, final, site.URL)
+		resp.Body.Close()
+		if link != "" && checkPage(ctx, link, site) {
+			return link
+		}
+	}
+	return ""
+}
+
+// linkedVariant returns the first link of the page in body to a numbered
+// variant of siteURL other than siteURL itself
+func linkedVariant(body io.Reader, base *url.URL, siteURL string) string {
+	doc, err := goquery.NewDocumentFromReader(body)
+	if err != nil {
+		return ""
+	}
+	current, _ := url.Parse(siteURL)
+	link := ""
+	doc.Find("a[href]").EachWithBreak(func(i int, s *goquery.Selection) bool {
+		href, _ := s.Attr("href")
+		u, err := base.Parse(href)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") ||
+			(current != nil && strings.EqualFold(u.Host, current.Host)) || !sameSite(siteURL, u.Host) {
+			return true
+		}
+		link = u.Scheme + "://" + u.Host
+		return false
+	})
+	return link
+}
+
+// probeNumbered probes the numbered variants of the site's URL at once and
+// returns the highest that is up and shows the page of the site, or ""
+// when none does
+func probeNumbered(ctx context.Context, site SiteConfig) string {
+	candidates := MirrorCandidates(site.URL)
+	up := make([]bool, len(candidates))
+	var wg sync.WaitGroup
+	for i, u := range candidates {
+		wg.Add(1)
+		go func(i int, u string) {
+			defer wg.Done()
+			up[i] = checkPage(ctx, u, site)
+		}(i, u)
+	}
+	wg.Wait()
+	best, bestN := "", -1
+	for i, u := range candidates {
+		if !up[i] {
+			continue
+		}
+		parsed, _ := url.Parse(u)
+		m := numberedHost.FindStringSubmatch(parsed.Host)
+		if n, _ := strconv.Atoi(m[2]); n > bestN {
+			best, bestN = u, n
+		}
+	}
+	return best
+}
+
+// Redirects returns the sites of statuses that redirect to another host,
+// as discoveries of that host
+func Redirects(statuses []SiteStatus) []Discovery {
+	var found []Discovery
+	for _, s := range statuses {
+		if s.Redirect != "" {
+			found = append(found, Discovery{Site: s.Name, Current: s.URL, Found: s.Redirect, Source: "redirect"})
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].Site < found[j].Site })
+	return found
+}
+
+// ApplyDiscoveries makes each found address the URL of its site
+func ApplyDiscoveries(found []Discovery) error {
+	return UpdateConfig(func(c *Config) error {
+		for _, d := range found {
+			site, exists := c.Sites[d.Site]
+			if !exists {
+				return fmt.Errorf("site '%s' not found", d.Site)
+			}
+			site.URL = d.Found
+			c.Sites[d.Site] = site
+		}
+		return nil
+	})
+}
+
+// PrintDiscoveries prints the addresses found by DiscoverMirrors for the
+// sites that are down
+func PrintDiscoveries(w io.Writer, found []Discovery, applied bool) {
+	if len(found) == 0 {
+		fmt.Fprintln(w, "No new addresses found for the sites that are down")
+		return
+	}
+	for _, d := range found {
+		fmt.Fprintf(w, "%s: %s -> %s (%s)\n", d.Site, d.Current, d.Found, d.Source)
+	}
+	if applied {
+		fmt.Fprintf(w, "Updated %s\n", GetConfigPath())
+	} else {
+		fmt.Fprintln(w, "Run 'tspider doctor --discover --apply' to update the config")
+	}
+}
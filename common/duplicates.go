@@ -0,0 +1,105 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// normalizeSiteURL makes URLs that reach the same site compare equal
+func normalizeSiteURL(u string) string {
+	return strings.TrimRight(strings.ToLower(strings.TrimSpace(u)), "/")
+}
+
+// DuplicateURLs returns the URLs used by more than one site of c, each with
+// the sorted names of the sites sharing it
+func DuplicateURLs(c *Config) map[string][]string {
+	byURL := map[string][]string{}
+	for name, site := range c.Sites {
+		u := normalizeSiteURL(site.URL)
+		byURL[u] = append(byURL[u], name)
+	}
+	for u, names := range byURL {
+		if len(names) < 2 {
+			delete(byURL, u)
+			continue
+		}
+		sort.Strings(names)
+	}
+	return byURL
+}
+
+// warnDuplicateURL prints a warning when sites other than name already use url
+func warnDuplicateURL(c *Config, name, url string) {
+	var others []string
+	for other, site := range c.Sites {
+		if other != name && normalizeSiteURL(site.URL) == normalizeSiteURL(url) {
+			others = append(others, other)
+		}
+	}
+	if len(others) > 0 {
+		sort.Strings(others)
+		fmt.Fprintf(os.Stderr, "[!] %s has the same URL as %s; use 'tspider config validate --dedupe' to merge them\n",
+			name, strings.Join(others, ", "))
+	}
+}
+
+// DedupeSites keeps one site per URL and removes the others, pointing the
+// profiles that used them at the kept site. A site tspider can search, a
+// default site with its own scraper or a Torznab or board site, is kept
+// over a name no scraper answers to, then an enabled site over a disabled
+// one, then the first by name. The kept site is enabled when any of the
+// merged ones was. It returns removed → kept.
+func DedupeSites() (map[string]string, error) {
+	merged := map[string]string{}
+	defaults := DefaultConfig().Sites
+	err := UpdateConfig(func(c *Config) error {
+		for _, names := range DuplicateURLs(c) {
+			rank := func(name string) int {
+				site := c.Sites[name]
+				r := 0
+				if _, ok := defaults[name]; !ok && site.Type == "" {
+					r += 2
+				}
+				if !site.Enabled {
+					r++
+				}
+				return r
+			}
+			keep := names[0]
+			enabled := false
+			for _, name := range names {
+				if rank(name) < rank(keep) {
+					keep = name
+				}
+				enabled = enabled || c.Sites[name].Enabled
+			}
+			site := c.Sites[keep]
+			site.Enabled = enabled
+			c.Sites[keep] = site
+			for _, name := range names {
+				if name != keep {
+					merged[name] = keep
+				}
+			}
+		}
+		for removed := range merged {
+			delete(c.Sites, removed)
+		}
+		for profile, sites := range c.Profiles {
+			var kept []string
+			for _, site := range sites {
+				if to, ok := merged[site]; ok {
+					site = to
+				}
+				if !containsString(kept, site) {
+					kept = append(kept, site)
+				}
+			}
+			c.Profiles[profile] = kept
+		}
+		return nil
+	})
+	return merged, err
+}
@@ -0,0 +1,14 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows)
+
+package common
+
+import (
+	"errors"
+	"os"
+)
+
+// disableEcho fails where there is no known way to turn off the echo of a
+// terminal, so that a password is never shown as it is typed
+func disableEcho(f *os.File) (func(), error) {
+	return nil, errors.New("not supported on this system")
+}
@@ -0,0 +1,28 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package common
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// disableEcho turns off the echo of the terminal f with stty and returns a
+// function that restores its previous settings
+func disableEcho(f *os.File) (func(), error) {
+	stty := func(args ...string) (string, error) {
+		cmd := exec.Command("stty", args...)
+		cmd.Stdin = f
+		out, err := cmd.Output()
+		return strings.TrimSpace(string(out)), err
+	}
+	saved, err := stty("-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stty("-echo"); err != nil {
+		return nil, err
+	}
+	return func() { stty(saved) }, nil
+}
@@ -0,0 +1,27 @@
+//go:build windows
+
+package common
+
+import (
+	"os"
+	"syscall"
+)
+
+var procSetConsoleMode = kernel32.NewProc("SetConsoleMode")
+
+// enableEchoInput is ENABLE_ECHO_INPUT
+const enableEchoInput = 0x4
+
+// disableEcho turns off the echo of the console f and returns a function
+// that restores its previous mode
+func disableEcho(f *os.File) (func(), error) {
+	h := syscall.Handle(f.Fd())
+	var mode uint32
+	if err := syscall.GetConsoleMode(h, &mode); err != nil {
+		return nil, err
+	}
+	if r, _, err := procSetConsoleMode.Call(uintptr(h), uintptr(mode&^enableEchoInput)); r == 0 {
+		return nil, err
+	}
+	return func() { procSetConsoleMode.Call(uintptr(h), uintptr(mode)) }, nil
+}
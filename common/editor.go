@@ -0,0 +1,45 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Editor returns the command line of the user's editor: $VISUAL, then
+// $EDITOR, then notepad on Windows or the first of nano and vi found
+func Editor() ([]string, error) {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if args := strings.Fields(os.Getenv(env)); len(args) > 0 {
+			return args, nil
+		}
+	}
+	candidates := []string{"nano", "vi"}
+	if runtime.GOOS == "windows" {
+		candidates = []string{"notepad"}
+	}
+	for _, name := range candidates {
+		if _, err := exec.LookPath(name); err == nil {
+			return []string{name}, nil
+		}
+	}
+	return nil, fmt.Errorf("no editor found; set $EDITOR, or edit %s by hand", GetConfigPath())
+}
+
+// EditFile opens path in the user's editor and waits for it to exit
+func EditFile(path string) error {
+	args, err := Editor()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(args[0], append(args[1:], path)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor %s: %w", args[0], err)
+	}
+	return nil
+}
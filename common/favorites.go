@@ -0,0 +1,164 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// Favorite is a bookmarked result
+type Favorite struct {
+	SearchResult
+	// Keyword is the search the result was found by, empty when it was
+	// bookmarked by a bare info hash
+	Keyword string    `json:"keyword,omitempty"`
+	Added   time.Time `json:"added"`
+}
+
+// GetFavoritesPath returns the favorites file path
+func GetFavoritesPath() string {
+	return filepath.Join(filepath.Dir(GetConfigPath()), ".tspider_favorites.json")
+}
+
+// LoadFavorites returns the favorites, oldest first. A missing file is no
+// favorites.
+func LoadFavorites() ([]Favorite, error) {
+	data, err := os.ReadFile(GetFavoritesPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read favorites: %w", err)
+	}
+	var favs []Favorite
+	if err := json.Unmarshal(data, &favs); err != nil {
+		return nil, fmt.Errorf("failed to parse favorites %s: %w", GetFavoritesPath(), err)
+	}
+	return favs, nil
+}
+
+func saveFavorites(favs []Favorite) error {
+	data, err := json.MarshalIndent(favs, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal favorites: %w", err)
+	}
+	if err := WriteFileAtomic(GetFavoritesPath(), data); err != nil {
+		return fmt.Errorf("failed to save favorites: %w", err)
+	}
+	return nil
+}
+
+// ResolveFavorite finds the result ref names: the Nth result of the most
+// recent search, as listed, or a result with that info hash in the recorded
+// searches, most recent first. An info hash not found there is bookmarked
+// with a bare magnet link.
+func ResolveFavorite(ref string) (Favorite, error) {
+	entries, err := LoadHistory()
+	if err != nil {
+		return Favorite{}, err
+	}
+	if n, err := strconv.Atoi(ref); err == nil {
+		if len(entries) == 0 {
+			return Favorite{}, fmt.Errorf("no search history yet")
+		}
+		last := entries[len(entries)-1]
+		results, err := LoadHistoryResults(last.ID)
+		if err != nil {
+			return Favorite{}, err
+		}
+		if n < 1 || n > len(results) {
+			return Favorite{}, fmt.Errorf("the last search (%q) found %d result(s); choose 1 to %d", last.Keyword, len(results), len(results))
+		}
+		return Favorite{SearchResult: results[n-1], Keyword: last.Keyword}, nil
+	}
+
+	hash := InfoHash("magnet:?xt=urn:btih:" + strings.TrimSpace(ref))
+	if hash == "" {
+		return Favorite{}, fmt.Errorf("%q is neither a result number nor an info hash", ref)
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		results, err := LoadHistoryResults(entries[i].ID)
+		if err != nil {
+			continue
+		}
+		for _, r := range results {
+			if InfoHash(r.Magnet) == hash {
+				return Favorite{SearchResult: r, Keyword: entries[i].Keyword}, nil
+			}
+		}
+	}
+	magnet := "magnet:?xt=urn:btih:" + hash
+	return Favorite{SearchResult: SearchResult{Title: hash, Magnet: magnet}}, nil
+}
+
+// AddFavorite bookmarks fav, unless a favorite with the same info hash
+// exists, and reports whether it was added
+func AddFavorite(fav Favorite) (bool, error) {
+	favs, err := LoadFavorites()
+	if err != nil {
+		return false, err
+	}
+	key := resultKey(fav.SearchResult)
+	for _, f := range favs {
+		if resultKey(f.SearchResult) == key {
+			return false, nil
+		}
+	}
+	if fav.Added.IsZero() {
+		fav.Added = time.Now()
+	}
+	return true, saveFavorites(append(favs, fav))
+}
+
+// RemoveFavorite removes the Nth favorite, as listed, or the one with an
+// info hash, and returns it
+func RemoveFavorite(ref string) (Favorite, error) {
+	favs, err := LoadFavorites()
+	if err != nil {
+		return Favorite{}, err
+	}
+	i := -1
+	if n, err := strconv.Atoi(ref); err == nil {
+		if n < 1 || n > len(favs) {
+			return Favorite{}, fmt.Errorf("there are %d favorite(s); choose 1 to %d", len(favs), len(favs))
+		}
+		i = n - 1
+	} else if hash := InfoHash("magnet:?xt=urn:btih:" + strings.TrimSpace(ref)); hash != "" {
+		for j, f := range favs {
+			if InfoHash(f.Magnet) == hash {
+				i = j
+				break
+			}
+		}
+	}
+	if i < 0 {
+		return Favorite{}, fmt.Errorf("no favorite %q; see 'tspider fav list'", ref)
+	}
+	removed := favs[i]
+	return removed, saveFavorites(append(favs[:i], favs[i+1:]...))
+}
+
+// PrintFavorites prints favs as a numbered table
+func PrintFavorites(w io.Writer, favs []Favorite) {
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"#", "Title", "Site", "Size", "Added", "Magnet"})
+	for i, f := range favs {
+		table.Append([]string{
+			strconv.Itoa(i + 1),
+			displayTitle(f.Title),
+			f.Site,
+			f.Size,
+			f.Added.Format("2006-01-02"),
+			f.Magnet,
+		})
+	}
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.Render()
+}
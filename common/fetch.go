@@ -0,0 +1,277 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+// Fetcher fetches the pages scrapers parse, giving up when ctx is done.
+// When ok is false the body, if any, has already been closed.
+type Fetcher interface {
+	Fetch(ctx context.Context, url string) (resp *http.Response, ok bool)
+}
+
+var (
+	// DefaultFetcher fetches the pages of most sites
+	DefaultFetcher Fetcher = HTTPFetcher{}
+	// JSFetcher fetches the pages of sites with render_js set. When nil, a
+	// BrowserFetcher is used if a browser is found.
+	JSFetcher Fetcher
+)
+
+// FetcherFor returns the fetcher for url: a browser when its site has
+// render_js set and one is available, DefaultFetcher otherwise
+func FetcherFor(rawURL string) Fetcher {
+	if site, ok := siteConfigForURL(rawURL); ok && site.RenderJS {
+		if JSFetcher != nil {
+			return JSFetcher
+		}
+		if b := NewBrowserFetcher(); b != nil {
+			return b
+		}
+	}
+	return DefaultFetcher
+}
+
+// defaultMaxBodyMB is the largest page read, in megabytes, when the config
+// does not set max_body_mb
+const defaultMaxBodyMB = 5
+
+// ErrBodyTooLarge is returned when reading a page beyond max_body_mb
+var ErrBodyTooLarge = errors.New("response body too large")
+
+// maxBodySize returns the largest page read in bytes, or 0 for no limit
+func maxBodySize() int64 {
+	mb := GetConfig().MaxBodyMB
+	if mb < 0 {
+		return 0
+	}
+	if mb == 0 {
+		mb = defaultMaxBodyMB
+	}
+	return int64(mb) << 20
+}
+
+// limitBody caps the body of resp at limit bytes, so a hostile or broken
+// mirror cannot make a scraper read without end. A response announcing a
+// longer body is closed at once; one that turns out longer fails with
+// ErrBodyTooLarge once limit bytes have been read.
+func limitBody(resp *http.Response, limit int64) (*http.Response, bool) {
+	if limit <= 0 {
+		return resp, true
+	}
+	if resp.ContentLength > limit {
+		resp.Body.Close()
+		return nil, false
+	}
+	resp.Body = &limitedBody{ReadCloser: resp.Body, left: limit}
+	return resp, true
+}
+
+// limitedBody is a body failing with ErrBodyTooLarge after left bytes
+type limitedBody struct {
+	io.ReadCloser
+	left int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.left <= 0 {
+		// Tell a body that ends here from one that goes on
+		var one [1]byte
+		if n, err := b.ReadCloser.Read(one[:]); n == 0 {
+			return 0, err
+		}
+		return 0, ErrBodyTooLarge
+	}
+	if int64(len(p)) > b.left {
+		p = p[:b.left]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.left -= int64(n)
+	return n, err
+}
+
+// siteConfigForURL returns the configured site serving rawURL
+func siteConfigForURL(rawURL string) (SiteConfig, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return SiteConfig{}, false
+	}
+	for _, site := range GetConfig().Sites {
+		if site.servesHost(u.Host) {
+			return site, true
+		}
+	}
+	return SiteConfig{}, false
+}
+
+// HTTPFetcher fetches pages with the shared HTTP client. Timeouts, 5xx and
+// 429 responses are retried up to max_retries times with a growing backoff.
+// Each retry, and each request failing for good, is recorded in Retries().
+type HTTPFetcher struct{}
+
+// Fetch returns the response to a GET of url. Canceling ctx aborts the
+// request and any retries still to come.
+func (HTTPFetcher) Fetch(ctx context.Context, url string) (resp *http.Response, ok bool) {
+	retried := false
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			return resp, false
+		}
+		req.Header.Set("User-Agent", NextUserAgent())
+		start := time.Now()
+		resp, err = HTTPClient().Do(req)
+		Metrics().request(siteForURL(url), time.Since(start), err == nil && resp.StatusCode == 200)
+		reason := retryReason(resp, err)
+		if reason == "" || attempt >= maxRetries() || ctx.Err() != nil {
+			ok = err == nil && resp.StatusCode == 200
+			if retried {
+				Retries().finished(siteForURL(url), ok)
+			}
+			if !ok && ctx.Err() == nil {
+				Retries().failed(siteForURL(url), failureDescription(resp, err, attempt))
+			}
+			if err == nil && !ok {
+				// Release the pooled connection; callers only read successful responses
+				resp.Body.Close()
+			}
+			return resp, ok
+		}
+		if err == nil {
+			resp.Body.Close()
+		}
+		retried = true
+		Retries().retried(siteForURL(url), reason)
+		wait := time.NewTimer(retryDelay(attempt, resp))
+		select {
+		case <-wait.C:
+		case <-ctx.Done():
+			wait.Stop()
+			Retries().finished(siteForURL(url), false)
+			return nil, false
+		}
+	}
+}
+
+// browserErrorPage reports whether dom, dumped by the browser, is not a
+// page of the site: empty, without a body, or the error page Chrome shows
+// when the site cannot be reached
+func browserErrorPage(dom []byte) bool {
+	dom = bytes.ToLower(bytes.TrimSpace(dom))
+	return len(dom) == 0 || !bytes.Contains(dom, []byte("<body")) ||
+		bytes.Contains(dom, []byte("chrome-error://")) || bytes.Contains(dom, []byte(`id="main-frame-error"`))
+}
+
+// failureDescription describes a failed request for the per-site warnings
+func failureDescription(resp *http.Response, err error, retries int) string {
+	var d string
+	if err != nil {
+		d = err.Error()
+	} else {
+		d = fmt.Sprintf("%s answered %s", resp.Request.URL.Redacted(), resp.Status)
+	}
+	if retries > 0 {
+		d += fmt.Sprintf(" (after %d retries)", retries)
+	}
+	return d
+}
+
+// browserNames are the executables tried by NewBrowserFetcher, in order
+var browserNames = []string{"chromium", "chromium-browser", "google-chrome", "google-chrome-stable", "chrome", "msedge"}
+
+// BrowserFetcher fetches pages with a headless Chrome or Chromium and
+// returns the DOM after its scripts have run
+type BrowserFetcher struct {
+	// Path is the browser executable
+	Path string
+	// Budget is how long scripts may run before the DOM is taken
+	Budget time.Duration
+}
+
+// NewBrowserFetcher returns a fetcher using the browser at browser_path or,
+// without one, the first Chrome or Chromium found; nil when there is none
+func NewBrowserFetcher() *BrowserFetcher {
+	path := GetConfig().BrowserPath
+	if path == "" {
+		path = findBrowser()
+	}
+	if path == "" {
+		return nil
+	}
+	return &BrowserFetcher{Path: path, Budget: 5 * time.Second}
+}
+
+func findBrowser() string {
+	for _, name := range browserNames {
+		if path, err := exec.LookPath(name); err == nil {
+			return path
+		}
+	}
+	if runtime.GOOS == "darwin" {
+		for _, app := range []string{"Google Chrome", "Chromium"} {
+			path := "/Applications/" + app + ".app/Contents/MacOS/" + app
+			if _, err := os.Stat(path); err == nil {
+				return path
+			}
+		}
+	}
+	return ""
+}
+
+// Fetch loads url in the browser and returns its DOM as the body of a 200
+// response. The browser does not report the HTTP status, so an empty DOM
+// or Chrome's own error page counts as a failure. Canceling ctx kills the
+// browser.
+func (b *BrowserFetcher) Fetch(ctx context.Context, url string) (*http.Response, bool) {
+	c := GetConfig()
+	limit := time.Duration(c.Timeout) * time.Second
+	if limit <= 0 {
+		limit = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, limit+b.Budget)
+	defer cancel()
+	args := []string{
+		"--headless=new", "--disable-gpu", "--no-first-run", "--mute-audio",
+		"--user-agent=" + NextUserAgent(),
+		"--virtual-time-budget=" + strconv.FormatInt(b.Budget.Milliseconds(), 10),
+	}
+	if proxy := runConfig(c).Proxy; proxy != "" {
+		args = append(args, "--proxy-server="+proxy)
+	}
+	// Chrome refuses to run as root, as in containers, with its sandbox;
+	// turning it off is left to browser_no_sandbox
+	if c.BrowserNoSandbox {
+		args = append(args, "--no-sandbox")
+	}
+	args = append(args, "--dump-dom", url)
+	start := time.Now()
+	out, err := exec.CommandContext(ctx, b.Path, args...).Output()
+	ok := err == nil && !browserErrorPage(out)
+	Metrics().request(siteForURL(url), time.Since(start), ok)
+	if !ok {
+		return nil, false
+	}
+	req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"text/html; charset=utf-8"}},
+		Body:          io.NopCloser(bytes.NewReader(out)),
+		ContentLength: int64(len(out)),
+		Request:       req,
+	}, true
+}
@@ -0,0 +1,40 @@
+package common
+
+import "time"
+
+// FreshnessScore estimates how likely a torrent is to still download, from
+// its upload date and seeder count alone:
+//
+//	high    - seeded by 10+ peers and uploaded within 30 days
+//	medium  - seeded and uploaded within 180 days
+//	low     - seeded but old, or too new to have peers yet
+//	dead    - no seeders and older than 30 days
+//	unknown - the site shows neither date nor seeders
+func FreshnessScore(r SearchResult) string {
+	return freshnessScore(r, time.Now())
+}
+
+func freshnessScore(r SearchResult, now time.Time) string {
+	const day = 24 * time.Hour
+	if r.Date.IsZero() {
+		switch {
+		case r.Seeders >= 10:
+			return "medium"
+		case r.Seeders > 0:
+			return "low"
+		}
+		return "unknown"
+	}
+	age := now.Sub(r.Date)
+	switch {
+	case r.Seeders >= 10 && age <= 30*day:
+		return "high"
+	case r.Seeders > 0 && age <= 180*day:
+		return "medium"
+	case r.Seeders > 0:
+		return "low"
+	case age <= 30*day:
+		return "low"
+	}
+	return "dead"
+}
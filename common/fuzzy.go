@@ -0,0 +1,61 @@
+package common
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// FuzzyScore matches pattern against text the way fzf does: the runes of
+// pattern must appear in text in order, ignoring case and spaces in pattern.
+// Runes matched right after the previous one, and to a lesser degree at the
+// start of a word, score higher. It returns false when text does not match.
+func FuzzyScore(pattern, text string) (int, bool) {
+	p := []rune(strings.ToLower(strings.Join(strings.Fields(pattern), "")))
+	if len(p) == 0 {
+		return 0, true
+	}
+	t := []rune(strings.ToLower(text))
+	score, j, last := 0, 0, -2
+	for i, r := range t {
+		if j == len(p) {
+			break
+		}
+		if r != p[j] {
+			continue
+		}
+		score++
+		if i == last+1 {
+			score += 4
+		} else if i == 0 || !unicode.IsLetter(t[i-1]) && !unicode.IsDigit(t[i-1]) {
+			score += 2
+		}
+		last = i
+		j++
+	}
+	if j < len(p) {
+		return 0, false
+	}
+	return score, true
+}
+
+// FuzzyFilter returns the results whose title matches pattern, best matches
+// first; equally good matches keep their order
+func FuzzyFilter(results []SearchResult, pattern string) []SearchResult {
+	type scored struct {
+		r     SearchResult
+		score int
+	}
+	var matches []scored
+	for _, r := range results {
+		if s, ok := FuzzyScore(pattern, r.Title); ok {
+			matches = append(matches, scored{r, s})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+	filtered := make([]SearchResult, len(matches))
+	for i, m := range matches {
+		filtered[i] = m.r
+	}
+	return filtered
+}
@@ -0,0 +1,272 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// HistoryEntry records one search invocation
+type HistoryEntry struct {
+	// ID numbers the searches from 1, oldest first
+	ID      int       `json:"id,omitempty"`
+	Time    time.Time `json:"time"`
+	Keyword string    `json:"keyword"`
+	Lang    string    `json:"lang,omitempty"`
+	// Args are the command line arguments the search was run with
+	Args    []string `json:"args"`
+	Results int      `json:"results"`
+	// Sites counts the results by site
+	Sites map[string]int `json:"sites,omitempty"`
+}
+
+// GetHistoryPath returns the search history file path
+func GetHistoryPath() string {
+	return filepath.Join(filepath.Dir(GetConfigPath()), ".tspider_history.jsonl")
+}
+
+// GetHistoryResultsDir returns the directory holding the results of each
+// recorded search, in a file named after its ID
+func GetHistoryResultsDir() string {
+	return filepath.Join(filepath.Dir(GetConfigPath()), ".tspider_history.d")
+}
+
+// defaultHistoryMaxEntries is how many searches the history keeps
+const defaultHistoryMaxEntries = 500
+
+// AppendHistory adds an entry to the search history, numbering it after
+// the last one unless it has an ID
+func AppendHistory(e HistoryEntry) error {
+	unlock, err := lockConfig()
+	if err != nil {
+		return fmt.Errorf("failed to lock history: %w", err)
+	}
+	defer unlock()
+	_, entries, err := appendHistory(e)
+	if err != nil {
+		return err
+	}
+	return pruneHistory(entries)
+}
+
+// RecordHistory adds an entry for a search to the history, with the count
+// of its results by site, and keeps the results for LoadHistoryResults.
+// It returns the entry as recorded.
+func RecordHistory(e HistoryEntry, results []SearchResult) (HistoryEntry, error) {
+	e.Results = len(results)
+	e.Sites = map[string]int{}
+	for _, r := range results {
+		e.Sites[r.Site]++
+	}
+	data, err := json.Marshal(results)
+	if err != nil {
+		return e, fmt.Errorf("failed to marshal search results: %w", err)
+	}
+	// Concurrent searches must not be given the same ID, or they would
+	// overwrite each other's results
+	unlock, err := lockConfig()
+	if err != nil {
+		return e, fmt.Errorf("failed to lock history: %w", err)
+	}
+	defer unlock()
+	e, entries, err := appendHistory(e)
+	if err != nil {
+		return e, err
+	}
+	if err := os.MkdirAll(GetHistoryResultsDir(), 0755); err != nil {
+		return e, fmt.Errorf("failed to save search results: %w", err)
+	}
+	if err := WriteFileAtomic(historyResultsPath(e.ID), data); err != nil {
+		return e, fmt.Errorf("failed to save search results: %w", err)
+	}
+	return e, pruneHistory(entries)
+}
+
+func historyResultsPath(id int) string {
+	return filepath.Join(GetHistoryResultsDir(), strconv.Itoa(id)+".json")
+}
+
+// appendHistory adds e to the history and returns it as recorded, with the
+// whole history. The caller holds the config lock.
+func appendHistory(e HistoryEntry) (HistoryEntry, []HistoryEntry, error) {
+	entries, err := LoadHistory()
+	if err != nil {
+		return e, nil, err
+	}
+	if e.ID == 0 {
+		e.ID = len(entries) + 1
+		if n := len(entries); n > 0 && entries[n-1].ID >= e.ID {
+			e.ID = entries[n-1].ID + 1
+		}
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return e, nil, fmt.Errorf("failed to marshal history entry: %w", err)
+	}
+	f, err := os.OpenFile(GetHistoryPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return e, nil, fmt.Errorf("failed to open history: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return e, nil, fmt.Errorf("failed to write history: %w", err)
+	}
+	return e, append(entries, e), nil
+}
+
+// pruneHistory rewrites the history with only its latest
+// history_max_entries entries, and removes the results of the searches
+// dropped, as well as any left behind by earlier ones. The caller holds the
+// config lock.
+func pruneHistory(entries []HistoryEntry) error {
+	max := GetConfig().HistoryMaxEntries
+	if max == 0 {
+		max = defaultHistoryMaxEntries
+	}
+	if max < 0 || len(entries) <= max {
+		return nil
+	}
+	kept := entries[len(entries)-max:]
+	var buf bytes.Buffer
+	for _, e := range kept {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("failed to marshal history entry: %w", err)
+		}
+		buf.Write(append(data, '\n'))
+	}
+	if err := WriteFileAtomic(GetHistoryPath(), buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	files, err := os.ReadDir(GetHistoryResultsDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to prune search results: %w", err)
+	}
+	for _, f := range files {
+		id, err := strconv.Atoi(strings.TrimSuffix(f.Name(), ".json"))
+		if err != nil || id >= kept[0].ID {
+			continue
+		}
+		if err := os.Remove(historyResultsPath(id)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to prune search results: %w", err)
+		}
+	}
+	return nil
+}
+
+// LoadHistory returns the search history, oldest first.
+// A missing history file is not an error; malformed lines are skipped.
+// Entries recorded before searches were numbered get the ID following the
+// previous entry.
+func LoadHistory() ([]HistoryEntry, error) {
+	f, err := os.Open(GetHistoryPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history: %w", err)
+	}
+	defer f.Close()
+	var entries []HistoryEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e HistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		if e.ID == 0 {
+			e.ID = 1
+			if n := len(entries); n > 0 {
+				e.ID = entries[n-1].ID + 1
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// RecentSearch returns the nth most recent search, starting at 1
+func RecentSearch(n int) (*HistoryEntry, error) {
+	entries, err := LoadHistory()
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no search history yet")
+	}
+	if n < 1 || n > len(entries) {
+		return nil, fmt.Errorf("history has %d search(es); choose 1 to %d", len(entries), len(entries))
+	}
+	return &entries[len(entries)-n], nil
+}
+
+// HistoryByID returns the search numbered id
+func HistoryByID(id int) (*HistoryEntry, error) {
+	entries, err := LoadHistory()
+	if err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		if entries[i].ID == id {
+			return &entries[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no search #%d in the history; see 'tspider history list'", id)
+}
+
+// LoadHistoryResults returns the results recorded for the search numbered id
+func LoadHistoryResults(id int) ([]SearchResult, error) {
+	data, err := os.ReadFile(historyResultsPath(id))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("the results of search #%d were not recorded", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read search results: %w", err)
+	}
+	var results []SearchResult
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, fmt.Errorf("failed to parse the results of search #%d: %w", id, err)
+	}
+	return results, nil
+}
+
+// PrintHistory prints entries as a table with their result counts by site
+func PrintHistory(w io.Writer, entries []HistoryEntry) {
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"ID", "Time", "Keyword", "Lang", "Results", "Sites"})
+	for _, e := range entries {
+		sites := make([]string, 0, len(e.Sites))
+		for site, n := range e.Sites {
+			sites = append(sites, fmt.Sprintf("%s %d", site, n))
+		}
+		sort.Strings(sites)
+		lang := e.Lang
+		if lang == "" {
+			lang = "jp"
+		}
+		table.Append([]string{
+			strconv.Itoa(e.ID),
+			e.Time.Format("2006-01-02 15:04"),
+			e.Keyword,
+			lang,
+			strconv.Itoa(e.Results),
+			strings.Join(sites, ", "),
+		})
+	}
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.Render()
+}
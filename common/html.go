@@ -0,0 +1,182 @@
+package common
+
+import (
+	"context"
+	"encoding/base64"
+	"html/template"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxFaviconSize caps the favicon bytes embedded in a report
+const maxFaviconSize = 64 << 10
+
+// defaultFaviconDays is how long a favicon is kept on disk when the config
+// does not set favicon_cache_days
+const defaultFaviconDays = 30
+
+var (
+	// favicons caches data URIs per site for this run; "" marks a failed fetch
+	favicons sync.Map
+)
+
+// faviconCachePath returns the on-disk cache file for a site's favicon
+func faviconCachePath(site string) string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "tspider", "favicons", site)
+}
+
+// faviconTTL returns how long a favicon cached on disk is used before it
+// is fetched again
+func faviconTTL() time.Duration {
+	days := defaultFaviconDays
+	if d := GetConfig().FaviconDays; d > 0 {
+		days = d
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// Favicon returns the favicon of site as a data URI, or "" if it cannot be
+// fetched. Icons are cached in memory and under the user cache directory,
+// where they are fetched again after faviconTTL. An expired icon is still
+// used when fetching it again fails.
+func Favicon(site string) string {
+	if v, ok := favicons.Load(site); ok {
+		return v.(string)
+	}
+	path := faviconCachePath(site)
+	var stale string
+	if path != "" {
+		if info, err := os.Stat(path); err == nil {
+			if data, err := os.ReadFile(path); err == nil {
+				if time.Since(info.ModTime()) < faviconTTL() {
+					favicons.Store(site, string(data))
+					return string(data)
+				}
+				stale = string(data)
+			}
+		}
+	}
+	uri := fetchFavicon(SiteURL(site))
+	if uri == "" {
+		favicons.Store(site, stale)
+		return stale
+	}
+	favicons.Store(site, uri)
+	if path != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err == nil {
+			os.WriteFile(path, []byte(uri), 0644)
+		}
+	}
+	return uri
+}
+
+// fetchFavicon downloads baseURL/favicon.ico and encodes it as a data URI
+func fetchFavicon(baseURL string) string {
+	if baseURL == "" {
+		return ""
+	}
+	// Favicons are fetched while the report is written, after the search,
+	// and are bounded by the client timeout
+	resp, ok := GetResponseFromURL(context.Background(), strings.TrimRight(baseURL, "/")+"/favicon.ico")
+	if !ok {
+		return ""
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFaviconSize+1))
+	if err != nil || len(data) == 0 || len(data) > maxFaviconSize {
+		return ""
+	}
+	ct, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if !strings.HasPrefix(ct, "image/") {
+		ct = http.DetectContentType(data)
+	}
+	if !strings.HasPrefix(ct, "image/") {
+		return ""
+	}
+	return "data:" + ct + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
+
+type htmlRow struct {
+	SearchResult
+	Link template.URL
+}
+
+type htmlGroup struct {
+	Site    string
+	Favicon template.URL
+	Rows    []htmlRow
+}
+
+var htmlReport = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>tspider: {{.Keyword}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+h2 img { width: 16px; height: 16px; margin-right: 0.4em; vertical-align: middle; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; }
+</style>
+</head>
+<body>
+<h1>{{.Keyword}}</h1>
+<p>{{.Count}} result(s), generated {{.Generated}}</p>
+{{range .Groups}}
+<h2>{{if .Favicon}}<img src="{{.Favicon}}" alt="">{{end}}{{.Site}}</h2>
+<table>
+<tr><th>Title</th><th>Uploader</th><th>Seeders</th><th>Leechers</th><th>Size</th><th>Magnet</th></tr>
+{{range .Rows}}<tr><td>{{.Title}}</td><td>{{.Uploader}}</td><td>{{.Seeders}}</td><td>{{.Leechers}}</td><td>{{.Size}}</td><td>{{if .Link}}<a href="{{.Link}}">magnet</a>{{else}}{{.Magnet}}{{end}}</td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
+
+// WriteHTML renders results as a standalone HTML report grouped by site,
+// with each site's favicon embedded next to its results when available
+func WriteHTML(w io.Writer, keyword string, results []SearchResult) error {
+	bySite := map[string][]htmlRow{}
+	for _, r := range results {
+		row := htmlRow{SearchResult: r}
+		if IsValidMagnet(r.Magnet) {
+			row.Link = template.URL(r.Magnet)
+		}
+		bySite[r.Site] = append(bySite[r.Site], row)
+	}
+	sites := make([]string, 0, len(bySite))
+	for site := range bySite {
+		sites = append(sites, site)
+	}
+	sort.Strings(sites)
+
+	groups := make([]htmlGroup, len(sites))
+	var wg sync.WaitGroup
+	for i, site := range sites {
+		groups[i] = htmlGroup{Site: site, Rows: bySite[site]}
+		wg.Add(1)
+		go func(g *htmlGroup) {
+			defer wg.Done()
+			g.Favicon = template.URL(Favicon(g.Site))
+		}(&groups[i])
+	}
+	wg.Wait()
+
+	return htmlReport.Execute(w, map[string]interface{}{
+		"Keyword":   keyword,
+		"Count":     len(results),
+		"Generated": time.Now().Format("2006-01-02 15:04"),
+		"Groups":    groups,
+	})
+}
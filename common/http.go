@@ -0,0 +1,170 @@
+package common
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultMaxTotalConns caps open connections across all hosts
+	defaultMaxTotalConns = 32
+	// defaultMaxConnsPerHost caps open connections to a single host
+	defaultMaxConnsPerHost = 8
+)
+
+var (
+	httpClient     *http.Client
+	httpClientOnce sync.Once
+)
+
+// HTTPClient returns the client shared by all scrapers, built once from the
+// config and the Tor settings of UseTor. Pages it fetches are revalidated through an HTTPCache unless
+// http_cache_days disables it, the cookies sites set are kept between runs
+// in a CookieJar, and requests carry the headers configured for their site.
+func HTTPClient() *http.Client {
+	httpClientOnce.Do(func() {
+		httpClient = NewHTTPClient(runConfig(GetConfig()))
+		httpClient.Jar = NewCookieJar(GetCookiesDir())
+		if dir, maxAge := httpCacheDir(); dir != "" {
+			httpClient.Transport = NewHTTPCache(dir, maxAge, httpClient.Transport)
+		}
+		httpClient.Transport = &siteHeaders{base: httpClient.Transport}
+	})
+	return httpClient
+}
+
+// NewHTTPClient builds a client whose transport keeps connections alive,
+// caps connections per host and caps the total number of open connections
+// across all hosts as configured in c. Requests go through c.Proxy when set;
+// otherwise the sites with the chrome tls_fingerprint are reached with
+// Chrome's TLS handshake.
+func NewHTTPClient(c *Config) *http.Client {
+	maxTotal := c.MaxTotalConns
+	if maxTotal <= 0 {
+		maxTotal = defaultMaxTotalConns
+	}
+	maxPerHost := c.MaxConnsPerHost
+	if maxPerHost <= 0 {
+		maxPerHost = defaultMaxConnsPerHost
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if c.Proxy != "" {
+		if u, err := url.Parse(c.Proxy); err == nil {
+			transport.Proxy = http.ProxyURL(u)
+			if c.TorIsolateSites && strings.HasPrefix(u.Scheme, "socks5") {
+				transport.Proxy = isolatingProxy(u)
+			}
+		}
+	}
+	transport.MaxConnsPerHost = maxPerHost
+	transport.MaxIdleConnsPerHost = maxPerHost
+	transport.MaxIdleConns = maxTotal
+	dialer := &limitedDialer{
+		dialer:    &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second},
+		sem:       make(chan struct{}, maxTotal),
+		closeIdle: transport.CloseIdleConnections,
+	}
+	transport.DialContext = dialer.DialContext
+	client := &http.Client{
+		Timeout:   time.Duration(c.Timeout) * time.Second,
+		Transport: transport,
+	}
+	if c.Proxy == "" {
+		chrome := newChromeTransport(transport, dialer.DialContext)
+		client.Transport = &fingerprintTransport{base: transport, chrome: chrome}
+		// Connections kept alive by the chrome transports hold slots too
+		dialer.closeIdle = func() {
+			transport.CloseIdleConnections()
+			chrome.CloseIdleConnections()
+		}
+	}
+	return client
+}
+
+// isolatingProxy returns a proxy function sending the requests of each site
+// through proxy with the site name as SOCKS username. Tor isolates streams
+// by SOCKS credentials, so each site gets its own circuit; the transport
+// also keeps separate connections per proxy URL.
+func isolatingProxy(proxy *url.URL) func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		u := *proxy
+		u.User = url.UserPassword(siteForURL(req.URL.String()), "tspider")
+		return &u, nil
+	}
+}
+
+// siteHeaders sets the headers configured for the site of each request
+type siteHeaders struct {
+	base http.RoundTripper
+}
+
+// RoundTrip sends req with the headers of its site
+func (t *siteHeaders) RoundTrip(req *http.Request) (*http.Response, error) {
+	if site, ok := siteConfigForURL(req.URL.String()); ok && len(site.Headers) > 0 {
+		req = req.Clone(req.Context())
+		for name, value := range site.Headers {
+			req.Header.Set(name, value)
+		}
+	}
+	return t.base.RoundTrip(req)
+}
+
+// idleRetry is how often a dial waiting for a slot closes idle connections
+const idleRetry = 50 * time.Millisecond
+
+// limitedDialer allows at most cap(sem) connections to be open at once
+type limitedDialer struct {
+	dialer    *net.Dialer
+	sem       chan struct{}
+	closeIdle func()
+}
+
+// DialContext waits for a free connection slot and dials. While every slot
+// is taken, idle keep-alive connections are closed every idleRetry so they
+// cannot starve new hosts: the transport keeps the connections that become
+// idle again as soon as another request is queued, so closing them once
+// when the wait starts is not enough.
+func (d *limitedDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	select {
+	case d.sem <- struct{}{}:
+	default:
+		tick := time.NewTicker(idleRetry)
+		defer tick.Stop()
+	wait:
+		for {
+			d.closeIdle()
+			select {
+			case d.sem <- struct{}{}:
+				break wait
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-tick.C:
+			}
+		}
+	}
+	conn, err := d.dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		<-d.sem
+		return nil, err
+	}
+	return &limitedConn{Conn: conn, release: func() { <-d.sem }}, nil
+}
+
+// limitedConn frees its dialer slot when closed
+type limitedConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+// Close closes the connection and frees its slot
+func (c *limitedConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.release)
+	return err
+}
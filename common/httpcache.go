@@ -0,0 +1,247 @@
+package common
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// defaultHTTPCacheDays is how long an unused cached page is kept
+	defaultHTTPCacheDays = 14
+	// maxCachedBody is the largest page the HTTP cache stores
+	maxCachedBody = 8 << 20
+)
+
+// HTTPCache is a transport that stores pages served with an ETag or a
+// Last-Modified header on disk and revalidates them on later requests, so a
+// page that has not changed, such as a detail page fetched by an earlier
+// search, is read from disk after a 304 Not Modified instead of downloaded
+// again. Other responses pass through untouched.
+type HTTPCache struct {
+	dir  string
+	base http.RoundTripper
+	// revalidated counts the responses served from disk
+	revalidated atomic.Int64
+}
+
+// httpCacheMeta is what the cache keeps of a response besides its body
+type httpCacheMeta struct {
+	URL          string      `json:"url"`
+	ETag         string      `json:"etag,omitempty"`
+	LastModified string      `json:"last_modified,omitempty"`
+	Header       http.Header `json:"header"`
+	Stored       time.Time   `json:"stored"`
+}
+
+// NewHTTPCache returns a cache in dir sending requests through base. Cached
+// pages not used for maxAge are removed.
+func NewHTTPCache(dir string, maxAge time.Duration, base http.RoundTripper) *HTTPCache {
+	c := &HTTPCache{dir: dir, base: base}
+	c.prune(maxAge)
+	return c
+}
+
+// httpCacheDir returns where the shared client caches pages, or "" when
+// http_cache_days disables the cache
+func httpCacheDir() (string, time.Duration) {
+	days := GetConfig().HTTPCacheDays
+	if days < 0 {
+		return "", 0
+	}
+	if days == 0 {
+		days = defaultHTTPCacheDays
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", 0
+	}
+	return filepath.Join(dir, "tspider", "http"), time.Duration(days) * 24 * time.Hour
+}
+
+// Revalidated returns how many responses were served from disk
+func (c *HTTPCache) Revalidated() int64 {
+	return c.revalidated.Load()
+}
+
+func (c *HTTPCache) path(url, ext string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:16])+ext)
+}
+
+// RoundTrip sends req, conditionally when its page is cached
+func (c *HTTPCache) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != "GET" || req.Header.Get("Range") != "" {
+		return c.base.RoundTrip(req)
+	}
+	url := req.URL.String()
+	meta, cached := c.load(url)
+	if cached {
+		req = req.Clone(req.Context())
+		if meta.ETag != "" {
+			req.Header.Set("If-None-Match", meta.ETag)
+		}
+		if meta.LastModified != "" {
+			req.Header.Set("If-Modified-Since", meta.LastModified)
+		}
+	}
+	resp, err := c.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if cached && resp.StatusCode == http.StatusNotModified {
+		if body, err := os.Open(c.path(url, ".body")); err == nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			c.revalidated.Add(1)
+			// Touch the page so pruning keeps what is in use
+			now := time.Now()
+			os.Chtimes(c.path(url, ".json"), now, now)
+			return c.fromDisk(req, meta, body), nil
+		}
+	}
+	if resp.StatusCode == http.StatusOK && storable(resp) {
+		resp.Body = &cachingBody{ReadCloser: resp.Body, cache: c, meta: httpCacheMeta{
+			URL:          url,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			Header:       storedHeader(resp.Header),
+		}}
+	}
+	return resp, nil
+}
+
+// unstoredHeaders are the response headers left out of the cache: cookies
+// and credentials, which must not be replayed or kept on disk, and the
+// hop-by-hop headers of the connection the page came over
+var unstoredHeaders = []string{
+	"Set-Cookie", "Set-Cookie2", "Authorization", "Proxy-Authorization",
+	"WWW-Authenticate", "Proxy-Authenticate", "Proxy-Authentication-Info", "Authentication-Info",
+	"Connection", "Keep-Alive", "TE", "Trailer", "Transfer-Encoding", "Upgrade",
+}
+
+// storedHeader returns the part of h kept in the cache
+func storedHeader(h http.Header) http.Header {
+	h = h.Clone()
+	// Connection also names the hop-by-hop headers of this response
+	for _, v := range h.Values("Connection") {
+		for _, name := range strings.Split(v, ",") {
+			h.Del(strings.TrimSpace(name))
+		}
+	}
+	for _, name := range unstoredHeaders {
+		h.Del(name)
+	}
+	return h
+}
+
+// storable reports whether resp can be revalidated and may be stored
+func storable(resp *http.Response) bool {
+	if resp.Header.Get("ETag") == "" && resp.Header.Get("Last-Modified") == "" {
+		return false
+	}
+	return !strings.Contains(strings.ToLower(resp.Header.Get("Cache-Control")), "no-store")
+}
+
+// fromDisk builds the 200 response of a revalidated page
+func (c *HTTPCache) fromDisk(req *http.Request, meta httpCacheMeta, body *os.File) *http.Response {
+	header := meta.Header.Clone()
+	header.Del("Content-Length")
+	length := int64(-1)
+	if fi, err := body.Stat(); err == nil {
+		length = fi.Size()
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          body,
+		ContentLength: length,
+		Request:       req,
+	}
+}
+
+func (c *HTTPCache) load(url string) (httpCacheMeta, bool) {
+	var meta httpCacheMeta
+	data, err := os.ReadFile(c.path(url, ".json"))
+	if err != nil || json.Unmarshal(data, &meta) != nil || meta.URL != url {
+		return meta, false
+	}
+	return meta, true
+}
+
+// store keeps a fully read page, readable only by the user as pages may
+// follow a login. The body is written before the metadata, whose presence
+// means the page is usable.
+func (c *HTTPCache) store(meta httpCacheMeta, body []byte) {
+	meta.Stored = time.Now()
+	data, err := json.Marshal(meta)
+	if err != nil || os.MkdirAll(c.dir, 0700) != nil {
+		return
+	}
+	if writeFileAtomicMode(c.path(meta.URL, ".body"), body, 0600) != nil {
+		return
+	}
+	writeFileAtomicMode(c.path(meta.URL, ".json"), data, 0600)
+}
+
+// prune removes the pages not used for maxAge
+func (c *HTTPCache) prune(maxAge time.Duration) {
+	if maxAge <= 0 {
+		return
+	}
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+	cutoff := time.Now().Add(-maxAge)
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		if info, err := e.Info(); err == nil && info.ModTime().Before(cutoff) {
+			os.Remove(filepath.Join(c.dir, name))
+			os.Remove(filepath.Join(c.dir, strings.TrimSuffix(name, ".json")+".body"))
+		}
+	}
+}
+
+// cachingBody copies a response body as it is read and stores the page
+// once it has been read to the end
+type cachingBody struct {
+	io.ReadCloser
+	cache    *HTTPCache
+	meta     httpCacheMeta
+	buf      bytes.Buffer
+	tooLarge bool
+	stored   bool
+}
+
+func (b *cachingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if !b.tooLarge {
+		if b.buf.Len()+n > maxCachedBody {
+			b.tooLarge = true
+			b.buf = bytes.Buffer{}
+		} else {
+			b.buf.Write(p[:n])
+		}
+	}
+	if err == io.EOF && !b.tooLarge && !b.stored {
+		b.stored = true
+		b.cache.store(b.meta, b.buf.Bytes())
+	}
+	return n, err
+}
@@ -0,0 +1,155 @@
+package common
+
+import (
+	"strings"
+	"unicode"
+)
+
+// KeywordTransform is one named rewrite applied to a keyword before searching
+type KeywordTransform struct {
+	Name  string
+	Apply func(string) string
+}
+
+// KeywordTransforms is the default chain applied by TransformKeyword, in order.
+// --raw-keyword skips all of them.
+var KeywordTransforms = []KeywordTransform{
+	{Name: "trim", Apply: strings.TrimSpace},
+	{Name: "collapse-spaces", Apply: func(s string) string { return strings.Join(strings.Fields(s), " ") }},
+}
+
+// TransformKeyword runs keyword through KeywordTransforms
+func TransformKeyword(keyword string) string {
+	for _, t := range KeywordTransforms {
+		keyword = t.Apply(keyword)
+	}
+	return keyword
+}
+
+// PhraseSites are the sites that honour a quoted keyword as an exact phrase.
+// Other sites get the plain keyword with --exact and rely on FilterPhrase.
+var PhraseSites = map[string]bool{
+	"nyaa":   true,
+	"sukebe": true,
+}
+
+// normalizePhrase lowercases s and turns the separators used in release
+// names (space, '_', '.', '-') into single spaces
+func normalizePhrase(s string) string {
+	s = strings.ToLower(s)
+	s = strings.Map(func(r rune) rune {
+		switch r {
+		case '_', '.', '-':
+			return ' '
+		}
+		return r
+	}, s)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// MatchPhrase reports whether title contains phrase with its words adjacent
+// and in order, ignoring case and release-name separators
+func MatchPhrase(title, phrase string) bool {
+	phrase = normalizePhrase(phrase)
+	if phrase == "" {
+		return true
+	}
+	return strings.Contains(" "+normalizePhrase(title)+" ", " "+phrase+" ")
+}
+
+// FilterPhrase keeps the results whose title matches phrase. Results from
+// PhraseSites were already matched by the site and are kept as they are.
+func FilterPhrase(results []SearchResult, phrase string) []SearchResult {
+	kept := results[:0:0]
+	for _, r := range results {
+		if PhraseSites[r.Site] || MatchPhrase(r.Title, phrase) {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
+
+// ParseNegatives splits keyword into the positive query and its negative
+// terms, the tokens written with a leading '-' ("naruto -dub"). A quoted
+// phrase groups words into one token: -"english dub" excludes the phrase,
+// while "-dub" in quotes is a positive term. A lone "-" is kept as is.
+func ParseNegatives(keyword string) (positive string, negatives []string) {
+	var kept []string
+	for _, tok := range splitQuoted(keyword) {
+		if len(tok) > 1 && tok[0] == '-' {
+			if term := strings.Trim(tok[1:], `"`); term != "" {
+				negatives = append(negatives, term)
+				continue
+			}
+		}
+		kept = append(kept, tok)
+	}
+	return strings.Join(kept, " "), negatives
+}
+
+// splitQuoted splits s at whitespace outside double quotes. Quotes are kept
+// in the tokens; an unterminated quote runs to the end of s.
+func splitQuoted(s string) []string {
+	var (
+		tokens []string
+		cur    strings.Builder
+		quoted bool
+	)
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			cur.WriteRune(r)
+		case !quoted && (r == ' ' || r == '\t' || r == '\n'):
+			if cur.Len() > 0 {
+				tokens = append(tokens, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if cur.Len() > 0 {
+		tokens = append(tokens, cur.String())
+	}
+	return tokens
+}
+
+// NegativeSites are the sites that honour "-term" in a query. They get
+// single-word negative terms in the query, which spares fetching the detail
+// pages of excluded results; phrases are left to ExcludeTerms.
+var NegativeSites = map[string]bool{
+	"nyaa":   true,
+	"sukebe": true,
+}
+
+// words lowercases s and joins its runs of letters and digits with single
+// spaces, so that "[Dub]" and "Dub." both read as the word "dub"
+func words(s string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// ExcludeTerms drops the results whose title contains any of terms as whole
+// words, ignoring case and any punctuation around or between them
+func ExcludeTerms(results []SearchResult, terms []string) []SearchResult {
+	if len(terms) == 0 {
+		return results
+	}
+	kept := results[:0:0]
+	for _, r := range results {
+		title := " " + words(r.Title) + " "
+		excluded := false
+		for _, t := range terms {
+			if w := words(t); w != "" && strings.Contains(title, " "+w+" ") {
+				excluded = true
+				break
+			}
+		}
+		if !excluded {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
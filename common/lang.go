@@ -0,0 +1,17 @@
+package common
+
+import "strings"
+
+// Languages are the site groups tspider searches: Korean ("kr"), Japanese
+// ("jp") and English ("en") sites
+var Languages = []string{"kr", "jp", "en"}
+
+// ValidLanguage reports whether lang is one of Languages
+func ValidLanguage(lang string) bool {
+	return containsString(Languages, lang)
+}
+
+// LanguageList names Languages for messages, as in "kr, jp or en"
+func LanguageList() string {
+	return strings.Join(Languages[:len(Languages)-1], ", ") + " or " + Languages[len(Languages)-1]
+}
@@ -0,0 +1,243 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-runewidth"
+)
+
+const (
+	// maxLatencySamples is how many checks of a site the latency history
+	// keeps; older ones are dropped
+	maxLatencySamples = 500
+	// sparklineWidth is how many of the latest checks a trend draws
+	sparklineWidth = 24
+)
+
+// LatencySample is one check of a site
+type LatencySample struct {
+	Time      time.Time     `json:"time"`
+	URL       string        `json:"url"`
+	Available bool          `json:"available"`
+	Latency   time.Duration `json:"latency_ns"`
+}
+
+// LatencyHistory keeps the latencies measured by doctor and by the
+// availability checks of searches across runs, so that the mirrors of a
+// site can be compared over time and sites ranked by health. It is not safe
+// for concurrent use; changes go through UpdateLatencyHistory.
+type LatencyHistory struct {
+	path  string
+	Sites map[string][]LatencySample `json:"sites"`
+}
+
+// GetLatencyHistoryPath returns the latency history file path
+func GetLatencyHistoryPath() string {
+	return filepath.Join(filepath.Dir(GetConfigPath()), ".tspider_latency.json")
+}
+
+// LoadLatencyHistory reads the latency history. A missing file is an empty
+// history.
+func LoadLatencyHistory() (*LatencyHistory, error) {
+	h := &LatencyHistory{path: GetLatencyHistoryPath(), Sites: map[string][]LatencySample{}}
+	data, err := os.ReadFile(h.path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read latency history: %w", err)
+	}
+	if err := json.Unmarshal(data, h); err != nil {
+		return nil, fmt.Errorf("failed to parse latency history %s: %w", h.path, err)
+	}
+	if h.Sites == nil {
+		h.Sites = map[string][]LatencySample{}
+	}
+	return h, nil
+}
+
+// UpdateLatencyHistory applies fn to the latency history under the config
+// lock and saves the result, so that concurrent checks never lose each
+// other's samples. Nothing is saved when fn returns an error.
+func UpdateLatencyHistory(fn func(h *LatencyHistory) error) error {
+	unlock, err := lockConfig()
+	if err != nil {
+		return fmt.Errorf("failed to lock latency history: %w", err)
+	}
+	defer unlock()
+	h, err := LoadLatencyHistory()
+	if err != nil {
+		return err
+	}
+	if err := fn(h); err != nil {
+		return err
+	}
+	return h.Save()
+}
+
+// Record adds the statuses checked at t, keeping the latest
+// maxLatencySamples checks of each site
+func (h *LatencyHistory) Record(t time.Time, statuses []SiteStatus) {
+	for _, s := range statuses {
+		samples := append(h.Sites[s.Name], LatencySample{Time: t.UTC(), URL: s.URL, Available: s.Available, Latency: s.Latency})
+		if len(samples) > maxLatencySamples {
+			samples = samples[len(samples)-maxLatencySamples:]
+		}
+		h.Sites[s.Name] = samples
+	}
+}
+
+// Save writes the latency history
+func (h *LatencyHistory) Save() error {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(h.path, data)
+}
+
+// LatencyTrend summarizes the checks of one URL of a site
+type LatencyTrend struct {
+	Site string `json:"site"`
+	URL  string `json:"url"`
+	// Checks counts the checks of URL, Up those that found it available
+	Checks int       `json:"checks"`
+	Up     int       `json:"up"`
+	Last   time.Time `json:"last_checked"`
+	// Median and LastLatency are over the checks that found URL up
+	Median      time.Duration `json:"median_ns"`
+	LastLatency time.Duration `json:"last_latency_ns"`
+	// Direction compares the newer half of those checks with the older:
+	// "faster", "slower", "steady", or "" with fewer than 4 of them
+	Direction string `json:"direction,omitempty"`
+	// Sparkline draws the latest checks, a dot for each one that was down
+	Sparkline string `json:"sparkline"`
+}
+
+// Trends summarizes the history of each URL of site, or of every site when
+// site is "", the most recently checked URL of a site first
+func (h *LatencyHistory) Trends(site string) []LatencyTrend {
+	names := make([]string, 0, len(h.Sites))
+	for name := range h.Sites {
+		if site == "" || name == site {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var trends []LatencyTrend
+	for _, name := range names {
+		byURL := map[string][]LatencySample{}
+		var urls []string
+		for _, s := range h.Sites[name] {
+			if _, ok := byURL[s.URL]; !ok {
+				urls = append(urls, s.URL)
+			}
+			byURL[s.URL] = append(byURL[s.URL], s)
+		}
+		rows := make([]LatencyTrend, 0, len(urls))
+		for _, u := range urls {
+			rows = append(rows, latencyTrend(name, u, byURL[u]))
+		}
+		sort.SliceStable(rows, func(i, j int) bool { return rows[i].Last.After(rows[j].Last) })
+		trends = append(trends, rows...)
+	}
+	return trends
+}
+
+// latencyTrend summarizes samples, the checks of url in time order
+func latencyTrend(site, url string, samples []LatencySample) LatencyTrend {
+	t := LatencyTrend{Site: site, URL: url, Checks: len(samples), Last: samples[len(samples)-1].Time}
+	var up []time.Duration
+	for _, s := range samples {
+		if s.Available {
+			up = append(up, s.Latency)
+		}
+	}
+	t.Up = len(up)
+	if len(up) > 0 {
+		t.Median = medianLatency(up)
+		t.LastLatency = up[len(up)-1]
+	}
+	if len(up) >= 4 {
+		older, newer := medianLatency(up[:len(up)/2]), medianLatency(up[len(up)/2:])
+		switch {
+		case newer > older*5/4:
+			t.Direction = "slower"
+		case newer < older*4/5:
+			t.Direction = "faster"
+		default:
+			t.Direction = "steady"
+		}
+	}
+	t.Sparkline = sparkline(samples[max(0, len(samples)-sparklineWidth):])
+	return t
+}
+
+// medianLatency returns the median of latencies, which it does not modify
+func medianLatency(latencies []time.Duration) time.Duration {
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[len(sorted)/2]
+}
+
+// sparkline draws the latency of each sample with a bar scaled between the
+// fastest and slowest, and a dot for each sample that was down
+func sparkline(samples []LatencySample) string {
+	bars := []rune("▁▂▃▄▅▆▇█")
+	var lo, hi time.Duration
+	first := true
+	for _, s := range samples {
+		if !s.Available {
+			continue
+		}
+		if first || s.Latency < lo {
+			lo = s.Latency
+		}
+		if first || s.Latency > hi {
+			hi = s.Latency
+		}
+		first = false
+	}
+	var b strings.Builder
+	for _, s := range samples {
+		switch {
+		case !s.Available:
+			b.WriteRune('·')
+		case hi == lo:
+			b.WriteRune(bars[0])
+		default:
+			b.WriteRune(bars[int(s.Latency-lo)*(len(bars)-1)/int(hi-lo)])
+		}
+	}
+	return b.String()
+}
+
+// PrintLatencyTrends prints trends, one row per URL of a site
+func PrintLatencyTrends(w io.Writer, trends []LatencyTrend) {
+	if len(trends) == 0 {
+		fmt.Fprintln(w, "[*] No latency history yet; doctor and searches record it")
+		return
+	}
+	row := func(name, url, checks, up, median, last, direction, spark string) {
+		fmt.Fprintf(w, "%s %s %s %s %s %s %s %s\n", padRight(name, 15), padRight(url, 35), padRight(checks, 7),
+			padRight(up, 6), padRight(median, 8), padRight(last, 8), padRight(direction, 7), spark)
+	}
+	row("SITE", "URL", "CHECKS", "UP", "MEDIAN", "LAST", "TREND", "LATEST CHECKS")
+	fmt.Fprintln(w, strings.Repeat("─", 115))
+	for _, t := range trends {
+		median, last := "-", "-"
+		if t.Up > 0 {
+			median = fmt.Sprintf("%dms", t.Median.Milliseconds())
+			last = fmt.Sprintf("%dms", t.LastLatency.Milliseconds())
+		}
+		up := fmt.Sprintf("%d%%", t.Up*100/t.Checks)
+		row(t.Site, runewidth.Truncate(t.URL, 33, "..."), fmt.Sprint(t.Checks), up, median, last, t.Direction, t.Sparkline)
+	}
+}
@@ -0,0 +1,35 @@
+package common
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+// OpenLink hands a magnet link to the application registered for it: open
+// on macOS, the URL protocol handler on Windows, xdg-open elsewhere. Anything
+// but a valid magnet is refused, since the link comes from a scraped page and
+// a file path or another URL scheme would launch whatever handles it.
+func OpenLink(link string) error {
+	if !IsValidMagnet(link) {
+		return fmt.Errorf("refusing to open %q: not a magnet link", link)
+	}
+	var args []string
+	switch runtime.GOOS {
+	case "darwin":
+		args = []string{"open", link}
+	case "windows":
+		args = []string{"rundll32", "url.dll,FileProtocolHandler", link}
+	default:
+		args = []string{"xdg-open", link}
+	}
+	path, err := exec.LookPath(args[0])
+	if err != nil {
+		return fmt.Errorf("cannot open links: %s not found", args[0])
+	}
+	// Start only: the handler may keep running, e.g. a torrent client
+	if err := exec.Command(path, args[1:]...).Start(); err != nil {
+		return fmt.Errorf("%s: %w", args[0], err)
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows)
+
+package common
+
+// lockFile is a no-op where no file locking is available; updates are then
+// only serialized within the process
+func lockFile(path string) (func(), error) {
+	return func() {}, nil
+}
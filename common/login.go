@@ -0,0 +1,190 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+const (
+	// defaultUserField and defaultPasswordField are the login form fields
+	// used when a site does not set its own
+	defaultUserField     = "username"
+	defaultPasswordField = "password"
+)
+
+// Authenticator is implemented by scrapers whose site logs in some other
+// way than by posting a form to login_url. Login is given the shared client,
+// whose cookie jar keeps the session for the requests that follow.
+type Authenticator interface {
+	Login(ctx context.Context, client *http.Client, site SiteConfig) error
+}
+
+// NeedsLogin reports whether the site is configured with credentials or a
+// cookie
+func (s SiteConfig) NeedsLogin() bool {
+	return s.Username != "" || s.Cookie != ""
+}
+
+// loginResult records the login to a site, shared by the searches of a run.
+// mu makes concurrent searches wait for one login instead of each logging in.
+type loginResult struct {
+	mu       sync.Mutex
+	loggedIn bool
+}
+
+var logins sync.Map
+
+// LoginSite logs in to the site called name before it is searched, once
+// per run: after a successful login later calls return at once, while a
+// failed one is tried again by the next call. A site with a
+// cookie string has it added to the cookie jar; one with a username logs
+// in through scraper when it is an Authenticator, or by posting the
+// username and password to login_url. Sites without either need no login.
+func LoginSite(ctx context.Context, name string, scraper Scraper) error {
+	site, exists := GetConfig().Sites[name]
+	if !exists || !site.NeedsLogin() {
+		return nil
+	}
+	// Log in to the mirror the site is searched on
+	if u, ok := LookupSiteURL(name); ok {
+		site.URL = u
+	}
+	v, _ := logins.LoadOrStore(name, &loginResult{})
+	l := v.(*loginResult)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.loggedIn {
+		return nil
+	}
+	if err := login(ctx, site, scraper); err != nil {
+		return fmt.Errorf("%s: login failed: %w", name, err)
+	}
+	l.loggedIn = true
+	return nil
+}
+
+// ResetLogins forgets the logins of this run, so sites log in again. Long
+// running commands call it before each search, as a session may have
+// expired since the last.
+func ResetLogins() {
+	logins.Range(func(k, _ any) bool {
+		logins.Delete(k)
+		return true
+	})
+}
+
+func login(ctx context.Context, site SiteConfig, scraper Scraper) error {
+	client := HTTPClient()
+	if site.Cookie != "" {
+		return setCookieString(client, site)
+	}
+	if a, ok := scraper.(Authenticator); ok {
+		return a.Login(ctx, client, site)
+	}
+	return postLogin(ctx, client, site)
+}
+
+// setCookieString adds the cookies of a "name=value; name2=value2" string,
+// as copied from a browser, to the jar for the site
+func setCookieString(client *http.Client, site SiteConfig) error {
+	if client.Jar == nil {
+		return fmt.Errorf("no cookie jar")
+	}
+	u, err := url.Parse(site.URL)
+	if err != nil {
+		return fmt.Errorf("invalid site URL: %w", err)
+	}
+	cookies := (&http.Request{Header: http.Header{"Cookie": {site.Cookie}}}).Cookies()
+	if len(cookies) == 0 {
+		return fmt.Errorf("cookie %q has no name=value pairs", site.Cookie)
+	}
+	for _, c := range cookies {
+		c.Path = "/"
+	}
+	client.Jar.SetCookies(u, cookies)
+	return nil
+}
+
+// postLogin posts the username and password to the site's login_url as a
+// form and expects the site to set a session cookie
+func postLogin(ctx context.Context, client *http.Client, site SiteConfig) error {
+	if site.LoginURL == "" {
+		return fmt.Errorf("set login_url, the address of the login form")
+	}
+	userField, passwordField := site.UserField, site.PasswordField
+	if userField == "" {
+		userField = defaultUserField
+	}
+	if passwordField == "" {
+		passwordField = defaultPasswordField
+	}
+	if client.Jar == nil {
+		return fmt.Errorf("no cookie jar")
+	}
+	// Watch the cookies set along the redirects that follow the login
+	jar := &recordingJar{CookieJar: client.Jar}
+	loginClient := *client
+	loginClient.Jar = jar
+	form := url.Values{userField: {site.Username}, passwordField: {site.Password}}
+	loginURL, err := URLJoin(site.URL, site.LoginURL)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", loginURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", NextUserAgent())
+	resp, err := loginClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("%s answered %s", loginURL, resp.Status)
+	}
+	if !jar.set {
+		return fmt.Errorf("%s set no session cookie; check username and password", loginURL)
+	}
+	return nil
+}
+
+// recordingJar notes whether any cookie was set through it
+type recordingJar struct {
+	http.CookieJar
+	set bool
+}
+
+func (j *recordingJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	if len(cookies) > 0 {
+		j.set = true
+	}
+	j.CookieJar.SetCookies(u, cookies)
+}
+
+// SetLogin stores the credentials of a site: a username and password posted
+// to loginURL, or a cookie string. Empty values clear them.
+func SetLogin(site, username, password, loginURL, cookie string) error {
+	if loginURL != "" {
+		if _, err := url.Parse(loginURL); err != nil {
+			return fmt.Errorf("invalid login URL %q: %w", loginURL, err)
+		}
+	}
+	return UpdateConfig(func(c *Config) error {
+		s, exists := c.Sites[site]
+		if !exists {
+			return fmt.Errorf("site '%s' not found", site)
+		}
+		s.Username, s.Password, s.Cookie = username, password, cookie
+		if loginURL != "" || username == "" {
+			s.LoginURL = loginURL
+		}
+		c.Sites[site] = s
+		return nil
+	})
+}
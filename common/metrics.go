@@ -0,0 +1,138 @@
+package common
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LatencyBuckets are the upper bounds, in seconds, of the request latency
+// histogram
+var LatencyBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// siteMetrics are the counters of one site
+type siteMetrics struct {
+	requests int64
+	errors   int64
+	// buckets counts requests by the first LatencyBuckets bound they fit
+	// under; the last entry holds the slower ones
+	buckets    []int64
+	latencySum float64
+	searches   int64
+	failures   int64
+	results    int64
+}
+
+// MetricsCollector records per-site request and search metrics for the
+// /metrics endpoint of tspider serve. It is safe for concurrent use.
+type MetricsCollector struct {
+	mu    sync.Mutex
+	sites map[string]*siteMetrics
+}
+
+var metrics = &MetricsCollector{sites: map[string]*siteMetrics{}}
+
+// Metrics returns the collector shared by all requests of this process
+func Metrics() *MetricsCollector {
+	return metrics
+}
+
+func (m *MetricsCollector) site(name string) *siteMetrics {
+	s, ok := m.sites[name]
+	if !ok {
+		s = &siteMetrics{buckets: make([]int64, len(LatencyBuckets)+1)}
+		m.sites[name] = s
+	}
+	return s
+}
+
+// request records a request to site that took d and failed unless ok
+func (m *MetricsCollector) request(site string, d time.Duration, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.site(site)
+	s.requests++
+	if !ok {
+		s.errors++
+	}
+	secs := d.Seconds()
+	s.latencySum += secs
+	i := sort.SearchFloat64s(LatencyBuckets, secs)
+	s.buckets[i]++
+}
+
+// searched records a search of site that found results, or failed unless ok
+func (m *MetricsCollector) searched(site string, results int, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.site(site)
+	s.searches++
+	if !ok {
+		s.failures++
+	}
+	s.results += int64(results)
+}
+
+// Reset forgets all recorded metrics
+func (m *MetricsCollector) Reset() {
+	m.mu.Lock()
+	m.sites = map[string]*siteMetrics{}
+	m.mu.Unlock()
+}
+
+// WritePrometheus writes the metrics in the Prometheus text format
+func (m *MetricsCollector) WritePrometheus(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.sites))
+	for name := range m.sites {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	bw := bufio.NewWriter(w)
+	counter := func(metric, help string, value func(*siteMetrics) int64) {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s counter\n", metric, help, metric)
+		for _, name := range names {
+			fmt.Fprintf(bw, "%s{site=%s} %d\n", metric, labelValue(name), value(m.sites[name]))
+		}
+	}
+	counter("tspider_requests_total", "HTTP requests sent to the site.",
+		func(s *siteMetrics) int64 { return s.requests })
+	counter("tspider_request_errors_total", "HTTP requests to the site that failed or did not return 200.",
+		func(s *siteMetrics) int64 { return s.errors })
+
+	const hist = "tspider_request_duration_seconds"
+	fmt.Fprintf(bw, "# HELP %s Latency of HTTP requests to the site.\n# TYPE %s histogram\n", hist, hist)
+	for _, name := range names {
+		s := m.sites[name]
+		site := labelValue(name)
+		var cumulative int64
+		for i, bound := range LatencyBuckets {
+			cumulative += s.buckets[i]
+			fmt.Fprintf(bw, "%s_bucket{site=%s,le=\"%s\"} %d\n", hist, site, strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+		}
+		fmt.Fprintf(bw, "%s_bucket{site=%s,le=\"+Inf\"} %d\n", hist, site, s.requests)
+		fmt.Fprintf(bw, "%s_sum{site=%s} %s\n", hist, site, strconv.FormatFloat(s.latencySum, 'g', -1, 64))
+		fmt.Fprintf(bw, "%s_count{site=%s} %d\n", hist, site, s.requests)
+	}
+
+	counter("tspider_searches_total", "Searches of the site.",
+		func(s *siteMetrics) int64 { return s.searches })
+	counter("tspider_search_failures_total", "Searches of the site that failed or were abandoned over budget.",
+		func(s *siteMetrics) int64 { return s.failures })
+	counter("tspider_search_results_total", "Results found on the site.",
+		func(s *siteMetrics) int64 { return s.results })
+	return bw.Flush()
+}
+
+// labelValue quotes s as a Prometheus label value
+func labelValue(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(s) + `"`
+}
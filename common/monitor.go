@@ -0,0 +1,56 @@
+package common
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// AvailabilityRecord is a site status checked at a time, as logged by
+// doctor --log
+type AvailabilityRecord struct {
+	Time time.Time `json:"time"`
+	SiteStatus
+}
+
+// AppendAvailabilityHistory appends the statuses checked at t to the file
+// at path, one JSON record per line, creating it if needed
+func AppendAvailabilityHistory(path string, t time.Time, statuses []SiteStatus) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, s := range statuses {
+		if err := enc.Encode(AvailabilityRecord{Time: t.UTC(), SiteStatus: s}); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadAvailabilityHistory reads the records appended to the file at path,
+// skipping lines that are not records
+func ReadAvailabilityHistory(path string) ([]AvailabilityRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var records []AvailabilityRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r AvailabilityRecord
+		if json.Unmarshal(scanner.Bytes(), &r) == nil {
+			records = append(records, r)
+		}
+	}
+	return records, scanner.Err()
+}
@@ -0,0 +1,41 @@
+package common
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// ResultEnvelope is one line of --format ndjson-results: a result together
+// with the site it came from. Site is always set, even when the result's own
+// Site field would be empty.
+type ResultEnvelope struct {
+	Site   string       `json:"site"`
+	Result SearchResult `json:"result"`
+}
+
+// WriteEnvelopes writes results found on site to w as newline-delimited
+// JSON, one ResultEnvelope per line
+func WriteEnvelopes(w io.Writer, site string, results []SearchResult) error {
+	enc := json.NewEncoder(w)
+	for _, r := range results {
+		if r.Site == "" {
+			r.Site = site
+		}
+		if err := enc.Encode(ResultEnvelope{Site: site, Result: r}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteNDJSON writes results to w as newline-delimited JSON, one SearchResult
+// per line, as printed by --stream
+func WriteNDJSON(w io.Writer, results []SearchResult) error {
+	enc := json.NewEncoder(w)
+	for _, r := range results {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package common
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Pager pipes everything written to it through an external pager, like git does
+type Pager struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	gone  bool
+}
+
+// NewPager starts the pager named by $PAGER, falling back to less and then more
+func NewPager() (*Pager, error) {
+	candidates := [][]string{{"less"}, {"more"}}
+	if env := strings.Fields(os.Getenv("PAGER")); len(env) > 0 {
+		candidates = append([][]string{env}, candidates...)
+	}
+	for _, args := range candidates {
+		path, err := exec.LookPath(args[0])
+		if err != nil {
+			continue
+		}
+		cmd := exec.Command(path, args[1:]...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		// Quit at once when the output fits on one screen and keep colors, as git does
+		if os.Getenv("LESS") == "" {
+			cmd.Env = append(os.Environ(), "LESS=FRX")
+		}
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			continue
+		}
+		return &Pager{cmd: cmd, stdin: stdin}, nil
+	}
+	return nil, fmt.Errorf("no pager found; set $PAGER")
+}
+
+// Write feeds b to the pager. Once the pager has exited (the user quit early),
+// further output is discarded instead of failing with a broken pipe.
+func (p *Pager) Write(b []byte) (int, error) {
+	if p.gone {
+		return len(b), nil
+	}
+	if _, err := p.stdin.Write(b); err != nil {
+		p.gone = true
+	}
+	return len(b), nil
+}
+
+// Close ends the pager input and waits for the user to leave the pager
+func (p *Pager) Close() error {
+	p.stdin.Close()
+	return p.cmd.Wait()
+}
+
+// IsTerminal reports whether f is attached to a terminal
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
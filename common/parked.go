@@ -0,0 +1,58 @@
+package common
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// parkedMarkers are found on the pages domain parking and resale services
+// put on dead domains, lowercased
+var parkedMarkers = []string{
+	"this domain is for sale",
+	"this domain may be for sale",
+	"buy this domain",
+	"domain is parked",
+	"parked free",
+	"sedoparking.com",
+	"parkingcrew.net",
+	"bodis.com",
+	"afternic.com",
+	"dan.com/buy-domain",
+}
+
+// pageProblem returns why the page in body, which answered 200, is not a
+// page of site, or "" when it is. A site with expect_selector or
+// expect_text must show them; the page of any other is checked for the
+// marks of a domain parking page. Torznab endpoints are not checked.
+func pageProblem(site SiteConfig, body io.Reader) string {
+	if site.Type == SiteTypeTorznab {
+		return ""
+	}
+	if limit := maxBodySize(); limit > 0 {
+		body = io.LimitReader(body, limit)
+	}
+	doc, err := goquery.NewDocumentFromReader(body)
+	if err != nil {
+		return ""
+	}
+	if site.ExpectSelector != "" && doc.Find(site.ExpectSelector).Length() == 0 {
+		return fmt.Sprintf("page lacks %s", site.ExpectSelector)
+	}
+	if site.ExpectText != "" && !strings.Contains(doc.Text(), site.ExpectText) {
+		return fmt.Sprintf("page lacks %q", site.ExpectText)
+	}
+	if site.ExpectSelector != "" || site.ExpectText != "" {
+		return ""
+	}
+	page, _ := doc.Html()
+	page = strings.ToLower(page)
+	for _, marker := range parkedMarkers {
+		if strings.Contains(page, marker) {
+			return "parked domain"
+		}
+	}
+	return ""
+}
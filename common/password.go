@@ -0,0 +1,32 @@
+package common
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ReadPassword prints prompt to out and reads a line from in. On a terminal
+// the typed characters are not echoed; piped input is read as it is, so
+// scripts can pass a password on stdin.
+func ReadPassword(prompt string, in *os.File, out io.Writer) (string, error) {
+	fmt.Fprint(out, prompt)
+	if IsTerminal(in) {
+		restore, err := disableEcho(in)
+		if err != nil {
+			return "", fmt.Errorf("cannot hide the password: %w", err)
+		}
+		defer func() {
+			restore()
+			// The newline typed was not echoed either
+			fmt.Fprintln(out)
+		}()
+	}
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,100 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// ErrNothingPicked is returned by the pickers when the user quits without
+// choosing a result
+var ErrNothingPicked = errors.New("no result picked")
+
+// pickListed is how many of the best matches PickPrompt lists
+const pickListed = 10
+
+// Pick lets the user choose one of results in fzf when it is installed, and
+// with PickPrompt over in and out otherwise
+func Pick(results []SearchResult, in io.Reader, out io.Writer) (SearchResult, error) {
+	if _, err := exec.LookPath("fzf"); err != nil {
+		return PickPrompt(results, in, out)
+	}
+	return PickFzf(results)
+}
+
+// PickPrompt lets the user choose one of results by narrowing them with
+// fuzzy queries read from in. Each query lists the best matches, numbered;
+// entering a number picks that match, and an empty line picks the only match
+// left. q or the end of in quits with ErrNothingPicked.
+func PickPrompt(results []SearchResult, in io.Reader, out io.Writer) (SearchResult, error) {
+	matches := results
+	list := func() {
+		for i, r := range matches {
+			if i == pickListed {
+				fmt.Fprintf(out, "    ... %d more, type to narrow\n", len(matches)-pickListed)
+				break
+			}
+			fmt.Fprintf(out, "%3d %s [%s]\n", i+1, displayTitle(r.Title), r.Site)
+		}
+		if len(matches) == 0 {
+			fmt.Fprintln(out, "[!] No match")
+		}
+	}
+	list()
+	sc := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(out, "query or number> ")
+		if !sc.Scan() {
+			fmt.Fprintln(out)
+			return SearchResult{}, ErrNothingPicked
+		}
+		line := strings.TrimSpace(sc.Text())
+		if n, err := strconv.Atoi(line); err == nil && n >= 1 && n <= len(matches) && n <= pickListed {
+			return matches[n-1], nil
+		}
+		switch {
+		case line == "q":
+			return SearchResult{}, ErrNothingPicked
+		case line == "" && len(matches) == 1:
+			return matches[0], nil
+		}
+		matches = FuzzyFilter(results, line)
+		list()
+	}
+}
+
+// PickFzf lets the user choose one of results in fzf, which must be installed
+func PickFzf(results []SearchResult) (SearchResult, error) {
+	path, err := exec.LookPath("fzf")
+	if err != nil {
+		return SearchResult{}, err
+	}
+	var lines bytes.Buffer
+	for i, r := range results {
+		fmt.Fprintf(&lines, "%d\t%s\t[%s]\n", i+1, displayTitle(r.Title), r.Site)
+	}
+	cmd := exec.Command(path, "--delimiter", "\t", "--with-nth", "2..", "--no-multi")
+	cmd.Stdin = &lines
+	cmd.Stderr = os.Stderr
+	chosen, err := cmd.Output()
+	var exit *exec.ExitError
+	if errors.As(err, &exit) {
+		// fzf exits with 1 when nothing matched and 130 when aborted
+		return SearchResult{}, ErrNothingPicked
+	}
+	if err != nil {
+		return SearchResult{}, err
+	}
+	number, _, _ := strings.Cut(string(chosen), "\t")
+	n, err := strconv.Atoi(number)
+	if err != nil || n < 1 || n > len(results) {
+		return SearchResult{}, fmt.Errorf("unexpected fzf output %q", chosen)
+	}
+	return results[n-1], nil
+}
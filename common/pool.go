@@ -0,0 +1,74 @@
+package common
+
+import "sync"
+
+// defaultDetailConcurrency is how many detail pages are fetched at once
+// across all scrapers when the config does not set detail_concurrency
+const defaultDetailConcurrency = 16
+
+// WorkerPool runs tasks on a fixed number of goroutines
+type WorkerPool struct {
+	tasks chan func()
+}
+
+// NewWorkerPool starts a pool of workers goroutines
+func NewWorkerPool(workers int) *WorkerPool {
+	p := &WorkerPool{tasks: make(chan func())}
+	for i := 0; i < workers; i++ {
+		go func() {
+			for task := range p.tasks {
+				task()
+			}
+		}()
+	}
+	return p
+}
+
+var (
+	detailPool     *WorkerPool
+	detailPoolOnce sync.Once
+)
+
+// DetailPool returns the pool shared by all scrapers for fetching detail
+// pages, sized by detail_concurrency, so that a search page listing
+// hundreds of results does not open hundreds of connections at once
+func DetailPool() *WorkerPool {
+	detailPoolOnce.Do(func() {
+		n := GetConfig().DetailConcurrency
+		if n <= 0 {
+			n = defaultDetailConcurrency
+		}
+		detailPool = NewWorkerPool(n)
+	})
+	return detailPool
+}
+
+// TaskGroup is a set of tasks run by a WorkerPool that can be waited for
+type TaskGroup struct {
+	pool *WorkerPool
+	wg   sync.WaitGroup
+}
+
+// Group returns an empty group of tasks run by p
+func (p *WorkerPool) Group() *TaskGroup {
+	return &TaskGroup{pool: p}
+}
+
+// NewFetchGroup returns a group of detail-page fetches run by DetailPool
+func NewFetchGroup() *TaskGroup {
+	return DetailPool().Group()
+}
+
+// Go runs task once a worker is free, blocking until then
+func (g *TaskGroup) Go(task func()) {
+	g.wg.Add(1)
+	g.pool.tasks <- func() {
+		defer g.wg.Done()
+		task()
+	}
+}
+
+// Wait waits for the tasks of the group to finish
+func (g *TaskGroup) Wait() {
+	g.wg.Wait()
+}
@@ -0,0 +1,44 @@
+package common
+
+import (
+	"fmt"
+	"sort"
+)
+
+// PrunePlan returns the sites a prune would change given a doctor probe:
+// every unreachable site when remove is set, otherwise only the unreachable
+// sites that are still enabled. Reachable sites are never included.
+func PrunePlan(statuses []SiteStatus, remove bool) []string {
+	var names []string
+	for _, s := range statuses {
+		if s.Available || (!remove && !s.Enabled) {
+			continue
+		}
+		names = append(names, s.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PruneSites removes or disables the named sites and saves the config once.
+// Nothing is saved if any site is unknown.
+func PruneSites(names []string, remove bool) error {
+	if len(names) == 0 {
+		return nil
+	}
+	return UpdateConfig(func(c *Config) error {
+		for _, name := range names {
+			if _, exists := c.Sites[name]; !exists {
+				return fmt.Errorf("site '%s' not found", name)
+			}
+		}
+		for _, name := range names {
+			if remove {
+				removeSite(c, name)
+			} else {
+				enableSite(c, name, false)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,77 @@
+package common
+
+import (
+	"sort"
+	"time"
+)
+
+// rankWindow is how many of the latest recorded checks of a site its rank
+// is based on
+const rankWindow = 20
+
+// SiteRank is how a site fared in its latest recorded checks, by doctor and
+// by the availability checks of searches
+type SiteRank struct {
+	Site string
+	// Checks counts the checks considered, SuccessRate the share that
+	// found the site up
+	Checks      int
+	SuccessRate float64
+	// Median is the median latency of the checks that found the site up
+	Median time.Duration
+}
+
+// Rank returns the rank of site from its latest rankWindow checks. A site
+// without any counts as always up, with an unknown latency.
+func (h *LatencyHistory) Rank(site string) SiteRank {
+	samples := h.Sites[site]
+	samples = samples[max(0, len(samples)-rankWindow):]
+	r := SiteRank{Site: site, Checks: len(samples), SuccessRate: 1}
+	var up []time.Duration
+	for _, s := range samples {
+		if s.Available {
+			up = append(up, s.Latency)
+		}
+	}
+	if len(samples) > 0 {
+		r.SuccessRate = float64(len(up)) / float64(len(samples))
+	}
+	if len(up) > 0 {
+		r.Median = medianLatency(up)
+	}
+	return r
+}
+
+// RankSites orders names from the healthiest site to the least healthy:
+// by success rate, in steps of 10%, then by median latency. Sites of equal
+// success rate without a known latency come after the others, and ties are
+// broken by name.
+func RankSites(names []string) []string {
+	ranked := append([]string(nil), names...)
+	sort.Strings(ranked)
+	history, err := LoadLatencyHistory()
+	if err != nil {
+		return ranked
+	}
+	ranks := make(map[string]SiteRank, len(ranked))
+	for _, name := range ranked {
+		ranks[name] = history.Rank(name)
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := ranks[ranked[i]], ranks[ranked[j]]
+		if sa, sb := int(a.SuccessRate*10+0.5), int(b.SuccessRate*10+0.5); sa != sb {
+			return sa > sb
+		}
+		if (a.Median == 0) != (b.Median == 0) {
+			return b.Median == 0
+		}
+		return a.Median < b.Median
+	})
+	return ranked
+}
+
+// maxSitesPerSearch returns how many sites a search queries at most, or 0
+// when max_sites_per_search does not cap them
+func maxSitesPerSearch() int {
+	return max(0, GetConfig().MaxSites)
+}
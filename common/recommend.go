@@ -0,0 +1,63 @@
+package common
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// RecommendMirror returns the best site of lang in statuses, or nil when no
+// site qualifies. Only enabled sites that are up and not degraded qualify;
+// callers drop the sites without a scraper beforehand. The site with the most
+// results (set by DeepDoctor) wins, then the one with the lowest latency.
+func RecommendMirror(statuses []SiteStatus, lang string) *SiteStatus {
+	var best *SiteStatus
+	for i := range statuses {
+		s := &statuses[i]
+		if s.Language != lang || !s.Enabled || !s.Available || s.Degraded {
+			continue
+		}
+		if best == nil || s.Results > best.Results ||
+			(s.Results == best.Results && s.Latency < best.Latency) {
+			best = s
+		}
+	}
+	return best
+}
+
+// DownLanguages returns the languages of langs none of whose enabled sites
+// in statuses is up (and not degraded). Languages without enabled sites
+// are left out.
+func DownLanguages(statuses []SiteStatus, langs []string) []string {
+	var down []string
+	for _, lang := range langs {
+		enabled := false
+		for _, s := range statuses {
+			if s.Language == lang && s.Enabled {
+				enabled = true
+				break
+			}
+		}
+		if enabled && RecommendMirror(statuses, lang) == nil {
+			down = append(down, lang)
+		}
+	}
+	return down
+}
+
+// PrintRecommendations prints the recommended mirror of each of langs
+func PrintRecommendations(w io.Writer, statuses []SiteStatus, langs []string) {
+	for _, lang := range langs {
+		label := strings.ToUpper(lang)
+		s := RecommendMirror(statuses, lang)
+		if s == nil {
+			fmt.Fprintf(w, "Recommended %s: none (no enabled site is up)\n", label)
+			continue
+		}
+		detail := fmt.Sprintf("OK, %dms", s.Latency.Milliseconds())
+		if s.Results > 0 {
+			detail += fmt.Sprintf(", %d results", s.Results)
+		}
+		fmt.Fprintf(w, "Recommended %s: %s (%s)\n", label, s.Name, detail)
+	}
+}
@@ -0,0 +1,66 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxRedirects is how many redirects are followed, as by the default client
+const maxRedirects = 10
+
+// RedirectHop is a URL that answered with a redirect, and its status
+type RedirectHop struct {
+	URL    string `json:"url"`
+	Status int    `json:"status"`
+}
+
+// redirectRecorder is a client like client that records the redirects it
+// follows in hops, and in last the URL they led to, even when that URL
+// then fails
+type redirectRecorder struct {
+	client *http.Client
+	hops   []RedirectHop
+	last   string
+}
+
+func recordRedirects(client *http.Client) *redirectRecorder {
+	r := &redirectRecorder{}
+	c := *client
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return errors.New("stopped after 10 redirects")
+		}
+		status := 0
+		if req.Response != nil {
+			status = req.Response.StatusCode
+		}
+		r.hops = append(r.hops, RedirectHop{URL: via[len(via)-1].URL.String(), Status: status})
+		r.last = req.URL.String()
+		return nil
+	}
+	r.client = &c
+	return r
+}
+
+// printRedirects lists the redirect chains of the sites of statuses
+func printRedirects(w io.Writer, statuses []SiteStatus) {
+	header := false
+	for _, s := range statuses {
+		if len(s.RedirectChain) == 0 {
+			continue
+		}
+		if !header {
+			fmt.Fprintln(w, "\nRedirects:")
+			header = true
+		}
+		var chain strings.Builder
+		for _, hop := range s.RedirectChain {
+			fmt.Fprintf(&chain, "%s (%d) -> ", hop.URL, hop.Status)
+		}
+		chain.WriteString(s.FinalURL)
+		fmt.Fprintf(w, "  %s: %s\n", s.Name, chain.String())
+	}
+}
@@ -0,0 +1,79 @@
+package common
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Relevance tiers, best first; see RelevanceScore
+const (
+	relevanceExact   = 1000
+	relevancePrefix  = 800
+	relevanceWord    = 600
+	relevanceSubstr  = 400
+	relevancePartial = 200
+	relevanceNoMatch = 0
+)
+
+// SortOrders are the values accepted by SortResults
+var SortOrders = []string{"relevance", "title", "seeders"}
+
+// RelevanceScore rates how well title matches keyword, ignoring case and the
+// separators used in release names: an exact match scores highest, then a
+// title starting with the keyword, then the keyword as whole words, then as
+// a plain substring. Multi-word keywords whose words are not adjacent score
+// by the share of words found.
+func RelevanceScore(title, keyword string) int {
+	t, k := normalizePhrase(title), normalizePhrase(keyword)
+	switch {
+	case k == "":
+		return relevanceNoMatch
+	case t == k:
+		return relevanceExact
+	case strings.HasPrefix(t, k):
+		return relevancePrefix
+	case strings.Contains(" "+t+" ", " "+k+" "):
+		return relevanceWord
+	case strings.Contains(t, k):
+		return relevanceSubstr
+	}
+	words := strings.Fields(k)
+	if len(words) < 2 {
+		return relevanceNoMatch
+	}
+	// Whole-word hits count fully, substring hits half
+	points := 0
+	for _, w := range words {
+		switch {
+		case strings.Contains(" "+t+" ", " "+w+" "):
+			points += 2
+		case strings.Contains(t, w):
+			points++
+		}
+	}
+	return relevancePartial * points / (2 * len(words))
+}
+
+// SortResults orders results in place: "relevance" by RelevanceScore against
+// keyword, "title" by title descending (the merge order), "seeders" by most
+// seeded. Ties keep title order.
+func SortResults(results []SearchResult, order, keyword string) error {
+	switch order {
+	case "relevance":
+		scores := make(map[string]int, len(results))
+		for _, r := range results {
+			scores[r.Title] = RelevanceScore(r.Title, keyword)
+		}
+		sort.SliceStable(results, func(i, j int) bool {
+			return scores[results[i].Title] > scores[results[j].Title]
+		})
+	case "title":
+		sort.SliceStable(results, func(i, j int) bool { return results[i].Title > results[j].Title })
+	case "seeders":
+		sort.SliceStable(results, func(i, j int) bool { return results[i].Seeders > results[j].Seeders })
+	default:
+		return fmt.Errorf("unknown sort order '%s', want one of %v", order, SortOrders)
+	}
+	return nil
+}
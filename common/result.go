@@ -0,0 +1,275 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daite/tspider/metadata"
+)
+
+// SearchResult is a single torrent found on a site
+type SearchResult struct {
+	Site     string `json:"site"`
+	Title    string `json:"title"`
+	Magnet   string `json:"magnet"`
+	Uploader string `json:"uploader,omitempty"`
+	Seeders  int    `json:"seeders,omitempty"`
+	Leechers int    `json:"leechers,omitempty"`
+	Snatches int    `json:"snatches,omitempty"`
+	Size     string `json:"size,omitempty"`
+	Folder   bool   `json:"folder,omitempty"`
+	// Category is the site's category of the torrent, empty when unknown
+	Category string `json:"category,omitempty"`
+	// DetailURL is the page of the torrent on its site, empty when unknown
+	DetailURL string `json:"detail_url,omitempty"`
+	// Date is the upload time, zero when the site does not show it
+	Date time.Time `json:"date"`
+}
+
+// siteResults prepares the results crawled from site: each is attributed to
+// site and its magnet cleaned
+func siteResults(site string, results []SearchResult) []SearchResult {
+	for i := range results {
+		results[i].Site = site
+		results[i].Magnet = CleanMagnetForClipboard(results[i].Magnet)
+	}
+	return results
+}
+
+// ResultsFromMap returns the results stored in m, keyed by title, as
+// scrapers that fetch detail pages concurrently collect them
+func ResultsFromMap(m *sync.Map) []SearchResult {
+	var results []SearchResult
+	m.Range(func(_, value interface{}) bool {
+		results = append(results, value.(SearchResult))
+		return true
+	})
+	return results
+}
+
+// mergeResults drains the per-site results currently in ch into one list.
+// Entries without a magnet are dropped, duplicates are merged by Dedup, and
+// the list is sorted by title in descending order.
+func mergeResults(ch chan []SearchResult) ([]SearchResult, DedupStats) {
+	var all []SearchResult
+	for {
+		select {
+		case results := <-ch:
+			for _, r := range results {
+				if r.Magnet != "no magnet" {
+					all = append(all, r)
+				}
+			}
+		default:
+			merged, stats := Dedup(all)
+			sort.SliceStable(merged, func(i, j int) bool { return merged[i].Title > merged[j].Title })
+			return merged, stats
+		}
+	}
+}
+
+// DedupStats describes what Dedup did
+type DedupStats struct {
+	// Input is the number of results before deduplication
+	Input int `json:"input"`
+	// Unique is the number of distinct torrents, by info hash or, for
+	// results without one, by site and title
+	Unique int `json:"unique"`
+	// Duplicates is the number of results merged into another one
+	Duplicates int `json:"duplicates"`
+	// CrossSite is the number of distinct torrents found on two or more sites
+	CrossSite int `json:"cross_site"`
+}
+
+// Dedup keeps one result per torrent, preferring the best-seeded copy, and
+// reports what it merged. Stats are gathered in the same pass.
+func Dedup(results []SearchResult) ([]SearchResult, DedupStats) {
+	stats := DedupStats{Input: len(results)}
+	index := make(map[string]int, len(results))
+	sites := make(map[string][]string, len(results))
+	unique := make([]SearchResult, 0, len(results))
+	for _, r := range results {
+		k := resultKey(r)
+		i, seen := index[k]
+		if !seen {
+			index[k] = len(unique)
+			sites[k] = []string{r.Site}
+			unique = append(unique, r)
+			continue
+		}
+		stats.Duplicates++
+		if !containsString(sites[k], r.Site) {
+			if len(sites[k]) == 1 {
+				stats.CrossSite++
+			}
+			sites[k] = append(sites[k], r.Site)
+		}
+		if r.Seeders > unique[i].Seeders {
+			unique[i] = r
+		}
+	}
+	stats.Unique = len(unique)
+	return unique, stats
+}
+
+// StreamDedup passes each torrent on once, by the key Dedup merges on, for
+// results written as sites finish. It cannot wait for the best-seeded copy
+// across sites, so the first site to report a torrent wins. The zero value
+// is ready to use and safe for concurrent use.
+type StreamDedup struct {
+	mu   sync.Mutex
+	seen map[string]bool
+}
+
+// Fresh returns the results of one site's batch that were not passed on
+// before. Like the merged results, it leaves out entries without a magnet.
+func (d *StreamDedup) Fresh(results []SearchResult) []SearchResult {
+	var batch []SearchResult
+	for _, r := range results {
+		if r.Magnet != "no magnet" {
+			batch = append(batch, r)
+		}
+	}
+	batch, _ = Dedup(batch)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.seen == nil {
+		d.seen = make(map[string]bool)
+	}
+	fresh := batch[:0]
+	for _, r := range batch {
+		if k := resultKey(r); !d.seen[k] {
+			d.seen[k] = true
+			fresh = append(fresh, r)
+		}
+	}
+	return fresh
+}
+
+// String summarizes s on one line
+func (s DedupStats) String() string {
+	return fmt.Sprintf("%d result(s) in, %d unique, %d duplicate(s) merged, %d found on several sites",
+		s.Input, s.Unique, s.Duplicates, s.CrossSite)
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// displayTitle keeps long titles on one table line
+func displayTitle(title string) string {
+	return strings.Replace(title, " ", "_", -1)
+}
+
+// InfoHash returns the lower-case hex info hash of a magnet link, or "" if
+// the link carries none
+func InfoHash(magnet string) string {
+	h, err := metadata.ParseMagnet(magnet)
+	if err != nil {
+		return ""
+	}
+	return h.String()
+}
+
+// resultKey identifies a result by info hash, falling back to site and title
+func resultKey(r SearchResult) string {
+	if h := InfoHash(r.Magnet); h != "" {
+		return h
+	}
+	return r.Site + "\x00" + r.Title
+}
+
+// DiffResults compares two result sets by info hash and returns the results
+// only present in new (added) and only present in old (removed)
+func DiffResults(old, new []SearchResult) (added, removed []SearchResult) {
+	oldKeys := make(map[string]bool, len(old))
+	for _, r := range old {
+		oldKeys[resultKey(r)] = true
+	}
+	newKeys := make(map[string]bool, len(new))
+	for _, r := range new {
+		k := resultKey(r)
+		newKeys[k] = true
+		if !oldKeys[k] {
+			added = append(added, r)
+		}
+	}
+	for _, r := range old {
+		if !newKeys[resultKey(r)] {
+			removed = append(removed, r)
+		}
+	}
+	return added, removed
+}
+
+// WriteJSON writes v to w as indented JSON. It is the JSON counterpart of
+// PrintData and PrintDataEx: results of Korean and Japanese sites share the
+// SearchResult schema, with the fields a site does not provide omitted.
+func WriteJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// SaveResults writes results to path as JSON
+func SaveResults(path string, results []SearchResult) error {
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal results: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write results: %w", err)
+	}
+	return nil
+}
+
+// LoadResults reads results saved with SaveResults
+func LoadResults(path string) ([]SearchResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read results: %w", err)
+	}
+	var results []SearchResult
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, fmt.Errorf("failed to parse results in %s: %w", path, err)
+	}
+	return results, nil
+}
+
+// CleanMagnetForClipboard strips what terminals and scraped HTML leave around
+// a magnet link: bracketed-paste markers and other escape sequences, control
+// characters such as newlines and tabs, and surrounding whitespace
+func CleanMagnetForClipboard(m string) string {
+	var b strings.Builder
+	for i := 0; i < len(m); i++ {
+		c := m[i]
+		if c == 0x1b {
+			// Skip a CSI sequence (ESC [ params final) or a lone ESC x pair
+			if i+1 < len(m) && m[i+1] == '[' {
+				i += 2
+				for i < len(m) && (m[i] < 0x40 || m[i] > 0x7e) {
+					i++
+				}
+			} else {
+				i++
+			}
+			continue
+		}
+		if c < 0x20 || c == 0x7f {
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return strings.TrimSpace(b.String())
+}
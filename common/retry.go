@@ -0,0 +1,231 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultRetryJitter is the share by which retry waits vary when the
+	// config does not set retry_jitter
+	defaultRetryJitter = 0.2
+	// maxRetryAfter caps the wait a site may ask for with Retry-After
+	maxRetryAfter = 30 * time.Second
+)
+
+// RetryBackoff is the wait before the first retry when the config does not
+// set retry_delay_ms; it doubles with every further retry. Tests shorten it.
+var RetryBackoff = 500 * time.Millisecond
+
+// Retry reasons, the classes of failure that are retried
+const (
+	RetryTimeout     = "timeout"
+	RetryServerError = "5xx"
+	RetryRateLimited = "429"
+)
+
+// maxRetries returns the configured number of retries. Retries are opt-in:
+// without max_retries, or with a negative one, there are none.
+func maxRetries() int {
+	if n := GetConfig().MaxRetries; n > 0 {
+		return n
+	}
+	return 0
+}
+
+// retryDelay returns the wait before retry number attempt+1 of a request
+// that got resp: the base delay doubled for each earlier retry and varied
+// by the jitter, or longer when the site asks for it with Retry-After
+func retryDelay(attempt int, resp *http.Response) time.Duration {
+	c := GetConfig()
+	d := RetryBackoff
+	if c.RetryDelay > 0 {
+		d = time.Duration(c.RetryDelay) * time.Millisecond
+	}
+	d <<= attempt
+	jitter := c.RetryJitter
+	if jitter == 0 {
+		jitter = defaultRetryJitter
+	}
+	if jitter > 0 {
+		d = time.Duration(float64(d) * (1 + jitter*(2*rand.Float64()-1)))
+	}
+	if resp != nil {
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+			if after := min(time.Duration(secs)*time.Second, maxRetryAfter); after > d {
+				d = after
+			}
+		}
+	}
+	return d
+}
+
+// retryReason classifies a failed attempt, returning "" when it is not worth
+// retrying
+func retryReason(resp *http.Response, err error) string {
+	if err != nil {
+		var netErr net.Error
+		if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+			return RetryTimeout
+		}
+		return ""
+	}
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return RetryRateLimited
+	case resp.StatusCode >= 500:
+		return RetryServerError
+	}
+	return ""
+}
+
+// SiteRetries summarizes the retries of one site during a search
+type SiteRetries struct {
+	Site string `json:"site"`
+	// Retries counts retries by reason (timeout, 5xx, 429)
+	Retries map[string]int `json:"retries"`
+	// Recovered is the number of retried requests that finally succeeded,
+	// Failed the number that did not
+	Recovered int `json:"recovered"`
+	Failed    int `json:"failed"`
+}
+
+// Total returns the number of retries of all reasons
+func (s SiteRetries) Total() int {
+	n := 0
+	for _, v := range s.Retries {
+		n += v
+	}
+	return n
+}
+
+// RetryCollector records the retries made by GetResponseFromURL and the
+// requests that failed despite them. It is safe for concurrent use.
+type RetryCollector struct {
+	mu    sync.Mutex
+	sites map[string]*SiteRetries
+	// failures holds the last failed request of each site
+	failures map[string]string
+}
+
+var retries = &RetryCollector{sites: map[string]*SiteRetries{}, failures: map[string]string{}}
+
+// Retries returns the collector shared by all requests of this process
+func Retries() *RetryCollector {
+	return retries
+}
+
+func (rc *RetryCollector) site(name string) *SiteRetries {
+	s, ok := rc.sites[name]
+	if !ok {
+		s = &SiteRetries{Site: name, Retries: map[string]int{}}
+		rc.sites[name] = s
+	}
+	return s
+}
+
+// retried records a retry of a request to site for reason
+func (rc *RetryCollector) retried(site, reason string) {
+	rc.mu.Lock()
+	rc.site(site).Retries[reason]++
+	rc.mu.Unlock()
+}
+
+// finished records the outcome of a request to site that was retried
+func (rc *RetryCollector) finished(site string, ok bool) {
+	rc.mu.Lock()
+	if ok {
+		rc.site(site).Recovered++
+	} else {
+		rc.site(site).Failed++
+	}
+	rc.mu.Unlock()
+}
+
+// failed records a request to site that failed for good, as described
+func (rc *RetryCollector) failed(site, description string) {
+	rc.mu.Lock()
+	rc.failures[site] = description
+	rc.mu.Unlock()
+}
+
+// Failure describes the last request to site that failed for good, after
+// any retries, or returns "" when none did
+func (rc *RetryCollector) Failure(site string) string {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.failures[site]
+}
+
+// Reset forgets all recorded retries and failures
+func (rc *RetryCollector) Reset() {
+	rc.mu.Lock()
+	rc.sites = map[string]*SiteRetries{}
+	rc.failures = map[string]string{}
+	rc.mu.Unlock()
+}
+
+// Report returns the retries recorded so far, by site name
+func (rc *RetryCollector) Report() []SiteRetries {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	report := make([]SiteRetries, 0, len(rc.sites))
+	for _, s := range rc.sites {
+		c := *s
+		c.Retries = make(map[string]int, len(s.Retries))
+		for k, v := range s.Retries {
+			c.Retries[k] = v
+		}
+		report = append(report, c)
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Site < report[j].Site })
+	return report
+}
+
+// PrintRetryReport prints report, or nothing when no request was retried
+func PrintRetryReport(w io.Writer, report []SiteRetries) {
+	total := 0
+	for _, s := range report {
+		total += s.Total()
+	}
+	if total == 0 {
+		return
+	}
+	fmt.Fprintf(w, "[*] Retries: %d\n", total)
+	for _, s := range report {
+		var reasons []string
+		for _, r := range []string{RetryTimeout, RetryServerError, RetryRateLimited} {
+			if n := s.Retries[r]; n > 0 {
+				reasons = append(reasons, fmt.Sprintf("%s %d", r, n))
+			}
+		}
+		fmt.Fprintf(w, "    %s: %d (%s), %d recovered, %d failed\n",
+			s.Site, s.Total(), strings.Join(reasons, ", "), s.Recovered, s.Failed)
+	}
+}
+
+// siteForURL returns the name of the configured site serving rawURL, or its
+// host when no site matches
+func siteForURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	for name, site := range GetConfig().Sites {
+		if site.servesHost(u.Host) {
+			return name
+		}
+	}
+	return u.Host
+}
@@ -0,0 +1,86 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// Schedule is a search tspider daemon runs whenever Cron fires, see
+// ParseCron for its syntax
+type Schedule struct {
+	Keyword string `json:"keyword"`
+	// Lang is one of Languages (default jp)
+	Lang string `json:"lang,omitempty"`
+	Cron string `json:"cron"`
+}
+
+// Language returns the language the schedule searches in
+func (s Schedule) Language() string {
+	if s.Lang == "" {
+		return "jp"
+	}
+	return s.Lang
+}
+
+func (s Schedule) validate() error {
+	if strings.TrimSpace(s.Keyword) == "" {
+		return fmt.Errorf("empty keyword")
+	}
+	if lang := s.Language(); !ValidLanguage(lang) {
+		return fmt.Errorf("language %q is not %s", lang, LanguageList())
+	}
+	_, err := ParseCron(s.Cron)
+	return err
+}
+
+// AddSchedule creates or replaces a named schedule
+func AddSchedule(name string, s Schedule) error {
+	if err := s.validate(); err != nil {
+		return fmt.Errorf("schedule '%s': %w", name, err)
+	}
+	return UpdateConfig(func(c *Config) error {
+		if c.Schedules == nil {
+			c.Schedules = make(map[string]Schedule)
+		}
+		c.Schedules[name] = s
+		return nil
+	})
+}
+
+// RemoveSchedule removes a named schedule
+func RemoveSchedule(name string) error {
+	return UpdateConfig(func(c *Config) error {
+		if _, exists := c.Schedules[name]; !exists {
+			return fmt.Errorf("schedule '%s' not found", name)
+		}
+		delete(c.Schedules, name)
+		return nil
+	})
+}
+
+// ScheduleNames returns the names of the configured schedules, sorted
+func ScheduleNames() []string {
+	c := GetConfig()
+	names := make([]string, 0, len(c.Schedules))
+	for name := range c.Schedules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ListSchedules prints all configured schedules
+func ListSchedules() {
+	c := GetConfig()
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Schedule", "Keyword", "Lang", "Cron"})
+	for _, name := range ScheduleNames() {
+		s := c.Schedules[name]
+		table.Append([]string{name, s.Keyword, s.Language(), s.Cron})
+	}
+	table.Render()
+}
@@ -0,0 +1,93 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SeenResult records when a result was first found
+type SeenResult struct {
+	Title     string    `json:"title"`
+	Site      string    `json:"site"`
+	FirstSeen time.Time `json:"first_seen"`
+}
+
+// SeenStore remembers the results repeated searches, such as the schedules
+// of tspider daemon, have already found, keyed by search name and then by
+// info hash, or site and title for results without one. It is not safe for
+// concurrent use.
+type SeenStore struct {
+	path     string
+	Searches map[string]map[string]SeenResult `json:"searches"`
+}
+
+// GetSeenPath returns the seen-results file path
+func GetSeenPath() string {
+	return filepath.Join(filepath.Dir(GetConfigPath()), ".tspider_seen.json")
+}
+
+// LoadSeen reads the seen-results store. A missing file is an empty store.
+func LoadSeen() (*SeenStore, error) {
+	s := &SeenStore{path: GetSeenPath(), Searches: map[string]map[string]SeenResult{}}
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read seen results: %w", err)
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("failed to parse seen results %s: %w", s.path, err)
+	}
+	if s.Searches == nil {
+		s.Searches = map[string]map[string]SeenResult{}
+	}
+	return s, nil
+}
+
+// Known reports whether the search name has recorded results before, so
+// that its first run can be taken as a baseline rather than news
+func (s *SeenStore) Known(name string) bool {
+	_, ok := s.Searches[name]
+	return ok
+}
+
+// Add records results for the search name and returns those it had not
+// seen before, in order. Results repeated within results count once.
+func (s *SeenStore) Add(name string, results []SearchResult, now time.Time) []SearchResult {
+	seen, ok := s.Searches[name]
+	if !ok {
+		seen = map[string]SeenResult{}
+		s.Searches[name] = seen
+	}
+	var added []SearchResult
+	for _, r := range results {
+		key := resultKey(r)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = SeenResult{Title: r.Title, Site: r.Site, FirstSeen: now}
+		added = append(added, r)
+	}
+	return added
+}
+
+// Forget drops what the search name has seen
+func (s *SeenStore) Forget(name string) {
+	delete(s.Searches, name)
+}
+
+// Save writes the store back to its file
+func (s *SeenStore) Save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal seen results: %w", err)
+	}
+	if err := WriteFileAtomic(s.path, data); err != nil {
+		return fmt.Errorf("failed to save seen results: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseSelection parses a selection of result rows such as "1,3,5-7" or
+// "all" against a table of n rows numbered from 1. It returns the 0-based
+// indices in the order given, without duplicates. Empty items are ignored;
+// anything else that is not a row or a range is an error naming it.
+func ParseSelection(input string, n int) ([]int, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return nil, fmt.Errorf("empty selection")
+	}
+	if strings.EqualFold(input, "all") || input == "*" {
+		indices := make([]int, n)
+		for i := range indices {
+			indices[i] = i
+		}
+		return indices, nil
+	}
+	var indices []int
+	seen := map[int]bool{}
+	add := func(row int) {
+		if !seen[row] {
+			seen[row] = true
+			indices = append(indices, row-1)
+		}
+	}
+	row := func(item, s string) (int, error) {
+		v, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return 0, fmt.Errorf("invalid selection %q: %q is not a row number", item, strings.TrimSpace(s))
+		}
+		if v < 1 || v > n {
+			return 0, fmt.Errorf("row %d is out of range 1-%d", v, n)
+		}
+		return v, nil
+	}
+	for _, item := range strings.FieldsFunc(input, func(r rune) bool { return r == ',' || r == ' ' }) {
+		from, to, isRange := strings.Cut(item, "-")
+		first, err := row(item, from)
+		if err != nil {
+			return nil, err
+		}
+		last := first
+		if isRange {
+			if last, err = row(item, to); err != nil {
+				return nil, err
+			}
+			if last < first {
+				return nil, fmt.Errorf("invalid range %q: %d comes after %d", item, first, last)
+			}
+		}
+		for r := first; r <= last; r++ {
+			add(r)
+		}
+	}
+	return indices, nil
+}
@@ -0,0 +1,62 @@
+package common
+
+import (
+	"fmt"
+
+	"github.com/andybalholm/cascadia"
+)
+
+// SelectorKeys are the selector overrides a site accepts: "list" picks the
+// result links on a search page and "magnet" the magnet element on a detail
+// page. Scrapers without a magnet selector ignore the latter.
+var SelectorKeys = []string{"list", "magnet"}
+
+// ValidateSelector reports whether sel is a CSS selector goquery can use
+func ValidateSelector(sel string) error {
+	if _, err := cascadia.Compile(sel); err != nil {
+		return fmt.Errorf("invalid selector '%s': %w", sel, err)
+	}
+	return nil
+}
+
+// SetSelector overrides a built-in selector of site; an empty selector
+// restores the default
+func SetSelector(site, key, sel string) error {
+	if sel != "" {
+		if err := ValidateSelector(sel); err != nil {
+			return err
+		}
+	}
+	return UpdateConfig(func(c *Config) error {
+		s, exists := c.Sites[site]
+		if !exists {
+			return fmt.Errorf("site '%s' not found", site)
+		}
+		switch key {
+		case "list":
+			s.ListSelector = sel
+		case "magnet":
+			s.MagnetSelector = sel
+		default:
+			return fmt.Errorf("unknown selector '%s', want one of %v", key, SelectorKeys)
+		}
+		c.Sites[site] = s
+		return nil
+	})
+}
+
+// ListSelector returns the configured list selector of site, or def
+func ListSelector(site, def string) string {
+	if sel := GetConfig().Sites[site].ListSelector; sel != "" {
+		return sel
+	}
+	return def
+}
+
+// MagnetSelector returns the configured magnet selector of site, or def
+func MagnetSelector(site, def string) string {
+	if sel := GetConfig().Sites[site].MagnetSelector; sel != "" {
+		return sel
+	}
+	return def
+}
@@ -0,0 +1,348 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ConfigVersion is the schema version written to new config files. Files
+// without a version predate it and are reported as version 0.
+const ConfigVersion = 1
+
+// selfCheckEnv lists the environment variables that change how tspider
+// connects or displays results
+var selfCheckEnv = []string{
+	"HTTP_PROXY", "http_proxy", "HTTPS_PROXY", "https_proxy",
+	"NO_PROXY", "no_proxy", "ALL_PROXY", "all_proxy", "PAGER",
+}
+
+// SelfReport describes the tspider installation itself, as opposed to the
+// sites it scrapes
+type SelfReport struct {
+	ConfigPath    string `json:"config_path"`
+	ConfigExists  bool   `json:"config_exists"`
+	ConfigValid   bool   `json:"config_valid"`
+	ConfigError   string `json:"config_error,omitempty"`
+	Writable      bool   `json:"writable"`
+	SchemaVersion int    `json:"schema_version"`
+	// Problems are the findings of ValidateConfig
+	Problems []string `json:"problems,omitempty"`
+	// Implemented and Configured count the sites with a scraper and the sites
+	// in the config file; Enabled counts the configured sites turned on
+	Implemented int `json:"implemented_sites"`
+	Configured  int `json:"configured_sites"`
+	Enabled     int `json:"enabled_sites"`
+	// NoScraper are configured sites tspider has no scraper for, and
+	// NotConfigured are implemented sites missing from the config file
+	NoScraper     []string          `json:"no_scraper,omitempty"`
+	NotConfigured []string          `json:"not_configured,omitempty"`
+	Env           map[string]string `json:"env,omitempty"`
+}
+
+// ReadConfigFile parses the config file at path without loading it
+func ReadConfigFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Config{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// ValidateConfigFile parses the config file at path and returns the problems
+// found by ValidateConfig; the error is set when the file cannot be parsed
+func ValidateConfigFile(path string) ([]string, error) {
+	c, err := ReadConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ValidateConfig(c), nil
+}
+
+// headerName matches the names allowed for HTTP headers (RFC 7230 tokens)
+var headerName = regexp.MustCompile("^[!#$%&'*+.^_`|~0-9A-Za-z-]+$")
+
+// ValidateConfig returns a description of every problem found in c
+func ValidateConfig(c *Config) []string {
+	var problems []string
+	names := make([]string, 0, len(c.Sites))
+	for name := range c.Sites {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		site := c.Sites[name]
+		if u, err := url.Parse(site.URL); err != nil || u.Scheme == "" || u.Host == "" {
+			problems = append(problems, fmt.Sprintf("site %s: invalid URL %q", name, site.URL))
+		}
+		for _, m := range site.URLs {
+			if u, err := url.Parse(m); err != nil || u.Scheme == "" || u.Host == "" {
+				problems = append(problems, fmt.Sprintf("site %s: invalid mirror URL %q", name, m))
+			}
+		}
+		if !ValidLanguage(site.Language) {
+			problems = append(problems, fmt.Sprintf("site %s: language %q is not %s", name, site.Language, LanguageList()))
+		}
+		switch site.Type {
+		case "", SiteTypeTorznab:
+		case SiteTypeBoard:
+			if problem := site.boardProblem(); problem != "" {
+				problems = append(problems, fmt.Sprintf("site %s: %s", name, problem))
+			}
+		default:
+			problems = append(problems, fmt.Sprintf("site %s: type %q is not %s or %s", name, site.Type, SiteTypeTorznab, SiteTypeBoard))
+		}
+		if site.Timeout < 0 {
+			problems = append(problems, fmt.Sprintf("site %s: timeout_seconds is %g, want a positive number", name, site.Timeout))
+		}
+		for _, sel := range []string{site.ListSelector, site.MagnetSelector, site.ExpectSelector} {
+			if sel != "" && ValidateSelector(sel) != nil {
+				problems = append(problems, fmt.Sprintf("site %s: invalid selector %q", name, sel))
+			}
+		}
+		headers := make([]string, 0, len(site.Headers))
+		for header := range site.Headers {
+			headers = append(headers, header)
+		}
+		sort.Strings(headers)
+		for _, header := range headers {
+			if value := site.Headers[header]; !headerName.MatchString(header) || strings.ContainsAny(value, "\r\n\x00") {
+				problems = append(problems, fmt.Sprintf("site %s: invalid header %q: %q", name, header, value))
+			}
+		}
+		if site.TLSFingerprint != "" && site.TLSFingerprint != TLSFingerprintChrome {
+			problems = append(problems, fmt.Sprintf("site %s: tls_fingerprint %q is not %s", name, site.TLSFingerprint, TLSFingerprintChrome))
+		}
+		if site.Password != "" && site.Username == "" {
+			problems = append(problems, fmt.Sprintf("site %s: password without a username", name))
+		}
+		if site.LoginURL != "" {
+			if _, err := url.Parse(site.LoginURL); err != nil {
+				problems = append(problems, fmt.Sprintf("site %s: invalid login_url %q", name, site.LoginURL))
+			}
+		}
+	}
+	dups := DuplicateURLs(c)
+	urls := make([]string, 0, len(dups))
+	for u := range dups {
+		urls = append(urls, u)
+	}
+	sort.Strings(urls)
+	for _, u := range urls {
+		problems = append(problems, fmt.Sprintf("sites %s share the URL %s", strings.Join(dups[u], ", "), u))
+	}
+	profiles := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		profiles = append(profiles, name)
+	}
+	sort.Strings(profiles)
+	for _, name := range profiles {
+		for _, site := range c.Profiles[name] {
+			if _, ok := c.Sites[site]; !ok {
+				problems = append(problems, fmt.Sprintf("profile %s: unknown site %s", name, site))
+			}
+		}
+	}
+	schedules := make([]string, 0, len(c.Schedules))
+	for name := range c.Schedules {
+		schedules = append(schedules, name)
+	}
+	sort.Strings(schedules)
+	for _, name := range schedules {
+		if err := c.Schedules[name].validate(); err != nil {
+			problems = append(problems, fmt.Sprintf("schedule %s: %v", name, err))
+		}
+	}
+	notifiers := make([]string, 0, len(c.Notifiers))
+	for name := range c.Notifiers {
+		notifiers = append(notifiers, name)
+	}
+	sort.Strings(notifiers)
+	for _, name := range notifiers {
+		n := c.Notifiers[name]
+		if n.Type == "" {
+			problems = append(problems, fmt.Sprintf("notifier %s: missing type", name))
+		}
+		if n.URL != "" {
+			if u, err := url.Parse(n.URL); err != nil || u.Scheme == "" || u.Host == "" {
+				problems = append(problems, fmt.Sprintf("notifier %s: invalid URL %q", name, n.URL))
+			}
+		}
+		if n.Digest != "" {
+			if _, err := ParseCron(n.Digest); err != nil {
+				problems = append(problems, fmt.Sprintf("notifier %s: digest: %v", name, err))
+			}
+		}
+	}
+	for _, p := range c.Blocklist {
+		if _, err := regexp.Compile(p); err != nil {
+			problems = append(problems, fmt.Sprintf("blocklist: invalid pattern %q", p))
+		}
+	}
+	if c.Proxy != "" {
+		if u, err := url.Parse(c.Proxy); err != nil || u.Scheme == "" || u.Host == "" {
+			problems = append(problems, fmt.Sprintf("proxy: invalid URL %q", c.Proxy))
+		}
+	}
+	if c.UserAgentRotation != "" && !containsString(UserAgentRotations, c.UserAgentRotation) {
+		problems = append(problems, fmt.Sprintf("user_agent_rotation %q is not one of %v", c.UserAgentRotation, UserAgentRotations))
+	}
+	if _, err := parseSizeBuckets(c.SizeBuckets); err != nil {
+		problems = append(problems, fmt.Sprintf("size_buckets: %v", err))
+	}
+	if c.RetryDelay < 0 {
+		problems = append(problems, fmt.Sprintf("retry_delay_ms is %d, want a positive number", c.RetryDelay))
+	}
+	if c.RetryJitter > 1 {
+		problems = append(problems, fmt.Sprintf("retry_jitter is %g, want at most 1", c.RetryJitter))
+	}
+	if c.Timeout <= 0 {
+		problems = append(problems, fmt.Sprintf("timeout_seconds is %d, want a positive number", c.Timeout))
+	}
+	if c.Version > ConfigVersion {
+		problems = append(problems, fmt.Sprintf("schema version %d is newer than this tspider (%d)", c.Version, ConfigVersion))
+	}
+	return problems
+}
+
+// SelfCheck inspects the config file and cross-references its sites with
+// implemented, the names of the sites tspider has scrapers for
+func SelfCheck(implemented []string) *SelfReport {
+	r := &SelfReport{ConfigPath: GetConfigPath(), Implemented: len(implemented)}
+
+	c, err := ReadConfigFile(r.ConfigPath)
+	switch {
+	case os.IsNotExist(err):
+		r.ConfigError = err.Error()
+		c = GetConfig()
+	case err != nil:
+		r.ConfigExists = true
+		r.ConfigError = err.Error()
+		c = GetConfig()
+	default:
+		r.ConfigExists = true
+		r.ConfigValid = true
+	}
+	r.Writable = writable(r.ConfigPath)
+	r.SchemaVersion = c.Version
+	r.Problems = ValidateConfig(c)
+	if len(r.Problems) > 0 {
+		r.ConfigValid = false
+	}
+
+	known := make(map[string]bool, len(implemented))
+	for _, name := range implemented {
+		known[name] = true
+		if _, ok := c.Sites[name]; !ok {
+			r.NotConfigured = append(r.NotConfigured, name)
+		}
+	}
+	r.Configured = len(c.Sites)
+	for name, site := range c.Sites {
+		if site.Enabled {
+			r.Enabled++
+		}
+		if !known[name] {
+			r.NoScraper = append(r.NoScraper, name)
+		}
+	}
+	sort.Strings(r.NoScraper)
+	sort.Strings(r.NotConfigured)
+
+	for _, key := range selfCheckEnv {
+		if v, ok := os.LookupEnv(key); ok {
+			if r.Env == nil {
+				r.Env = map[string]string{}
+			}
+			if strings.HasSuffix(strings.ToUpper(key), "_PROXY") {
+				v = redactUserinfo(v)
+			}
+			r.Env[key] = v
+		}
+	}
+	return r
+}
+
+// redactUserinfo hides the user name and password of a proxy URL, with or
+// without a scheme, so that the report can be shared. It cuts at the last
+// '@', as passwords are often written with reserved characters unescaped.
+func redactUserinfo(v string) string {
+	prefix, rest := "", v
+	if i := strings.Index(v, "://"); i >= 0 {
+		prefix, rest = v[:i+3], v[i+3:]
+	}
+	at := strings.LastIndex(rest, "@")
+	if at < 0 {
+		return v
+	}
+	return prefix + "xxxxx" + rest[at:]
+}
+
+// writable reports whether path can be written, creating nothing when it
+// already exists
+func writable(path string) bool {
+	if f, err := os.OpenFile(path, os.O_WRONLY, 0); err == nil {
+		f.Close()
+		return true
+	} else if !os.IsNotExist(err) {
+		return false
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), ".tspider-check-*")
+	if err != nil {
+		return false
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return true
+}
+
+// PrintSelfReport prints r for humans
+func PrintSelfReport(w io.Writer, r *SelfReport) {
+	yesNo := func(b bool) string {
+		if b {
+			return "yes"
+		}
+		return "no"
+	}
+	fmt.Fprintf(w, "Config file:    %s\n", r.ConfigPath)
+	fmt.Fprintf(w, "  exists:       %s\n", yesNo(r.ConfigExists))
+	fmt.Fprintf(w, "  valid:        %s\n", yesNo(r.ConfigValid))
+	if r.ConfigError != "" {
+		fmt.Fprintf(w, "  error:        %s\n", r.ConfigError)
+	}
+	fmt.Fprintf(w, "  writable:     %s\n", yesNo(r.Writable))
+	fmt.Fprintf(w, "  schema:       v%d (current v%d)\n", r.SchemaVersion, ConfigVersion)
+	for _, p := range r.Problems {
+		fmt.Fprintf(w, "  [!] %s\n", p)
+	}
+	fmt.Fprintf(w, "Sites:          %d implemented, %d configured, %d enabled\n", r.Implemented, r.Configured, r.Enabled)
+	if len(r.NoScraper) > 0 {
+		fmt.Fprintf(w, "  no scraper:   %v\n", r.NoScraper)
+	}
+	if len(r.NotConfigured) > 0 {
+		fmt.Fprintf(w, "  unconfigured: %v\n", r.NotConfigured)
+	}
+	if len(r.Env) == 0 {
+		fmt.Fprintln(w, "Environment:    no overrides")
+		return
+	}
+	fmt.Fprintln(w, "Environment:")
+	keys := make([]string, 0, len(r.Env))
+	for k := range r.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "  %s=%s\n", k, r.Env[k])
+	}
+}
@@ -0,0 +1,78 @@
+package common
+
+import "sync"
+
+var (
+	// torrentURL maps the names of the sites active for this run, the
+	// enabled sites or those of the active profile, to the URL they are
+	// searched on. Searches served concurrently read and update it, so it is
+	// only reached through the functions below, which load the config first.
+	torrentURL   = map[string]string{}
+	torrentURLMu sync.RWMutex
+)
+
+// SiteURL returns the URL the site name is searched on, "" when it is not
+// active
+func SiteURL(name string) string {
+	LoadConfig()
+	torrentURLMu.RLock()
+	defer torrentURLMu.RUnlock()
+	return torrentURL[name]
+}
+
+// LookupSiteURL returns the URL the site name is searched on and whether it
+// is active
+func LookupSiteURL(name string) (string, bool) {
+	LoadConfig()
+	torrentURLMu.RLock()
+	defer torrentURLMu.RUnlock()
+	u, ok := torrentURL[name]
+	return u, ok
+}
+
+// SiteURLs returns a copy of the URLs of the active sites, by name
+func SiteURLs() map[string]string {
+	LoadConfig()
+	torrentURLMu.RLock()
+	defer torrentURLMu.RUnlock()
+	urls := make(map[string]string, len(torrentURL))
+	for name, u := range torrentURL {
+		urls[name] = u
+	}
+	return urls
+}
+
+// UseSiteURL makes name an active site searched on url for the rest of the
+// run, without changing the config
+func UseSiteURL(name, url string) {
+	LoadConfig()
+	torrentURLMu.Lock()
+	torrentURL[name] = url
+	torrentURLMu.Unlock()
+}
+
+// StopSite makes name inactive for the rest of the run
+func StopSite(name string) {
+	LoadConfig()
+	torrentURLMu.Lock()
+	delete(torrentURL, name)
+	torrentURLMu.Unlock()
+}
+
+// setSiteURLs makes the sites of urls the only active ones
+func setSiteURLs(urls map[string]string) {
+	torrentURLMu.Lock()
+	torrentURL = urls
+	torrentURLMu.Unlock()
+}
+
+// enabledSiteURLs returns the URLs of the enabled sites of c, by name
+func enabledSiteURLs(c *Config) map[string]string {
+	urls := make(map[string]string)
+	for name, site := range c.Sites {
+		if site.Enabled {
+			urls[name] = site.URL
+		}
+	}
+	return urls
+}
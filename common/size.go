@@ -0,0 +1,153 @@
+package common
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultSizeBuckets are the bucket bounds used by --group-by-size when the
+// config does not set size_buckets: <500MiB, 500MiB–2GiB and >2GiB
+var DefaultSizeBuckets = []int64{500 << 20, 2 << 30}
+
+// UnknownSizeBucket holds the results whose size is missing or unparseable
+const UnknownSizeBucket = "unknown"
+
+var sizeUnits = []struct {
+	name  string
+	bytes int64
+}{
+	{"TiB", 1 << 40},
+	{"GiB", 1 << 30},
+	{"MiB", 1 << 20},
+	{"KiB", 1 << 10},
+}
+
+var sizePattern = regexp.MustCompile(`(?i)^([0-9]+(?:[.,][0-9]+)?)\s*([KMGT]i?B|B|bytes)?$`)
+
+// ParseSize parses a size as shown by torrent sites, such as "1.4 GiB",
+// "700MB" or "512 KiB". KB and KiB (and so on) are both taken as powers of
+// 1024, as sites use them interchangeably. A number without unit is bytes.
+func ParseSize(s string) (int64, bool) {
+	m := sizePattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(strings.Replace(m[1], ",", ".", 1), 64)
+	if err != nil {
+		return 0, false
+	}
+	unit := int64(1)
+	if m[2] != "" {
+		switch strings.ToUpper(m[2][:1]) {
+		case "K":
+			unit = 1 << 10
+		case "M":
+			unit = 1 << 20
+		case "G":
+			unit = 1 << 30
+		case "T":
+			unit = 1 << 40
+		}
+	}
+	return int64(n * float64(unit)), true
+}
+
+// FormatSize formats n bytes with the largest binary unit it reaches, e.g.
+// "500MiB" or "1.5GiB"
+func FormatSize(n int64) string {
+	for _, u := range sizeUnits {
+		if n >= u.bytes {
+			return strconv.FormatFloat(float64(n)/float64(u.bytes), 'f', -1, 64) + u.name
+		}
+	}
+	return fmt.Sprintf("%dB", n)
+}
+
+// SizeBuckets returns the bucket bounds from the size_buckets config key,
+// or DefaultSizeBuckets when it is unset or invalid
+func SizeBuckets() []int64 {
+	sizes := GetConfig().SizeBuckets
+	if len(sizes) == 0 {
+		return DefaultSizeBuckets
+	}
+	bounds, err := parseSizeBuckets(sizes)
+	if err != nil {
+		return DefaultSizeBuckets
+	}
+	return bounds
+}
+
+// parseSizeBuckets parses bucket bounds and sorts them
+func parseSizeBuckets(sizes []string) ([]int64, error) {
+	bounds := make([]int64, 0, len(sizes))
+	for _, s := range sizes {
+		n, ok := ParseSize(s)
+		if !ok || n <= 0 {
+			return nil, fmt.Errorf("invalid size %q", s)
+		}
+		bounds = append(bounds, n)
+	}
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i] < bounds[j] })
+	return bounds, nil
+}
+
+// SizeBucketLabels returns the labels of the buckets delimited by bounds,
+// smallest first, followed by UnknownSizeBucket
+func SizeBucketLabels(bounds []int64) []string {
+	bounds = sortedBounds(bounds)
+	var labels []string
+	for i, b := range bounds {
+		if i == 0 {
+			labels = append(labels, "<"+FormatSize(b))
+		}
+		if i+1 < len(bounds) {
+			labels = append(labels, FormatSize(b)+"–"+FormatSize(bounds[i+1]))
+		} else {
+			labels = append(labels, ">"+FormatSize(b))
+		}
+	}
+	return append(labels, UnknownSizeBucket)
+}
+
+func sortedBounds(bounds []int64) []int64 {
+	bounds = append([]int64(nil), bounds...)
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i] < bounds[j] })
+	return bounds
+}
+
+// BucketBySize groups results by size into the buckets delimited by bounds,
+// keyed by the labels of SizeBucketLabels. A bound belongs to the bucket it
+// starts. Results keep their order within a bucket.
+func BucketBySize(results []SearchResult, bounds []int64) map[string][]SearchResult {
+	bounds = sortedBounds(bounds)
+	labels := SizeBucketLabels(bounds)
+	buckets := map[string][]SearchResult{}
+	for _, r := range results {
+		size, ok := ParseSize(r.Size)
+		if !ok || len(bounds) == 0 {
+			buckets[UnknownSizeBucket] = append(buckets[UnknownSizeBucket], r)
+			continue
+		}
+		i := sort.Search(len(bounds), func(i int) bool { return bounds[i] > size })
+		buckets[labels[i]] = append(buckets[labels[i]], r)
+	}
+	return buckets
+}
+
+// PrintBySize prints results as one table per size bucket, smallest first,
+// each under a header with the bucket and its result count. Empty buckets
+// are skipped.
+func PrintBySize(w io.Writer, columns []Column, results []SearchResult, bounds []int64) {
+	buckets := BucketBySize(results, bounds)
+	for _, label := range SizeBucketLabels(bounds) {
+		if len(buckets[label]) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "== %s (%d) ==\n", label, len(buckets[label]))
+		PrintTable(w, columns, buckets[label])
+	}
+}
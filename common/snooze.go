@@ -0,0 +1,149 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// defaultSnoozeAfter is how many availability checks in a row a site
+	// fails before it is snoozed, when the config does not set
+	// snooze_after_failures
+	defaultSnoozeAfter = 3
+	// defaultSnoozeHours is how long a site stays snoozed when the config
+	// does not set snooze_hours
+	defaultSnoozeHours = 24
+)
+
+// SiteHealth is the record of a site failing its availability checks
+type SiteHealth struct {
+	// Failures counts the checks failed in a row
+	Failures     int       `json:"failures"`
+	LastFailure  time.Time `json:"last_failure"`
+	SnoozedUntil time.Time `json:"snoozed_until,omitempty"`
+}
+
+// SiteState remembers, across runs, the sites failing their availability
+// checks, so that a site failing snooze_after_failures checks in a row is
+// skipped by searches for snooze_hours instead of being checked again. It
+// is not safe for concurrent use; changes go through UpdateSiteState.
+type SiteState struct {
+	path  string
+	Sites map[string]SiteHealth `json:"sites"`
+}
+
+// GetSiteStatePath returns the site state file path
+func GetSiteStatePath() string {
+	return filepath.Join(filepath.Dir(GetConfigPath()), ".tspider_state.json")
+}
+
+// LoadSiteState reads the site state. A missing file is an empty state.
+func LoadSiteState() (*SiteState, error) {
+	s := &SiteState{path: GetSiteStatePath(), Sites: map[string]SiteHealth{}}
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read site state: %w", err)
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("failed to parse site state %s: %w", s.path, err)
+	}
+	if s.Sites == nil {
+		s.Sites = map[string]SiteHealth{}
+	}
+	return s, nil
+}
+
+// UpdateSiteState applies fn to the site state under the config lock and
+// saves the result, so that concurrent searches never lose each other's
+// checks. Nothing is saved when fn returns an error.
+func UpdateSiteState(fn func(s *SiteState) error) error {
+	unlock, err := lockConfig()
+	if err != nil {
+		return fmt.Errorf("failed to lock site state: %w", err)
+	}
+	defer unlock()
+	s, err := LoadSiteState()
+	if err != nil {
+		return err
+	}
+	if err := fn(s); err != nil {
+		return err
+	}
+	return s.Save()
+}
+
+// snoozePolicy returns after how many failures in a row a site is snoozed,
+// 0 when sites are never snoozed, and for how long
+func snoozePolicy() (int, time.Duration) {
+	c := GetConfig()
+	after, hours := c.SnoozeAfter, c.SnoozeHours
+	if after < 0 {
+		return 0, 0
+	}
+	if after == 0 {
+		after = defaultSnoozeAfter
+	}
+	if hours <= 0 {
+		hours = defaultSnoozeHours
+	}
+	return after, time.Duration(hours) * time.Hour
+}
+
+// Snoozed reports whether site is snoozed at now
+func (s *SiteState) Snoozed(site string, now time.Time) bool {
+	return now.Before(s.Sites[site].SnoozedUntil)
+}
+
+// RecordFailure counts a failed check of site at now, snoozing the site
+// when that makes enough failures in a row; it reports whether it did
+func (s *SiteState) RecordFailure(site string, now time.Time) bool {
+	h := s.Sites[site]
+	h.Failures++
+	h.LastFailure = now
+	after, snooze := snoozePolicy()
+	snoozed := after > 0 && h.Failures >= after
+	if snoozed {
+		h.SnoozedUntil = now.Add(snooze)
+		// A site still failing once awake is snoozed again at once
+		h.Failures = after - 1
+	}
+	s.Sites[site] = h
+	return snoozed
+}
+
+// RecordSuccess forgets the failures of site
+func (s *SiteState) RecordSuccess(site string) {
+	delete(s.Sites, site)
+}
+
+// Reset forgets the failures of sites, or of every site when none is given
+func (s *SiteState) Reset(sites ...string) {
+	if len(sites) == 0 {
+		s.Sites = map[string]SiteHealth{}
+		return
+	}
+	for _, site := range sites {
+		delete(s.Sites, site)
+	}
+}
+
+// Save writes the site state, removing the file when it is empty
+func (s *SiteState) Save() error {
+	if len(s.Sites) == 0 {
+		if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(s.path, data)
+}
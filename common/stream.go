@@ -0,0 +1,112 @@
+package common
+
+import (
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// StreamParse makes scrapers extract result links with StreamLinks instead
+// of building a goquery document, unless the site has a list selector
+// override. It trades selector flexibility for memory on very large pages.
+var StreamParse bool
+
+// Link is a result link found on a search page
+type Link struct {
+	Title string
+	Href  string
+}
+
+// LinkRule tells StreamLinks which links are results
+type LinkRule struct {
+	// Within, when set, only accepts links inside an element with this class
+	Within string
+	// Href reports whether a link target is a result
+	Href func(href string) bool
+	// TitleFromText takes the title from the link text instead of its
+	// title attribute
+	TitleFromText bool
+}
+
+// voidElements never have an end tag, so they must not change the depth
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true,
+	"img": true, "input": true, "link": true, "meta": true, "source": true, "track": true, "wbr": true,
+}
+
+// StreamLinks tokenizes an HTML page and returns the links matching rule,
+// without building the DOM. Links without a title are skipped.
+func StreamLinks(r io.Reader, rule LinkRule) ([]Link, error) {
+	var (
+		links []Link
+		// depth counts open elements inside the Within container, 0 outside
+		depth int
+		// current is the link being read, text its text so far
+		current *Link
+		text    strings.Builder
+	)
+	z := html.NewTokenizer(r)
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			if z.Err() == io.EOF {
+				return links, nil
+			}
+			return links, z.Err()
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			tag := string(name)
+			attrs := map[string]string{}
+			for hasAttr {
+				var k, v []byte
+				k, v, hasAttr = z.TagAttr()
+				attrs[string(k)] = string(v)
+			}
+			if tt == html.SelfClosingTagToken || voidElements[tag] {
+				continue
+			}
+			if depth > 0 {
+				depth++
+			} else if rule.Within != "" && hasClass(attrs["class"], rule.Within) {
+				depth = 1
+			}
+			inside := rule.Within == "" || depth > 0
+			if tag == "a" && inside && current == nil {
+				if href, ok := attrs["href"]; ok && rule.Href(href) {
+					current = &Link{Title: attrs["title"], Href: href}
+					text.Reset()
+				}
+			}
+		case html.TextToken:
+			if current != nil {
+				text.Write(z.Text())
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			if string(name) == "a" && current != nil {
+				if rule.TitleFromText {
+					current.Title = text.String()
+				}
+				current.Title = strings.TrimSpace(current.Title)
+				if current.Title != "" {
+					links = append(links, *current)
+				}
+				current = nil
+			}
+			if depth > 0 {
+				depth--
+			}
+		}
+	}
+}
+
+func hasClass(attr, class string) bool {
+	for _, c := range strings.Fields(attr) {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package common
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SiteSuggestions are the completions a site suggests for a prefix, or the
+// error asking it for them
+type SiteSuggestions struct {
+	Site        string   `json:"site"`
+	Suggestions []string `json:"suggestions"`
+	Error       string   `json:"error,omitempty"`
+}
+
+// Suggest asks the enabled sites among sites whose scraper is a Suggester
+// for their completions of prefix, at once and each within the configured
+// timeout, and returns them sorted by site. The other sites are skipped, so
+// nothing is returned when none offers suggestions.
+func Suggest(ctx context.Context, sites map[string]Scraper, prefix string) []SiteSuggestions {
+	var names []string
+	for name, scraper := range sites {
+		if _, ok := scraper.(Suggester); !ok {
+			continue
+		}
+		if _, ok := LookupSiteURL(name); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	timeout := time.Duration(GetConfig().Timeout) * time.Second
+	found := make([]SiteSuggestions, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		found[i] = SiteSuggestions{Site: name, Suggestions: []string{}}
+		wg.Add(1)
+		go func(s *SiteSuggestions, suggester Suggester) {
+			defer wg.Done()
+			ctx := ctx
+			if timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, timeout)
+				defer cancel()
+			}
+			suggestions, err := suggester.Suggest(ctx, prefix)
+			if err != nil {
+				s.Error = err.Error()
+				return
+			}
+			if suggestions != nil {
+				s.Suggestions = suggestions
+			}
+		}(&found[i], sites[name].(Suggester))
+	}
+	wg.Wait()
+	return found
+}
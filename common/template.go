@@ -0,0 +1,44 @@
+package common
+
+import (
+	"io"
+	"strings"
+	"text/template"
+)
+
+// templateEscapes are the escapes ParseResultTemplate unescapes, as shells
+// pass them literally inside single quotes
+var templateEscapes = strings.NewReplacer(`\t`, "\t", `\n`, "\n")
+
+// ParseResultTemplate parses text as a Go template executed for each
+// SearchResult by WriteTemplate, e.g. "{{.Title}}\t{{.Magnet}}". It is tried
+// on an empty result so that unknown fields are reported before a search.
+func ParseResultTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("result").Parse(templateEscapes.Replace(text))
+	if err != nil {
+		return nil, err
+	}
+	if err := tmpl.Execute(io.Discard, SearchResult{}); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// WriteTemplate executes tmpl for each result, writing one line per result
+// to w. A newline is appended unless the template output already ends in one.
+func WriteTemplate(w io.Writer, tmpl *template.Template, results []SearchResult) error {
+	var b strings.Builder
+	for _, r := range results {
+		b.Reset()
+		if err := tmpl.Execute(&b, r); err != nil {
+			return err
+		}
+		if !strings.HasSuffix(b.String(), "\n") {
+			b.WriteByte('\n')
+		}
+		if _, err := io.WriteString(w, b.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package common
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+
+	utls "github.com/refraction-networking/utls"
+	"golang.org/x/net/http2"
+)
+
+// fingerprintTransport sends the https requests of sites with the chrome
+// tls_fingerprint through chrome, and every other request through base
+type fingerprintTransport struct {
+	base   http.RoundTripper
+	chrome *chromeTransport
+}
+
+// RoundTrip sends req through the transport its site calls for
+func (t *fingerprintTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme == "https" {
+		if site, ok := siteConfigForURL(req.URL.String()); ok && site.TLSFingerprint == TLSFingerprintChrome {
+			return t.chrome.RoundTrip(req)
+		}
+	}
+	return t.base.RoundTrip(req)
+}
+
+// chromeTransport sends https requests over connections whose TLS
+// ClientHello is that of Chrome, made with uTLS, for mirrors that block the
+// handshake of Go clients. Like Chrome it offers HTTP/2 and HTTP/1.1, and
+// speaks whichever the server picks.
+type chromeTransport struct {
+	dial func(ctx context.Context, network, addr string) (net.Conn, error)
+	h1   *http.Transport
+	h2   *http2.Transport
+
+	mu sync.Mutex
+	// h1Hosts are the hosts that picked HTTP/1.1
+	h1Hosts map[string]bool
+}
+
+// newChromeTransport returns a chromeTransport dialing with dial and
+// keeping connections alive like base
+func newChromeTransport(base *http.Transport, dial func(ctx context.Context, network, addr string) (net.Conn, error)) *chromeTransport {
+	t := &chromeTransport{dial: dial, h1Hosts: map[string]bool{}}
+	t.h1 = base.Clone()
+	t.h1.Proxy = nil
+	t.h1.DialTLSContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return t.dialTLS(ctx, network, addr)
+	}
+	t.h2 = &http2.Transport{
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			conn, err := t.dialTLS(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			if conn.ConnectionState().NegotiatedProtocol != http2.NextProtoTLS {
+				conn.Close()
+				t.mu.Lock()
+				t.h1Hosts[addr] = true
+				t.mu.Unlock()
+				return nil, fmt.Errorf("%s does not speak HTTP/2", addr)
+			}
+			return conn, nil
+		},
+	}
+	return t
+}
+
+// RoundTrip sends req over HTTP/2 unless its host picked HTTP/1.1 before.
+// A host found to pick HTTP/1.1 while dialing gets req resent over it; no
+// part of req was sent on the connection dropped.
+func (t *chromeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	addr := canonicalAddr(req)
+	if !t.usesHTTP1(addr) {
+		resp, err := t.h2.RoundTrip(req)
+		if err == nil || !t.usesHTTP1(addr) {
+			return resp, err
+		}
+	}
+	return t.h1.RoundTrip(req)
+}
+
+// CloseIdleConnections closes the idle connections of both protocols
+func (t *chromeTransport) CloseIdleConnections() {
+	t.h1.CloseIdleConnections()
+	t.h2.CloseIdleConnections()
+}
+
+// usesHTTP1 reports whether the host at addr picked HTTP/1.1
+func (t *chromeTransport) usesHTTP1(addr string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.h1Hosts[addr]
+}
+
+// dialTLS connects to addr and performs a TLS handshake with the ClientHello
+// of Chrome
+func (t *chromeTransport) dialTLS(ctx context.Context, network, addr string) (*utls.UConn, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := t.dial(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	tlsConn := utls.UClient(conn, &utls.Config{ServerName: host}, utls.HelloChrome_Auto)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// canonicalAddr returns the host:port req is sent to
+func canonicalAddr(req *http.Request) string {
+	port := req.URL.Port()
+	if port == "" {
+		port = "443"
+	}
+	return net.JoinHostPort(req.URL.Hostname(), port)
+}
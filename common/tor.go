@@ -0,0 +1,117 @@
+package common
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultTorSocksAddr is where a local Tor daemon listens for SOCKS clients
+	DefaultTorSocksAddr = "127.0.0.1:9050"
+	// DefaultTorControlAddr is where a local Tor daemon listens for controllers
+	DefaultTorControlAddr = "127.0.0.1:9051"
+)
+
+// torSocksAddr returns the configured Tor SOCKS address
+func torSocksAddr() string {
+	if addr := GetConfig().TorSocksAddr; addr != "" {
+		return addr
+	}
+	return DefaultTorSocksAddr
+}
+
+// torProxy is the SOCKS proxy this run goes through, set by UseTor, and
+// torIsolate whether each site gets its own circuit on it. They override
+// the config for this run only and are never saved with it.
+var (
+	torMu      sync.Mutex
+	torProxy   string
+	torIsolate bool
+)
+
+// UseTor routes every request of this run through the local Tor SOCKS proxy,
+// over a separate circuit for each site when isolateSites is set. It fails
+// if nothing listens on the SOCKS port, and must be called before the first
+// request since the shared client is built once. The config is left as it
+// is, so saving it does not save the proxy.
+func UseTor(isolateSites bool) error {
+	addr := torSocksAddr()
+	conn, err := net.DialTimeout("tcp", addr, 3*time.Second)
+	if err != nil {
+		return fmt.Errorf("Tor does not seem to be running: no SOCKS proxy at %s (%v)", addr, err)
+	}
+	conn.Close()
+	torMu.Lock()
+	torProxy = "socks5://" + addr
+	torIsolate = isolateSites
+	torMu.Unlock()
+	return nil
+}
+
+// runConfig returns c with the Tor settings of UseTor applied, on a copy
+func runConfig(c *Config) *Config {
+	torMu.Lock()
+	defer torMu.Unlock()
+	if torProxy == "" {
+		return c
+	}
+	run := *c
+	run.Proxy = torProxy
+	run.TorIsolateSites = run.TorIsolateSites || torIsolate
+	return &run
+}
+
+// NewTorCircuit asks Tor, through its control port, to use new circuits for
+// new connections, and drops idle connections so the next request uses one.
+// The control port must be enabled in torrc (ControlPort 9051) with either
+// no authentication or HashedControlPassword matching tor_control_password.
+func NewTorCircuit() error {
+	c := GetConfig()
+	addr := c.TorControlAddr
+	if addr == "" {
+		addr = DefaultTorControlAddr
+	}
+	if err := torSignalNewnym(addr, c.TorControlPassword); err != nil {
+		return err
+	}
+	HTTPClient().CloseIdleConnections()
+	return nil
+}
+
+// torSignalNewnym authenticates to the Tor control port at addr and sends
+// SIGNAL NEWNYM
+func torSignalNewnym(addr, password string) error {
+	conn, err := net.DialTimeout("tcp", addr, 3*time.Second)
+	if err != nil {
+		return fmt.Errorf("cannot reach the Tor control port at %s; enable ControlPort in torrc (%v)", addr, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(conn)
+
+	command := func(line string) error {
+		if _, err := fmt.Fprintf(conn, "%s\r\n", line); err != nil {
+			return err
+		}
+		reply, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(reply, "250") {
+			return fmt.Errorf("Tor control port: %s", strings.TrimSpace(reply))
+		}
+		return nil
+	}
+	auth := "AUTHENTICATE"
+	if password != "" {
+		auth += ` "` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(password) + `"`
+	}
+	if err := command(auth); err != nil {
+		return err
+	}
+	return command("SIGNAL NEWNYM")
+}
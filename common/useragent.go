@@ -0,0 +1,70 @@
+package common
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// UserAgentRotations are the accepted values of user_agent_rotation
+var UserAgentRotations = []string{"round-robin", "random"}
+
+// uaCounter is the position of the next round-robin User-Agent
+var uaCounter uint64
+
+// NextUserAgent returns the User-Agent for the next request: one of the
+// user_agents pool, in turn or at random depending on user_agent_rotation,
+// or the single user_agent when the pool is empty. Safe for concurrent use.
+func NextUserAgent() string {
+	c := GetConfig()
+	pool := c.UserAgents
+	switch {
+	case len(pool) == 0:
+		return c.UserAgent
+	case c.UserAgentRotation == "random":
+		return pool[rand.Intn(len(pool))]
+	default:
+		return pool[(atomic.AddUint64(&uaCounter, 1)-1)%uint64(len(pool))]
+	}
+}
+
+// AddUserAgent adds a User-Agent string to the rotation pool
+func AddUserAgent(ua string) error {
+	ua = strings.TrimSpace(ua)
+	if ua == "" {
+		return fmt.Errorf("User-Agent must not be empty")
+	}
+	return UpdateConfig(func(c *Config) error {
+		if containsString(c.UserAgents, ua) {
+			return fmt.Errorf("User-Agent '%s' is already in the pool", ua)
+		}
+		c.UserAgents = append(c.UserAgents, ua)
+		return nil
+	})
+}
+
+// ListUserAgents prints the User-Agent pool, or the single User-Agent in use
+// when the pool is empty
+func ListUserAgents() {
+	c := GetConfig()
+	if len(c.UserAgents) == 0 {
+		fmt.Printf("No User-Agent pool; every request uses:\n%s\n", c.UserAgent)
+		return
+	}
+	rotation := c.UserAgentRotation
+	if rotation == "" {
+		rotation = UserAgentRotations[0]
+	}
+	fmt.Printf("Rotation: %s\n\n", rotation)
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"#", "User-Agent"})
+	table.SetColWidth(100)
+	for i, ua := range c.UserAgents {
+		table.Append([]string{fmt.Sprint(i + 1), ua})
+	}
+	table.Render()
+}
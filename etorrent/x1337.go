@@ -0,0 +1,152 @@
+package etorrent
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/daite/tspider/common"
+)
+
+// ordinal matches the suffix of the day in the dates of 1337x, as in
+// "Oct. 15th '26"
+var ordinal = regexp.MustCompile(`(\d)(?:st|nd|rd|th)\b`)
+
+// X1337 struct is for 1337x torrent web site
+type X1337 struct {
+	Name        string
+	Keyword     string
+	SearchURL   string
+	ScrapedData *sync.Map
+}
+
+// initialize method set keyword and URL based on default url
+func (x *X1337) initialize(keyword string) {
+	x.Keyword = keyword
+	x.Name = "1337x"
+	x.SearchURL = common.SiteURL(x.Name) + "/search/" + url.QueryEscape(x.Keyword) + "/1/"
+}
+
+// Crawl torrent data from web site
+func (x *X1337) Crawl(ctx context.Context, keyword string) []common.SearchResult {
+	x.initialize(keyword)
+	data := x.getData(ctx, x.SearchURL)
+	if data == nil {
+		return nil
+	}
+	return common.ResultsFromMap(data)
+}
+
+// GetData method returns the results by title
+func (x *X1337) getData(ctx context.Context, url string) *sync.Map {
+	details := common.NewFetchGroup()
+	m := &sync.Map{}
+	resp, ok := common.GetResponseFromURL(ctx, url)
+	if !ok {
+		return nil
+	}
+	defer resp.Body.Close()
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return nil
+	}
+	for _, r := range X1337Results(doc, url) {
+		r := r
+		details.Go(func() {
+			r.Magnet, r.Category = x.getDetail(ctx, r.DetailURL)
+			m.Store(r.Title, r)
+		})
+	}
+	details.Wait()
+	x.ScrapedData = m
+	return m
+}
+
+// X1337Results returns the results listed on a 1337x search page fetched
+// from pageURL, without their magnets: the title, the detail page resolved
+// against pageURL, the seeders, leechers, size, upload day and uploader
+func X1337Results(doc *goquery.Document, pageURL string) []common.SearchResult {
+	var results []common.SearchResult
+	doc.Find(common.ListSelector("1337x", `td.name a[href^="/torrent/"]`)).Each(func(i int, s *goquery.Selection) {
+		title := strings.TrimSpace(s.Text())
+		href, ok := s.Attr("href")
+		if title == "" || !ok {
+			return
+		}
+		detail, err := common.URLJoin(pageURL, href)
+		if err != nil {
+			return
+		}
+		row := s.Closest("tr")
+		r := common.SearchResult{
+			Title:     title,
+			DetailURL: strings.TrimSpace(detail),
+			Uploader:  strings.TrimSpace(row.Find("td.coll-5 a").Text()),
+			Date:      x1337Date(row.Find("td.coll-date").Text()),
+		}
+		r.Seeders, _ = strconv.Atoi(strings.TrimSpace(row.Find("td.seeds").Text()))
+		r.Leechers, _ = strconv.Atoi(strings.TrimSpace(row.Find("td.leeches").Text()))
+		// The size cell repeats the seeders for small screens
+		r.Size = strings.TrimSpace(row.Find("td.size").Clone().Children().Remove().End().Text())
+		results = append(results, r)
+	})
+	return results
+}
+
+// x1337Date reads the upload day of a 1337x result, such as "Oct. 15th '26";
+// results of the current day show their hour instead and get a zero time
+func x1337Date(s string) time.Time {
+	s = ordinal.ReplaceAllString(strings.ReplaceAll(strings.TrimSpace(s), ".", ""), "$1")
+	t, _ := time.Parse("Jan 2 '06", s)
+	return t
+}
+
+// GetMagnet method returns torrent magnet
+func (x *X1337) GetMagnet(ctx context.Context, url string) string {
+	magnet, _ := x.getDetail(ctx, url)
+	return magnet
+}
+
+// getDetail fetches the detail page at url and returns its magnet, or why
+// there is none, and its category
+func (x *X1337) getDetail(ctx context.Context, url string) (magnet, category string) {
+	resp, ok := common.GetResponseFromURL(ctx, url)
+	if !ok {
+		return "failed to fetch magnet", ""
+	}
+	defer resp.Body.Close()
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return fmt.Sprintf("parse error: %v", err), ""
+	}
+	if magnet = X1337Magnet(doc); magnet == "" {
+		magnet = "no magnet"
+	}
+	return magnet, X1337Category(doc)
+}
+
+// X1337Magnet returns the magnet of a 1337x detail page, or "" if there is
+// none
+func X1337Magnet(doc *goquery.Document) string {
+	href, _ := doc.Find(common.MagnetSelector("1337x", `a[href^="magnet:"]`)).First().Attr("href")
+	return strings.TrimSpace(href)
+}
+
+// X1337Category returns the category a 1337x detail page lists, or "" if
+// there is none
+func X1337Category(doc *goquery.Document) string {
+	category := ""
+	doc.Find("ul.list li").EachWithBreak(func(i int, s *goquery.Selection) bool {
+		if strings.TrimSpace(s.Find("strong").Text()) == "Category" {
+			category = strings.TrimSpace(s.Find("span").Text())
+		}
+		return category == ""
+	})
+	return category
+}
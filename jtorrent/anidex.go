@@ -0,0 +1,89 @@
+package jtorrent
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/daite/tspider/common"
+)
+
+// anidexSize matches the size column of an AniDex result, such as "1.37 GB"
+var anidexSize = regexp.MustCompile(`^[0-9][0-9,]*(?:\.[0-9]+)?\s*[KMGT]i?B$`)
+
+// AniDex struct is for AniDex anime torrent web site
+type AniDex struct {
+	Name        string
+	Keyword     string
+	SearchURL   string
+	ScrapedData []common.SearchResult
+}
+
+// initialize method set keyword and URL based on default url
+func (a *AniDex) initialize(keyword string) {
+	a.Keyword = keyword
+	a.Name = "anidex"
+	a.SearchURL = common.SiteURL(a.Name) + "/?q=" + url.QueryEscape(a.Keyword)
+}
+
+// Crawl torrent data from web site. The search page lists the magnet of
+// each result, so no detail page is fetched.
+func (a *AniDex) Crawl(ctx context.Context, keyword string) []common.SearchResult {
+	a.initialize(keyword)
+	resp, ok := common.GetResponseFromURL(ctx, a.SearchURL)
+	if !ok {
+		return nil
+	}
+	defer resp.Body.Close()
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return nil
+	}
+	a.ScrapedData = AniDexResults(doc, a.SearchURL)
+	return a.ScrapedData
+}
+
+// AniDexResults returns the results listed on an AniDex search page fetched
+// from pageURL, one per row: the title, magnet, detail page, category,
+// uploader, size, upload time, and seeder, leecher and completed counts
+func AniDexResults(doc *goquery.Document, pageURL string) []common.SearchResult {
+	var results []common.SearchResult
+	doc.Find(common.ListSelector("anidex", "a.torrent")).Each(func(i int, s *goquery.Selection) {
+		title := strings.TrimSpace(s.Find("span[title]").AttrOr("title", s.Text()))
+		row := s.Closest("tr")
+		magnet := strings.TrimSpace(row.Find(`a[href^="magnet:"]`).AttrOr("href", ""))
+		if title == "" || magnet == "" {
+			return
+		}
+		r := common.SearchResult{
+			Title:    title,
+			Magnet:   magnet,
+			Category: strings.TrimSpace(row.Find(".category").Text()),
+			Uploader: strings.TrimSpace(row.Find(`a[href*="page=user"]`).Text()),
+		}
+		if href, ok := s.Attr("href"); ok {
+			r.DetailURL, _ = common.URLJoin(pageURL, href)
+		}
+		row.Children().Each(func(i int, td *goquery.Selection) {
+			text := strings.TrimSpace(td.Text())
+			switch {
+			case anidexSize.MatchString(text):
+				r.Size = text
+			case td.HasClass("text-success"):
+				r.Seeders, _ = strconv.Atoi(text)
+			case td.HasClass("text-danger"):
+				r.Leechers, _ = strconv.Atoi(text)
+			}
+			if uploaded, ok := td.Attr("title"); ok {
+				r.Date, _ = time.Parse("2006-01-02 15:04:05", uploaded)
+			}
+		})
+		r.Snatches, _ = strconv.Atoi(strings.TrimSpace(row.Children().Last().Text()))
+		results = append(results, r)
+	})
+	return results
+}
@@ -1,9 +1,12 @@
 package jtorrent
 
 import (
+	"context"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/daite/tspider/common"
@@ -18,16 +21,12 @@ type Client struct {
 // Data struct is for receiving final data
 type Data struct {
 	title string
+	link  string
 	info  []string
 }
 
-var (
-	clients = make(chan Client, 100)
-	data    = make(chan Data, 100)
-)
-
-func create(doc *goquery.Document, baseURL string) {
-	doc.Find("a[href*=view]:last-child").Each(func(i int, s *goquery.Selection) {
+func create(doc *goquery.Document, baseURL, selector string, clients chan<- Client) {
+	doc.Find(selector).Each(func(i int, s *goquery.Selection) {
 		title := strings.TrimSpace(s.Text())
 		link, _ := s.Attr("href")
 		link = baseURL + link
@@ -37,81 +36,142 @@ func create(doc *goquery.Document, baseURL string) {
 	close(clients)
 }
 
+// feed sends links found by common.StreamLinks as tasks
+func feed(links []common.Link, baseURL string, clients chan<- Client) {
+	for _, l := range links {
+		clients <- Client{l.Title, baseURL + l.Href}
+	}
+	close(clients)
+}
+
+// infoResult builds the result titled title from the fields GetInfo read on
+// its detail page at link. uploader stands in for a missing uploader field.
+func infoResult(title, link, uploader string, info []string) common.SearchResult {
+	// Category 0
+	// Time     1
+	// Uploader 2
+	// Seeder   3
+	// Info     4
+	// Leecher  5
+	// FileSize 6
+	// Snatch   7
+	// Magnet   8
+	// Folder   9
+	if info[2] != "" {
+		uploader = info[2]
+	}
+	seeders, _ := strconv.Atoi(info[3])
+	leechers, _ := strconv.Atoi(info[5])
+	snatches, _ := strconv.Atoi(info[7])
+	date, _ := time.Parse("2006-01-02 15:04 MST", info[1])
+	return common.SearchResult{
+		Title:     title,
+		Magnet:    "magnet:?xt=urn:btih:" + info[8],
+		Uploader:  uploader,
+		Seeders:   seeders,
+		Leechers:  leechers,
+		Snatches:  snatches,
+		Size:      info[6],
+		Folder:    info[9] == "Yes",
+		Date:      date,
+		DetailURL: link,
+	}
+}
+
+// ListLinks matches the result links of a Nyaa-style search page for
+// common.StreamLinks, like the default list selector: the title link of each
+// row (/view/N), not its comment count (/view/N#comments)
+var ListLinks = common.LinkRule{
+	Href: func(href string) bool {
+		return strings.HasPrefix(href, "/view/") && !strings.Contains(href, "#")
+	},
+	TitleFromText: true,
+}
+
+// SearchURL builds a Nyaa-style search URL on baseURL. With an uploader it
+// searches that user's listing (/user/NAME), otherwise the whole site. When
+// exact is set the keyword is quoted, which Nyaa treats as a phrase. Each
+// single-word term of exclude is appended as "-term"; phrases are not, as
+// Nyaa has no negative phrase syntax.
+func SearchURL(baseURL, uploader, keyword string, exact bool, exclude []string) string {
+	path := "/"
+	if uploader != "" {
+		path = "/user/" + url.PathEscape(uploader)
+	}
+	if exact && keyword != "" {
+		keyword = `"` + keyword + `"`
+	}
+	for _, term := range exclude {
+		if !strings.ContainsAny(term, " \t") {
+			keyword = strings.TrimSpace(keyword + " -" + term)
+		}
+	}
+	return baseURL + path + "?f=0&c=0_0&q=" + url.QueryEscape(keyword)
+}
+
 // Nyaa struct is for Nyaa torrent web site
 type Nyaa struct {
 	Name        string
 	Keyword     string
 	SearchURL   string
-	ScrapedData map[string][]string
+	Uploader    string   // limits the search to one user's uploads when set
+	Exact       bool     // searches the keyword as a quoted phrase
+	Exclude     []string // negative terms sent in the query
+	ScrapedData []common.SearchResult
+	clients     chan Client
+	data        chan Data
 }
 
 // initialize method set keyword and URL based on default url
 func (n *Nyaa) initialize(keyword string) {
+	n.clients = make(chan Client, 100)
+	n.data = make(chan Data, 100)
 	n.Keyword = keyword
 	n.Name = "nyaa"
-	n.SearchURL = common.TorrentURL[n.Name] + "/?f=0&c=0_0&q=" + url.QueryEscape(n.Keyword)
+	n.SearchURL = SearchURL(common.SiteURL(n.Name), n.Uploader, n.Keyword, n.Exact, n.Exclude)
 }
 
 // Crawl torrent data from web site
 // NOTE: status code error: 429 429 Too Many Requests for goroutines
 // Max concurrent request: 5
-func (n *Nyaa) Crawl(keyword string) map[string][]string {
+func (n *Nyaa) Crawl(ctx context.Context, keyword string) []common.SearchResult {
 	n.initialize(keyword)
-	data := n.getData(n.SearchURL)
-	if data == nil {
-		return nil
-	}
-	return data
+	return n.getData(ctx, n.SearchURL)
 }
 
-// GetData method returns map(title, bbs url)
-func (n *Nyaa) getData(url string) map[string][]string {
-	resp, ok := common.GetResponseFromURL(url)
+// GetData method returns the results listed on the search page at url
+func (n *Nyaa) getData(ctx context.Context, url string) []common.SearchResult {
+	resp, ok := common.GetResponseFromURL(ctx, url)
 	if !ok {
 		return nil
 	}
 	defer resp.Body.Close()
-	doc, err := goquery.NewDocumentFromResponse(resp)
-	if err != nil {
-		return nil
-	}
-	go create(doc, common.TorrentURL[n.Name])
-	n.makeWP(5)
-	m := make(map[string][]string, 0)
-	for d := range data {
-		title := d.title
-		// Category 0
-		// Time     1
-		// Uploader 2
-		// Seeder   3
-		// Info     4
-		// Leecher  5
-		// FileSize 6
-		// Snatch   7
-		// Magnet   8
-		// Folder   9
-		uploader := d.info[2]
-		seeder := d.info[3]
-		leecher := d.info[5]
-		snatch := d.info[7]
-		fileSize := d.info[6]
-		hash := d.info[8]
-		folder := d.info[9]
-		magnet := "magnet:?xt=urn:btih:" + hash
-		info := []string{
-			uploader, seeder, leecher, snatch,
-			fileSize, magnet, folder,
+	if common.StreamParse && common.ListSelector(n.Name, "") == "" {
+		links, err := common.StreamLinks(resp.Body, ListLinks)
+		if err != nil {
+			return nil
+		}
+		go feed(links, common.SiteURL(n.Name), n.clients)
+	} else {
+		doc, err := goquery.NewDocumentFromResponse(resp)
+		if err != nil {
+			return nil
 		}
-		m[title] = info
+		go create(doc, common.SiteURL(n.Name), common.ListSelector(n.Name, "a[href*=view]:last-child"), n.clients)
+	}
+	n.makeWP(ctx, 5)
+	results := []common.SearchResult{}
+	for d := range n.data {
+		results = append(results, infoResult(d.title, d.link, n.Uploader, d.info))
 	}
-	n.ScrapedData = m
-	return m
+	n.ScrapedData = results
+	return results
 }
 
 // GetInfo method returns torrent info
-func (n *Nyaa) GetInfo(url string) []string {
+func (n *Nyaa) GetInfo(ctx context.Context, url string) []string {
 	info := make([]string, 10)
-	resp, ok := common.GetResponseFromURL(url)
+	resp, ok := common.GetResponseFromURL(ctx, url)
 	if !ok {
 		return info
 	}
@@ -131,22 +191,22 @@ func (n *Nyaa) GetInfo(url string) []string {
 	return info
 }
 
-func (n *Nyaa) worker(wg *sync.WaitGroup) {
-	for c := range clients {
+func (n *Nyaa) worker(ctx context.Context, wg *sync.WaitGroup) {
+	for c := range n.clients {
 		title := c.title
-		info := n.GetInfo(c.link)
-		data <- Data{title, info}
+		info := n.GetInfo(ctx, c.link)
+		n.data <- Data{title, c.link, info}
 	}
 	wg.Done()
 }
 
-func (n *Nyaa) makeWP(num int) {
+func (n *Nyaa) makeWP(ctx context.Context, num int) {
 	// Max concurrent should be "5"
 	var wg sync.WaitGroup
 	for i := 0; i < num; i++ {
 		wg.Add(1)
-		go n.worker(&wg)
+		go n.worker(ctx, &wg)
 	}
 	wg.Wait()
-	close(data)
+	close(n.data)
 }
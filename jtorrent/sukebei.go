@@ -1,7 +1,7 @@
 package jtorrent
 
 import (
-	"net/url"
+	"context"
 	"strings"
 	"sync"
 
@@ -18,23 +18,27 @@ type SClient struct {
 // SData struct is for receiving final data
 type SData struct {
 	title string
+	link  string
 	info  []string
 }
 
-var (
-	sclients = make(chan SClient, 100)
-	sdata    = make(chan SData, 100)
-)
-
-func screate(doc *goquery.Document, baseURL string) {
-	doc.Find("a[href*=view]:last-child").Each(func(i int, s *goquery.Selection) {
+func screate(doc *goquery.Document, baseURL, selector string, clients chan<- SClient) {
+	doc.Find(selector).Each(func(i int, s *goquery.Selection) {
 		title := strings.TrimSpace(s.Text())
 		link, _ := s.Attr("href")
 		link = baseURL + link
 		c := SClient{title, link}
-		sclients <- c
+		clients <- c
 	})
-	close(sclients)
+	close(clients)
+}
+
+// sfeed sends links found by common.StreamLinks as tasks
+func sfeed(links []common.Link, baseURL string, clients chan<- SClient) {
+	for _, l := range links {
+		clients <- SClient{l.Title, baseURL + l.Href}
+	}
+	close(clients)
 }
 
 // SuKeBe struct is for sukebei torrent web site
@@ -42,75 +46,70 @@ type SuKeBe struct {
 	Name        string
 	Keyword     string
 	SearchURL   string
-	ScrapedData map[string][]string
+	Uploader    string   // limits the search to one user's uploads when set
+	Exact       bool     // searches the keyword as a quoted phrase
+	Exclude     []string // negative terms sent in the query
+	ScrapedData []common.SearchResult
+	clients     chan SClient
+	data        chan SData
 }
 
 // initialize method set keyword and URL based on default url
 func (s *SuKeBe) initialize(keyword string) {
+	s.clients = make(chan SClient, 100)
+	s.data = make(chan SData, 100)
 	s.Keyword = keyword
 	s.Name = "sukebe"
-	s.SearchURL = common.TorrentURL[s.Name] + "/?f=0&c=0_0&q=" + url.QueryEscape(s.Keyword)
+	s.SearchURL = SearchURL(common.SiteURL(s.Name), s.Uploader, s.Keyword, s.Exact, s.Exclude)
 }
 
 // Crawl torrent data from web site
 // NOTE: status code error: 429 429 Too Many Requests for goroutines
 // Max concurrent request: 5
-func (s *SuKeBe) Crawl(keyword string) map[string][]string {
+func (s *SuKeBe) Crawl(ctx context.Context, keyword string) []common.SearchResult {
 	s.initialize(keyword)
-	data := s.getData(s.SearchURL)
-	if data == nil {
-		return nil
-	}
-	return data
+	return s.getData(ctx, s.SearchURL)
 }
 
-// GetData method returns map(title, bbs url)
-func (s *SuKeBe) getData(url string) map[string][]string {
-	resp, ok := common.GetResponseFromURL(url)
+// GetData method returns the results listed on the search page at url
+func (s *SuKeBe) getData(ctx context.Context, url string) []common.SearchResult {
+	resp, ok := common.GetResponseFromURL(ctx, url)
 	if !ok {
 		return nil
 	}
 	defer resp.Body.Close()
-	doc, err := goquery.NewDocumentFromResponse(resp)
-	if err != nil {
-		return nil
+	if common.StreamParse && common.ListSelector(s.Name, "") == "" {
+		links, err := common.StreamLinks(resp.Body, ListLinks)
+		if err != nil {
+			return nil
+		}
+		go sfeed(links, common.SiteURL(s.Name), s.clients)
+	} else {
+		doc, err := goquery.NewDocumentFromResponse(resp)
+		if err != nil {
+			return nil
+		}
+		go screate(doc, common.SiteURL(s.Name), common.ListSelector(s.Name, "a[href*=view]:last-child"), s.clients)
 	}
-	go screate(doc, common.TorrentURL[s.Name])
-	s.makeWP(5)
-	m := make(map[string][]string, 0)
-	for d := range sdata {
-		// Category 0
-		// Time     1
-		// Uploader 2
-		// Seeder   3
-		// Info     4
-		// Leecher  5
-		// FileSize 6
-		// Snatch   7
-		// Magnet   8
-		uploader := d.info[2]
-		seeder := d.info[3]
-		leecher := d.info[5]
-		snatch := d.info[7]
-		fileSize := d.info[6]
+	s.makeWP(ctx, 5)
+	results := []common.SearchResult{}
+	for d := range s.data {
 		hash := d.info[8]
-		folder := d.info[9]
-		magnet := "magnet:?xt=urn:btih:" + hash
-		info := []string{
-			uploader, seeder, leecher,
-			snatch, fileSize, magnet, folder,
+		if len(hash) > 5 {
+			hash = hash[:5]
 		}
-		title := common.RemoveNonAscII(d.title) + " _ " + hash[:5]
-		m[title] = info
+		// info is empty when the detail page could not be fetched
+		title := common.RemoveNonAscII(d.title) + " _ " + hash
+		results = append(results, infoResult(title, d.link, s.Uploader, d.info))
 	}
-	s.ScrapedData = m
-	return m
+	s.ScrapedData = results
+	return results
 }
 
 // GetInfo method returns torrent info
-func (s *SuKeBe) GetInfo(url string) []string {
+func (s *SuKeBe) GetInfo(ctx context.Context, url string) []string {
 	info := make([]string, 10)
-	resp, ok := common.GetResponseFromURL(url)
+	resp, ok := common.GetResponseFromURL(ctx, url)
 	if !ok {
 		return info
 	}
@@ -130,22 +129,22 @@ func (s *SuKeBe) GetInfo(url string) []string {
 	return info
 }
 
-func (s *SuKeBe) worker(wg *sync.WaitGroup) {
-	for c := range sclients {
+func (s *SuKeBe) worker(ctx context.Context, wg *sync.WaitGroup) {
+	for c := range s.clients {
 		title := c.title
-		info := s.GetInfo(c.link)
-		sdata <- SData{title, info}
+		info := s.GetInfo(ctx, c.link)
+		s.data <- SData{title, c.link, info}
 	}
 	wg.Done()
 }
 
-func (s *SuKeBe) makeWP(num int) {
+func (s *SuKeBe) makeWP(ctx context.Context, num int) {
 	// Max concurrent should be "5"
 	var wg sync.WaitGroup
 	for i := 0; i < num; i++ {
 		wg.Add(1)
-		go s.worker(&wg)
+		go s.worker(ctx, &wg)
 	}
 	wg.Wait()
-	close(sdata)
+	close(s.data)
 }
@@ -0,0 +1,116 @@
+package jtorrent
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/daite/tspider/common"
+)
+
+// TokyoToshoCategories names the categories of Tokyo Toshokan by their
+// number, the cat parameter of its category links
+var TokyoToshoCategories = map[string]string{
+	"1":  "Anime",
+	"2":  "Music",
+	"3":  "Manga",
+	"4":  "Hentai",
+	"5":  "Other",
+	"7":  "Raws",
+	"8":  "Drama",
+	"9":  "Music Video",
+	"10": "Non-English",
+	"11": "Batch",
+	"12": "Hentai (Anime)",
+	"13": "Hentai (Manga)",
+	"14": "Hentai (Games)",
+	"15": "JAV",
+}
+
+var (
+	// toshoStats matches the peer counts of a Tokyo Toshokan result
+	toshoStats = regexp.MustCompile(`S:\s*(\d+)\s*L:\s*(\d+)\s*C:\s*(\d+)`)
+	// toshoCategory matches the number of a category link
+	toshoCategory = regexp.MustCompile(`[?&]cat=(\d+)`)
+)
+
+// TokyoTosho struct is for Tokyo Toshokan torrent web site
+type TokyoTosho struct {
+	Name        string
+	Keyword     string
+	SearchURL   string
+	ScrapedData []common.SearchResult
+}
+
+// initialize method set keyword and URL based on default url
+func (t *TokyoTosho) initialize(keyword string) {
+	t.Keyword = keyword
+	t.Name = "tokyotosho"
+	t.SearchURL = common.SiteURL(t.Name) + "/search.php?terms=" + url.QueryEscape(t.Keyword) + "&type=0"
+}
+
+// Crawl torrent data from web site. The search page lists the magnet of
+// each result, so no detail page is fetched.
+func (t *TokyoTosho) Crawl(ctx context.Context, keyword string) []common.SearchResult {
+	t.initialize(keyword)
+	resp, ok := common.GetResponseFromURL(ctx, t.SearchURL)
+	if !ok {
+		return nil
+	}
+	defer resp.Body.Close()
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return nil
+	}
+	t.ScrapedData = TokyoToshoResults(doc, t.SearchURL)
+	return t.ScrapedData
+}
+
+// TokyoToshoResults returns the results listed on a Tokyo Toshokan search
+// page fetched from pageURL. Each takes two rows of the listing: the first
+// holds the category, the magnet, the title and the peer counts, the second
+// the submitter, the size and the date.
+func TokyoToshoResults(doc *goquery.Document, pageURL string) []common.SearchResult {
+	var results []common.SearchResult
+	doc.Find(common.ListSelector("tokyotosho", "table.listing td.desc-top")).Each(func(i int, s *goquery.Selection) {
+		magnet := strings.TrimSpace(s.Find(`a[href^="magnet:"]`).AttrOr("href", ""))
+		title := strings.TrimSpace(s.Find(`a:not([href^="magnet:"])`).Last().Text())
+		if title == "" || magnet == "" {
+			return
+		}
+		row := s.Closest("tr")
+		r := common.SearchResult{Title: title, Magnet: magnet}
+		if m := toshoCategory.FindStringSubmatch(row.Find(`a[href*="cat="]`).AttrOr("href", "")); m != nil {
+			r.Category = TokyoToshoCategories[m[1]]
+		}
+		if href, ok := row.Find(`td.web a[href*="details.php"]`).Attr("href"); ok {
+			r.DetailURL, _ = common.URLJoin(pageURL, href)
+		}
+		if m := toshoStats.FindStringSubmatch(row.Find("td.stats").Text()); m != nil {
+			r.Seeders, _ = strconv.Atoi(m[1])
+			r.Leechers, _ = strconv.Atoi(m[2])
+			r.Snatches, _ = strconv.Atoi(m[3])
+		}
+		for _, field := range strings.Split(row.Next().Find("td.desc-bot").Text(), "|") {
+			name, value, ok := strings.Cut(field, ":")
+			if !ok {
+				continue
+			}
+			value = strings.TrimSpace(value)
+			switch strings.TrimSpace(name) {
+			case "Submitter":
+				r.Uploader = value
+			case "Size":
+				r.Size = value
+			case "Date":
+				r.Date, _ = time.Parse("2006-01-02 15:04 MST", value)
+			}
+		}
+		results = append(results, r)
+	})
+	return results
+}
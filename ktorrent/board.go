@@ -0,0 +1,226 @@
+package ktorrent
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/daite/tspider/common"
+)
+
+// BoardLayout describes where the results are on the pages of a board: a
+// search page listing them as links to detail pages, each of which shows a
+// magnet link
+type BoardLayout struct {
+	// List selects the result links on a search page
+	List string
+	// TitleAttr is the attribute of a result link holding its title; the
+	// link text is the title when it is empty
+	TitleAttr string
+	// Row selects the ancestor of a result link holding the rest of the
+	// result, where its size and post time are looked for
+	Row string
+	// Magnet selects the magnet link on a detail page, or an element inside
+	// it or next to it, such as the magnet icon
+	Magnet string
+}
+
+var (
+	// Gnuboard is the layout of the many Korean sites built on Gnuboard:
+	// each result is a link in a div.media-heading, next to the time it was
+	// posted, and the magnet is a link in a ul.list-group after a magnet icon
+	Gnuboard = BoardLayout{
+		List:   "div.media-heading a",
+		Row:    "div.media-body",
+		Magnet: "ul.list-group i.fa-magnet",
+	}
+	// TopicBoard is the layout of torrenttop and its clones: each result is
+	// a row of class topic-item, with the title in the title attribute of
+	// its link and the size in a cell of its own, and the magnet is a link
+	// around a magnet icon
+	TopicBoard = BoardLayout{
+		List:      ".topic-item a[title]",
+		TitleAttr: "title",
+		Row:       ".topic-item",
+		Magnet:    "i.fa-magnet",
+	}
+)
+
+// sizeCell matches the text of a cell showing a size, such as "2.33 GB" or
+// "3,467.1M"
+var sizeCell = regexp.MustCompile(`(?i)^[0-9][0-9,]*(?:\.[0-9]+)?\s*[KMGT]i?B?$`)
+
+// crawl searches the board site laid out as l: it fetches the search page
+// at searchURL, then the magnet of each result on the detail pages, through
+// the shared detail pool
+func (l BoardLayout) crawl(ctx context.Context, site, searchURL string) *sync.Map {
+	resp, ok := common.GetResponseFromURL(ctx, searchURL)
+	if !ok {
+		return nil
+	}
+	defer resp.Body.Close()
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return nil
+	}
+	details := common.NewFetchGroup()
+	m := &sync.Map{}
+	for _, r := range l.Results(doc, site, searchURL) {
+		r := r
+		details.Go(func() {
+			r.Magnet = l.magnetAt(ctx, site, r.DetailURL)
+			m.Store(r.Title, r)
+		})
+	}
+	details.Wait()
+	return m
+}
+
+// Results returns the results listed on the search page of the board site,
+// fetched from pageURL, without their magnets: the title, the detail page
+// resolved against pageURL and, when the page shows them, the size and the
+// time of the post. The list_selector of site overrides List.
+func (l BoardLayout) Results(doc *goquery.Document, site, pageURL string) []common.SearchResult {
+	var results []common.SearchResult
+	doc.Find(common.ListSelector(site, l.List)).Each(func(i int, s *goquery.Selection) {
+		title := s.Text()
+		if l.TitleAttr != "" {
+			title = s.AttrOr(l.TitleAttr, "")
+		}
+		title = strings.Join(strings.Fields(title), " ")
+		href, ok := s.Attr("href")
+		if title == "" || !ok {
+			return
+		}
+		detail, err := common.URLJoin(pageURL, href)
+		if err != nil {
+			return
+		}
+		r := common.SearchResult{Title: title, DetailURL: strings.TrimSpace(detail)}
+		if l.Row != "" {
+			row := s.Closest(l.Row)
+			if posted, ok := row.Find("time[datetime]").Attr("datetime"); ok {
+				r.Date, _ = time.Parse(time.RFC3339, posted)
+			}
+			row.Find("div, span").EachWithBreak(func(i int, cell *goquery.Selection) bool {
+				if text := strings.TrimSpace(cell.Text()); sizeCell.MatchString(text) {
+					r.Size = text
+				}
+				return r.Size == ""
+			})
+		}
+		results = append(results, r)
+	})
+	return results
+}
+
+// magnetAt fetches the detail page at url of the board site and returns its
+// magnet, or why there is none
+func (l BoardLayout) magnetAt(ctx context.Context, site, url string) string {
+	resp, ok := common.GetResponseFromURL(ctx, url)
+	if !ok {
+		return "failed to fetch magnet"
+	}
+	defer resp.Body.Close()
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return fmt.Sprintf("parse error: %v", err)
+	}
+	if magnet := l.MagnetOf(doc, site); magnet != "" {
+		return magnet
+	}
+	return "no magnet"
+}
+
+// MagnetOf returns the magnet on a detail page of the board site: the first
+// magnet link that the elements Magnet selects are, are inside of, or sit
+// next to; "" if there is none. The magnet_selector of site overrides
+// Magnet.
+func (l BoardLayout) MagnetOf(doc *goquery.Document, site string) string {
+	magnet := ""
+	isMagnet := func(i int, a *goquery.Selection) bool {
+		if href, ok := a.Attr("href"); ok && strings.HasPrefix(strings.TrimSpace(href), "magnet:?") {
+			magnet = strings.TrimSpace(href)
+		}
+		return magnet == ""
+	}
+	doc.Find(common.MagnetSelector(site, l.Magnet)).EachWithBreak(func(i int, s *goquery.Selection) bool {
+		s.Closest("a").EachWithBreak(isMagnet)
+		if magnet == "" {
+			s.Parent().Find("a").EachWithBreak(isMagnet)
+		}
+		return magnet == ""
+	})
+	return magnet
+}
+
+// BoardLayouts are the layouts a board site of the config can name
+var BoardLayouts = map[string]BoardLayout{
+	common.BoardLayoutGnuboard: Gnuboard,
+	common.BoardLayoutTopic:    TopicBoard,
+}
+
+// BoardScraper searches a board laid out as Layout, so that a mirror or a
+// clone of a known board is added with its selectors instead of a scraper
+// of its own
+type BoardScraper struct {
+	Name   string
+	Layout BoardLayout
+	// SearchPath is the path of the search page, relative to the URL of the
+	// site, with common.KeywordPlaceholder where the keyword goes
+	SearchPath  string
+	Keyword     string
+	SearchURL   string
+	ScrapedData *sync.Map
+}
+
+// NewBoardScraper returns the scraper of the board site name configured as
+// site: the selectors of its layout, if any, with its title_attr in place
+// of theirs. Its list_selector and magnet_selector are applied when it is
+// searched.
+func NewBoardScraper(name string, site common.SiteConfig) (*BoardScraper, error) {
+	var layout BoardLayout
+	if site.Layout != "" {
+		l, ok := BoardLayouts[site.Layout]
+		if !ok {
+			return nil, fmt.Errorf("site '%s': unknown layout '%s', want one of %v", name, site.Layout, common.BoardLayoutNames)
+		}
+		layout = l
+	} else if site.ListSelector == "" || site.MagnetSelector == "" {
+		return nil, fmt.Errorf("site '%s': a board without a layout needs a list_selector and a magnet_selector", name)
+	}
+	if !strings.Contains(site.SearchPath, common.KeywordPlaceholder) {
+		return nil, fmt.Errorf("site '%s': search_path '%s' does not contain %s", name, site.SearchPath, common.KeywordPlaceholder)
+	}
+	if site.TitleAttr != "" {
+		layout.TitleAttr = site.TitleAttr
+	}
+	return &BoardScraper{Name: name, Layout: layout, SearchPath: site.SearchPath}, nil
+}
+
+// initialize method set keyword and URL based on default url
+func (b *BoardScraper) initialize(keyword string) {
+	b.Keyword = keyword
+	b.SearchURL = common.SiteURL(b.Name) + strings.ReplaceAll(b.SearchPath, common.KeywordPlaceholder, url.QueryEscape(keyword))
+}
+
+// Crawl torrent data from web site
+func (b *BoardScraper) Crawl(ctx context.Context, keyword string) []common.SearchResult {
+	b.initialize(keyword)
+	data := b.Layout.crawl(ctx, b.Name, b.SearchURL)
+	if data == nil {
+		return nil
+	}
+	b.ScrapedData = data
+	return common.ResultsFromMap(data)
+}
+
+// GetMagnet method returns torrent magnet
+func (b *BoardScraper) GetMagnet(ctx context.Context, url string) string {
+	return b.Layout.magnetAt(ctx, b.Name, url)
+}
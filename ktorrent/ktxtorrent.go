@@ -1,7 +1,7 @@
 package ktorrent
 
 import (
-	"fmt"
+	"context"
 	"net/url"
 	"strings"
 	"sync"
@@ -22,30 +22,24 @@ type KTXTorrent struct {
 func (t *KTXTorrent) initialize(keyword string) {
 	t.Keyword = keyword
 	t.Name = "ktxtorrent"
-	t.SearchURL = common.TorrentURL[t.Name] + "/bbs/search.php?&stx=" + url.QueryEscape(t.Keyword)
+	t.SearchURL = common.SiteURL(t.Name) + "/bbs/search.php?&stx=" + url.QueryEscape(t.Keyword)
 }
 
 // Crawl torrent data from web site
-func (t *KTXTorrent) Crawl(keyword string) map[string]string {
+func (t *KTXTorrent) Crawl(ctx context.Context, keyword string) []common.SearchResult {
 	t.initialize(keyword)
-	data := t.getData(t.SearchURL)
+	data := t.getData(ctx, t.SearchURL)
 	if data == nil {
 		return nil
 	}
-	m := map[string]string{}
-	data.Range(
-		func(key, value interface{}) bool {
-			m[fmt.Sprint(key)] = fmt.Sprint(value)
-			return true
-		})
-	return m
+	return common.ResultsFromMap(data)
 }
 
-// GetData method returns map(title, bbs url)
-func (t *KTXTorrent) getData(url string) *sync.Map {
-	var wg sync.WaitGroup
+// GetData method returns the results by title
+func (t *KTXTorrent) getData(ctx context.Context, url string) *sync.Map {
+	details := common.NewFetchGroup()
 	m := &sync.Map{}
-	resp, ok := common.GetResponseFromURL(url)
+	resp, ok := common.GetResponseFromURL(ctx, url)
 	if !ok {
 		return nil
 	}
@@ -55,24 +49,26 @@ func (t *KTXTorrent) getData(url string) *sync.Map {
 		return nil
 	}
 	doc.Find("div.media-heading a").Each(func(i int, s *goquery.Selection) {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
+		details.Go(func() {
 			title := strings.TrimSpace(s.Text())
 			link, _ := s.Attr("href")
-			link = strings.TrimSpace(common.URLJoin(common.TorrentURL[t.Name], link))
-			magnet := t.GetMagnet(link)
-			m.Store(title, magnet)
-		}()
+			link, err := common.URLJoin(common.SiteURL(t.Name)+"/bbs/", link)
+			if err != nil {
+				return
+			}
+			link = strings.TrimSpace(link)
+			magnet := t.GetMagnet(ctx, link)
+			m.Store(title, common.SearchResult{Title: title, Magnet: magnet, DetailURL: link})
+		})
 	})
-	wg.Wait()
+	details.Wait()
 	t.ScrapedData = m
 	return m
 }
 
 // GetMagnet method returns torrent magnet
-func (t *KTXTorrent) GetMagnet(url string) string {
-	resp, ok := common.GetResponseFromURL(url)
+func (t *KTXTorrent) GetMagnet(ctx context.Context, url string) string {
+	resp, ok := common.GetResponseFromURL(ctx, url)
 	if !ok {
 		return "failed to fetch magnet"
 	}
@@ -1,7 +1,7 @@
 package ktorrent
 
 import (
-	"fmt"
+	"context"
 	"net/url"
 	"strings"
 	"sync"
@@ -22,27 +22,24 @@ type TorrentGram struct {
 func (t *TorrentGram) initialize(keyword string) {
 	t.Keyword = keyword
 	t.Name = "torrentgram"
-	t.SearchURL = common.TorrentURL[t.Name] + "/bbs/search.php?&stx=" + url.QueryEscape(t.Keyword)
+	t.SearchURL = common.SiteURL(t.Name) + "/bbs/search.php?&stx=" + url.QueryEscape(t.Keyword)
 }
 
 // Crawl torrent data from web site
-func (t *TorrentGram) Crawl(keyword string) map[string]string {
+func (t *TorrentGram) Crawl(ctx context.Context, keyword string) []common.SearchResult {
 	t.initialize(keyword)
-	data := t.getData(t.SearchURL)
-	m := map[string]string{}
-	data.Range(
-		func(key, value interface{}) bool {
-			m[fmt.Sprint(key)] = fmt.Sprint(value)
-			return true
-		})
-	return m
+	data := t.getData(ctx, t.SearchURL)
+	if data == nil {
+		return nil
+	}
+	return common.ResultsFromMap(data)
 }
 
-// GetData method returns map(title, bbs url)
-func (t *TorrentGram) getData(url string) *sync.Map {
-	var wg sync.WaitGroup
+// GetData method returns the results by title
+func (t *TorrentGram) getData(ctx context.Context, url string) *sync.Map {
+	details := common.NewFetchGroup()
 	m := &sync.Map{}
-	resp, ok := common.GetResponseFromURL(url)
+	resp, ok := common.GetResponseFromURL(ctx, url)
 	if !ok {
 		return nil
 	}
@@ -52,24 +49,26 @@ func (t *TorrentGram) getData(url string) *sync.Map {
 		return nil
 	}
 	doc.Find("div.media-heading a").Each(func(i int, s *goquery.Selection) {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
+		details.Go(func() {
 			title := strings.TrimSpace(s.Text())
 			link, _ := s.Attr("href")
-			link = strings.TrimSpace(common.URLJoin(common.TorrentURL[t.Name], link))
-			magnet := t.GetMagnet(link)
-			m.Store(title, magnet)
-		}()
+			link, err := common.URLJoin(common.SiteURL(t.Name)+"/bbs/", link)
+			if err != nil {
+				return
+			}
+			link = strings.TrimSpace(link)
+			magnet := t.GetMagnet(ctx, link)
+			m.Store(title, common.SearchResult{Title: title, Magnet: magnet, DetailURL: link})
+		})
 	})
-	wg.Wait()
+	details.Wait()
 	t.ScrapedData = m
 	return m
 }
 
 // GetMagnet method returns torrent magnet
-func (t *TorrentGram) GetMagnet(url string) string {
-	resp, ok := common.GetResponseFromURL(url)
+func (t *TorrentGram) GetMagnet(ctx context.Context, url string) string {
+	resp, ok := common.GetResponseFromURL(ctx, url)
 	if !ok {
 		return "failed to fetch magnet"
 	}
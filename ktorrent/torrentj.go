@@ -1,7 +1,7 @@
 package ktorrent
 
 import (
-	"fmt"
+	"context"
 	"log"
 	"net/url"
 	"strings"
@@ -23,27 +23,24 @@ type TorrentJ struct {
 func (t *TorrentJ) initialize(keyword string) {
 	t.Keyword = keyword
 	t.Name = "torrentj"
-	t.SearchURL = common.TorrentURL[t.Name] + "/bbs/search.php?&stx=" + url.QueryEscape(t.Keyword)
+	t.SearchURL = common.SiteURL(t.Name) + "/bbs/search.php?&stx=" + url.QueryEscape(t.Keyword)
 }
 
 // Crawl torrent data from web site
-func (t *TorrentJ) Crawl(keyword string) map[string]string {
+func (t *TorrentJ) Crawl(ctx context.Context, keyword string) []common.SearchResult {
 	t.initialize(keyword)
-	data := t.getData(t.SearchURL)
-	m := map[string]string{}
-	data.Range(
-		func(key, value interface{}) bool {
-			m[fmt.Sprint(key)] = fmt.Sprint(value)
-			return true
-		})
-	return m
+	data := t.getData(ctx, t.SearchURL)
+	if data == nil {
+		return nil
+	}
+	return common.ResultsFromMap(data)
 }
 
-// GetData method returns map(title, bbs url)
-func (t *TorrentJ) getData(url string) *sync.Map {
-	var wg sync.WaitGroup
+// GetData method returns the results by title
+func (t *TorrentJ) getData(ctx context.Context, url string) *sync.Map {
+	details := common.NewFetchGroup()
 	m := &sync.Map{}
-	resp, ok := common.GetResponseFromURL(url)
+	resp, ok := common.GetResponseFromURL(ctx, url)
 	if !ok {
 		return nil
 	}
@@ -53,24 +50,26 @@ func (t *TorrentJ) getData(url string) *sync.Map {
 		log.Fatalln(err)
 	}
 	doc.Find("div.media-heading a").Each(func(i int, s *goquery.Selection) {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
+		details.Go(func() {
 			title := strings.TrimSpace(s.Text())
 			link, _ := s.Attr("href")
-			link = strings.TrimSpace(common.URLJoin(common.TorrentURL[t.Name], link))
-			magnet := t.GetMagnet(link)
-			m.Store(title, magnet)
-		}()
+			link, err := common.URLJoin(common.SiteURL(t.Name)+"/bbs/", link)
+			if err != nil {
+				return
+			}
+			link = strings.TrimSpace(link)
+			magnet := t.GetMagnet(ctx, link)
+			m.Store(title, common.SearchResult{Title: title, Magnet: magnet, DetailURL: link})
+		})
 	})
-	wg.Wait()
+	details.Wait()
 	t.ScrapedData = m
 	return m
 }
 
 // GetMagnet method returns torrent magnet
-func (t *TorrentJ) GetMagnet(url string) string {
-	resp, ok := common.GetResponseFromURL(url)
+func (t *TorrentJ) GetMagnet(ctx context.Context, url string) string {
+	resp, ok := common.GetResponseFromURL(ctx, url)
 	if !ok {
 		return "failed to fetch magnet"
 	}
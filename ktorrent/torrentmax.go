@@ -1,16 +1,15 @@
 package ktorrent
 
 import (
-	"fmt"
+	"context"
 	"net/url"
-	"strings"
 	"sync"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/daite/tspider/common"
 )
 
-// TorrentMax struct is for TorrentMax torrent web site
+// TorrentMax struct is for TorrentMax torrent web site, a Gnuboard board
 type TorrentMax struct {
 	Name        string
 	Keyword     string
@@ -22,65 +21,43 @@ type TorrentMax struct {
 func (t *TorrentMax) initialize(keyword string) {
 	t.Keyword = keyword
 	t.Name = "torrentmax"
-	t.SearchURL = common.TorrentURL[t.Name] + "/bbs/search.php?&stx=" + url.QueryEscape(t.Keyword)
+	t.SearchURL = common.SiteURL(t.Name) + "/search?stx=" + url.QueryEscape(t.Keyword)
 }
 
 // Crawl torrent data from web site
-func (t *TorrentMax) Crawl(keyword string) map[string]string {
+func (t *TorrentMax) Crawl(ctx context.Context, keyword string) []common.SearchResult {
 	t.initialize(keyword)
-	data := t.getData(t.SearchURL)
-	m := map[string]string{}
-	data.Range(
-		func(key, value interface{}) bool {
-			m[fmt.Sprint(key)] = fmt.Sprint(value)
-			return true
-		})
-	return m
-}
-
-// GetData method returns map(title, bbs url)
-func (t *TorrentMax) getData(url string) *sync.Map {
-	var wg sync.WaitGroup
-	m := &sync.Map{}
-	resp, ok := common.GetResponseFromURL(url)
-	if !ok {
-		return nil
-	}
-	defer resp.Body.Close()
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
+	data := t.getData(ctx, t.SearchURL)
+	if data == nil {
 		return nil
 	}
-	doc.Find("div.media-heading a").Each(func(i int, s *goquery.Selection) {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			title := strings.TrimSpace(s.Text())
-			link, _ := s.Attr("href")
-			link = strings.TrimSpace(common.URLJoin(common.TorrentURL[t.Name], link))
-			magnet := t.GetMagnet(link)
-			m.Store(title, magnet)
-		}()
-	})
-	wg.Wait()
+	return common.ResultsFromMap(data)
+}
+
+// GetData method returns the results by title
+func (t *TorrentMax) getData(ctx context.Context, url string) *sync.Map {
+	m := Gnuboard.crawl(ctx, t.Name, url)
 	t.ScrapedData = m
 	return m
 }
 
-// GetMagnet method returns torrent magnet
-func (t *TorrentMax) GetMagnet(url string) string {
-	resp, ok := common.GetResponseFromURL(url)
-	if !ok {
-		return "failed to fetch magnet"
+// TorrentMaxLinks returns the result links of a TorrentMax search page
+// fetched from pageURL, with their targets resolved against it
+func TorrentMaxLinks(doc *goquery.Document, pageURL string) []common.Link {
+	var links []common.Link
+	for _, r := range Gnuboard.Results(doc, "torrentmax", pageURL) {
+		links = append(links, common.Link{Title: r.Title, Href: r.DetailURL})
 	}
-	defer resp.Body.Close()
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
-		return err.Error()
-	}
-	magnet := strings.TrimSpace(doc.Find("ul.list-group").Text())
-	if magnet == "" {
-		return "no magnet"
-	}
-	return magnet
+	return links
+}
+
+// GetMagnet method returns torrent magnet
+func (t *TorrentMax) GetMagnet(ctx context.Context, url string) string {
+	return Gnuboard.magnetAt(ctx, "torrentmax", url)
+}
+
+// TorrentMaxMagnet returns the magnet on a TorrentMax detail page, the link
+// next to the magnet icon of the file list, or "" if there is none
+func TorrentMaxMagnet(doc *goquery.Document) string {
+	return Gnuboard.MagnetOf(doc, "torrentmax")
 }
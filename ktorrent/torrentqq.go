@@ -1,9 +1,11 @@
 package ktorrent
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"regexp"
+	"strings"
 	"sync"
 
 	"github.com/PuerkitoBio/goquery"
@@ -18,34 +20,32 @@ type TorrentQQ struct {
 	ScrapedData *sync.Map
 }
 
+// infoHash matches the info hash a TorrentQQ detail page shows in place of
+// a magnet link. The text of the next row follows it without a space.
+var infoHash = regexp.MustCompile(`(?:^|[^0-9a-fA-F])([0-9a-fA-F]{40})(?:[^0-9a-fA-F]|$)`)
+
 // initialize method set keyword and URL based on default url
 func (t *TorrentQQ) initialize(keyword string) {
 	t.Keyword = keyword
 	t.Name = "torrentqq"
-	t.SearchURL = common.TorrentURL[t.Name] + "/search?q=" + url.QueryEscape(t.Keyword)
+	t.SearchURL = common.SiteURL(t.Name) + "/search?q=" + url.QueryEscape(t.Keyword)
 }
 
 // Crawl torrent data from web site
-func (t *TorrentQQ) Crawl(keyword string) map[string]string {
+func (t *TorrentQQ) Crawl(ctx context.Context, keyword string) []common.SearchResult {
 	t.initialize(keyword)
-	data := t.getData(t.SearchURL)
+	data := t.getData(ctx, t.SearchURL)
 	if data == nil {
 		return nil
 	}
-	m := map[string]string{}
-	data.Range(
-		func(key, value interface{}) bool {
-			m[fmt.Sprint(key)] = fmt.Sprint(value)
-			return true
-		})
-	return m
+	return common.ResultsFromMap(data)
 }
 
-// GetData method returns map(title, bbs url)
-func (t *TorrentQQ) getData(url string) *sync.Map {
-	var wg sync.WaitGroup
+// GetData method returns the results by title
+func (t *TorrentQQ) getData(ctx context.Context, url string) *sync.Map {
+	details := common.NewFetchGroup()
 	m := &sync.Map{}
-	resp, ok := common.GetResponseFromURL(url)
+	resp, ok := common.GetResponseFromURL(ctx, url)
 	if !ok {
 		return nil
 	}
@@ -54,41 +54,68 @@ func (t *TorrentQQ) getData(url string) *sync.Map {
 	if err != nil {
 		return nil
 	}
-	doc.Find("a.subject").Each(func(i int, s *goquery.Selection) {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			title := s.Text()
-			link, _ := s.Attr("href")
-			magnet := t.GetMagnet(link)
-			m.Store(title, magnet)
-		}()
-	})
-	wg.Wait()
+	for _, r := range TorrentQQResults(doc, url) {
+		r := r
+		details.Go(func() {
+			r.Magnet = t.GetMagnet(ctx, r.DetailURL)
+			m.Store(r.Title, r)
+		})
+	}
+	details.Wait()
 	t.ScrapedData = m
 	return m
 }
 
+// TorrentQQResults returns the results listed on a TorrentQQ search page
+// fetched from pageURL, without their magnets: the title, the detail page
+// resolved against pageURL and the size
+func TorrentQQResults(doc *goquery.Document, pageURL string) []common.SearchResult {
+	var results []common.SearchResult
+	doc.Find(common.ListSelector("torrentqq", "a.subject")).Each(func(i int, s *goquery.Selection) {
+		title := strings.TrimSpace(s.Text())
+		href, ok := s.Attr("href")
+		if title == "" || !ok {
+			return
+		}
+		detail, err := common.URLJoin(pageURL, href)
+		if err != nil {
+			return
+		}
+		results = append(results, common.SearchResult{
+			Title:     title,
+			DetailURL: strings.TrimSpace(detail),
+			Size:      strings.TrimSpace(s.Closest("li.list-item").Find("div.wr-size").Text()),
+		})
+	})
+	return results
+}
+
 // GetMagnet method returns torrent magnet
-func (t *TorrentQQ) GetMagnet(url string) string {
-	resp, ok := common.GetResponseFromURL(url)
+func (t *TorrentQQ) GetMagnet(ctx context.Context, url string) string {
+	resp, ok := common.GetResponseFromURL(ctx, url)
 	if !ok {
 		return "failed to fetch magnet"
 	}
 	defer resp.Body.Close()
 	doc, err := goquery.NewDocumentFromReader(resp.Body)
 	if err != nil {
-		return err.Error()
+		return fmt.Sprintf("parse error: %v", err)
 	}
-	re := regexp.MustCompile("[0-9,a-z]{40}")
-	magnet := "no magnet"
-	doc.Find("tr > td").Each(func(i int, s *goquery.Selection) {
-		if i == 0 {
-			text := re.FindString(s.Text())
-			if len(text) == 40 {
-				magnet = "magnet:?xt=urn:btih:" + text
-			}
+	if magnet := TorrentQQMagnet(doc); magnet != "" {
+		return magnet
+	}
+	return "no magnet"
+}
+
+// TorrentQQMagnet returns the magnet of a TorrentQQ detail page, built from
+// the info hash in its file table, or "" if there is none
+func TorrentQQMagnet(doc *goquery.Document) string {
+	magnet := ""
+	doc.Find(common.MagnetSelector("torrentqq", "table.table-bordered td")).EachWithBreak(func(i int, s *goquery.Selection) bool {
+		if m := infoHash.FindStringSubmatch(s.Text()); m != nil {
+			magnet = "magnet:?xt=urn:btih:" + strings.ToLower(m[1])
 		}
+		return magnet == ""
 	})
 	return magnet
 }
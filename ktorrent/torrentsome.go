@@ -1,16 +1,14 @@
 package ktorrent
 
 import (
-	"fmt"
+	"context"
 	"net/url"
-	"strings"
 	"sync"
 
-	"github.com/PuerkitoBio/goquery"
 	"github.com/daite/tspider/common"
 )
 
-// TorrentSome struct is for TorrentSome web site
+// TorrentSome struct is for TorrentSome web site, a clone of TorrentTop
 type TorrentSome struct {
 	Name        string
 	Keyword     string
@@ -22,67 +20,21 @@ type TorrentSome struct {
 func (t *TorrentSome) initialize(keyword string) {
 	t.Keyword = keyword
 	t.Name = "torrentsome"
-	t.SearchURL = common.TorrentURL[t.Name] + "/search/index?keywords=" + url.QueryEscape(t.Keyword)
+	t.SearchURL = common.SiteURL(t.Name) + "/search/index?keywords=" + url.QueryEscape(t.Keyword)
 }
 
 // Crawl torrent data from web site
-func (t *TorrentSome) Crawl(keyword string) map[string]string {
+func (t *TorrentSome) Crawl(ctx context.Context, keyword string) []common.SearchResult {
 	t.initialize(keyword)
-	data := t.getData(t.SearchURL)
-	m := map[string]string{}
-	data.Range(
-		func(key, value interface{}) bool {
-			m[fmt.Sprint(key)] = fmt.Sprint(value)
-			return true
-		})
-	return m
-}
-
-// GetData method returns map(title, bbs url)
-func (t *TorrentSome) getData(url string) *sync.Map {
-	var wg sync.WaitGroup
-	m := &sync.Map{}
-	resp, ok := common.GetResponseFromURL(url)
-	if !ok {
-		return nil
-	}
-	defer resp.Body.Close()
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
+	data := TopicBoard.crawl(ctx, t.Name, t.SearchURL)
+	if data == nil {
 		return nil
 	}
-	doc.Find("div.flex-auto a").Each(func(i int, s *goquery.Selection) {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			title, _ := s.Attr("title")
-			title = strings.TrimSpace(title)
-			link, _ := s.Attr("href")
-			link = strings.TrimSpace(common.TorrentURL[t.Name] + link)
-			magnet := t.GetMagnet(link)
-			m.Store(title, magnet)
-		}()
-	})
-	wg.Wait()
-	t.ScrapedData = m
-	return m
+	t.ScrapedData = data
+	return common.ResultsFromMap(data)
 }
 
 // GetMagnet method returns torrent magnet
-func (t *TorrentSome) GetMagnet(url string) string {
-	resp, ok := common.GetResponseFromURL(url)
-	if !ok {
-		return "failed to fetch magnet"
-	}
-	defer resp.Body.Close()
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
-		return err.Error()
-	}
-	magnet, _ := doc.Find("a.ml-3").Attr("href")
-	magnet = strings.TrimSpace(magnet)
-	if magnet == "" {
-		return "no magnet"
-	}
-	return magnet
+func (t *TorrentSome) GetMagnet(ctx context.Context, url string) string {
+	return TopicBoard.magnetAt(ctx, "torrentsome", url)
 }
@@ -1,6 +1,7 @@
 package ktorrent
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"strings"
@@ -22,65 +23,80 @@ type TorrentTop struct {
 func (t *TorrentTop) initialize(keyword string) {
 	t.Keyword = keyword
 	t.Name = "torrenttop"
-	t.SearchURL = common.TorrentURL[t.Name] + "/search/index?keywords=" + url.QueryEscape(t.Keyword)
+	t.SearchURL = common.SiteURL(t.Name) + "/search/index?keywords=" + url.QueryEscape(t.Keyword)
 }
 
 // Crawl torrent data from web site
-func (t *TorrentTop) Crawl(keyword string) map[string]string {
+func (t *TorrentTop) Crawl(ctx context.Context, keyword string) []common.SearchResult {
 	t.initialize(keyword)
-	data := t.getData(t.SearchURL)
+	data := t.getData(ctx, t.SearchURL)
 	if data == nil {
 		return nil
 	}
-	m := map[string]string{}
-	data.Range(
-		func(key, value interface{}) bool {
-			m[fmt.Sprint(key)] = fmt.Sprint(value)
-			return true
-		})
-	return m
+	return common.ResultsFromMap(data)
+}
+
+// TorrentTopLinks matches the result links of a TorrentTop search page for
+// common.StreamLinks, like the default list selector
+var TorrentTopLinks = common.LinkRule{
+	Within: "topic-item",
+	Href:   func(href string) bool { return href != "" },
 }
 
-// GetData method returns map(title, bbs url)
-func (t *TorrentTop) getData(url string) *sync.Map {
-	var wg sync.WaitGroup
+// GetData method returns the results by title
+func (t *TorrentTop) getData(ctx context.Context, url string) *sync.Map {
+	details := common.NewFetchGroup()
 	m := &sync.Map{}
 
-	resp, ok := common.GetResponseFromURL(url)
+	resp, ok := common.GetResponseFromURL(ctx, url)
 	if !ok {
 		return nil
 	}
 	defer resp.Body.Close()
 
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
-		return nil
+	fetch := func(title, href string) {
+		details.Go(func() {
+			fullURL, err := common.URLJoin(common.SiteURL(t.Name), href)
+			if err != nil {
+				return
+			}
+			fullURL = strings.TrimSpace(fullURL)
+			title := strings.TrimSpace(title)
+			m.Store(title, common.SearchResult{Title: title, Magnet: t.GetMagnet(ctx, fullURL), DetailURL: fullURL})
+		})
 	}
 
-	doc.Find(".topic-item a").Each(func(i int, s *goquery.Selection) {
-		title, exists := s.Attr("title")
-		href, linkOk := s.Attr("href")
-		if !exists || !linkOk {
-			return
+	if common.StreamParse && common.ListSelector(t.Name, "") == "" {
+		links, err := common.StreamLinks(resp.Body, TorrentTopLinks)
+		if err != nil {
+			return nil
 		}
+		for _, l := range links {
+			fetch(l.Title, l.Href)
+		}
+	} else {
+		doc, err := goquery.NewDocumentFromReader(resp.Body)
+		if err != nil {
+			return nil
+		}
+		doc.Find(common.ListSelector(t.Name, ".topic-item a")).Each(func(i int, s *goquery.Selection) {
+			title, exists := s.Attr("title")
+			href, linkOk := s.Attr("href")
+			if !exists || !linkOk {
+				return
+			}
+			fetch(title, href)
+		})
+	}
 
-		wg.Add(1)
-		go func(title, href string) {
-			defer wg.Done()
-			fullURL := strings.TrimSpace(common.URLJoin(common.TorrentURL[t.Name], href))
-			magnet := t.GetMagnet(fullURL)
-			m.Store(strings.TrimSpace(title), magnet)
-		}(title, href)
-	})
-
-	wg.Wait()
+	details.Wait()
 	t.ScrapedData = m
 	return m
 }
 
 // GetMagnet method returns torrent magnet
-func (t *TorrentTop) GetMagnet(url string) string {
-	resp, ok := common.GetResponseFromURL(url)
+func (t *TorrentTop) GetMagnet(ctx context.Context, url string) string {
+	resp, ok := common.GetResponseFromURL(ctx, url)
 	if !ok {
 		return "failed to fetch magnet"
 	}
@@ -92,7 +108,7 @@ func (t *TorrentTop) GetMagnet(url string) string {
 	}
 
 	magnet := ""
-	doc.Find("i.fas.fa-magnet").Each(func(i int, s *goquery.Selection) {
+	doc.Find(common.MagnetSelector(t.Name, "i.fas.fa-magnet")).Each(func(i int, s *goquery.Selection) {
 		parent := s.Parent()
 		parent.Find("a").EachWithBreak(func(i int, a *goquery.Selection) bool {
 			href, exists := a.Attr("href")
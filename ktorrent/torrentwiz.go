@@ -1,16 +1,14 @@
 package ktorrent
 
 import (
-	"fmt"
+	"context"
 	"net/url"
-	"strings"
 	"sync"
 
-	"github.com/PuerkitoBio/goquery"
 	"github.com/daite/tspider/common"
 )
 
-// TorrentWiz struct is for TorrentWiz web site
+// TorrentWiz struct is for TorrentWiz web site, a Gnuboard board
 type TorrentWiz struct {
 	Name        string
 	Keyword     string
@@ -22,65 +20,21 @@ type TorrentWiz struct {
 func (t *TorrentWiz) initialize(keyword string) {
 	t.Keyword = keyword
 	t.Name = "torrentwiz"
-	t.SearchURL = common.TorrentURL[t.Name] + "/bbs/search.php?&stx=" + url.QueryEscape(t.Keyword)
+	t.SearchURL = common.SiteURL(t.Name) + "/bbs/search.php?&stx=" + url.QueryEscape(t.Keyword)
 }
 
 // Crawl torrent data from web site
-func (t *TorrentWiz) Crawl(keyword string) map[string]string {
+func (t *TorrentWiz) Crawl(ctx context.Context, keyword string) []common.SearchResult {
 	t.initialize(keyword)
-	data := t.getData(t.SearchURL)
-	m := map[string]string{}
-	data.Range(
-		func(key, value interface{}) bool {
-			m[fmt.Sprint(key)] = fmt.Sprint(value)
-			return true
-		})
-	return m
-}
-
-// GetData method returns map(title, bbs url)
-func (t *TorrentWiz) getData(url string) *sync.Map {
-	var wg sync.WaitGroup
-	m := &sync.Map{}
-	resp, ok := common.GetResponseFromURL(url)
-	if !ok {
-		return nil
-	}
-	defer resp.Body.Close()
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
+	data := Gnuboard.crawl(ctx, t.Name, t.SearchURL)
+	if data == nil {
 		return nil
 	}
-	doc.Find("div.media-heading a").Each(func(i int, s *goquery.Selection) {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			title := strings.TrimSpace(s.Text())
-			link, _ := s.Attr("href")
-			link = strings.TrimSpace(common.URLJoin(common.TorrentURL[t.Name], link))
-			magnet := t.GetMagnet(link)
-			m.Store(title, magnet)
-		}()
-	})
-	wg.Wait()
-	t.ScrapedData = m
-	return m
+	t.ScrapedData = data
+	return common.ResultsFromMap(data)
 }
 
 // GetMagnet method returns torrent magnet
-func (t *TorrentWiz) GetMagnet(url string) string {
-	resp, ok := common.GetResponseFromURL(url)
-	if !ok {
-		return "failed to fetch magnet"
-	}
-	defer resp.Body.Close()
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
-		return err.Error()
-	}
-	magnet := strings.TrimSpace(doc.Find("ul.list-group").Text())
-	if magnet == "" {
-		return "no magnet"
-	}
-	return magnet
+func (t *TorrentWiz) GetMagnet(ctx context.Context, url string) string {
+	return Gnuboard.magnetAt(ctx, "torrentwiz", url)
 }
@@ -0,0 +1,143 @@
+package metadata
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Encode bencodes v, which may be an int, int64, string, []byte,
+// []interface{} or map[string]interface{}
+func Encode(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := encode(&buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encode(buf *bytes.Buffer, v interface{}) error {
+	switch t := v.(type) {
+	case int:
+		fmt.Fprintf(buf, "i%de", t)
+	case int64:
+		fmt.Fprintf(buf, "i%de", t)
+	case string:
+		fmt.Fprintf(buf, "%d:%s", len(t), t)
+	case []byte:
+		fmt.Fprintf(buf, "%d:", len(t))
+		buf.Write(t)
+	case []interface{}:
+		buf.WriteByte('l')
+		for _, e := range t {
+			if err := encode(buf, e); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('e')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		buf.WriteByte('d')
+		for _, k := range keys {
+			fmt.Fprintf(buf, "%d:%s", len(k), k)
+			if err := encode(buf, t[k]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('e')
+	default:
+		return fmt.Errorf("bencode: unsupported type %T", v)
+	}
+	return nil
+}
+
+// maxDepth is how deeply lists and dictionaries may nest in decoded data,
+// which comes from untrusted peers
+const maxDepth = 64
+
+// Decode decodes the first bencoded value in data and returns it along with
+// the number of bytes consumed. Strings decode to string, integers to int64,
+// lists to []interface{} and dictionaries to map[string]interface{}.
+func Decode(data []byte) (interface{}, int, error) {
+	return decode(data, 0, 0)
+}
+
+func decode(data []byte, pos, depth int) (interface{}, int, error) {
+	if pos >= len(data) {
+		return nil, pos, fmt.Errorf("bencode: unexpected end of data")
+	}
+	if depth > maxDepth {
+		return nil, pos, fmt.Errorf("bencode: nested deeper than %d", maxDepth)
+	}
+	switch c := data[pos]; {
+	case c == 'i':
+		end := bytes.IndexByte(data[pos:], 'e')
+		if end < 0 {
+			return nil, pos, fmt.Errorf("bencode: unterminated integer")
+		}
+		n, err := strconv.ParseInt(string(data[pos+1:pos+end]), 10, 64)
+		if err != nil {
+			return nil, pos, fmt.Errorf("bencode: %v", err)
+		}
+		return n, pos + end + 1, nil
+	case c == 'l':
+		list := []interface{}{}
+		pos++
+		for pos < len(data) && data[pos] != 'e' {
+			v, next, err := decode(data, pos, depth+1)
+			if err != nil {
+				return nil, pos, err
+			}
+			list = append(list, v)
+			pos = next
+		}
+		if pos >= len(data) {
+			return nil, pos, fmt.Errorf("bencode: unterminated list")
+		}
+		return list, pos + 1, nil
+	case c == 'd':
+		dict := map[string]interface{}{}
+		pos++
+		for pos < len(data) && data[pos] != 'e' {
+			k, next, err := decode(data, pos, depth+1)
+			if err != nil {
+				return nil, pos, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, pos, fmt.Errorf("bencode: dictionary key is not a string")
+			}
+			v, next, err := decode(data, next, depth+1)
+			if err != nil {
+				return nil, pos, err
+			}
+			dict[key] = v
+			pos = next
+		}
+		if pos >= len(data) {
+			return nil, pos, fmt.Errorf("bencode: unterminated dictionary")
+		}
+		return dict, pos + 1, nil
+	case c >= '0' && c <= '9':
+		colon := bytes.IndexByte(data[pos:], ':')
+		if colon < 0 {
+			return nil, pos, fmt.Errorf("bencode: malformed string length")
+		}
+		n, err := strconv.Atoi(string(data[pos : pos+colon]))
+		if err != nil || n < 0 {
+			return nil, pos, fmt.Errorf("bencode: malformed string length")
+		}
+		start := pos + colon + 1
+		if n > len(data)-start {
+			return nil, pos, fmt.Errorf("bencode: string exceeds data")
+		}
+		return string(data[start : start+n]), start + n, nil
+	default:
+		return nil, pos, fmt.Errorf("bencode: unexpected byte %q", c)
+	}
+}
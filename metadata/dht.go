@@ -0,0 +1,147 @@
+package metadata
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"sort"
+	"time"
+)
+
+// bootstrapNodes are well-known entry points into the mainline DHT
+var bootstrapNodes = []string{
+	"router.bittorrent.com:6881",
+	"router.utorrent.com:6881",
+	"dht.transmissionbt.com:6881",
+}
+
+const (
+	// alpha is the number of nodes queried per lookup round
+	alpha = 8
+	// maxRounds bounds the iterative lookup
+	maxRounds = 8
+	// roundTimeout is how long each round waits for replies
+	roundTimeout = time.Second
+)
+
+type node struct {
+	id   []byte
+	addr *net.UDPAddr
+}
+
+// FindPeers runs a BEP 5 get_peers lookup for h and streams the peers it
+// learns about. The channel is closed when the lookup ends or ctx is done.
+func FindPeers(ctx context.Context, h InfoHash) <-chan string {
+	peers := make(chan string, 64)
+	go func() {
+		defer close(peers)
+		conn, err := net.ListenPacket("udp4", ":0")
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+		defer stop()
+
+		id := randomID()
+		query, err := Encode(map[string]interface{}{
+			"t": "gp",
+			"y": "q",
+			"q": "get_peers",
+			"a": map[string]interface{}{"id": string(id), "info_hash": string(h[:])},
+		})
+		if err != nil {
+			return
+		}
+
+		var candidates []node
+		for _, addr := range bootstrapNodes {
+			if a, err := net.ResolveUDPAddr("udp4", addr); err == nil {
+				candidates = append(candidates, node{addr: a})
+			}
+		}
+		queried := map[string]bool{}
+		seen := map[string]bool{}
+		buf := make([]byte, 8192)
+
+		for round := 0; round < maxRounds && ctx.Err() == nil; round++ {
+			sort.SliceStable(candidates, func(i, j int) bool {
+				return closer(candidates[i].id, candidates[j].id, h)
+			})
+			sent := 0
+			for _, n := range candidates {
+				if sent == alpha {
+					break
+				}
+				if queried[n.addr.String()] {
+					continue
+				}
+				queried[n.addr.String()] = true
+				conn.WriteTo(query, n.addr)
+				sent++
+			}
+			if sent == 0 {
+				return
+			}
+
+			conn.SetReadDeadline(time.Now().Add(roundTimeout))
+			for {
+				n, _, err := conn.ReadFrom(buf)
+				if err != nil {
+					break
+				}
+				v, _, err := Decode(buf[:n])
+				if err != nil {
+					continue
+				}
+				msg, _ := v.(map[string]interface{})
+				r, _ := msg["r"].(map[string]interface{})
+				if r == nil {
+					continue
+				}
+				values, _ := r["values"].([]interface{})
+				for _, p := range compactPeers(values) {
+					if seen[p] {
+						continue
+					}
+					seen[p] = true
+					select {
+					case peers <- p:
+					case <-ctx.Done():
+						return
+					}
+				}
+				nodes, _ := r["nodes"].(string)
+				for i := 0; i+26 <= len(nodes); i += 26 {
+					c := []byte(nodes[i : i+26])
+					addr := &net.UDPAddr{IP: net.IP(c[20:24]), Port: int(c[24])<<8 | int(c[25])}
+					if addr.Port == 0 || queried[addr.String()] {
+						continue
+					}
+					candidates = append(candidates, node{id: c[:20], addr: addr})
+				}
+			}
+			if ctx.Err() != nil {
+				return
+			}
+		}
+	}()
+	return peers
+}
+
+// closer reports whether node id a is closer to h than b by XOR distance.
+// Nodes with unknown ids sort last.
+func closer(a, b []byte, h InfoHash) bool {
+	if len(a) != 20 {
+		return false
+	}
+	if len(b) != 20 {
+		return true
+	}
+	da, db := make([]byte, 20), make([]byte, 20)
+	for i := range h {
+		da[i] = a[i] ^ h[i]
+		db[i] = b[i] ^ h[i]
+	}
+	return bytes.Compare(da, db) < 0
+}
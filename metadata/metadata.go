@@ -0,0 +1,163 @@
+package metadata
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+	"unicode"
+)
+
+// InfoHash is the SHA-1 hash identifying a torrent
+type InfoHash [20]byte
+
+// String returns the hash as lower-case hex
+func (h InfoHash) String() string {
+	return hex.EncodeToString(h[:])
+}
+
+// File is a single file inside a torrent
+type File struct {
+	Path   string
+	Length int64
+}
+
+// Info is the part of a torrent's info dictionary needed to verify a title
+type Info struct {
+	Name  string
+	Files []File
+}
+
+// ParseMagnet extracts the info hash from a magnet link, accepting both the
+// hex and base32 btih forms
+func ParseMagnet(magnet string) (InfoHash, error) {
+	var h InfoHash
+	i := strings.Index(strings.ToLower(magnet), "urn:btih:")
+	if i < 0 {
+		return h, fmt.Errorf("no btih in magnet link")
+	}
+	v := magnet[i+len("urn:btih:"):]
+	if j := strings.IndexByte(v, '&'); j >= 0 {
+		v = v[:j]
+	}
+	var raw []byte
+	var err error
+	switch len(v) {
+	case 40:
+		raw, err = hex.DecodeString(v)
+	case 32:
+		raw, err = base32.StdEncoding.DecodeString(strings.ToUpper(v))
+	default:
+		return h, fmt.Errorf("unexpected btih length %d", len(v))
+	}
+	if err != nil {
+		return h, fmt.Errorf("malformed btih: %v", err)
+	}
+	copy(h[:], raw)
+	return h, nil
+}
+
+// parseInfo converts a decoded info dictionary into Info
+func parseInfo(v interface{}) (*Info, error) {
+	dict, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("info is not a dictionary")
+	}
+	info := &Info{}
+	info.Name, _ = dict["name"].(string)
+	if files, ok := dict["files"].([]interface{}); ok {
+		for _, f := range files {
+			fd, ok := f.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			length, _ := fd["length"].(int64)
+			var parts []string
+			if path, ok := fd["path"].([]interface{}); ok {
+				for _, p := range path {
+					if s, ok := p.(string); ok {
+						parts = append(parts, s)
+					}
+				}
+			}
+			info.Files = append(info.Files, File{Path: strings.Join(parts, "/"), Length: length})
+		}
+	} else {
+		length, _ := dict["length"].(int64)
+		info.Files = []File{{Path: info.Name, Length: length}}
+	}
+	return info, nil
+}
+
+// Matches reports whether the torrent described by info plausibly is the
+// release called title. Names are compared case-insensitively on their
+// letters and digits; a match needs one to contain the other or at least
+// half of the title's words to appear in the torrent name or file list.
+func Matches(title string, info *Info) bool {
+	want := words(title)
+	if len(want) == 0 {
+		return true
+	}
+	names := []string{info.Name}
+	for _, f := range info.Files {
+		names = append(names, f.Path)
+	}
+	for _, name := range names {
+		got := words(name)
+		if len(got) == 0 {
+			continue
+		}
+		a, b := strings.Join(want, " "), strings.Join(got, " ")
+		if strings.Contains(a, b) || strings.Contains(b, a) {
+			return true
+		}
+		have := map[string]bool{}
+		for _, w := range got {
+			have[w] = true
+		}
+		common := 0
+		for _, w := range want {
+			if have[w] {
+				common++
+			}
+		}
+		if common*2 >= len(want) {
+			return true
+		}
+	}
+	return false
+}
+
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// randomID returns a random 20-byte node or peer id
+func randomID() []byte {
+	id := make([]byte, 20)
+	rand.Read(id)
+	return id
+}
+
+// compactPeers decodes BEP 5 compact IPv4 peer entries
+func compactPeers(values []interface{}) []string {
+	var peers []string
+	for _, v := range values {
+		s, ok := v.(string)
+		if !ok || len(s) != 6 {
+			continue
+		}
+		ip := net.IP([]byte(s[:4]))
+		port := binary.BigEndian.Uint16([]byte(s[4:]))
+		if port == 0 {
+			continue
+		}
+		peers = append(peers, net.JoinHostPort(ip.String(), fmt.Sprint(port)))
+	}
+	return peers
+}
@@ -0,0 +1,208 @@
+package metadata
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+const (
+	// maxMetadataSize caps the info dictionary a peer may announce
+	maxMetadataSize = 8 << 20
+	// metadataPieceSize is the BEP 9 piece size
+	metadataPieceSize = 16 << 10
+	// maxMessageSize caps any single peer wire message we accept
+	maxMessageSize = 1 << 20
+	// utMetadataID is the extended message id we assign to ut_metadata
+	utMetadataID = 1
+	// peerTimeout bounds a whole exchange with one peer
+	peerTimeout = 15 * time.Second
+)
+
+// FetchFromPeer downloads and checks the info dictionary for h from the
+// peer at addr using the BEP 10 extension protocol and BEP 9 ut_metadata
+func FetchFromPeer(ctx context.Context, addr string, h InfoHash) (*Info, error) {
+	d := net.Dialer{Timeout: 5 * time.Second}
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(peerTimeout))
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	if err := handshake(conn, h); err != nil {
+		return nil, err
+	}
+	if err := sendExtended(conn, 0, map[string]interface{}{
+		"m": map[string]interface{}{"ut_metadata": utMetadataID},
+	}); err != nil {
+		return nil, err
+	}
+
+	var (
+		size   int64
+		pieces [][]byte
+		got    int
+	)
+	for {
+		msg, err := readMessage(conn)
+		if err != nil {
+			return nil, err
+		}
+		// Only extended messages (id 20) matter here
+		if len(msg) < 2 || msg[0] != 20 {
+			continue
+		}
+		switch msg[1] {
+		case 0:
+			v, _, err := Decode(msg[2:])
+			if err != nil {
+				return nil, err
+			}
+			dict, _ := v.(map[string]interface{})
+			m, _ := dict["m"].(map[string]interface{})
+			theirID, _ := m["ut_metadata"].(int64)
+			size, _ = dict["metadata_size"].(int64)
+			if theirID <= 0 {
+				return nil, fmt.Errorf("peer does not support ut_metadata")
+			}
+			if size <= 0 || size > maxMetadataSize {
+				return nil, fmt.Errorf("peer announced metadata size %d", size)
+			}
+			pieces = make([][]byte, (size+metadataPieceSize-1)/metadataPieceSize)
+			for i := range pieces {
+				if err := sendExtended(conn, theirID, map[string]interface{}{
+					"msg_type": 0,
+					"piece":    i,
+				}); err != nil {
+					return nil, err
+				}
+			}
+		case utMetadataID:
+			if pieces == nil {
+				continue
+			}
+			v, n, err := Decode(msg[2:])
+			if err != nil {
+				return nil, err
+			}
+			dict, _ := v.(map[string]interface{})
+			switch t, _ := dict["msg_type"].(int64); t {
+			case 1:
+			case 2:
+				return nil, fmt.Errorf("peer rejected metadata request")
+			default:
+				continue
+			}
+			piece, _ := dict["piece"].(int64)
+			if piece < 0 || piece >= int64(len(pieces)) {
+				return nil, fmt.Errorf("peer sent unknown piece %d", piece)
+			}
+			payload := msg[2+n:]
+			if want := pieceLength(size, piece); int64(len(payload)) != want {
+				return nil, fmt.Errorf("peer sent %d bytes for piece %d, want %d", len(payload), piece, want)
+			}
+			if pieces[piece] == nil {
+				pieces[piece] = append([]byte(nil), payload...)
+				got++
+			}
+			if got < len(pieces) {
+				continue
+			}
+			data := bytes.Join(pieces, nil)
+			if int64(len(data)) != size {
+				return nil, fmt.Errorf("metadata is %d bytes, want %d", len(data), size)
+			}
+			if sha1.Sum(data) != h {
+				return nil, fmt.Errorf("metadata does not match info hash")
+			}
+			v, _, err = Decode(data)
+			if err != nil {
+				return nil, err
+			}
+			return parseInfo(v)
+		}
+	}
+}
+
+// pieceLength is the size of metadata piece i of an info dictionary of
+// size bytes: every piece is metadataPieceSize except the last, which holds
+// the remainder
+func pieceLength(size, i int64) int64 {
+	if last := (size - 1) / metadataPieceSize; i == last {
+		return size - last*metadataPieceSize
+	}
+	return metadataPieceSize
+}
+
+// handshake performs the BitTorrent handshake advertising extension support
+func handshake(conn net.Conn, h InfoHash) error {
+	const protocol = "BitTorrent protocol"
+	msg := make([]byte, 0, 68)
+	msg = append(msg, byte(len(protocol)))
+	msg = append(msg, protocol...)
+	reserved := make([]byte, 8)
+	reserved[5] |= 0x10
+	msg = append(msg, reserved...)
+	msg = append(msg, h[:]...)
+	msg = append(msg, randomID()...)
+	if _, err := conn.Write(msg); err != nil {
+		return err
+	}
+	resp := make([]byte, 68)
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return err
+	}
+	if resp[0] != byte(len(protocol)) || string(resp[1:20]) != protocol {
+		return fmt.Errorf("peer does not speak the BitTorrent protocol")
+	}
+	if resp[25]&0x10 == 0 {
+		return fmt.Errorf("peer does not support extensions")
+	}
+	if !bytes.Equal(resp[28:48], h[:]) {
+		return fmt.Errorf("peer answered for another torrent")
+	}
+	return nil
+}
+
+// sendExtended writes a BEP 10 extended message
+func sendExtended(w io.Writer, id int64, payload map[string]interface{}) error {
+	body, err := Encode(payload)
+	if err != nil {
+		return err
+	}
+	msg := make([]byte, 6, 6+len(body))
+	binary.BigEndian.PutUint32(msg, uint32(2+len(body)))
+	msg[4] = 20
+	msg[5] = byte(id)
+	_, err = w.Write(append(msg, body...))
+	return err
+}
+
+// readMessage reads one length-prefixed peer wire message, skipping keep-alives
+func readMessage(r io.Reader) ([]byte, error) {
+	for {
+		var length uint32
+		if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+			return nil, err
+		}
+		if length == 0 {
+			continue
+		}
+		if length > maxMessageSize {
+			return nil, fmt.Errorf("peer message of %d bytes is too large", length)
+		}
+		msg := make([]byte, length)
+		if _, err := io.ReadFull(r, msg); err != nil {
+			return nil, err
+		}
+		return msg, nil
+	}
+}
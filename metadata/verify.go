@@ -0,0 +1,158 @@
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// maxPeers is how many peers are tried per torrent
+	maxPeers = 16
+	// maxPeerConns is how many peers are contacted at once per torrent
+	maxPeerConns = 4
+	// maxVerifications is how many torrents are verified at once
+	maxVerifications = 4
+	// VerifyTimeout bounds the verification of one torrent
+	VerifyTimeout = 20 * time.Second
+)
+
+// Fetch finds peers for h on the DHT and downloads its info dictionary from
+// the first peer that serves it
+func Fetch(ctx context.Context, h InfoHash) (*Info, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	peers := FindPeers(ctx, h)
+	results := make(chan *Info, 1)
+	sem := make(chan struct{}, maxPeerConns)
+	var wg sync.WaitGroup
+	tried := 0
+
+loop:
+	for tried < maxPeers {
+		select {
+		case info := <-results:
+			return info, nil
+		case <-ctx.Done():
+			break loop
+		case addr, ok := <-peers:
+			if !ok {
+				break loop
+			}
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				break loop
+			}
+			tried++
+			wg.Add(1)
+			go func(addr string) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				if info, err := FetchFromPeer(ctx, addr, h); err == nil {
+					select {
+					case results <- info:
+					default:
+					}
+				}
+			}(addr)
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case info := <-results:
+		return info, nil
+	case <-done:
+	}
+	select {
+	case info := <-results:
+		return info, nil
+	default:
+	}
+	if tried == 0 {
+		return nil, fmt.Errorf("no peers found")
+	}
+	return nil, fmt.Errorf("no peer served metadata (%d tried)", tried)
+}
+
+// Result is the outcome of verifying one scraped title against its metadata
+type Result struct {
+	Title  string
+	Magnet string
+	// Name is the torrent name from the metadata, empty when unverified
+	Name  string
+	Match bool
+	Err   error
+}
+
+// Torrent is one scraped title and its magnet link to verify
+type Torrent struct {
+	Title  string
+	Magnet string
+}
+
+// Verify fetches metadata for every torrent and checks that the torrent name
+// matches the title. Work is bounded to a few torrents at a time and
+// VerifyTimeout per torrent; failures are reported, never fatal.
+func Verify(ctx context.Context, torrents []Torrent) []Result {
+	var wg sync.WaitGroup
+	results := make([]Result, len(torrents))
+	sem := make(chan struct{}, maxVerifications)
+	for i, t := range torrents {
+		results[i] = Result{Title: t.Title, Magnet: t.Magnet}
+		wg.Add(1)
+		go func(r *Result) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+				r.Name, r.Match, r.Err = verifyOne(ctx, r.Title, r.Magnet)
+			case <-ctx.Done():
+				r.Err = ctx.Err()
+			}
+		}(&results[i])
+	}
+	wg.Wait()
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Title < results[j].Title })
+	return results
+}
+
+func verifyOne(ctx context.Context, title, magnet string) (string, bool, error) {
+	h, err := ParseMagnet(magnet)
+	if err != nil {
+		return "", false, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, VerifyTimeout)
+	defer cancel()
+	info, err := Fetch(ctx, h)
+	if err != nil {
+		return "", false, err
+	}
+	return info.Name, Matches(title, info), nil
+}
+
+// PrintReport prints mismatched torrents followed by a one-line summary
+func PrintReport(w io.Writer, results []Result) {
+	matched, unverified := 0, 0
+	for _, r := range results {
+		switch {
+		case r.Err != nil:
+			unverified++
+		case r.Match:
+			matched++
+		default:
+			fmt.Fprintf(w, "[!] MISMATCH %s\n    metadata name: %s\n", r.Title, r.Name)
+		}
+	}
+	fmt.Fprintf(w, "Metadata: %d matched, %d mismatched, %d unverified\n",
+		matched, len(results)-matched-unverified, unverified)
+}
@@ -0,0 +1,157 @@
+package notifiers
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/daite/tspider/common"
+)
+
+func init() {
+	Register("desktop", func(name string, cfg common.NotifierConfig) (Notifier, error) { return NewDesktop(cfg), nil })
+}
+
+// Desktop shows a native desktop notification: notify-send on Linux,
+// terminal-notifier or else osascript on macOS, and a toast through
+// PowerShell on Windows
+type Desktop struct {
+	// CopyOnClick copies the magnets of the notification, one per line, when
+	// it is clicked. It needs notify-send 0.7.9 or terminal-notifier; other
+	// notifications are shown without it.
+	CopyOnClick bool
+}
+
+// NewDesktop returns a desktop notifier
+func NewDesktop(cfg common.NotifierConfig) *Desktop {
+	return &Desktop{CopyOnClick: cfg.CopyOnClick}
+}
+
+// Notify shows n. It returns once the notification is shown; a click is
+// waited for in the background.
+func (d *Desktop) Notify(n Notification) error {
+	title := "tspider: " + n.Search
+	body := desktopBody(n)
+	var magnets []string
+	for _, r := range n.Results {
+		magnets = append(magnets, r.Magnet)
+	}
+	copyText := strings.Join(magnets, "\n")
+
+	switch runtime.GOOS {
+	case "darwin":
+		if path, err := exec.LookPath("terminal-notifier"); err == nil {
+			args := []string{"-title", title, "-message", body, "-group", "tspider"}
+			if d.CopyOnClick && copyText != "" {
+				args = append(args, "-execute", "printf %s "+shellQuote(copyText)+" | pbcopy")
+			}
+			return run(path, args...)
+		}
+		script := fmt.Sprintf("display notification %s with title %s", appleScriptQuote(body), appleScriptQuote(title))
+		return run("osascript", "-e", script)
+	case "windows":
+		// The toast goes through the environment: the script text holds
+		// nothing scraped that could end its quoting
+		cmd := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", toastScript)
+		cmd.Env = append(os.Environ(), toastEnv+"="+toastXML(title, body))
+		return runCmd(cmd)
+	}
+	path, err := exec.LookPath("notify-send")
+	if err != nil {
+		return fmt.Errorf("cannot show notifications: notify-send not found (install libnotify)")
+	}
+	if !d.CopyOnClick || copyText == "" {
+		return run(path, "--app-name=tspider", "--", title, body)
+	}
+	// With an action notify-send waits for the notification to close and
+	// prints the action chosen
+	cmd := exec.Command(path, "--app-name=tspider", "--action=copy=Copy magnet", "--wait", "--", title, body)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("notify-send: %w", err)
+	}
+	go func() {
+		sc := bufio.NewScanner(out)
+		for sc.Scan() {
+			if strings.TrimSpace(sc.Text()) == "copy" {
+				common.CopyToClipboard(copyText)
+			}
+		}
+		cmd.Wait()
+	}()
+	return nil
+}
+
+// desktopBody names the new results, up to three of them
+func desktopBody(n Notification) string {
+	const shown = 3
+	var lines []string
+	for i, r := range n.Results {
+		if i == shown {
+			lines = append(lines, fmt.Sprintf("and %d more", len(n.Results)-shown))
+			break
+		}
+		lines = append(lines, fmt.Sprintf("%s [%s]", r.Title, r.Site))
+	}
+	if len(lines) == 0 {
+		return "No new results for " + n.Keyword
+	}
+	return strings.Join(lines, "\n")
+}
+
+// run runs a notification command, reporting its output on failure
+func run(name string, args ...string) error {
+	return runCmd(exec.Command(name, args...))
+}
+
+// runCmd runs cmd, reporting its output on failure
+func runCmd(cmd *exec.Cmd) error {
+	name := filepath.Base(cmd.Path)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%s: %w: %s", name, err, msg)
+		}
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// shellQuote quotes s for sh
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// appleScriptQuote quotes s as an AppleScript string
+func appleScriptQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// toastEnv is the environment variable passing the toast XML to toastScript
+const toastEnv = "TSPIDER_TOAST_XML"
+
+// toastXML is the XML of a toast showing title and body
+func toastXML(title, body string) string {
+	return "<toast><visual><binding template=\"ToastGeneric\"><text>" + html.EscapeString(title) +
+		"</text><text>" + html.EscapeString(body) + "</text></binding></visual></toast>"
+}
+
+// toastScript is a PowerShell script showing the toast whose XML is in the
+// toastEnv environment variable
+var toastScript = strings.Join([]string{
+	"[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] | Out-Null",
+	"[Windows.Data.Xml.Dom.XmlDocument, Windows.Data.Xml.Dom.XmlDocument, ContentType = WindowsRuntime] | Out-Null",
+	"$xml = New-Object Windows.Data.Xml.Dom.XmlDocument",
+	"$xml.LoadXml($env:" + toastEnv + ")",
+	"$toast = [Windows.UI.Notifications.ToastNotification]::new($xml)",
+	// PowerShell's own app ID, as unregistered apps cannot show toasts
+	`[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier('{1AC14E77-02E7-4E5D-B744-2EB1AE5198B7}\WindowsPowerShell\v1.0\powershell.exe').Show($toast)`,
+}, "; ")
@@ -0,0 +1,238 @@
+package notifiers
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/daite/tspider/common"
+)
+
+func init() {
+	Register("email", func(name string, cfg common.NotifierConfig) (Notifier, error) { return NewEmail(name, cfg) })
+}
+
+// Email mails notifications through an SMTP server, each at once or, with
+// a digest schedule, collected into one email per period
+type Email struct {
+	Name string
+	// Host is the host:port of the SMTP server. Port 465 is spoken over
+	// TLS; on others STARTTLS is used when the server offers it.
+	Host     string
+	Username string
+	Password string
+	From     string
+	To       []string
+	// digest is the schedule of digest emails, nil to mail at once
+	digest *common.Cron
+}
+
+// digestState is what an email digest has collected since it last sent one
+type digestState struct {
+	Since   time.Time      `json:"since"`
+	Pending []Notification `json:"pending,omitempty"`
+}
+
+// NewEmail returns the email notifier called name, which keeps its digest
+// under that name
+func NewEmail(name string, cfg common.NotifierConfig) (*Email, error) {
+	if cfg.SMTPHost == "" || cfg.From == "" || len(cfg.To) == 0 {
+		return nil, fmt.Errorf("email needs smtp_host, from and to")
+	}
+	if _, _, err := net.SplitHostPort(cfg.SMTPHost); err != nil {
+		return nil, fmt.Errorf("smtp_host %q: want host:port", cfg.SMTPHost)
+	}
+	e := &Email{Name: name, Host: cfg.SMTPHost, Username: cfg.Username, Password: cfg.Password, From: cfg.From, To: cfg.To}
+	if cfg.Digest != "" {
+		cron, err := common.ParseCron(cfg.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("digest: %w", err)
+		}
+		e.digest = cron
+	}
+	return e, nil
+}
+
+// GetDigestPath returns the file holding the results collected by email
+// digests
+func GetDigestPath() string {
+	return filepath.Join(filepath.Dir(common.GetConfigPath()), ".tspider_digests.json")
+}
+
+func loadDigests() (map[string]*digestState, error) {
+	digests := map[string]*digestState{}
+	data, err := os.ReadFile(GetDigestPath())
+	if os.IsNotExist(err) {
+		return digests, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read digests: %w", err)
+	}
+	if err := json.Unmarshal(data, &digests); err != nil {
+		return nil, fmt.Errorf("failed to parse digests %s: %w", GetDigestPath(), err)
+	}
+	return digests, nil
+}
+
+func saveDigests(digests map[string]*digestState) error {
+	data, err := json.MarshalIndent(digests, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal digests: %w", err)
+	}
+	if err := common.WriteFileAtomic(GetDigestPath(), data); err != nil {
+		return fmt.Errorf("failed to save digests: %w", err)
+	}
+	return nil
+}
+
+// Notify mails n, or adds it to the digest
+func (e *Email) Notify(n Notification) error {
+	if e.digest == nil {
+		subject := fmt.Sprintf("tspider: %d new result(s) for %s", len(n.Results), n.Search)
+		return e.send(subject, emailBody([]Notification{n}))
+	}
+	digests, err := loadDigests()
+	if err != nil {
+		return err
+	}
+	st := digests[e.Name]
+	if st == nil {
+		st = &digestState{Since: n.Time}
+		digests[e.Name] = st
+	}
+	st.Pending = append(st.Pending, n)
+	return saveDigests(digests)
+}
+
+// NextDigest returns when the digest is next due, or the zero time for
+// emails sent at once
+func (e *Email) NextDigest() time.Time {
+	if e.digest == nil {
+		return time.Time{}
+	}
+	digests, err := loadDigests()
+	if err != nil || digests[e.Name] == nil {
+		// Nothing collected yet, so nothing is due
+		return e.digest.Next(time.Now())
+	}
+	return e.digest.Next(digests[e.Name].Since)
+}
+
+// SendDigest mails the collected notifications, unless there are none. On
+// failure they are kept for the next attempt.
+func (e *Email) SendDigest(now time.Time) error {
+	if e.digest == nil {
+		return nil
+	}
+	digests, err := loadDigests()
+	if err != nil {
+		return err
+	}
+	if st := digests[e.Name]; st != nil && len(st.Pending) > 0 {
+		count := 0
+		searches := map[string]bool{}
+		for _, n := range st.Pending {
+			count += len(n.Results)
+			searches[n.Search] = true
+		}
+		subject := fmt.Sprintf("tspider digest: %d new result(s) from %d search(es)", count, len(searches))
+		if err := e.send(subject, emailBody(st.Pending)); err != nil {
+			return err
+		}
+	}
+	digests[e.Name] = &digestState{Since: now}
+	return saveDigests(digests)
+}
+
+// emailBody lists the results of notifications as plain text
+func emailBody(notifications []Notification) string {
+	var b strings.Builder
+	for _, n := range notifications {
+		fmt.Fprintf(&b, "%s (%q on %s sites), %s\n\n", n.Search, n.Keyword, n.Lang, n.Time.Format("2006-01-02 15:04"))
+		for _, r := range n.Results {
+			fmt.Fprintf(&b, "  %s [%s]", r.Title, r.Site)
+			if r.Size != "" {
+				fmt.Fprintf(&b, " %s", r.Size)
+			}
+			fmt.Fprintf(&b, "\n  %s\n\n", r.Magnet)
+		}
+	}
+	return b.String()
+}
+
+// message returns the email with subject and body, encoded for SMTP
+func (e *Email) message(subject, body string) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", e.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(e.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	qp := quotedprintable.NewWriter(&b)
+	qp.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n")))
+	qp.Close()
+	return b.Bytes()
+}
+
+// send mails subject and body to the recipients
+func (e *Email) send(subject, body string) error {
+	host, port, _ := net.SplitHostPort(e.Host)
+	dialer := &net.Dialer{Timeout: timeout()}
+	var conn net.Conn
+	var err error
+	if port == "465" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", e.Host, &tls.Config{ServerName: host})
+	} else {
+		conn, err = dialer.Dial("tcp", e.Host)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot reach mail server %s: %w", e.Host, err)
+	}
+	conn.SetDeadline(time.Now().Add(timeout()))
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("mail server %s: %w", e.Host, err)
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok && port != "465" {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return fmt.Errorf("mail server %s: STARTTLS: %w", e.Host, err)
+		}
+	}
+	if e.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", e.Username, e.Password, host)); err != nil {
+			return fmt.Errorf("mail server %s refused the login; check username and password: %w", e.Host, err)
+		}
+	}
+	if err := c.Mail(e.From); err != nil {
+		return fmt.Errorf("mail server %s: %w", e.Host, err)
+	}
+	for _, to := range e.To {
+		if err := c.Rcpt(to); err != nil {
+			return fmt.Errorf("mail server %s refused %s: %w", e.Host, to, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("mail server %s: %w", e.Host, err)
+	}
+	if _, err := w.Write(e.message(subject, body)); err != nil {
+		return fmt.Errorf("mail server %s: %w", e.Host, err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("mail server %s: %w", e.Host, err)
+	}
+	return c.Quit()
+}
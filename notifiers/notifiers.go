@@ -0,0 +1,166 @@
+package notifiers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/daite/tspider/common"
+)
+
+// Notification reports the new results of a repeated search
+type Notification struct {
+	// Search is the schedule name, or the keyword of a watch
+	Search  string                `json:"search"`
+	Keyword string                `json:"keyword"`
+	Lang    string                `json:"lang"`
+	Time    time.Time             `json:"time"`
+	Count   int                   `json:"count"`
+	Results []common.SearchResult `json:"results"`
+}
+
+// Notifier passes notifications on, such as to a webhook
+type Notifier interface {
+	Notify(n Notification) error
+}
+
+// Digester is a notifier that collects notifications and passes them on
+// together on a schedule, such as a daily email
+type Digester interface {
+	Notifier
+	// NextDigest returns when the collected notifications are next due, the
+	// zero time when it passes notifications on at once
+	NextDigest() time.Time
+	// SendDigest passes on the collected notifications, if any, and starts
+	// collecting anew
+	SendDigest(now time.Time) error
+}
+
+// SendDueDigests sends the digests of all that are due at now. A failing
+// digest does not stop the others; the failures are reported together.
+func SendDueDigests(all map[string]Notifier, now time.Time) error {
+	return sendDigests(all, now, false)
+}
+
+// FlushDigests sends the digests of all now, due or not
+func FlushDigests(all map[string]Notifier, now time.Time) error {
+	return sendDigests(all, now, true)
+}
+
+func sendDigests(all map[string]Notifier, now time.Time, force bool) error {
+	names := make([]string, 0, len(all))
+	for name := range all {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var failed []string
+	for _, name := range names {
+		d, ok := all[name].(Digester)
+		if !ok {
+			continue
+		}
+		next := d.NextDigest()
+		if next.IsZero() || (!force && next.After(now)) {
+			continue
+		}
+		if err := d.SendDigest(now); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("sending digests failed: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// NextDigest returns when the first digest of all is due, the zero time
+// when none collects notifications
+func NextDigest(all map[string]Notifier) time.Time {
+	var first time.Time
+	for _, n := range all {
+		if d, ok := n.(Digester); ok {
+			if next := d.NextDigest(); !next.IsZero() && (first.IsZero() || next.Before(first)) {
+				first = next
+			}
+		}
+	}
+	return first
+}
+
+// Factory returns a notifier for the settings of its section of the
+// config, notifiers.<name>
+type Factory func(name string, cfg common.NotifierConfig) (Notifier, error)
+
+// registry maps notifier types to their factories
+var registry = map[string]Factory{}
+
+// Register makes a notifier type available. Each type registers itself
+// from an init function.
+func Register(typ string, f Factory) {
+	if _, dup := registry[typ]; dup {
+		panic("notifiers: " + typ + " registered twice")
+	}
+	registry[typ] = f
+}
+
+// Types returns the registered notifier types
+func Types() []string {
+	types := make([]string, 0, len(registry))
+	for typ := range registry {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// New returns the notifier called name for cfg
+func New(name string, cfg common.NotifierConfig) (Notifier, error) {
+	f, ok := registry[cfg.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown notifier type %q (want %s)", cfg.Type, strings.Join(Types(), ", "))
+	}
+	return f(name, cfg)
+}
+
+// Configured returns the notifiers of the config, by name
+func Configured() (map[string]Notifier, error) {
+	cfgs := common.GetConfig().Notifiers
+	all := make(map[string]Notifier, len(cfgs))
+	for name, cfg := range cfgs {
+		n, err := New(name, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("notifier %s: %w", name, err)
+		}
+		all[name] = n
+	}
+	return all, nil
+}
+
+// NotifyAll sends n to each of all. A failing notifier does not stop the
+// others; the failures are reported together.
+func NotifyAll(all map[string]Notifier, n Notification) error {
+	if n.Count == 0 {
+		n.Count = len(n.Results)
+	}
+	names := make([]string, 0, len(all))
+	for name := range all {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var failed []string
+	for _, name := range names {
+		if err := all[name].Notify(n); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("notifying failed: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// timeout is the request timeout of the notifiers, timeout_seconds
+func timeout() time.Duration {
+	return time.Duration(common.GetConfig().Timeout) * time.Second
+}
@@ -0,0 +1,62 @@
+package notifiers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/daite/tspider/common"
+)
+
+func init() {
+	Register("webhook", func(name string, cfg common.NotifierConfig) (Notifier, error) { return NewWebhook(cfg) })
+}
+
+// Webhook POSTs each notification as JSON to a URL, such as an n8n or
+// Home Assistant webhook trigger
+type Webhook struct {
+	URL     string
+	Headers map[string]string
+	client  *http.Client
+}
+
+// NewWebhook returns a notifier posting to cfg.URL with cfg.Headers
+func NewWebhook(cfg common.NotifierConfig) (*Webhook, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("webhook needs a url")
+	}
+	return &Webhook{
+		URL:     cfg.URL,
+		Headers: cfg.Headers,
+		client:  &http.Client{Timeout: timeout()},
+	}, nil
+}
+
+// Notify posts n and fails unless the webhook answers with a 2xx status
+func (w *Webhook) Notify(n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "tspider/webhook")
+	for k, v := range w.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot reach webhook %s: %w", w.URL, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s: %s", w.URL, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/daite/tspider/common"
+)
+
+// SearchFunc returns the results the sites of lang list for keyword
+type SearchFunc func(ctx context.Context, keyword, lang string) ([]common.SearchResult, error)
+
+// DoctorFunc checks the sites of lang, all sites when it is empty, and with
+// deep also searches each one for its health keyword
+type DoctorFunc func(ctx context.Context, lang string, deep bool) []common.SiteStatus
+
+// API serves searches, site checks and the site list as JSON:
+//
+//	GET /api/search?q=KEYWORD[&lang=kr|jp][&sort=relevance|title|seeders][&limit=N]
+//	GET /api/doctor[?lang=kr|jp][&deep=1]
+//	GET /api/sites
+//	GET /api/stream?q=KEYWORD[&lang=kr|jp] (WebSocket, see Event)
+type API struct {
+	Search SearchFunc
+	Stream StreamFunc
+	Doctor DoctorFunc
+	// APIKey, when set, must be sent in the X-Api-Key header, or in the
+	// apikey parameter of /api/stream, as browsers cannot set headers on a
+	// WebSocket. Elsewhere the parameter would leave the key in proxy and
+	// server logs and in the browser history.
+	APIKey string
+	// Lang is the language searched when a request has no lang parameter
+	Lang string
+}
+
+// SearchResponse is the reply of /api/search
+type SearchResponse struct {
+	Query   string                `json:"query"`
+	Lang    string                `json:"lang"`
+	Count   int                   `json:"count"`
+	Results []common.SearchResult `json:"results"`
+}
+
+// Site is an entry of /api/sites
+type Site struct {
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+	Language string `json:"language"`
+	Enabled  bool   `json:"enabled"`
+	Type     string `json:"type,omitempty"`
+}
+
+// Handler returns the routes of the API
+func (a *API) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/search", a.authorized(a.search, false))
+	mux.HandleFunc("/api/doctor", a.authorized(a.doctor, false))
+	mux.HandleFunc("/api/sites", a.authorized(a.sites, false))
+	mux.HandleFunc("/api/stream", a.authorized(a.stream, true))
+	return mux
+}
+
+// authorized wraps h to reject requests that are not GET or lack the API
+// key, which is read from the apikey parameter too when inQuery is set
+func (a *API) authorized(h http.HandlerFunc, inQuery bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "use GET")
+			return
+		}
+		if a.APIKey != "" {
+			key := r.Header.Get("X-Api-Key")
+			if key == "" && inQuery {
+				key = r.URL.Query().Get("apikey")
+			}
+			if subtle.ConstantTimeCompare([]byte(key), []byte(a.APIKey)) != 1 {
+				writeError(w, http.StatusUnauthorized, "missing or wrong API key")
+				return
+			}
+		}
+		h(w, r)
+	}
+}
+
+func (a *API) search(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	keyword := strings.TrimSpace(q.Get("q"))
+	if keyword == "" {
+		writeError(w, http.StatusBadRequest, "missing q parameter")
+		return
+	}
+	lang := q.Get("lang")
+	if lang == "" {
+		lang = a.Lang
+	}
+	if !common.ValidLanguage(lang) {
+		writeError(w, http.StatusBadRequest, "lang must be "+common.LanguageList())
+		return
+	}
+	order := q.Get("sort")
+	if order == "" {
+		order = "relevance"
+	}
+	if err := common.SortResults(nil, order, keyword); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	results, err := a.Search(r.Context(), keyword, lang)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	common.SortResults(results, order, keyword)
+	if limit, err := strconv.Atoi(q.Get("limit")); err == nil && limit >= 0 && limit < len(results) {
+		results = results[:limit]
+	}
+	if results == nil {
+		results = []common.SearchResult{}
+	}
+	writeJSON(w, SearchResponse{Query: keyword, Lang: lang, Count: len(results), Results: safeLinks(results)})
+}
+
+// safeLinks returns results with the detail URLs that are not http or
+// https, and the magnets that are not magnet links, cleared. Both are
+// scraped from sites that may be hostile, and the web UI turns them into
+// links: a javascript: URL would run in the UI's origin, which holds the
+// API key.
+func safeLinks(results []common.SearchResult) []common.SearchResult {
+	safe := make([]common.SearchResult, len(results))
+	for i, r := range results {
+		if u, err := url.Parse(r.DetailURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			r.DetailURL = ""
+		}
+		if !strings.HasPrefix(r.Magnet, "magnet:?") {
+			r.Magnet = ""
+		}
+		safe[i] = r
+	}
+	return safe
+}
+
+func (a *API) doctor(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	lang := q.Get("lang")
+	if lang != "" && !common.ValidLanguage(lang) {
+		writeError(w, http.StatusBadRequest, "lang must be "+common.LanguageList())
+		return
+	}
+	deep, _ := strconv.ParseBool(q.Get("deep"))
+	statuses := a.Doctor(r.Context(), lang, deep)
+	if statuses == nil {
+		statuses = []common.SiteStatus{}
+	}
+	writeJSON(w, statuses)
+}
+
+func (a *API) sites(w http.ResponseWriter, r *http.Request) {
+	c := common.GetConfig()
+	sites := make([]Site, 0, len(c.Sites))
+	for name, s := range c.Sites {
+		sites = append(sites, Site{Name: name, URL: s.URL, Language: s.Language, Enabled: s.Enabled, Type: s.Type})
+	}
+	sort.Slice(sites, func(i, j int) bool { return sites[i].Name < sites[j].Name })
+	writeJSON(w, sites)
+}
+
+// MetricsHandler serves the per-site metrics of common.Metrics in the
+// Prometheus text format
+func MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		common.Metrics().WritePrometheus(w)
+	})
+}
+
+// writeJSON replies with v as JSON
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	common.WriteJSON(w, v)
+}
+
+// writeError replies with status and {"error": message}
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	common.WriteJSON(w, map[string]string{"error": message})
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/daite/tspider/common"
+	"golang.org/x/net/websocket"
+)
+
+// Event is a message of /api/stream. A search sends a "progress" event as
+// each site finishes and a "results" event for each site with results,
+// then ends with "done", carrying the number of distinct results, or
+// "error".
+type Event struct {
+	Type    string                `json:"type"`
+	Site    string                `json:"site,omitempty"`
+	Done    int                   `json:"done,omitempty"`
+	Total   int                   `json:"total,omitempty"`
+	Results []common.SearchResult `json:"results,omitempty"`
+	Count   int                   `json:"count,omitempty"`
+	Error   string                `json:"error,omitempty"`
+}
+
+// StreamFunc searches like SearchFunc, passing the progress and the results
+// of each site to send as they arrive. send may be called from several
+// goroutines at once.
+type StreamFunc func(ctx context.Context, keyword, lang string, send func(Event)) ([]common.SearchResult, error)
+
+// stream answers /api/stream?q=KEYWORD[&lang=kr|jp] with a WebSocket over
+// which the search streams its events. Browsers cannot set headers on a
+// WebSocket, so the API key goes in the apikey parameter. A browser
+// connecting from a page of another site is refused, see sameOrigin.
+func (a *API) stream(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	keyword := strings.TrimSpace(q.Get("q"))
+	if keyword == "" {
+		writeError(w, http.StatusBadRequest, "missing q parameter")
+		return
+	}
+	lang := q.Get("lang")
+	if lang == "" {
+		lang = a.Lang
+	}
+	if !common.ValidLanguage(lang) {
+		writeError(w, http.StatusBadRequest, "lang must be "+common.LanguageList())
+		return
+	}
+	srv := websocket.Server{Handshake: sameOrigin}
+	srv.Handler = func(ws *websocket.Conn) {
+		defer ws.Close()
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		// The client sends nothing; a failed read means it went away
+		go func() {
+			io.Copy(io.Discard, ws)
+			cancel()
+		}()
+		var mu sync.Mutex
+		send := func(e Event) {
+			if e.Results != nil {
+				e.Results = safeLinks(e.Results)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if websocket.JSON.Send(ws, e) != nil {
+				cancel()
+			}
+		}
+		results, err := a.Stream(ctx, keyword, lang, send)
+		if err != nil {
+			send(Event{Type: "error", Error: err.Error()})
+			return
+		}
+		send(Event{Type: "done", Count: len(results)})
+	}
+	srv.ServeHTTP(w, r)
+}
+
+// sameOrigin accepts a WebSocket opened by the web UI, whose Origin is the
+// host it was served from, or by a client that sends no Origin, which a
+// browser always does. Browsers do not apply the same-origin policy to
+// WebSockets, so without this any page could search through a server on
+// the user's machine or network.
+func sameOrigin(config *websocket.Config, r *http.Request) error {
+	origin, err := websocket.Origin(config, r)
+	if err != nil {
+		return err
+	}
+	if origin != nil && !strings.EqualFold(origin.Host, r.Host) {
+		return fmt.Errorf("origin %s does not match host %s", origin, r.Host)
+	}
+	return nil
+}
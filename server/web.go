@@ -0,0 +1,22 @@
+package server
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// webFiles is the web UI: a search page calling /api/search
+//
+//go:embed web
+var webFiles embed.FS
+
+// WebHandler serves the embedded web UI. It needs no API key itself; the
+// page asks for one when /api/search wants it.
+func WebHandler() http.Handler {
+	files, err := fs.Sub(webFiles, "web")
+	if err != nil {
+		panic(err)
+	}
+	return http.FileServer(http.FS(files))
+}
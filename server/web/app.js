@@ -0,0 +1,185 @@
+"use strict";
+
+const form = document.getElementById("search");
+const status = document.getElementById("status");
+const table = document.getElementById("results");
+const tbody = table.querySelector("tbody");
+
+// The API key, when the server wants one, is asked once and kept
+function apiKey() {
+  return localStorage.getItem("tspider-api-key") || "";
+}
+
+async function search(retried) {
+  const params = new URLSearchParams({
+    q: document.getElementById("q").value,
+    lang: document.getElementById("lang").value,
+    sort: document.getElementById("sort").value,
+  });
+  const resp = await fetch("api/search?" + params, {headers: {"X-Api-Key": apiKey()}});
+  if (resp.status === 401 && !retried) {
+    const key = prompt("API key");
+    if (key !== null) {
+      localStorage.setItem("tspider-api-key", key);
+      return search(true);
+    }
+  }
+  const body = await resp.json();
+  if (!resp.ok) {
+    throw new Error(body.error || resp.statusText);
+  }
+  return body;
+}
+
+// copyText copies text, falling back to a hidden text area where the
+// clipboard API is unavailable, as on plain HTTP from another machine
+async function copyText(text) {
+  if (navigator.clipboard && window.isSecureContext) {
+    return navigator.clipboard.writeText(text);
+  }
+  const area = document.createElement("textarea");
+  area.value = text;
+  area.style.position = "fixed";
+  area.style.opacity = "0";
+  document.body.appendChild(area);
+  area.select();
+  const ok = document.execCommand("copy");
+  area.remove();
+  if (!ok) {
+    throw new Error("copy failed");
+  }
+}
+
+function cell(row, text, className) {
+  const td = row.insertCell();
+  td.textContent = text;
+  if (className) {
+    td.className = className;
+  }
+  return td;
+}
+
+// webURL reports whether url is an http or https URL. Results are scraped
+// from sites that may be hostile, and a javascript: link would run in this
+// page, which holds the API key.
+function webURL(url) {
+  try {
+    const protocol = new URL(url).protocol;
+    return protocol === "http:" || protocol === "https:";
+  } catch (e) {
+    return false;
+  }
+}
+
+// magnetLink reports whether magnet is a magnet link
+function magnetLink(magnet) {
+  return typeof magnet === "string" && magnet.startsWith("magnet:?");
+}
+
+// addRows appends a row for each of results
+function addRows(results) {
+  for (const r of results) {
+    const row = tbody.insertRow();
+    const title = row.insertCell();
+    if (r.detail_url && webURL(r.detail_url)) {
+      const a = document.createElement("a");
+      a.href = r.detail_url;
+      a.target = "_blank";
+      a.rel = "noopener";
+      a.textContent = r.title;
+      title.appendChild(a);
+    } else {
+      title.textContent = r.title;
+    }
+    cell(row, r.site);
+    cell(row, r.size || "", "num");
+    cell(row, r.size ? (r.seeders || 0) + "/" + (r.leechers || 0) : "", "num");
+    const date = r.date && !r.date.startsWith("0001") ? r.date.slice(0, 10) : "";
+    cell(row, date, "num");
+
+    const actions = cell(row, "", "actions");
+    if (!magnetLink(r.magnet)) {
+      continue;
+    }
+    const copy = document.createElement("button");
+    copy.type = "button";
+    copy.textContent = "Copy magnet";
+    copy.addEventListener("click", async () => {
+      try {
+        await copyText(r.magnet);
+        copy.textContent = "Copied";
+      } catch (e) {
+        copy.textContent = "Copy failed";
+      }
+      setTimeout(() => { copy.textContent = "Copy magnet"; }, 1500);
+    });
+    actions.appendChild(copy);
+    const open = document.createElement("a");
+    open.href = r.magnet;
+    open.textContent = "Open";
+    actions.appendChild(open);
+  }
+  table.hidden = tbody.rows.length === 0;
+}
+
+// streamSearch shows the results of each site as it finishes, over the
+// /api/stream WebSocket. It resolves to false when the socket could not be
+// opened, such as for a missing API key, so the caller can fall back to
+// /api/search.
+function streamSearch() {
+  const params = new URLSearchParams({
+    q: document.getElementById("q").value,
+    lang: document.getElementById("lang").value,
+    apikey: apiKey(),
+  });
+  const scheme = location.protocol === "https:" ? "wss:" : "ws:";
+  const ws = new WebSocket(scheme + "//" + location.host + location.pathname.replace(/[^/]*$/, "") + "api/stream?" + params);
+  // Sites often list the same torrent; show it once
+  const seen = new Set();
+  return new Promise((resolve) => {
+    let opened = false;
+    ws.onopen = () => { opened = true; };
+    ws.onerror = () => {
+      if (!opened) {
+        resolve(false);
+      }
+    };
+    ws.onclose = () => resolve(true);
+    ws.onmessage = (msg) => {
+      const e = JSON.parse(msg.data);
+      switch (e.type) {
+      case "progress":
+        status.textContent = "Searching... " + e.done + "/" + e.total + " site(s) done";
+        break;
+      case "results":
+        addRows(e.results.filter((r) => !seen.has(r.magnet) && seen.add(r.magnet)));
+        break;
+      case "done":
+        status.textContent = e.count + " result(s) on " + params.get("lang") + " sites";
+        break;
+      case "error":
+        status.textContent = "Error: " + e.error;
+        break;
+      }
+    };
+  });
+}
+
+form.addEventListener("submit", async (event) => {
+  event.preventDefault();
+  status.textContent = "Searching...";
+  tbody.replaceChildren();
+  table.hidden = true;
+  // Streamed rows arrive site by site; other orders need the full result set
+  const relevance = document.getElementById("sort").value === "relevance";
+  if (relevance && "WebSocket" in window && await streamSearch()) {
+    return;
+  }
+  try {
+    const reply = await search(false);
+    status.textContent = reply.count + " result(s) for " + reply.query + " on " + reply.lang + " sites";
+    addRows(reply.results);
+  } catch (e) {
+    status.textContent = "Error: " + e.message;
+  }
+});
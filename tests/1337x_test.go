@@ -0,0 +1,135 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/daite/tspider/common"
+	"github.com/daite/tspider/etorrent"
+)
+
+func TestX1337Results(t *testing.T) {
+	useTempHome(t)
+	f, err := os.Open("../resources/1337x_search.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	doc, err := goquery.NewDocumentFromReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := etorrent.X1337Results(doc, "https://1337x.to/search/nijiiro+days/1/")
+	want := []common.SearchResult{
+		{
+			Title:     "Nijiiro.Days.S01E01.1080p.WEB.H264",
+			DetailURL: "https://1337x.to/torrent/6021345/Nijiiro-Days-S01E01-1080p-WEB-H264/",
+			Uploader:  "TvTeam",
+			Seeders:   845,
+			Leechers:  62,
+			Size:      "1.4 GB",
+			Date:      time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Title:     "Nijiiro.Days.S01E01.720p.HEVC.x265",
+			DetailURL: "https://1337x.to/torrent/6019876/Nijiiro-Days-S01E01-720p-HEVC-x265/",
+			Uploader:  "hevcguy",
+			Seeders:   120,
+			Leechers:  7,
+			Size:      "412.6 MB",
+			Date:      time.Date(2026, 5, 3, 0, 0, 0, 0, time.UTC),
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("X1337Results() = %+v, want %+v", got, want)
+	}
+}
+
+func TestX1337Detail(t *testing.T) {
+	useTempHome(t)
+	f, err := os.Open("../resources/1337x_bbs.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	doc, err := goquery.NewDocumentFromReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "magnet:?xt=urn:btih:9A3F2C1D8E7B6A5F4E3D2C1B0A9F8E7D6C5B4A3F&dn=Nijiiro.Days.S01E01.1080p.WEB.H264&tr=udp%3A%2F%2Ftracker.opentrackr.org%3A1337%2Fannounce"
+	if got := etorrent.X1337Magnet(doc); got != want {
+		t.Errorf("X1337Magnet() = %q, want %q", got, want)
+	}
+	if got := etorrent.X1337Category(doc); got != "TV" {
+		t.Errorf("X1337Category() = %q, want TV", got)
+	}
+}
+
+func TestX1337Crawl(t *testing.T) {
+	useTempHome(t)
+	search, err := os.ReadFile("../resources/1337x_search.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	detail, err := os.ReadFile("../resources/1337x_bbs.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var searched string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/search/"):
+			searched = r.URL.Path
+			w.Write(search)
+		case strings.HasPrefix(r.URL.Path, "/torrent/"):
+			w.Write(detail)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	c := common.DefaultConfig()
+	c.Sites["1337x"] = common.SiteConfig{URL: srv.URL, Language: "en", Enabled: true}
+	if err := common.SaveConfig(c); err != nil {
+		t.Fatal(err)
+	}
+
+	got := (&etorrent.X1337{}).Crawl(context.Background(), "nijiiro days")
+	if searched != "/search/nijiiro+days/1/" {
+		t.Errorf("searched %q, want the keyword in the path", searched)
+	}
+	sort.Slice(got, func(i, j int) bool { return got[i].Title < got[j].Title })
+	if len(got) != 2 {
+		t.Fatalf("Crawl() = %+v, want 2 results", got)
+	}
+	for _, r := range got {
+		if !strings.HasPrefix(r.Magnet, "magnet:?xt=urn:btih:9A3F2C1D") || r.Category != "TV" {
+			t.Errorf("Crawl() result %q has magnet %q and category %q, want those of the detail page", r.Title, r.Magnet, r.Category)
+		}
+	}
+	if got[0].DetailURL != srv.URL+"/torrent/6021345/Nijiiro-Days-S01E01-1080p-WEB-H264/" {
+		t.Errorf("Crawl()[0].DetailURL = %q", got[0].DetailURL)
+	}
+}
+
+func TestValidLanguage(t *testing.T) {
+	for _, lang := range []string{"kr", "jp", "en"} {
+		if !common.ValidLanguage(lang) {
+			t.Errorf("ValidLanguage(%q) = false, want true", lang)
+		}
+	}
+	if common.ValidLanguage("fr") {
+		t.Errorf("ValidLanguage(fr) = true, want false")
+	}
+	if got := common.LanguageList(); got != "kr, jp or en" {
+		t.Errorf("LanguageList() = %q", got)
+	}
+}
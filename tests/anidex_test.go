@@ -0,0 +1,103 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/daite/tspider/common"
+	"github.com/daite/tspider/jtorrent"
+)
+
+// aniDexWant is what resources/anidex_search.html lists: a batch with a
+// truncated title, an anonymous upload, a removed torrent without a magnet,
+// which is left out, and a dead manga batch
+func aniDexWant(base string) []common.SearchResult {
+	return []common.SearchResult{
+		{
+			Title:     "[Erai-raws] Sousou no Frieren - 01 ~ 28 [1080p][Multiple Subtitle][ENG][POR-BR][SPA-LA][SPA][ARA][FRE][GER][ITA][RUS] (Batch)",
+			Magnet:    "magnet:?xt=urn:btih:3c9d5e71a0b24f68c1e93d07b5a2f4e68d1c0b97&tr=http%3A%2F%2Fanidex.moe%3A6969%2Fannounce&tr=udp%3A%2F%2Ftracker.opentrackr.org%3A1337%2Fannounce",
+			Uploader:  "Erai-raws",
+			Seeders:   1204,
+			Leechers:  87,
+			Snatches:  15932,
+			Size:      "38.21 GB",
+			Category:  "Anime - Sub",
+			DetailURL: base + "/torrent/845102",
+			Date:      time.Date(2026, 10, 12, 9, 15, 30, 0, time.UTC),
+		},
+		{
+			Title:     "Sousou no Frieren S2 - 03 RAW (CX 1920x1080 HEVC AAC).mkv",
+			Magnet:    "magnet:?xt=urn:btih:9e04b7c2d1f835a6e0c4b9d27f18a3e5c6b0d4f2&tr=http%3A%2F%2Fanidex.moe%3A6969%2Fannounce",
+			Seeders:   57,
+			Leechers:  4,
+			Snatches:  310,
+			Size:      "1,024.5 MB",
+			Category:  "Anime - Raw",
+			DetailURL: base + "/torrent/845377",
+			Date:      time.Date(2026, 10, 14, 23, 48, 2, 0, time.UTC),
+		},
+		{
+			Title:     "Frieren - Beyond Journey's End v01-12 (2021-2024) (Digital) (1r0n)",
+			Magnet:    "magnet:?xt=urn:btih:d58a2e0f6c3b1947a8e5d0c2b6f4a1e93c7d5b08&tr=http%3A%2F%2Fanidex.moe%3A6969%2Fannounce",
+			Uploader:  "1r0n",
+			Size:      "2.3 GB",
+			Category:  "Manga - Eng",
+			DetailURL: base + "/torrent/843660",
+			Date:      time.Date(2026, 9, 30, 4, 6, 11, 0, time.UTC),
+		},
+	}
+}
+
+func TestAniDexResults(t *testing.T) {
+	useTempHome(t)
+	f, err := os.Open("../resources/anidex_search.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	doc, err := goquery.NewDocumentFromReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := jtorrent.AniDexResults(doc, "https://anidex.info/?q=frieren")
+	if want := aniDexWant("https://anidex.info"); !reflect.DeepEqual(got, want) {
+		t.Errorf("AniDexResults() = %+v, want %+v", got, want)
+	}
+}
+
+func TestAniDexCrawl(t *testing.T) {
+	useTempHome(t)
+	page, err := os.ReadFile("../resources/anidex_search.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var query string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		query = r.URL.Query().Get("q")
+		w.Write(page)
+	}))
+	defer srv.Close()
+	c := common.DefaultConfig()
+	c.Sites["anidex"] = common.SiteConfig{URL: srv.URL, Language: "jp", Enabled: true}
+	if err := common.SaveConfig(c); err != nil {
+		t.Fatal(err)
+	}
+
+	got := (&jtorrent.AniDex{}).Crawl(context.Background(), "sousou no frieren")
+	if query != "sousou no frieren" {
+		t.Errorf("searched for %q, want %q", query, "sousou no frieren")
+	}
+	if want := aniDexWant(srv.URL); !reflect.DeepEqual(got, want) {
+		t.Errorf("Crawl() = %+v, want %+v", got, want)
+	}
+}
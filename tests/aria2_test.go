@@ -0,0 +1,98 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/daite/tspider/clients"
+	"github.com/daite/tspider/common"
+)
+
+// fakeAria2 emulates aria2.addUri and aria2.tellActive of the aria2 JSON-RPC
+// interface
+type fakeAria2 struct {
+	secret string
+	params [][]json.RawMessage
+}
+
+func (f *fakeAria2) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	if f.secret != "" && (len(req.Params) == 0 || string(req.Params[0]) != `"token:`+f.secret+`"`) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"jsonrpc": "2.0", "id": "tspider", "error": {"code": 1, "message": "Unauthorized"}}`))
+		return
+	}
+	if req.Method == "aria2.tellActive" {
+		w.Write([]byte(`{"jsonrpc": "2.0", "id": "tspider", "result": [{"gid": "2089b05ecca3d829",
+			"status": "active", "infoHash": "abc", "totalLength": "2048", "completedLength": "1024",
+			"downloadSpeed": "1024", "bittorrent": {"info": {"name": "Ubuntu"}}}]}`))
+		return
+	}
+	f.params = append(f.params, req.Params)
+	w.Write([]byte(`{"jsonrpc": "2.0", "id": "tspider", "result": "2089b05ecca3d829"}`))
+}
+
+func useAria2(t *testing.T, url, secret string) {
+	useTempHome(t)
+	c := common.DefaultConfig()
+	c.Clients = map[string]common.ClientConfig{"aria2": {URL: url, Secret: secret}}
+	if err := common.SaveConfig(c); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAria2AddMagnets(t *testing.T) {
+	fake := &fakeAria2{secret: "s3cret"}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	useAria2(t, srv.URL, "s3cret")
+
+	client, err := clients.New("aria2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.AddMagnet("magnet:?xt=urn:btih:a", clients.AddOptions{SavePath: "/data"}); err != nil {
+		t.Fatalf("AddMagnet() error = %v", err)
+	}
+	if len(fake.params) != 1 || len(fake.params[0]) != 3 {
+		t.Fatalf("params = %s, want token, uris and options", fake.params)
+	}
+	if got := string(fake.params[0][1]); got != `["magnet:?xt=urn:btih:a"]` {
+		t.Errorf("uris = %s, want the magnet", got)
+	}
+	if got := string(fake.params[0][2]); got != `{"dir":"/data"}` {
+		t.Errorf("options = %s, want the download dir", got)
+	}
+}
+
+func TestAria2WrongSecret(t *testing.T) {
+	srv := httptest.NewServer(&fakeAria2{secret: "s3cret"})
+	defer srv.Close()
+	useAria2(t, srv.URL, "wrong")
+
+	err := clients.NewAria2(common.ClientConfig{URL: srv.URL, Secret: "wrong"}).TestConnection()
+	if err == nil || !strings.Contains(err.Error(), "clients.aria2.secret") {
+		t.Errorf("TestConnection() error = %v, want a secret error", err)
+	}
+}
+
+func TestAria2ListActive(t *testing.T) {
+	srv := httptest.NewServer(&fakeAria2{})
+	defer srv.Close()
+
+	got, err := clients.NewAria2(common.ClientConfig{URL: srv.URL}).ListActive()
+	if err != nil {
+		t.Fatalf("ListActive() error = %v", err)
+	}
+	want := clients.Torrent{Name: "Ubuntu", Hash: "abc", Progress: 0.5, State: "active", DownloadRate: 1024}
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("ListActive() = %+v, want [%+v]", got, want)
+	}
+}
@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/daite/tspider/common"
+)
+
+func TestAvailabilityCacheExpires(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	cache := common.NewAvailabilityCache(time.Minute, "")
+	cache.Now = func() time.Time { return now }
+
+	if _, ok := cache.Get("https://nyaa.si"); ok {
+		t.Fatalf("Get() on an empty cache hit")
+	}
+	cache.Set("https://nyaa.si", true)
+	cache.Set("https://dead.example", false)
+
+	now = now.Add(59 * time.Second)
+	if up, ok := cache.Get("https://nyaa.si"); !ok || !up {
+		t.Errorf("Get() after 59s = %v, %v; want a cached up", up, ok)
+	}
+	if up, ok := cache.Get("https://dead.example"); !ok || up {
+		t.Errorf("Get() after 59s = %v, %v; want a cached down", up, ok)
+	}
+
+	now = now.Add(time.Second)
+	if _, ok := cache.Get("https://nyaa.si"); ok {
+		t.Errorf("Get() after the TTL hit, want a miss")
+	}
+}
+
+func TestAvailabilityCachePersistsAndResets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "availability.json")
+	cache := common.NewAvailabilityCache(time.Minute, path)
+	var wg sync.WaitGroup
+	for _, url := range []string{"https://a.example", "https://b.example", "https://c.example"} {
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+			cache.Set(url, true)
+		}(url)
+	}
+	wg.Wait()
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := common.NewAvailabilityCache(time.Minute, path)
+	if up, ok := reloaded.Get("https://b.example"); !ok || !up {
+		t.Errorf("Get() after reload = %v, %v; want a cached up", up, ok)
+	}
+	reloaded.Reset()
+	if _, ok := reloaded.Get("https://b.example"); ok {
+		t.Errorf("Get() after Reset() hit, want a miss")
+	}
+}
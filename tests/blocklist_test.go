@@ -0,0 +1,51 @@
+package tests
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/daite/tspider/common"
+)
+
+func TestAddBlockRejectsInvalidRegex(t *testing.T) {
+	useTempHome(t)
+	if err := common.AddBlock("(unclosed"); err == nil {
+		t.Errorf("AddBlock() with invalid regex = nil, want error")
+	}
+	if err := common.AddBlock(`(?i)\bcam\b`); err != nil {
+		t.Fatalf("AddBlock() = %v", err)
+	}
+	if got := common.GetConfig().Blocklist; !reflect.DeepEqual(got, []string{`(?i)\bcam\b`}) {
+		t.Errorf("Blocklist = %q, want only the valid pattern", got)
+	}
+	if err := common.RemoveBlock(`(?i)\bcam\b`); err != nil {
+		t.Errorf("RemoveBlock() = %v", err)
+	}
+	if got := common.GetConfig().Blocklist; len(got) != 0 {
+		t.Errorf("Blocklist after RemoveBlock() = %q, want empty", got)
+	}
+}
+
+func TestExcludeResults(t *testing.T) {
+	patterns, err := common.CompilePatterns([]string{`(?i)\bcam\b`, `^Show_EP01`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := []common.SearchResult{
+		{Title: "Movie 2024 CAM"},
+		{Title: "Movie 2024 1080p"},
+		{Title: "Show EP01"},
+		{Title: "Show EP02"},
+	}
+	var got []string
+	for _, r := range common.ExcludeResults(results, patterns) {
+		got = append(got, r.Title)
+	}
+	want := []string{"Movie 2024 1080p", "Show EP02"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ExcludeResults() = %q, want %q", got, want)
+	}
+	if _, err := common.CompilePatterns([]string{"["}); err == nil {
+		t.Errorf("CompilePatterns() with invalid regex = nil, want error")
+	}
+}
@@ -0,0 +1,153 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/daite/tspider/common"
+	"github.com/daite/tspider/ktorrent"
+)
+
+func TestBoardScraperCrawl(t *testing.T) {
+	useTempHome(t)
+	search, err := os.ReadFile("../resources/torrentsome_search.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	detail, err := os.ReadFile("../resources/torrentsome_bbs.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var query string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/find":
+			query = r.URL.Query().Get("q")
+			w.Write(search)
+		case strings.HasPrefix(r.URL.Path, "/v/"):
+			w.Write(detail)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	site := common.SiteConfig{
+		URL:        srv.URL,
+		Language:   "kr",
+		Enabled:    true,
+		Type:       common.SiteTypeBoard,
+		Layout:     common.BoardLayoutTopic,
+		SearchPath: "/find?q={keyword}",
+	}
+	c := common.DefaultConfig()
+	c.Sites["somemirror"] = site
+	if err := common.SaveConfig(c); err != nil {
+		t.Fatal(err)
+	}
+	board, err := ktorrent.NewBoardScraper("somemirror", site)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := board.Crawl(context.Background(), "동상이몽 2")
+	if query != "동상이몽 2" {
+		t.Errorf("search page queried for %q, want the escaped keyword", query)
+	}
+	if len(got) < 2 {
+		t.Fatalf("Crawl() found %d results, want the whole list", len(got))
+	}
+	want := common.SearchResult{
+		Title:     "동상이몽2 너는 내운명_E186_210301",
+		Magnet:    "magnet:?xt=urn:btih:08a1b53bcb809a94c2ce9582bb4d70b6a4ad4460",
+		DetailURL: srv.URL + "/v/123335",
+		Size:      "2.33 GB",
+	}
+	found := false
+	for _, r := range got {
+		if r.Title == want.Title {
+			found = true
+			if r != want {
+				t.Errorf("Crawl() result = %+v, want %+v", r, want)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("Crawl() = %+v, want %q among the results", got, want.Title)
+	}
+}
+
+func TestNewBoardScraperTakesTitleAttr(t *testing.T) {
+	useTempHome(t)
+	board, err := ktorrent.NewBoardScraper("mirror", common.SiteConfig{
+		Layout:     common.BoardLayoutGnuboard,
+		TitleAttr:  "data-title",
+		SearchPath: "/bbs/search.php?stx={keyword}",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ktorrent.Gnuboard
+	want.TitleAttr = "data-title"
+	if board.Layout != want {
+		t.Errorf("Layout = %+v, want %+v", board.Layout, want)
+	}
+}
+
+func TestNewBoardScraperRejectsIncompleteSites(t *testing.T) {
+	useTempHome(t)
+	sites := map[string]common.SiteConfig{
+		"no keyword":     {Layout: common.BoardLayoutGnuboard, SearchPath: "/bbs/search.php"},
+		"unknown layout": {Layout: "phpbb", SearchPath: "/search?q={keyword}"},
+		"no selectors":   {ListSelector: "a.subject", SearchPath: "/search?q={keyword}"},
+	}
+	for name, site := range sites {
+		if _, err := ktorrent.NewBoardScraper(name, site); err == nil {
+			t.Errorf("NewBoardScraper(%s) = nil error, want one", name)
+		}
+	}
+	if _, err := ktorrent.NewBoardScraper("selectors", common.SiteConfig{
+		ListSelector:   "a.subject",
+		MagnetSelector: "a.magnet",
+		SearchPath:     "/search?q={keyword}",
+	}); err != nil {
+		t.Errorf("NewBoardScraper() with both selectors = %v, want nil", err)
+	}
+}
+
+func TestValidateConfigChecksBoards(t *testing.T) {
+	c := &common.Config{
+		Sites: map[string]common.SiteConfig{
+			"board": {URL: "https://example.com", Language: "kr", Type: common.SiteTypeBoard, Layout: "phpbb", SearchPath: "/search?q={keyword}"},
+			"other": {URL: "https://example.org", Language: "kr", Type: "rss"},
+		},
+		Timeout: 10,
+	}
+	got := common.ValidateConfig(c)
+	want := []string{
+		`site board: layout "phpbb" is not one of [gnuboard topic]`,
+		`site other: type "rss" is not torznab or board`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ValidateConfig() = %q, want %q", got, want)
+	}
+}
+
+func TestAddBoardSite(t *testing.T) {
+	useTempHome(t)
+	if err := common.AddBoardSite("mirror", common.SiteConfig{URL: "https://example.com", Language: "kr", SearchPath: "/search"}); err == nil {
+		t.Errorf("AddBoardSite() without {keyword} = nil, want error")
+	}
+	site := common.SiteConfig{URL: "https://example.com", Language: "kr", Layout: common.BoardLayoutGnuboard, SearchPath: "/bbs/search.php?stx={keyword}"}
+	if err := common.AddBoardSite("mirror", site); err != nil {
+		t.Fatal(err)
+	}
+	got := common.GetConfig().Sites["mirror"]
+	if got.Type != common.SiteTypeBoard || !got.Enabled || got.SearchPath != site.SearchPath || got.Layout != site.Layout {
+		t.Errorf("AddBoardSite() saved %+v", got)
+	}
+}
@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/daite/tspider/common"
+)
+
+func TestResponseBodyIsCapped(t *testing.T) {
+	useTempHome(t)
+	useRetries(t, 0)
+	page := "<html><body>" + strings.Repeat("<p>padding</p>", 200<<10) + "</body></html>"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/announced" {
+			w.Header().Set("Content-Length", "2097152")
+			io.WriteString(w, page[:2<<20])
+			return
+		}
+		// Flush before writing so the length is not known up front
+		w.(http.Flusher).Flush()
+		io.WriteString(w, page)
+	}))
+	defer srv.Close()
+
+	c := common.DefaultConfig()
+	c.MaxBodyMB = 1
+	if err := common.SaveConfig(c); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := common.GetResponseFromURL(context.Background(), srv.URL+"/announced"); ok {
+		t.Errorf("GetResponseFromURL(2 MB Content-Length) ok = true, want the page refused")
+	}
+
+	resp, ok := common.GetResponseFromURL(context.Background(), srv.URL+"/streamed")
+	if !ok {
+		t.Fatalf("GetResponseFromURL(streamed) ok = false")
+	}
+	defer resp.Body.Close()
+	if _, err := goquery.NewDocumentFromReader(resp.Body); !errors.Is(err, common.ErrBodyTooLarge) {
+		t.Errorf("parsing a %d byte page err = %v, want %v", len(page), err, common.ErrBodyTooLarge)
+	}
+}
+
+func TestResponseBodyAtLimitIsRead(t *testing.T) {
+	useTempHome(t)
+	page := strings.Repeat("x", 1<<20)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.(http.Flusher).Flush()
+		io.WriteString(w, page)
+	}))
+	defer srv.Close()
+
+	c := common.DefaultConfig()
+	c.MaxBodyMB = 1
+	if err := common.SaveConfig(c); err != nil {
+		t.Fatal(err)
+	}
+	resp, ok := common.GetResponseFromURL(context.Background(), srv.URL)
+	if !ok {
+		t.Fatalf("GetResponseFromURL() ok = false")
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil || len(body) != len(page) {
+		t.Errorf("ReadAll() = %d bytes, %v; want %d bytes", len(body), err, len(page))
+	}
+}
@@ -0,0 +1,74 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/daite/tspider/common"
+)
+
+// pagedSite fetches its detail pages one after the other, naming each
+// result after the page, marked "failed" when its fetch failed
+type pagedSite struct {
+	pages []string
+}
+
+func (d pagedSite) Crawl(ctx context.Context, keyword string) []common.SearchResult {
+	var results []common.SearchResult
+	for _, page := range d.pages {
+		title := page
+		if resp, ok := common.GetResponseFromURL(ctx, page); ok {
+			resp.Body.Close()
+		} else {
+			title += " failed"
+		}
+		results = append(results, common.SearchResult{Title: title, Magnet: "magnet:?xt=urn:btih:" + page})
+	}
+	return results
+}
+
+func TestCollectDataSkipsPagesAfterSiteFails(t *testing.T) {
+	useTempHome(t)
+	var requested []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		switch r.URL.Path {
+		case "/gone":
+			w.WriteHeader(http.StatusNotFound)
+		case "/broken":
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+	c := common.DefaultConfig()
+	c.MaxRetries = -1
+	c.Sites = map[string]common.SiteConfig{"flaky": {URL: srv.URL, Enabled: true, Language: "kr"}}
+	if err := common.SaveConfig(c); err != nil {
+		t.Fatal(err)
+	}
+
+	pages := []string{srv.URL + "/ok", srv.URL + "/gone", srv.URL + "/broken", srv.URL + "/later", srv.URL + "/last"}
+	spinner := common.NewSpinner("test")
+	got, _ := common.CollectData(context.Background(), map[string]common.Scraper{"flaky": pagedSite{pages}}, "x", spinner)
+	// A missing page does not trip the breaker, a 502 does
+	if want := []string{"/ok", "/gone", "/broken"}; !reflect.DeepEqual(requested, want) {
+		t.Errorf("requested %v, want %v", requested, want)
+	}
+	if len(got) != 5 {
+		t.Errorf("CollectData() returned %d results, want the 5 of the degraded site", len(got))
+	}
+	if degraded := spinner.Degraded(); !reflect.DeepEqual(degraded, []string{"flaky"}) {
+		t.Errorf("Degraded() = %v, want [flaky]", degraded)
+	}
+
+	// Each search starts with the breaker closed
+	requested = nil
+	spinner = common.NewSpinner("test")
+	common.CollectData(context.Background(), map[string]common.Scraper{"flaky": pagedSite{pages[:1]}}, "x", spinner)
+	if len(requested) != 1 || len(spinner.Degraded()) != 0 {
+		t.Errorf("second search requested %v, degraded %v; want /ok fetched and nothing degraded", requested, spinner.Degraded())
+	}
+}
@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/daite/tspider/common"
+)
+
+func browseResults(n int) []common.SearchResult {
+	results := make([]common.SearchResult, n)
+	for i := range results {
+		results[i] = common.SearchResult{
+			Site:   "nyaa",
+			Title:  fmt.Sprintf("Show %02d", i+1),
+			Magnet: fmt.Sprintf("magnet:?xt=urn:btih:%040d", i+1),
+		}
+	}
+	results[4].Title = "Special 05 [1080p]"
+	return results
+}
+
+func runBrowser(t *testing.T, results []common.SearchResult, input string) (string, []string, []string) {
+	var out bytes.Buffer
+	var copied, opened []string
+	b := &common.Browser{
+		Results:  results,
+		Columns:  common.DataColumns,
+		In:       strings.NewReader(input),
+		Out:      &out,
+		PageSize: 3,
+		Copy:     func(m string) error { copied = append(copied, m); return nil },
+		Open:     func(m string) error { opened = append(opened, m); return nil },
+	}
+	if err := b.Run(); err != nil {
+		t.Fatal(err)
+	}
+	return out.String(), copied, opened
+}
+
+func TestBrowserPagesAndCopies(t *testing.T) {
+	out, copied, opened := runBrowser(t, browseResults(7), "n\nc 4\no 1\nq\n")
+	if !strings.Contains(out, "Page 1/3") || !strings.Contains(out, "Page 2/3") {
+		t.Errorf("Browser output lacks page 1 and 2:\n%s", out)
+	}
+	if len(copied) != 1 || copied[0] != fmt.Sprintf("magnet:?xt=urn:btih:%040d", 4) {
+		t.Errorf("copied = %q, want the magnet of result 4", copied)
+	}
+	if len(opened) != 1 || opened[0] != fmt.Sprintf("magnet:?xt=urn:btih:%040d", 1) {
+		t.Errorf("opened = %q, want the magnet of result 1", opened)
+	}
+}
+
+func TestBrowserFilterRenumbers(t *testing.T) {
+	out, copied, _ := runBrowser(t, browseResults(7), "/special\nc 1\nd 1\n/\n")
+	if !strings.Contains(out, "Page 1/1, 1 result(s)") {
+		t.Errorf("Browser output lacks the filtered page:\n%s", out)
+	}
+	if len(copied) != 1 || copied[0] != fmt.Sprintf("magnet:?xt=urn:btih:%040d", 5) {
+		t.Errorf("copied = %q, want the magnet of the only filtered result", copied)
+	}
+	if !strings.Contains(out, "Title:    Special 05 [1080p]") {
+		t.Errorf("Browser output lacks the details of the filtered result:\n%s", out)
+	}
+	if !strings.Contains(out, "Page 1/3, 7 result(s)") {
+		t.Errorf("Browser output does not show all results after clearing the filter:\n%s", out)
+	}
+}
+
+func TestBrowserRejectsBadRows(t *testing.T) {
+	out, copied, opened := runBrowser(t, browseResults(7), "c 9\no 2-x\nd 9\nx\nq\n")
+	if len(copied) != 0 || len(opened) != 0 {
+		t.Errorf("copied = %q, opened = %q, want nothing for bad rows", copied, opened)
+	}
+	for _, want := range []string{"row 9 is out of range 1-7", `invalid selection "2-x"`, `no result "9"`, `unknown command "x"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Browser output lacks %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestBrowserActsOnSelections(t *testing.T) {
+	results := browseResults(7)
+	results[2].Magnet = "no magnet"
+	out, copied, opened := runBrowser(t, results, "c 1,3,5-6\no 6-7\nq\n")
+	magnet := func(n int) string { return fmt.Sprintf("magnet:?xt=urn:btih:%040d", n) }
+	if want := []string{magnet(1) + "\n" + magnet(5) + "\n" + magnet(6)}; !reflect.DeepEqual(copied, want) {
+		t.Errorf("copied = %q, want %q", copied, want)
+	}
+	if want := []string{magnet(6), magnet(7)}; !reflect.DeepEqual(opened, want) {
+		t.Errorf("opened = %q, want %q", opened, want)
+	}
+	for _, want := range []string{
+		"[!] 3 Show 03: no magnet",
+		"[+] 3 of 4 magnets copied to the clipboard",
+		"[+] 6 Show 06: opened",
+		"[+] 7 Show 07: opened",
+		"[+] 2 of 2 magnets opened",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Browser output lacks %q:\n%s", want, out)
+		}
+	}
+}
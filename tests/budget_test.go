@@ -0,0 +1,55 @@
+package tests
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/daite/tspider/common"
+)
+
+type slowSite struct {
+	delay time.Duration
+}
+
+func (s slowSite) Crawl(ctx context.Context, keyword string) []common.SearchResult {
+	time.Sleep(s.delay)
+	return []common.SearchResult{{Title: keyword + " slow", Magnet: "magnet:?xt=urn:btih:slow"}}
+}
+
+func TestCollectDataAbandonsSiteOverBudget(t *testing.T) {
+	useTempHome(t)
+	c := common.DefaultConfig()
+	c.Sites["slow"] = common.SiteConfig{URL: "https://slow.example", Enabled: true, Language: "kr", Timeout: 0.1}
+	common.SaveConfig(c)
+
+	sites := map[string]common.Scraper{"fast": fastSite{}, "slow": slowSite{2 * time.Second}}
+	spinner := common.NewSpinner("test")
+	start := time.Now()
+	got, _ := common.CollectData(context.Background(), sites, "test", spinner)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("CollectData() took %s, want the slow site abandoned after its 100ms budget", elapsed)
+	}
+	want := []common.SearchResult{{Site: "fast", Title: "test fast", Magnet: "magnet:?xt=urn:btih:fast"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CollectData() = %+v, want %+v", got, want)
+	}
+	if abandoned := spinner.Abandoned(); !reflect.DeepEqual(abandoned, []string{"slow"}) {
+		t.Errorf("Abandoned() = %v, want [slow]", abandoned)
+	}
+}
+
+func TestCollectDataKeepsSiteWithinBudget(t *testing.T) {
+	useTempHome(t)
+	c := common.DefaultConfig()
+	c.Sites["slow"] = common.SiteConfig{URL: "https://slow.example", Enabled: true, Language: "kr", Timeout: 5}
+	common.SaveConfig(c)
+
+	sites := map[string]common.Scraper{"slow": slowSite{50 * time.Millisecond}}
+	spinner := common.NewSpinner("test")
+	got, _ := common.CollectData(context.Background(), sites, "test", spinner)
+	if len(got) != 1 || len(spinner.Abandoned()) != 0 {
+		t.Errorf("CollectData() = %+v, abandoned %v; want the site's result and nothing abandoned", got, spinner.Abandoned())
+	}
+}
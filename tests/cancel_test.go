@@ -0,0 +1,104 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/daite/tspider/common"
+)
+
+// stalledServer accepts requests and answers none until the client gives
+// up, which it reports on aborted
+func stalledServer(t *testing.T) (*httptest.Server, chan struct{}) {
+	aborted := make(chan struct{}, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		aborted <- struct{}{}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, aborted
+}
+
+func TestGetResponseFromURLCanceled(t *testing.T) {
+	useTempHome(t)
+	srv, aborted := stalledServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	if _, ok := common.GetResponseFromURL(ctx, srv.URL); ok {
+		t.Fatalf("GetResponseFromURL() ok = true after cancel")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("GetResponseFromURL() returned after %s, want at once on cancel", elapsed)
+	}
+	select {
+	case <-aborted:
+	case <-time.After(time.Second):
+		t.Errorf("the request was not aborted")
+	}
+}
+
+func TestRetryStopsOnCancel(t *testing.T) {
+	useRetries(t, 5)
+	common.RetryBackoff = time.Minute
+	srv, hits := flakyServer(t, http.StatusServiceUnavailable, 100)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	common.GetResponseFromURL(ctx, srv.URL)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("GetResponseFromURL() returned after %s, want the backoff cut short", elapsed)
+	}
+	if *hits != 1 {
+		t.Errorf("server hits = %d, want no retry after cancel", *hits)
+	}
+}
+
+type stalledSite struct{ url string }
+
+func (s stalledSite) Crawl(ctx context.Context, keyword string) []common.SearchResult {
+	if resp, ok := common.GetResponseFromURL(ctx, s.url); ok {
+		resp.Body.Close()
+	}
+	return nil
+}
+
+func TestCollectDataCancelsRequests(t *testing.T) {
+	useTempHome(t)
+	srv, aborted := stalledServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	sites := map[string]common.Scraper{"fast": fastSite{}, "stalled": stalledSite{srv.URL}}
+	got, _ := common.CollectData(ctx, sites, "test", common.NewSpinner("test"))
+	if len(got) != 1 || got[0].Site != "fast" {
+		t.Errorf("CollectData() = %+v, want the fast site's result", got)
+	}
+	select {
+	case <-aborted:
+	case <-time.After(time.Second):
+		t.Errorf("the stalled site's request was not aborted")
+	}
+}
+
+func TestDoctorCanceled(t *testing.T) {
+	useTempHome(t)
+	srv, _ := stalledServer(t)
+	c := common.DefaultConfig()
+	c.Sites = map[string]common.SiteConfig{"stalled": {URL: srv.URL, Enabled: true, Language: "kr"}}
+	if err := common.SaveConfig(c); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	statuses := common.Doctor(ctx, "")
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Doctor() returned after %s, want at once on cancel", elapsed)
+	}
+	if len(statuses) != 1 || statuses[0].Available || statuses[0].Error == "" {
+		t.Errorf("Doctor() = %+v, want the stalled site unavailable with an error", statuses)
+	}
+}
@@ -0,0 +1,185 @@
+package tests
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/daite/tspider/common"
+)
+
+// buildTspider builds the tspider binary into a temporary directory
+func buildTspider(t *testing.T) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("builds the tspider binary")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found")
+	}
+	bin := filepath.Join(t.TempDir(), "tspider")
+	if out, err := exec.Command(goTool, "build", "-o", bin, "../cmd/tspider").CombinedOutput(); err != nil {
+		t.Fatalf("go build: %v\n%s", err, out)
+	}
+	return bin
+}
+
+// runTspider runs the tspider binary at bin with args, its config in home
+// and its cache under it, and returns what it printed on stdout and stderr
+func runTspider(bin, home string, args ...string) (string, string, error) {
+	cmd := exec.Command(bin, args...)
+	cmd.Env = append(os.Environ(), "HOME="+home, "USERPROFILE="+home, "XDG_CACHE_HOME="+filepath.Join(home, ".cache"))
+	var stdout, stderr strings.Builder
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	return stdout.String(), stderr.String(), err
+}
+
+// nyaaOnly points a temporary home at a config where nyaa, served by a stub
+// answering every search with the nyaa fixture, is the only enabled site. It
+// returns the home and a function listing the requests the stub got.
+func nyaaOnly(t *testing.T) (string, func() []string) {
+	home := useTempHome(t)
+	page, err := os.ReadFile("../resources/nyaa_search.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.URL.RequestURI())
+		mu.Unlock()
+		if strings.HasPrefix(r.URL.Path, "/view/") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(page)
+	}))
+	t.Cleanup(srv.Close)
+	c := common.DefaultConfig()
+	for name, site := range c.Sites {
+		site.Enabled = false
+		c.Sites[name] = site
+	}
+	c.Sites["nyaa"] = common.SiteConfig{URL: srv.URL, Language: "jp", Enabled: true}
+	if err := common.SaveConfig(c); err != nil {
+		t.Fatal(err)
+	}
+	return home, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), requests...)
+	}
+}
+
+// TestUploaderWithoutKeyword runs the tspider binary with --uploader and no
+// keyword, both as the default action and as the search command, and checks
+// that it searches the uploader's listing instead of showing the help or
+// asking for a keyword
+func TestUploaderWithoutKeyword(t *testing.T) {
+	bin := buildTspider(t)
+	home, requests := nyaaOnly(t)
+	for _, args := range [][]string{
+		{"--lang", "jp", "--uploader", "Erai-raws", "--json", "--no-cache"},
+		{"search", "--lang", "jp", "--uploader", "Erai-raws", "--json", "--no-cache"},
+	} {
+		before := len(requests())
+		stdout, stderr, err := runTspider(bin, home, args...)
+		if err != nil {
+			t.Errorf("tspider %s: %v\n%s%s", strings.Join(args, " "), err, stdout, stderr)
+			continue
+		}
+		if strings.Contains(stdout+stderr, "USAGE:") {
+			t.Errorf("tspider %s showed the help:\n%s%s", strings.Join(args, " "), stdout, stderr)
+		}
+		if searched := strings.Join(requests()[before:], " "); !strings.Contains(searched, "/user/Erai-raws") {
+			t.Errorf("tspider %s requested %q, want the listing of Erai-raws", strings.Join(args, " "), searched)
+		}
+	}
+}
+
+// TestDeadlineBoundsDoctor runs doctor, not a search, against a site that
+// never answers, and checks --deadline ends it with its own exit code
+func TestDeadlineBoundsDoctor(t *testing.T) {
+	bin := buildTspider(t)
+	home := useTempHome(t)
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { close(release) })
+	c := common.DefaultConfig()
+	for name, site := range c.Sites {
+		site.Enabled = false
+		c.Sites[name] = site
+	}
+	c.Sites["nyaa"] = common.SiteConfig{URL: srv.URL, Language: "jp", Enabled: true}
+	if err := common.SaveConfig(c); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	stdout, stderr, err := runTspider(bin, home, "--deadline", "300ms", "doctor", "--lang", "jp")
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("doctor took %s, want it bounded by --deadline", elapsed)
+	}
+	var exit *exec.ExitError
+	if !errors.As(err, &exit) || exit.ExitCode() != 3 {
+		t.Errorf("doctor under --deadline: %v, want exit code 3\n%s%s", err, stdout, stderr)
+	}
+}
+
+// TestSendSelection sends the magnets picked by --select from those read on
+// stdin, and checks the summary of each
+func TestSendSelection(t *testing.T) {
+	bin := buildTspider(t)
+	fake := &fakeQBittorrent{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	useQBittorrent(t, srv.URL, "admin", "secret")
+
+	magnets := []string{
+		"magnet:?xt=urn:btih:" + strings.Repeat("a", 40) + "&dn=Show+01",
+		"magnet:?xt=urn:btih:" + strings.Repeat("b", 40) + "&dn=Show+02",
+		"magnet:?xt=urn:btih:" + strings.Repeat("c", 40),
+	}
+	run := func(selection string) (string, error) {
+		home := os.Getenv("HOME")
+		cmd := exec.Command(bin, "send", "--select", selection)
+		cmd.Env = append(os.Environ(), "HOME="+home, "USERPROFILE="+home, "XDG_CACHE_HOME="+filepath.Join(home, ".cache"))
+		cmd.Stdin = strings.NewReader(strings.Join(magnets, "\n") + "\n")
+		out, err := cmd.CombinedOutput()
+		return string(out), err
+	}
+
+	out, err := run("1,3")
+	if err != nil {
+		t.Fatalf("send --select 1,3: %v\n%s", err, out)
+	}
+	if want := []string{magnets[0], magnets[2]}; strings.Join(fake.added, " ") != strings.Join(want, " ") {
+		t.Errorf("added = %q, want %q", fake.added, want)
+	}
+	for _, want := range []string{"[+] Show 01\n", "[+] " + strings.Repeat("c", 40) + "\n", "[+] Sent 2 magnet(s) to qbittorrent"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("send output lacks %q:\n%s", want, out)
+		}
+	}
+
+	fake.added = nil
+	if out, err := run("2-4"); err == nil || !strings.Contains(out, "row 4 is out of range 1-3") || len(fake.added) != 0 {
+		t.Errorf("send --select 2-4 = %v, added %q:\n%s", err, fake.added, out)
+	}
+}
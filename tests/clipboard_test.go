@@ -0,0 +1,39 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/daite/tspider/common"
+)
+
+func TestOSC52(t *testing.T) {
+	got := common.OSC52("magnet:?xt=urn:btih:abc")
+	want := "\033]52;c;bWFnbmV0Oj94dD11cm46YnRpaDphYmM=\a"
+	if got != want {
+		t.Errorf("OSC52() = %q, want %q", got, want)
+	}
+}
+
+func TestCopyResultWithoutClipboardCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("clip is always installed")
+	}
+	dir := t.TempDir()
+	t.Setenv("PATH", dir)
+	if got := common.CopyResult(); got != "sent to the terminal" {
+		t.Errorf("CopyResult() without a clipboard command = %q, want the OSC52 fallback named", got)
+	}
+	name := "wl-copy"
+	if runtime.GOOS == "darwin" {
+		name = "pbcopy"
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\ncat >/dev/null\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if got := common.CopyResult(); got != "copied to the clipboard" {
+		t.Errorf("CopyResult() with %s = %q, want copied to the clipboard", name, got)
+	}
+}
@@ -0,0 +1,134 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/daite/tspider/common"
+)
+
+// TestHelperAddProfile is run as a subprocess by TestConcurrentConfigUpdates
+func TestHelperAddProfile(t *testing.T) {
+	name := os.Getenv("TSPIDER_HELPER_PROFILE")
+	if name == "" {
+		t.Skip("helper process only")
+	}
+	if err := common.AddProfile(name, []string{"nyaa"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := common.AddBlock(name); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConcurrentConfigUpdates(t *testing.T) {
+	home := useTempHome(t)
+	common.SaveConfig(common.DefaultConfig())
+
+	const procs = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, procs)
+	for i := 0; i < procs; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			cmd := exec.Command(os.Args[0], "-test.run=^TestHelperAddProfile$")
+			cmd.Env = append(os.Environ(),
+				"HOME="+home, "USERPROFILE="+home,
+				fmt.Sprintf("TSPIDER_HELPER_PROFILE=p%d", i))
+			if out, err := cmd.CombinedOutput(); err != nil {
+				errs <- fmt.Errorf("helper %d: %v\n%s", i, err, out)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	c, err := common.ReadConfigFile(common.GetConfigPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < procs; i++ {
+		name := fmt.Sprintf("p%d", i)
+		if _, ok := c.Profiles[name]; !ok {
+			t.Errorf("profile %s was lost", name)
+		}
+	}
+	if len(c.Blocklist) != procs {
+		t.Errorf("blocklist has %d patterns, want %d: %v", len(c.Blocklist), procs, c.Blocklist)
+	}
+}
+
+func TestUpdateConfigLeavesInvalidFile(t *testing.T) {
+	useTempHome(t)
+	common.SaveConfig(common.DefaultConfig())
+	if err := os.WriteFile(common.GetConfigPath(), []byte("{broken"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := common.AddBlock("x"); err == nil {
+		t.Error("AddBlock() on an invalid config file = nil, want error")
+	}
+	data, _ := os.ReadFile(common.GetConfigPath())
+	if string(data) != "{broken" {
+		t.Errorf("config file was overwritten: %q", data)
+	}
+}
+
+func TestConcurrentSiteStateUpdates(t *testing.T) {
+	useTempHome(t)
+	common.SaveConfig(common.DefaultConfig())
+
+	const updates = 8
+	now := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < updates; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := common.UpdateSiteState(func(s *common.SiteState) error {
+				s.RecordFailure(fmt.Sprintf("site%d", i), now)
+				return nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	s, err := common.LoadSiteState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Sites) != updates {
+		t.Errorf("site state has %d sites, want %d: %v", len(s.Sites), updates, s.Sites)
+	}
+}
+
+func TestGetConfigDuringSaveConfig(t *testing.T) {
+	useTempHome(t)
+	common.SaveConfig(common.DefaultConfig())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			common.SaveConfig(common.DefaultConfig())
+		}()
+		go func() {
+			defer wg.Done()
+			if common.GetConfig() == nil {
+				t.Error("GetConfig() = nil")
+			}
+		}()
+	}
+	wg.Wait()
+}
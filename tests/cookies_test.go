@@ -0,0 +1,90 @@
+package tests
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/daite/tspider/common"
+)
+
+func TestCookieJarPersists(t *testing.T) {
+	useTempHome(t)
+	var challenged int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie("cf_clearance"); err == nil && c.Value == "ok" {
+			io.WriteString(w, "results")
+			return
+		}
+		challenged++
+		http.SetCookie(w, &http.Cookie{Name: "cf_clearance", Value: "ok", Path: "/", MaxAge: 3600})
+		http.SetCookie(w, &http.Cookie{Name: "tracking", Value: "x", Path: "/", MaxAge: -1})
+		io.WriteString(w, "challenge")
+	}))
+	defer srv.Close()
+	if err := common.AddSite("guarded", srv.URL, "kr"); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "cookies")
+	get := func(jar http.CookieJar) string {
+		t.Helper()
+		resp, err := (&http.Client{Jar: jar}).Get(srv.URL + "/search")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+	if got := get(common.NewCookieJar(dir)); got != "challenge" {
+		t.Fatalf("first visit = %q, want the challenge", got)
+	}
+
+	path := filepath.Join(dir, "guarded.json")
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("cookies of the site not saved: %v", err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("cookie file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	// A new jar, as in a later run, sends the saved cookie
+	if got := get(common.NewCookieJar(dir)); got != "results" {
+		t.Errorf("later run = %q, want results without a challenge", got)
+	}
+	if challenged != 1 {
+		t.Errorf("challenged %d times, want 1", challenged)
+	}
+}
+
+func TestCookieJarForgetsDeletedCookies(t *testing.T) {
+	useTempHome(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/logout" {
+			http.SetCookie(w, &http.Cookie{Name: "session", Path: "/", MaxAge: -1})
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc", Path: "/"})
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	client := &http.Client{Jar: common.NewCookieJar(dir)}
+	for _, path := range []string{"/", "/logout"} {
+		resp, err := client.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	u, _ := http.NewRequest("GET", srv.URL, nil)
+	if cookies := common.NewCookieJar(dir).Cookies(u.URL); len(cookies) != 0 {
+		t.Errorf("Cookies() after the site deleted its cookie = %v, want none", cookies)
+	}
+}
@@ -0,0 +1,140 @@
+package tests
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/daite/tspider/common"
+)
+
+func TestCrawlKeyIgnoresSiteOrder(t *testing.T) {
+	a := common.CrawlKey{Keyword: "k", Lang: "jp", Sites: []string{"nyaa=https://nyaa.si", "sukebe=https://sukebei.nyaa.si"}}
+	b := common.CrawlKey{Keyword: "k", Lang: "jp", Sites: []string{"sukebe=https://sukebei.nyaa.si", "nyaa=https://nyaa.si"}}
+	if a.String() != b.String() {
+		t.Errorf("keys differing only in site order: %s != %s", a, b)
+	}
+}
+
+func TestCrawlKeyCrawlOptions(t *testing.T) {
+	base := common.CrawlKey{Keyword: "k", Lang: "jp", Sites: []string{"nyaa=https://nyaa.si"}}
+	variants := []common.CrawlKey{
+		{Keyword: "other", Lang: "jp", Sites: base.Sites},
+		{Keyword: "k", Lang: "kr", Sites: base.Sites},
+		{Keyword: "k", Lang: "jp", Uploader: "someone", Sites: base.Sites},
+		{Keyword: "k", Lang: "jp", Exact: true, Sites: base.Sites},
+		{Keyword: "k", Lang: "jp", Sites: []string{"nyaa=https://nyaa.example"}},
+	}
+	for _, v := range variants {
+		if v.String() == base.String() {
+			t.Errorf("key %+v = key %+v, want different", v, base)
+		}
+	}
+}
+
+func TestNewCrawlKeyFollowsProfile(t *testing.T) {
+	useTempHome(t)
+	common.SaveConfig(common.DefaultConfig())
+	all := common.NewCrawlKey("k", "jp", "", false)
+	if err := common.AddProfile("only-nyaa", []string{"nyaa"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := common.UseProfile("only-nyaa"); err != nil {
+		t.Fatal(err)
+	}
+	if got := common.NewCrawlKey("k", "jp", "", false); got.String() == all.String() {
+		t.Errorf("NewCrawlKey() unchanged by a profile, sites %v", got.Sites)
+	}
+}
+
+// TestCrawlCacheHitAcrossFormats runs the same search as a table and then
+// as JSON, and checks that the second run renders the first one's crawl,
+// saying so on stderr, without searching the site again
+func TestCrawlCacheHitAcrossFormats(t *testing.T) {
+	bin := buildTspider(t)
+	home, requests := nyaaOnly(t)
+	searches := func() int {
+		n := 0
+		for _, r := range requests() {
+			if strings.Contains(r, "q=show") {
+				n++
+			}
+		}
+		return n
+	}
+
+	table, stderr, err := runTspider(bin, home, "--lang", "jp", "--format", "table", "show")
+	if err != nil {
+		t.Fatalf("--format table: %v\n%s", err, stderr)
+	}
+	if searches() != 1 || strings.Contains(stderr, "Reusing") {
+		t.Fatalf("--format table searched %d times, stderr %q; want one search and no reuse", searches(), stderr)
+	}
+	out, stderr, err := runTspider(bin, home, "--lang", "jp", "--format", "json", "show")
+	if err != nil {
+		t.Fatalf("--format json: %v\n%s", err, stderr)
+	}
+	if searches() != 1 {
+		t.Errorf("--format json searched the site again, want the crawl of --format table reused")
+	}
+	if !strings.Contains(stderr, "Reusing the results of an identical recent search") {
+		t.Errorf("--format json stderr = %q, want the reuse noted", stderr)
+	}
+	var results []common.SearchResult
+	if err := json.Unmarshal([]byte(out), &results); err != nil {
+		t.Fatalf("--format json output is not JSON: %v\n%s", err, out)
+	}
+	// the table writes spaces in titles as underscores
+	if len(results) == 0 || !strings.Contains(table, strings.ReplaceAll(results[0].Title, " ", "_")) {
+		t.Errorf("--format json results %+v, want those of the table:\n%s", results, table)
+	}
+
+	if _, _, err := runTspider(bin, home, "--lang", "jp", "--format", "json", "--no-cache", "show"); err != nil {
+		t.Fatal(err)
+	}
+	if searches() != 2 {
+		t.Errorf("--no-cache searched %d times in all, want the site searched again", searches())
+	}
+
+	// The daemon looks for new uploads, so it must never reuse a crawl
+	if err := common.AddSchedule("show", common.Schedule{Keyword: "show", Cron: "@hourly"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, stderr, err := runTspider(bin, home, "daemon", "--once"); err != nil {
+		t.Fatalf("daemon --once: %v\n%s", err, stderr)
+	}
+	if searches() != 3 {
+		t.Errorf("daemon --once searched %d times in all, want the site searched again", searches())
+	}
+}
+
+func TestCrawlCacheExpires(t *testing.T) {
+	useTempHome(t)
+	common.SaveConfig(common.DefaultConfig())
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	cache := common.NewCrawlCache(time.Minute, t.TempDir())
+	cache.Now = func() time.Time { return now }
+
+	results := []common.SearchResult{{Site: "nyaa", Title: "Show", Magnet: "magnet:?xt=urn:btih:abc", Seeders: 3}}
+	stats := common.DedupStats{Input: 2, Unique: 1, Duplicates: 1}
+	key := common.NewCrawlKey("show", "jp", "", false)
+	if _, _, ok := cache.Get(key); ok {
+		t.Fatal("Get() before any crawl hit")
+	}
+	if err := cache.Put(key, results, stats); err != nil {
+		t.Fatal(err)
+	}
+	got, gotStats, ok := cache.Get(key)
+	if !ok || !reflect.DeepEqual(got, results) || gotStats != stats {
+		t.Errorf("Get() = %v, %v, %v, want %v, %v", got, gotStats, ok, results, stats)
+	}
+	if _, _, ok := cache.Get(common.NewCrawlKey("other", "jp", "", false)); ok {
+		t.Error("Get() for another keyword hit")
+	}
+	now = now.Add(time.Minute)
+	if _, _, ok := cache.Get(key); ok {
+		t.Error("Get() hit after the TTL")
+	}
+}
@@ -0,0 +1,37 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/daite/tspider/common"
+)
+
+func TestWriteCSV(t *testing.T) {
+	results := []common.SearchResult{
+		{Site: "torrenttop", Title: `title, with "quotes"`, Magnet: "magnet:?xt=urn:btih:aaa"},
+		{
+			Site: "nyaa", Title: "jp", Magnet: "magnet:?xt=urn:btih:bbb", Seeders: 12, Leechers: 3,
+			Size: "1.2 GiB", Uploader: "sub", Date: time.Date(2021, 1, 2, 3, 4, 0, 0, time.UTC),
+		},
+	}
+	var buf bytes.Buffer
+	if err := common.WriteCSV(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	got, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("WriteCSV() wrote invalid CSV: %v", err)
+	}
+	want := [][]string{
+		common.CSVHeader,
+		{"torrenttop", `title, with "quotes"`, "magnet:?xt=urn:btih:aaa", "0", "0", "", "", "", ""},
+		{"nyaa", "jp", "magnet:?xt=urn:btih:bbb", "12", "3", "1.2 GiB", "sub", "2021-01-02T03:04:00Z", ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("WriteCSV() = %q, want %q", got, want)
+	}
+}
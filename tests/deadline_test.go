@@ -0,0 +1,46 @@
+package tests
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/daite/tspider/common"
+)
+
+type fastSite struct{}
+
+func (fastSite) Crawl(ctx context.Context, keyword string) []common.SearchResult {
+	return []common.SearchResult{{Title: keyword + " fast", Magnet: "magnet:?xt=urn:btih:fast"}}
+}
+
+type hangingSite struct {
+	release chan struct{}
+}
+
+func (h hangingSite) Crawl(ctx context.Context, keyword string) []common.SearchResult {
+	<-h.release
+	return []common.SearchResult{{Title: keyword + " slow", Magnet: "magnet:?xt=urn:btih:slow"}}
+}
+
+func TestCollectDataReturnsPartialResultsOnDeadline(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	sites := map[string]common.Scraper{"fast": fastSite{}, "hanging": hangingSite{release}}
+	start := time.Now()
+	got, _ := common.CollectData(ctx, sites, "test", common.NewSpinner("test"))
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("CollectData() took %s, want it bounded by the deadline", elapsed)
+	}
+	if ctx.Err() != context.DeadlineExceeded {
+		t.Fatalf("ctx.Err() = %v, want %v", ctx.Err(), context.DeadlineExceeded)
+	}
+	want := []common.SearchResult{{Site: "fast", Title: "test fast", Magnet: "magnet:?xt=urn:btih:fast"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CollectData() = %+v, want %+v", got, want)
+	}
+}
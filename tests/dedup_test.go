@@ -0,0 +1,33 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/daite/tspider/common"
+)
+
+func TestDedupStats(t *testing.T) {
+	const (
+		a = "magnet:?xt=urn:btih:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+		b = "magnet:?xt=urn:btih:bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+	)
+	results := []common.SearchResult{
+		{Site: "nyaa", Title: "A", Magnet: a, Seeders: 3},
+		{Site: "sukebe", Title: "A (mirror)", Magnet: a, Seeders: 9},
+		{Site: "nyaa", Title: "A again", Magnet: a, Seeders: 1},
+		{Site: "nyaa", Title: "B", Magnet: b},
+		{Site: "torrenttop", Title: "no hash", Magnet: "magnet:?xt=urn:btih:"},
+		{Site: "torrenttop", Title: "no hash", Magnet: "magnet:?xt=urn:btih:"},
+	}
+	got, stats := common.Dedup(results)
+	want := common.DedupStats{Input: 6, Unique: 3, Duplicates: 3, CrossSite: 1}
+	if stats != want {
+		t.Errorf("Dedup() stats = %+v, want %+v", stats, want)
+	}
+	if len(got) != 3 {
+		t.Fatalf("Dedup() kept %d results, want 3", len(got))
+	}
+	if got[0].Site != "sukebe" || got[0].Seeders != 9 {
+		t.Errorf("Dedup() kept %+v for A, want the best-seeded copy from sukebe", got[0])
+	}
+}
@@ -0,0 +1,72 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/daite/tspider/common"
+)
+
+func TestDiagnoseSite(t *testing.T) {
+	useTempHome(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			http.Redirect(w, r, "/home", http.StatusFound)
+		case "/home":
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	tlsSrv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer tlsSrv.Close()
+
+	siteURL := strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)
+	c := common.DefaultConfig()
+	c.Sites = map[string]common.SiteConfig{
+		"plain":     {URL: siteURL, Language: "jp", Enabled: true},
+		"untrusted": {URL: tlsSrv.URL, Language: "jp", Enabled: true},
+		"missing":   {URL: srv.URL + "/missing", Language: "jp", Enabled: true},
+	}
+	if err := common.SaveConfig(c); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := common.DiagnoseSite(context.Background(), "plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.Available || d.StatusCode != 200 || d.FinalURL != siteURL+"/home" || d.Error != "" {
+		t.Errorf("DiagnoseSite(plain) = %+v, want 200 at %s/home", d, siteURL)
+	}
+	if len(d.RedirectChain) != 1 || d.RedirectChain[0] != (common.RedirectHop{URL: siteURL, Status: http.StatusFound}) {
+		t.Errorf("DiagnoseSite(plain) redirects = %+v, want one 302 from %s", d.RedirectChain, siteURL)
+	}
+	if len(d.Addresses) == 0 || d.DNSError != "" {
+		t.Errorf("DiagnoseSite(plain) addresses = %v (%s), want localhost resolved", d.Addresses, d.DNSError)
+	}
+	var buf bytes.Buffer
+	common.PrintDiagnosis(&buf, d)
+	for _, want := range []string{"DNS:", "HTTP:       200 OK", "Final URL:  " + siteURL + "/home", "Status:     OK"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("PrintDiagnosis() lacks %q:\n%s", want, buf.String())
+		}
+	}
+
+	d, _ = common.DiagnoseSite(context.Background(), "untrusted")
+	if d.Available || d.TLS != nil || !strings.Contains(d.Error, "certificate") {
+		t.Errorf("DiagnoseSite(untrusted) = %+v, want a certificate error", d)
+	}
+	d, _ = common.DiagnoseSite(context.Background(), "missing")
+	if d.Available || d.StatusCode != 404 || d.Error != "HTTP 404" {
+		t.Errorf("DiagnoseSite(missing) = %+v, want down with HTTP 404", d)
+	}
+	if _, err := common.DiagnoseSite(context.Background(), "nosuch"); err == nil {
+		t.Errorf("DiagnoseSite(unknown site) err = nil")
+	}
+}
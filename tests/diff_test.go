@@ -0,0 +1,43 @@
+package tests
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/daite/tspider/common"
+)
+
+func TestDiffResults(t *testing.T) {
+	kept := common.SearchResult{Site: "nyaa", Title: "kept", Magnet: "magnet:?xt=urn:btih:087858c2626987779f9a3e107e4d12607a6e66aa"}
+	// Same torrent under another title and case on a different site is not new
+	keptElsewhere := common.SearchResult{Site: "sukebe", Title: "kept (mirror)", Magnet: "magnet:?xt=urn:btih:087858C2626987779F9A3E107E4D12607A6E66AA&dn=x"}
+	gone := common.SearchResult{Site: "nyaa", Title: "gone", Magnet: "magnet:?xt=urn:btih:6bb34701c93505114029e5c91a0e88a30c11703b"}
+	fresh := common.SearchResult{Site: "nyaa", Title: "new", Magnet: "magnet:?xt=urn:btih:e9322c31da47494a31c7f8312c92e7a50a973759"}
+
+	added, removed := common.DiffResults(
+		[]common.SearchResult{kept, gone},
+		[]common.SearchResult{keptElsewhere, fresh},
+	)
+	if want := []common.SearchResult{fresh}; !reflect.DeepEqual(added, want) {
+		t.Errorf("DiffResults() added = %+v, want %+v", added, want)
+	}
+	if want := []common.SearchResult{gone}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("DiffResults() removed = %+v, want %+v", removed, want)
+	}
+}
+
+func TestSaveAndLoadResults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.json")
+	want := []common.SearchResult{{Site: "nyaa", Title: "a", Magnet: "magnet:?xt=urn:btih:x", Seeders: 3, Size: "1.2 GiB"}}
+	if err := common.SaveResults(path, want); err != nil {
+		t.Fatalf("SaveResults() = %v", err)
+	}
+	got, err := common.LoadResults(path)
+	if err != nil {
+		t.Fatalf("LoadResults() = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadResults() = %+v, want %+v", got, want)
+	}
+}
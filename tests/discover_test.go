@@ -0,0 +1,58 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/daite/tspider/common"
+)
+
+func TestMirrorCandidates(t *testing.T) {
+	got := common.MirrorCandidates("https://torrenttop152.com/bbs/?q=x")
+	want := []string{"https://torrenttop153.com", "https://torrenttop151.com", "https://torrenttop154.com", "https://torrenttop150.com", "https://torrenttop155.com"}
+	if len(got) != 12 || !reflect.DeepEqual(got[:5], want) {
+		t.Errorf("MirrorCandidates() = %v, want 12 starting with %v", got, want)
+	}
+	if got := common.MirrorCandidates("https://nyaa.si"); got != nil {
+		t.Errorf("MirrorCandidates(unnumbered) = %v, want nil", got)
+	}
+}
+
+func TestDiscoverMirrorsFollowsHubRedirect(t *testing.T) {
+	useTempHome(t)
+	moved := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer moved.Close()
+	hub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, moved.URL+"/", http.StatusFound)
+	}))
+	defer hub.Close()
+	gone := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	gone.Close()
+
+	c := common.DefaultConfig()
+	c.Sites = map[string]common.SiteConfig{
+		"torrentqq": {URL: gone.URL, Hubs: []string{hub.URL}, Language: "kr", Enabled: true},
+		"nohub":     {URL: gone.URL + "/nohub", Language: "kr", Enabled: true},
+	}
+	if err := common.SaveConfig(c); err != nil {
+		t.Fatal(err)
+	}
+
+	found := common.DiscoverMirrors(context.Background(), []string{"nohub", "torrentqq"})
+	want := []common.Discovery{{Site: "torrentqq", Current: gone.URL, Found: moved.URL, Source: "hub"}}
+	if !reflect.DeepEqual(found, want) {
+		t.Fatalf("DiscoverMirrors() = %+v, want %+v", found, want)
+	}
+	if err := common.ApplyDiscoveries(found); err != nil {
+		t.Fatal(err)
+	}
+	if got := common.GetConfig().Sites["torrentqq"].URL; got != moved.URL {
+		t.Errorf("URL after ApplyDiscoveries() = %s, want %s", got, moved.URL)
+	}
+	if got := common.SiteURL("torrentqq"); got != moved.URL {
+		t.Errorf("SiteURL after ApplyDiscoveries() = %s, want %s", got, moved.URL)
+	}
+}
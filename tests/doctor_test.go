@@ -0,0 +1,152 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/daite/tspider/common"
+	"github.com/mattn/go-runewidth"
+)
+
+func TestPrintDoctorStatusAlignsWideCharacters(t *testing.T) {
+	statuses := []common.SiteStatus{
+		{Name: "nyaa", URL: "https://nyaa.si", Available: true, Latency: 120 * time.Millisecond, Enabled: true},
+		{Name: "토렌트탑", URL: "https://torrenttop152.com", Latency: 3 * time.Second, Error: "연결이 거부되었습니다 (connection refused)"},
+	}
+	var buf bytes.Buffer
+	common.PrintDoctorStatus(&buf, statuses)
+
+	var rows []string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.Contains(line, "https://") {
+			rows = append(rows, line)
+		}
+	}
+	if len(rows) != 2 {
+		t.Fatalf("PrintDoctorStatus() printed %d site rows, want 2:\n%s", len(rows), buf.String())
+	}
+	for _, row := range rows {
+		if col := runewidth.StringWidth(row[:strings.Index(row, "https://")]); col != 16 {
+			t.Errorf("URL column starts at display column %d, want 16 in %q", col, row)
+		}
+		status := strings.Index(row, "OK")
+		if status < 0 {
+			status = strings.Index(row, "DOWN")
+		}
+		if col := runewidth.StringWidth(row[:status]); col != 57 {
+			t.Errorf("STATUS column starts at display column %d, want 57 in %q", col, row)
+		}
+	}
+}
+
+func TestDoctorReportsRedirectToNewHost(t *testing.T) {
+	useTempHome(t)
+	moved := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer moved.Close()
+	// A different host name for the same server, as the old address
+	old := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, moved.URL+"/index.php", http.StatusMovedPermanently)
+	}))
+	defer old.Close()
+	oldURL := strings.Replace(old.URL, "127.0.0.1", "localhost", 1)
+
+	c := common.DefaultConfig()
+	c.Sites = map[string]common.SiteConfig{
+		"torrentqq": {URL: oldURL, Language: "kr", Enabled: true},
+		"nyaa":      {URL: moved.URL, Language: "jp", Enabled: true},
+	}
+	if err := common.SaveConfig(c); err != nil {
+		t.Fatal(err)
+	}
+	statuses := common.Doctor(context.Background(), "")
+	want := []common.Discovery{{Site: "torrentqq", Current: oldURL, Found: moved.URL, Source: "redirect"}}
+	if got := common.Redirects(statuses); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Redirects() = %+v, want %+v", got, want)
+	}
+	for _, s := range statuses {
+		if s.Name != "torrentqq" {
+			continue
+		}
+		chain := []common.RedirectHop{{URL: oldURL, Status: http.StatusMovedPermanently}}
+		if !reflect.DeepEqual(s.RedirectChain, chain) || s.FinalURL != moved.URL+"/index.php" {
+			t.Errorf("redirects = %+v to %s, want %+v to %s/index.php", s.RedirectChain, s.FinalURL, chain, moved.URL)
+		}
+	}
+	var buf bytes.Buffer
+	common.PrintDoctorStatus(&buf, statuses)
+	if !strings.Contains(buf.String(), "moved to ") {
+		t.Errorf("PrintDoctorStatus() does not show the new address:\n%s", buf.String())
+	}
+	if want := "Redirects:\n  torrentqq: " + oldURL + " (301) -> " + moved.URL + "/index.php\n"; !strings.Contains(buf.String(), want) {
+		t.Errorf("PrintDoctorStatus() lacks %q:\n%s", want, buf.String())
+	}
+	if err := common.ApplyDiscoveries(want); err != nil {
+		t.Fatal(err)
+	}
+	if got := common.GetConfig().Sites["torrentqq"].URL; got != moved.URL {
+		t.Errorf("URL after ApplyDiscoveries() = %s, want %s", got, moved.URL)
+	}
+}
+
+func TestAvailabilityHistory(t *testing.T) {
+	path := t.TempDir() + "/history.ndjson"
+	first := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	checks := [][]common.SiteStatus{
+		{{Name: "nyaa", Available: true, Latency: 80 * time.Millisecond}, {Name: "sukebe", Error: "HTTP 503"}},
+		{{Name: "nyaa", Available: false, Error: "timeout"}},
+	}
+	for i, statuses := range checks {
+		if err := common.AppendAvailabilityHistory(path, first.Add(time.Duration(i)*time.Minute), statuses); err != nil {
+			t.Fatal(err)
+		}
+	}
+	records, err := common.ReadAvailabilityHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("ReadAvailabilityHistory() = %d records, want 3", len(records))
+	}
+	last := records[2]
+	if !last.Time.Equal(first.Add(time.Minute)) || last.Name != "nyaa" || last.Available || last.Error != "timeout" {
+		t.Errorf("last record = %+v, want nyaa down a minute later", last)
+	}
+	if records[0].Latency != 80*time.Millisecond || records[1].Error != "HTTP 503" {
+		t.Errorf("first check = %+v, %+v", records[0], records[1])
+	}
+}
+
+func TestDoctorReportsCertificate(t *testing.T) {
+	useTempHome(t)
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	c := common.DefaultConfig()
+	c.Sites = map[string]common.SiteConfig{"selfsigned": {URL: srv.URL, Language: "jp", Enabled: true}}
+	if err := common.SaveConfig(c); err != nil {
+		t.Fatal(err)
+	}
+
+	statuses := common.Doctor(context.Background(), "")
+	if len(statuses) != 1 {
+		t.Fatalf("Doctor() = %d statuses, want 1", len(statuses))
+	}
+	s := statuses[0]
+	if s.Available || s.CertIssuer != "Acme Co" || s.CertDaysLeft == nil || *s.CertDaysLeft < 365 {
+		t.Errorf("Doctor() = %+v, want the test certificate of Acme Co, valid for years", s)
+	}
+	if s.CertWarning != "certificate signed by an unknown authority" {
+		t.Errorf("CertWarning = %q, want the unknown authority reported", s.CertWarning)
+	}
+
+	var buf bytes.Buffer
+	common.PrintDoctorStatus(&buf, statuses)
+	if want := "Certificate warnings:\n  selfsigned: certificate signed by an unknown authority (issued by Acme Co)"; !strings.Contains(buf.String(), want) {
+		t.Errorf("PrintDoctorStatus() lacks %q:\n%s", want, buf.String())
+	}
+}
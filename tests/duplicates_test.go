@@ -0,0 +1,80 @@
+package tests
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/daite/tspider/common"
+)
+
+func TestAddSiteWithDuplicateURL(t *testing.T) {
+	useTempHome(t)
+	if err := common.SaveConfig(common.DefaultConfig()); err != nil {
+		t.Fatal(err)
+	}
+	if err := common.AddSite("mirror-a", "https://mirror.example", "kr"); err != nil {
+		t.Fatal(err)
+	}
+	if err := common.AddSite("mirror-b", "https://Mirror.example/", "kr"); err != nil {
+		t.Fatal(err)
+	}
+	dups := common.DuplicateURLs(common.GetConfig())
+	want := map[string][]string{"https://mirror.example": {"mirror-a", "mirror-b"}}
+	if !reflect.DeepEqual(dups, want) {
+		t.Errorf("DuplicateURLs() = %v, want %v", dups, want)
+	}
+	found := false
+	for _, p := range common.ValidateConfig(common.GetConfig()) {
+		if strings.Contains(p, "mirror-a, mirror-b") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("ValidateConfig() does not name the conflicting sites")
+	}
+
+	common.EnableSite("mirror-a", false)
+	if err := common.AddProfile("mirrors", []string{"mirror-a", "mirror-b"}); err != nil {
+		t.Fatal(err)
+	}
+	merged, err := common.DedupeSites()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(merged, map[string]string{"mirror-a": "mirror-b"}) {
+		t.Errorf("DedupeSites() = %v, want the disabled mirror-a merged into mirror-b", merged)
+	}
+	if got := common.GetConfig().Profiles["mirrors"]; !reflect.DeepEqual(got, []string{"mirror-b"}) {
+		t.Errorf("profile after DedupeSites() = %q, want [mirror-b]", got)
+	}
+}
+
+func TestDedupeSitesKeepsScrapedSite(t *testing.T) {
+	useTempHome(t)
+	c := common.DefaultConfig()
+	nyaa := c.Sites["nyaa"]
+	nyaa.Enabled = false
+	c.Sites["nyaa"] = nyaa
+	// An enabled copy under a name no scraper answers to, sorting first
+	c.Sites["a-nyaa"] = common.SiteConfig{URL: nyaa.URL + "/", Language: "jp", Enabled: true}
+	c.Profiles = map[string][]string{"anime": {"a-nyaa"}}
+	if err := common.SaveConfig(c); err != nil {
+		t.Fatal(err)
+	}
+
+	merged, err := common.DedupeSites()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(merged, map[string]string{"a-nyaa": "nyaa"}) {
+		t.Errorf("DedupeSites() = %v, want a-nyaa merged into the nyaa scraper's site", merged)
+	}
+	got := common.GetConfig()
+	if !got.Sites["nyaa"].Enabled {
+		t.Errorf("nyaa is disabled, want it enabled as the merged a-nyaa was")
+	}
+	if !reflect.DeepEqual(got.Profiles["anime"], []string{"nyaa"}) {
+		t.Errorf("profile after DedupeSites() = %q, want [nyaa]", got.Profiles["anime"])
+	}
+}
@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/daite/tspider/common"
+)
+
+func TestFavorites(t *testing.T) {
+	useTempHome(t)
+	if _, err := common.ResolveFavorite("1"); err == nil {
+		t.Errorf("ResolveFavorite(1) without history = nil error, want error")
+	}
+	older := []common.SearchResult{{Site: "nyaa", Title: "Old Show", Magnet: "magnet:?xt=urn:btih:1111111111111111111111111111111111111111"}}
+	last := []common.SearchResult{
+		{Site: "nyaa", Title: "Show 01", Magnet: "magnet:?xt=urn:btih:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"},
+		{Site: "sukebe", Title: "Show 02", Magnet: "magnet:?xt=urn:btih:bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"},
+	}
+	for _, s := range []struct {
+		keyword string
+		results []common.SearchResult
+	}{{"old", older}, {"show", last}} {
+		if _, err := common.RecordHistory(common.HistoryEntry{Time: time.Now(), Keyword: s.keyword}, s.results); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fav, err := common.ResolveFavorite("2")
+	if err != nil || fav.Title != "Show 02" || fav.Keyword != "show" {
+		t.Errorf("ResolveFavorite(2) = %+v, %v; want the 2nd result of the last search", fav, err)
+	}
+	if _, err := common.ResolveFavorite("3"); err == nil {
+		t.Errorf("ResolveFavorite(3) past the last search = nil error, want error")
+	}
+	byHash, err := common.ResolveFavorite("1111111111111111111111111111111111111111")
+	if err != nil || byHash.Title != "Old Show" || byHash.Keyword != "old" {
+		t.Errorf("ResolveFavorite(hash) = %+v, %v; want the result of the older search", byHash, err)
+	}
+	bare, err := common.ResolveFavorite("CCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCC")
+	if err != nil || bare.Magnet != "magnet:?xt=urn:btih:cccccccccccccccccccccccccccccccccccccccc" {
+		t.Errorf("ResolveFavorite(unknown hash) = %+v, %v; want a bare magnet", bare, err)
+	}
+	if _, err := common.ResolveFavorite("not-a-hash"); err == nil {
+		t.Errorf("ResolveFavorite(not-a-hash) = nil error, want error")
+	}
+
+	for _, f := range []common.Favorite{fav, byHash, fav} {
+		if _, err := common.AddFavorite(f); err != nil {
+			t.Fatalf("AddFavorite() = %v", err)
+		}
+	}
+	favs, err := common.LoadFavorites()
+	if err != nil || len(favs) != 2 {
+		t.Fatalf("LoadFavorites() = %d favorite(s), %v; want 2, the duplicate skipped", len(favs), err)
+	}
+	if favs[0].Magnet != last[1].Magnet || favs[0].Added.IsZero() {
+		t.Errorf("favorite 1 = %+v, want Show 02 with its time", favs[0])
+	}
+
+	removed, err := common.RemoveFavorite("bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb")
+	if err != nil || removed.Title != "Show 02" {
+		t.Errorf("RemoveFavorite(hash) = %+v, %v; want Show 02", removed, err)
+	}
+	if _, err := common.RemoveFavorite("2"); err == nil {
+		t.Errorf("RemoveFavorite(2) with one favorite = nil error, want error")
+	}
+	if removed, err := common.RemoveFavorite("1"); err != nil || removed.Title != "Old Show" {
+		t.Errorf("RemoveFavorite(1) = %+v, %v; want Old Show", removed, err)
+	}
+}
@@ -0,0 +1,214 @@
+package tests
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/daite/tspider/common"
+)
+
+// fakeBrowser writes a script that prints a rendered page with its
+// arguments, standing in for a headless Chrome
+func fakeBrowser(t *testing.T) string {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake browser is a shell script")
+	}
+	path := filepath.Join(t.TempDir(), "chromium")
+	script := "#!/bin/sh\necho \"<html><body>rendered $*</body></html>\"\n"
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFetcherForRenderJS(t *testing.T) {
+	useTempHome(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "<html><body>plain</body></html>")
+	}))
+	defer srv.Close()
+	browser := fakeBrowser(t)
+
+	c := common.DefaultConfig()
+	c.BrowserPath = browser
+	c.Sites = map[string]common.SiteConfig{
+		"js":    {URL: "http://js.example", Language: "jp", Enabled: true, RenderJS: true},
+		"plain": {URL: srv.URL, Language: "jp", Enabled: true},
+	}
+	if err := common.SaveConfig(c); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := common.FetcherFor(srv.URL + "/search").(common.HTTPFetcher); !ok {
+		t.Errorf("FetcherFor(plain site) is not the HTTP fetcher")
+	}
+	f, ok := common.FetcherFor("http://js.example/search?q=x").(*common.BrowserFetcher)
+	if !ok || f.Path != browser {
+		t.Fatalf("FetcherFor(render_js site) = %#v, want the browser at %s", f, browser)
+	}
+
+	resp, ok := common.GetResponseFromURL(context.Background(), "http://js.example/search?q=x")
+	if !ok {
+		t.Fatalf("GetResponseFromURL(render_js site) ok = false")
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), "rendered") || !strings.Contains(string(body), "--dump-dom http://js.example/search?q=x") {
+		t.Errorf("body = %q, want the DOM dumped by the browser", body)
+	}
+	if resp.Request == nil || resp.Request.URL.Host != "js.example" {
+		t.Errorf("resp.Request = %v, want the fetched URL for resolving links", resp.Request)
+	}
+
+	resp, ok = common.GetResponseFromURL(context.Background(), srv.URL)
+	if !ok {
+		t.Fatalf("GetResponseFromURL(plain site) ok = false")
+	}
+	defer resp.Body.Close()
+	if body, _ := io.ReadAll(resp.Body); string(body) != "<html><body>plain</body></html>" {
+		t.Errorf("plain body = %q", body)
+	}
+}
+
+func TestBrowserSandbox(t *testing.T) {
+	useTempHome(t)
+	f := &common.BrowserFetcher{Path: fakeBrowser(t)}
+	for _, noSandbox := range []bool{false, true} {
+		c := common.DefaultConfig()
+		c.BrowserNoSandbox = noSandbox
+		if err := common.SaveConfig(c); err != nil {
+			t.Fatal(err)
+		}
+		resp, ok := f.Fetch(context.Background(), "http://js.example/")
+		if !ok {
+			t.Fatalf("Fetch() ok = false")
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if got := strings.Contains(string(body), "--no-sandbox"); got != noSandbox {
+			t.Errorf("browser_no_sandbox %v: --no-sandbox passed = %v", noSandbox, got)
+		}
+	}
+}
+
+func TestBrowserErrorPage(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake browser is a shell script")
+	}
+	useTempHome(t)
+	for _, page := range []string{
+		"",
+		"<html><head></head></html>",
+		`<html><body class="neterror"><div id="main-frame-error" class="interstitial-wrapper">This site can't be reached</div></body></html>`,
+		`<html><body><script>var u = "chrome-error://chromewebdata/";</script></body></html>`,
+	} {
+		path := filepath.Join(t.TempDir(), "chromium")
+		if err := os.WriteFile(path, []byte("#!/bin/sh\nprintf '%s' '"+strings.ReplaceAll(page, "'", `'\''`)+"'\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if resp, ok := (&common.BrowserFetcher{Path: path}).Fetch(context.Background(), "http://down.example/"); ok {
+			resp.Body.Close()
+			t.Errorf("Fetch() of the page %q ok = true, want a failure", page)
+		}
+	}
+}
+
+func TestFetcherForRenderJSWithoutBrowser(t *testing.T) {
+	useTempHome(t)
+	t.Setenv("PATH", t.TempDir())
+	c := common.DefaultConfig()
+	c.Sites = map[string]common.SiteConfig{"js": {URL: "http://js.example", Language: "jp", Enabled: true, RenderJS: true}}
+	if err := common.SaveConfig(c); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := common.FetcherFor("http://js.example/").(common.HTTPFetcher); !ok {
+		t.Errorf("FetcherFor(render_js site) without a browser is not the HTTP fetcher")
+	}
+}
+
+// helloRecorder starts a TLS server recording the ClientHello of each
+// handshake
+func helloRecorder(t *testing.T) (*httptest.Server, chan *tls.ClientHelloInfo) {
+	hellos := make(chan *tls.ClientHelloInfo, 4)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.TLS = &tls.Config{GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		hellos <- hello
+		return nil, nil
+	}}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv, hellos
+}
+
+// greased reports whether hello offers a GREASE cipher suite, as Chrome
+// does and Go never does
+func greased(hello *tls.ClientHelloInfo) bool {
+	for _, cs := range hello.CipherSuites {
+		if cs&0x0f0f == 0x0a0a {
+			return true
+		}
+	}
+	return false
+}
+
+func TestTLSFingerprintChromeSendsChromeHello(t *testing.T) {
+	useTempHome(t)
+	picky, pickyHellos := helloRecorder(t)
+	plain, plainHellos := helloRecorder(t)
+	c := common.DefaultConfig()
+	c.Sites = map[string]common.SiteConfig{
+		"picky": {URL: picky.URL, Language: "jp", Enabled: true, TLSFingerprint: common.TLSFingerprintChrome},
+		"plain": {URL: plain.URL, Language: "jp", Enabled: true},
+	}
+	if err := common.SaveConfig(c); err != nil {
+		t.Fatal(err)
+	}
+	client := common.NewHTTPClient(c)
+	// The test certificates are not trusted; only the handshakes matter
+	for _, u := range []string{picky.URL, plain.URL} {
+		if resp, err := client.Get(u); err == nil {
+			resp.Body.Close()
+		}
+	}
+	// Each server saw its handshake before the client got its answer
+	hello := receivedHello(t, pickyHellos)
+	if !greased(hello) || !reflect.DeepEqual(hello.SupportedProtos, []string{"h2", "http/1.1"}) {
+		t.Errorf("ClientHello to chrome tls_fingerprint site = %v, %v, want Chrome's GREASE and ALPN", hello.CipherSuites, hello.SupportedProtos)
+	}
+	if greased(receivedHello(t, plainHellos)) {
+		t.Errorf("ClientHello to other site has GREASE, want Go's own")
+	}
+}
+
+// receivedHello returns the first ClientHello recorded in hellos
+func receivedHello(t *testing.T, hellos chan *tls.ClientHelloInfo) *tls.ClientHelloInfo {
+	select {
+	case hello := <-hellos:
+		return hello
+	default:
+		t.Fatal("no TLS handshake")
+		return nil
+	}
+}
+
+func TestValidateConfigTLSFingerprint(t *testing.T) {
+	c := &common.Config{
+		Sites: map[string]common.SiteConfig{
+			"site": {URL: "https://example.com", Language: "kr", TLSFingerprint: "firefox"},
+		},
+		Timeout: 10,
+	}
+	want := `site site: tls_fingerprint "firefox" is not chrome`
+	if problems := common.ValidateConfig(c); !reflect.DeepEqual(problems, []string{want}) {
+		t.Errorf("ValidateConfig() = %q, want [%q]", problems, want)
+	}
+}
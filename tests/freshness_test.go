@@ -0,0 +1,42 @@
+package tests
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/daite/tspider/common"
+)
+
+func TestFreshnessScore(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		name string
+		r    common.SearchResult
+		want string
+	}{
+		{"no data", common.SearchResult{}, "unknown"},
+		{"recent and seeded", common.SearchResult{Seeders: 25, Date: now.Add(-48 * time.Hour)}, "high"},
+		{"months old", common.SearchResult{Seeders: 25, Date: now.AddDate(0, -3, 0)}, "medium"},
+		{"years old", common.SearchResult{Seeders: 2, Date: now.AddDate(-2, 0, 0)}, "low"},
+		{"new without seeders", common.SearchResult{Date: now.Add(-time.Hour)}, "low"},
+		{"old without seeders", common.SearchResult{Date: now.AddDate(-1, 0, 0)}, "dead"},
+		{"undated but seeded", common.SearchResult{Seeders: 3}, "low"},
+	}
+	for _, tc := range cases {
+		if got := common.FreshnessScore(tc.r); got != tc.want {
+			t.Errorf("%s: FreshnessScore = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestPrintTableFreshnessColumn(t *testing.T) {
+	data := []common.SearchResult{{Title: "a", Magnet: "magnet:?xt=urn:btih:aa", Seeders: 12, Date: time.Now()}}
+	var buf bytes.Buffer
+	common.PrintTable(&buf, append(common.DataColumns, common.FreshnessColumn), data)
+	out := buf.String()
+	if !strings.Contains(out, "FRESHNESS") || !strings.Contains(out, "high") {
+		t.Errorf("table is missing the freshness column:\n%s", out)
+	}
+}
@@ -0,0 +1,67 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/daite/tspider/common"
+)
+
+func TestSiteHeaders(t *testing.T) {
+	useTempHome(t)
+	got := map[string]http.Header{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got[r.URL.Path] = r.Header.Clone()
+	}))
+	defer srv.Close()
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got["other"] = r.Header.Clone()
+	}))
+	defer other.Close()
+
+	c := common.DefaultConfig()
+	c.Sites["picky"] = common.SiteConfig{URL: srv.URL, Enabled: true, Language: "kr", Headers: map[string]string{
+		"Referer":         srv.URL + "/",
+		"Accept-Language": "ko",
+	}}
+	if err := common.SaveConfig(c); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, u := range []string{srv.URL + "/search", other.URL} {
+		resp, ok := common.GetResponseFromURL(context.Background(), u)
+		if !ok {
+			t.Fatalf("GetResponseFromURL(%s) failed", u)
+		}
+		resp.Body.Close()
+	}
+	if h := got["/search"]; h.Get("Referer") != srv.URL+"/" || h.Get("Accept-Language") != "ko" {
+		t.Errorf("site request headers = %v, want the configured Referer and Accept-Language", h)
+	}
+	if h := got["other"]; h.Get("Referer") != "" || h.Get("Accept-Language") != "" {
+		t.Errorf("other request headers = %v, want none of the site's", h)
+	}
+}
+
+func TestValidateConfigHeaders(t *testing.T) {
+	c := &common.Config{
+		Sites: map[string]common.SiteConfig{
+			"site": {URL: "https://example.com", Language: "kr", Headers: map[string]string{
+				"Referer":  "https://example.com/",
+				"Bad Name": "x",
+				"X-Split":  "a\r\nInjected: b",
+			}},
+		},
+		Timeout: 10,
+	}
+	want := []string{
+		`site site: invalid header "Bad Name": "x"`,
+		`site site: invalid header "X-Split": "a\r\nInjected: b"`,
+	}
+	if got := common.ValidateConfig(c); !reflect.DeepEqual(got, want) {
+		t.Errorf("ValidateConfig() = %q, want %q", got, want)
+	}
+}
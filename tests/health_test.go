@@ -0,0 +1,48 @@
+package tests
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/daite/tspider/common"
+)
+
+func TestHealthKeywordFor(t *testing.T) {
+	useTempHome(t)
+	c := common.DefaultConfig()
+	if err := common.SaveConfig(c); err != nil {
+		t.Fatal(err)
+	}
+	if got := common.HealthKeywordFor("jp"); got != "1080p" {
+		t.Errorf("HealthKeywordFor(jp) default = %q, want %q", got, "1080p")
+	}
+	c.HealthKeyword = "2024"
+	c.HealthKeywords = map[string]string{"kr": "드라마"}
+	if err := common.SaveConfig(c); err != nil {
+		t.Fatal(err)
+	}
+	if got := common.HealthKeywordFor("kr"); got != "드라마" {
+		t.Errorf("HealthKeywordFor(kr) = %q, want the per-language keyword", got)
+	}
+	if got := common.HealthKeywordFor("jp"); got != "2024" {
+		t.Errorf("HealthKeywordFor(jp) = %q, want the config-wide keyword", got)
+	}
+}
+
+func TestPrintDoctorStatusShowsDegraded(t *testing.T) {
+	statuses := []common.SiteStatus{
+		{Name: "nyaa", URL: "https://nyaa.si", Available: true, Enabled: true, Results: 75},
+		{Name: "sukebe", URL: "https://sukebei.nyaa.si", Available: true, Enabled: true, Degraded: true, Error: `no results for "1080p"`},
+		{Name: "torrenttop", URL: "https://torrenttop152.com", Error: "HTTP 503"},
+	}
+	var buf bytes.Buffer
+	common.PrintDoctorStatus(&buf, statuses)
+	out := buf.String()
+	if !strings.Contains(out, "DEGRADED") {
+		t.Errorf("output has no DEGRADED row:\n%s", out)
+	}
+	if !strings.Contains(out, "Total: 3 sites, 1 available, 1 degraded, 1 down") {
+		t.Errorf("output has the wrong totals:\n%s", out)
+	}
+}
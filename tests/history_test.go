@@ -0,0 +1,155 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/daite/tspider/common"
+)
+
+func TestRecentSearch(t *testing.T) {
+	useTempHome(t)
+	if _, err := common.RecentSearch(1); err == nil {
+		t.Errorf("RecentSearch(1) with no history = nil error, want error")
+	}
+	for _, kw := range []string{"first", "second", "third"} {
+		err := common.AppendHistory(common.HistoryEntry{Time: time.Now(), Keyword: kw, Args: []string{"-l", "kr", kw}})
+		if err != nil {
+			t.Fatalf("AppendHistory() = %v", err)
+		}
+	}
+	got, err := common.RecentSearch(1)
+	if err != nil {
+		t.Fatalf("RecentSearch(1) = %v", err)
+	}
+	if want := []string{"-l", "kr", "third"}; !reflect.DeepEqual(got.Args, want) {
+		t.Errorf("RecentSearch(1).Args = %q, want %q", got.Args, want)
+	}
+	if got, _ := common.RecentSearch(3); got == nil || got.Keyword != "first" {
+		t.Errorf("RecentSearch(3) = %+v, want keyword first", got)
+	}
+	if _, err := common.RecentSearch(4); err == nil {
+		t.Errorf("RecentSearch(4) with 3 entries = nil error, want error")
+	}
+}
+
+func TestRecordHistory(t *testing.T) {
+	useTempHome(t)
+	// An entry from before searches were numbered
+	if err := common.AppendHistory(common.HistoryEntry{Time: time.Now(), Keyword: "old"}); err != nil {
+		t.Fatalf("AppendHistory() = %v", err)
+	}
+	results := []common.SearchResult{
+		{Site: "nyaa", Title: "Show 01", Magnet: "magnet:?xt=urn:btih:aaa"},
+		{Site: "nyaa", Title: "Show 02", Magnet: "magnet:?xt=urn:btih:bbb"},
+		{Site: "sukebe", Title: "Show 01", Magnet: "magnet:?xt=urn:btih:ccc"},
+	}
+	e, err := common.RecordHistory(common.HistoryEntry{Time: time.Now(), Keyword: "show", Lang: "jp"}, results)
+	if err != nil {
+		t.Fatalf("RecordHistory() = %v", err)
+	}
+	if e.ID != 2 || e.Results != 3 || !reflect.DeepEqual(e.Sites, map[string]int{"nyaa": 2, "sukebe": 1}) {
+		t.Errorf("RecordHistory() = %+v, want #2 with 3 results, 2 on nyaa and 1 on sukebe", e)
+	}
+
+	got, err := common.HistoryByID(2)
+	if err != nil || got.Keyword != "show" || got.Sites["nyaa"] != 2 {
+		t.Errorf("HistoryByID(2) = %+v, %v; want the show search", got, err)
+	}
+	stored, err := common.LoadHistoryResults(2)
+	if err != nil {
+		t.Fatalf("LoadHistoryResults(2) = %v", err)
+	}
+	if !reflect.DeepEqual(stored, results) {
+		t.Errorf("LoadHistoryResults(2) = %+v, want %+v", stored, results)
+	}
+	if _, err := common.LoadHistoryResults(1); err == nil {
+		t.Errorf("LoadHistoryResults() of a search without stored results = nil error, want error")
+	}
+	if _, err := common.HistoryByID(3); err == nil {
+		t.Errorf("HistoryByID() of an unknown id = nil error, want error")
+	}
+}
+
+func TestRecordHistoryConcurrently(t *testing.T) {
+	useTempHome(t)
+	const n = 20
+	ids := make(chan int, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results := []common.SearchResult{{Site: "nyaa", Title: fmt.Sprintf("Show %02d", i)}}
+			e, err := common.RecordHistory(common.HistoryEntry{Time: time.Now(), Keyword: "show"}, results)
+			if err != nil {
+				t.Errorf("RecordHistory() = %v", err)
+				return
+			}
+			stored, err := common.LoadHistoryResults(e.ID)
+			if err != nil || len(stored) != 1 || stored[0].Title != results[0].Title {
+				t.Errorf("LoadHistoryResults(%d) = %+v, %v; want %q", e.ID, stored, err, results[0].Title)
+			}
+			ids <- e.ID
+		}(i)
+	}
+	wg.Wait()
+	close(ids)
+	seen := map[int]bool{}
+	for id := range ids {
+		if seen[id] {
+			t.Errorf("search #%d recorded twice", id)
+		}
+		seen[id] = true
+	}
+	if entries, _ := common.LoadHistory(); len(entries) != n {
+		t.Errorf("LoadHistory() has %d entries, want %d", len(entries), n)
+	}
+}
+
+func TestHistoryMaxEntries(t *testing.T) {
+	useTempHome(t)
+	c := common.DefaultConfig()
+	c.HistoryMaxEntries = 2
+	if err := common.SaveConfig(c); err != nil {
+		t.Fatal(err)
+	}
+	// Results left behind by a search no longer in the history
+	if err := os.MkdirAll(common.GetHistoryResultsDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	stale := filepath.Join(common.GetHistoryResultsDir(), "0.json")
+	if err := os.WriteFile(stale, []byte("[]"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, keyword := range []string{"one", "two", "three"} {
+		results := []common.SearchResult{{Site: "nyaa", Title: keyword}}
+		if _, err := common.RecordHistory(common.HistoryEntry{Time: time.Now(), Keyword: keyword}, results); err != nil {
+			t.Fatalf("RecordHistory(%s) = %v", keyword, err)
+		}
+	}
+
+	entries, err := common.LoadHistory()
+	if err != nil || len(entries) != 2 || entries[0].ID != 2 || entries[1].Keyword != "three" {
+		t.Errorf("LoadHistory() = %+v, %v; want searches #2 and #3", entries, err)
+	}
+	if _, err := common.LoadHistoryResults(1); err == nil {
+		t.Errorf("LoadHistoryResults(1) of a pruned search = nil error, want error")
+	}
+	if _, err := common.LoadHistoryResults(3); err != nil {
+		t.Errorf("LoadHistoryResults(3) = %v", err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("stale results file kept: %v", err)
+	}
+	// Numbering goes on after the pruned searches
+	e, err := common.RecordHistory(common.HistoryEntry{Time: time.Now(), Keyword: "four"}, nil)
+	if err != nil || e.ID != 4 {
+		t.Errorf("RecordHistory() after pruning = #%d, %v; want #4", e.ID, err)
+	}
+}
@@ -0,0 +1,111 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/daite/tspider/common"
+)
+
+// pngHeader is enough of a PNG for content sniffing
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestWriteHTMLEmbedsFavicons(t *testing.T) {
+	useTempHome(t)
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/favicon.ico" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(pngHeader)
+	}))
+	defer srv.Close()
+	broken := httptest.NewServer(http.NotFoundHandler())
+	defer broken.Close()
+	common.UseSiteURL("iconsite", srv.URL)
+	common.UseSiteURL("noiconsite", broken.URL)
+	defer common.StopSite("iconsite")
+	defer common.StopSite("noiconsite")
+
+	results := []common.SearchResult{
+		{Site: "iconsite", Title: "with icon", Magnet: "magnet:?xt=urn:btih:6bb34701c93505114029e5c91a0e88a30c11703b"},
+		{Site: "noiconsite", Title: "without icon", Magnet: "failed to fetch magnet"},
+	}
+	var buf bytes.Buffer
+	if err := common.WriteHTML(&buf, "test", results); err != nil {
+		t.Fatalf("WriteHTML() = %v", err)
+	}
+	got := buf.String()
+	if strings.Count(got, `<img src="data:image/png;base64,`) != 1 {
+		t.Errorf("WriteHTML() should embed exactly one favicon, got:\n%s", got)
+	}
+	if !strings.Contains(got, `href="magnet:?xt=urn:btih:6bb34701c93505114029e5c91a0e88a30c11703b"`) {
+		t.Errorf("WriteHTML() did not link the magnet, got:\n%s", got)
+	}
+	if !strings.Contains(got, "without icon") {
+		t.Errorf("WriteHTML() dropped the site without a favicon")
+	}
+}
+
+func TestFaviconCacheExpires(t *testing.T) {
+	useTempHome(t)
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	var fetches int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		w.Write(pngHeader)
+	}))
+	defer srv.Close()
+	broken := httptest.NewServer(http.NotFoundHandler())
+	defer broken.Close()
+
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		t.Skip(err)
+	}
+	const cached = "data:image/png;base64,Y2FjaGVk"
+	cache := func(site string, age time.Duration) {
+		path := filepath.Join(cacheDir, "tspider", "favicons", site)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(cached), 0644); err != nil {
+			t.Fatal(err)
+		}
+		old := time.Now().Add(-age)
+		if err := os.Chtimes(path, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fetched := "data:image/png;base64," + base64.StdEncoding.EncodeToString(pngHeader)
+	for _, tc := range []struct {
+		site, url string
+		age       time.Duration
+		want      string
+		fetches   int32
+	}{
+		{"fresh-icon", srv.URL, time.Hour, cached, 0},
+		{"expired-icon", srv.URL, 31 * 24 * time.Hour, fetched, 1},
+		{"expired-unreachable-icon", broken.URL, 31 * 24 * time.Hour, cached, 0},
+	} {
+		common.UseSiteURL(tc.site, tc.url)
+		defer common.StopSite(tc.site)
+		cache(tc.site, tc.age)
+		atomic.StoreInt32(&fetches, 0)
+		if got := common.Favicon(tc.site); got != tc.want {
+			t.Errorf("Favicon(%s) = %q, want %q", tc.site, got, tc.want)
+		}
+		if n := atomic.LoadInt32(&fetches); n != tc.fetches {
+			t.Errorf("Favicon(%s) fetched the icon %d time(s), want %d", tc.site, n, tc.fetches)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package tests
+
+import (
+	"context"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/daite/tspider/common"
+)
+
+func TestHTTPClientCapsTotalConnections(t *testing.T) {
+	const maxTotal = 4
+	var inFlight, peak int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		io.WriteString(w, "ok")
+	})
+	// Several hosts so the per-host limit alone cannot enforce the cap
+	servers := make([]*httptest.Server, 3)
+	for i := range servers {
+		servers[i] = httptest.NewServer(handler)
+		defer servers[i].Close()
+	}
+
+	client := common.NewHTTPClient(&common.Config{Timeout: 10, MaxTotalConns: maxTotal, MaxConnsPerHost: maxTotal})
+	var wg sync.WaitGroup
+	for i := 0; i < 60; i++ {
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+			resp, err := client.Get(url)
+			if err != nil {
+				t.Errorf("Get(%s) = %v", url, err)
+				return
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}(servers[i%len(servers)].URL)
+	}
+	wg.Wait()
+
+	if peak > maxTotal {
+		t.Errorf("peak concurrent connections = %d, want at most %d", peak, maxTotal)
+	}
+	if peak < 2 {
+		t.Errorf("peak concurrent connections = %d, want the load to use several connections", peak)
+	}
+}
+
+// TestHTTPClientFreesIdleSlot checks that with a single connection slot a
+// request to a second host is not starved by the first host's connection
+// once it is idle again. A request queued for the first host in between
+// makes the transport keep its next idle connection, so closing the idle
+// connections once, when the wait starts, is not enough.
+func TestHTTPClientFreesIdleSlot(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	busy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		io.WriteString(w, "busy")
+	}))
+	defer busy.Close()
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "other")
+	}))
+	defer other.Close()
+
+	client := common.NewHTTPClient(&common.Config{Timeout: 10, MaxTotalConns: 1, MaxConnsPerHost: 1})
+	get := func(ctx context.Context, url string, done chan<- error) {
+		req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			done <- err
+			return
+		}
+		io.Copy(io.Discard, resp.Body)
+		done <- resp.Body.Close()
+	}
+	// The requests to busy are not canceled once done, which would close
+	// their connection instead of keeping it alive
+	busyDone := make(chan error, 2)
+	go get(context.Background(), busy.URL, busyDone)
+	<-started
+	otherDone := make(chan error, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	go get(ctx, other.URL, otherDone)
+	time.Sleep(50 * time.Millisecond)
+	go get(context.Background(), busy.URL, busyDone)
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	for i := 0; i < 2; i++ {
+		if err := <-busyDone; err != nil {
+			t.Fatalf("Get(busy) = %v", err)
+		}
+	}
+	if err := <-otherDone; err != nil {
+		t.Errorf("Get(other) = %v, want the idle connection of busy closed for it", err)
+	}
+}
+
+// TestHelperChromeIdleSlot is run as a subprocess by
+// TestHTTPClientClosesIdleChromeConnections, with SSL_CERT_FILE trusting the
+// chrome site's test certificate
+func TestHelperChromeIdleSlot(t *testing.T) {
+	picky, plain := os.Getenv("TSPIDER_HELPER_CHROME_SITE"), os.Getenv("TSPIDER_HELPER_PLAIN_SITE")
+	if picky == "" {
+		t.Skip("helper process only")
+	}
+	c := &common.Config{
+		Timeout:         3,
+		MaxTotalConns:   1,
+		MaxConnsPerHost: 1,
+		Sites: map[string]common.SiteConfig{
+			"picky": {URL: picky, Language: "jp", Enabled: true, TLSFingerprint: common.TLSFingerprintChrome},
+		},
+	}
+	if err := common.SaveConfig(c); err != nil {
+		t.Fatal(err)
+	}
+	client := common.NewHTTPClient(c)
+	for _, u := range []string{picky, plain} {
+		resp, err := client.Get(u)
+		if err != nil {
+			t.Fatalf("Get(%s) = %v", u, err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+}
+
+func TestHTTPClientClosesIdleChromeConnections(t *testing.T) {
+	home := useTempHome(t)
+	picky := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "picky")
+	}))
+	defer picky.Close()
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "plain")
+	}))
+	defer plain.Close()
+	certFile := filepath.Join(t.TempDir(), "cert.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: picky.Certificate().Raw})
+	if err := os.WriteFile(certFile, cert, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The connection to picky stays idle in the chrome transport, holding the
+	// only slot the request to plain needs
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperChromeIdleSlot$")
+	cmd.Env = append(os.Environ(), "HOME="+home, "USERPROFILE="+home, "SSL_CERT_FILE="+certFile,
+		"TSPIDER_HELPER_CHROME_SITE="+picky.URL, "TSPIDER_HELPER_PLAIN_SITE="+plain.URL)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("helper: %v\n%s", err, out)
+	}
+}
@@ -0,0 +1,161 @@
+package tests
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/daite/tspider/common"
+)
+
+func TestHTTPCacheRevalidates(t *testing.T) {
+	var full, conditional int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/etag":
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				conditional++
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"v1"`)
+		case "/modified":
+			if r.Header.Get("If-Modified-Since") == "Fri, 16 Oct 2026 10:00:00 GMT" {
+				conditional++
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("Last-Modified", "Fri, 16 Oct 2026 10:00:00 GMT")
+		case "/no-store":
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("Cache-Control", "no-store")
+		}
+		full++
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, "<html>page "+r.URL.Path+"</html>")
+	}))
+	defer srv.Close()
+
+	cache := common.NewHTTPCache(t.TempDir(), 0, http.DefaultTransport)
+	client := &http.Client{Transport: cache}
+	get := func(path string) string {
+		t.Helper()
+		resp, err := client.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("GET %s = %d, want 200", path, resp.StatusCode)
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != "text/html; charset=utf-8" {
+			t.Errorf("GET %s Content-Type = %q, want the stored header", path, ct)
+		}
+		return string(body)
+	}
+	for _, path := range []string{"/etag", "/modified", "/no-store", "/plain"} {
+		for i := 0; i < 2; i++ {
+			if body, want := get(path), "<html>page "+path+"</html>"; body != want {
+				t.Errorf("GET %s #%d = %q, want %q", path, i+1, body, want)
+			}
+		}
+	}
+	// /etag and /modified are downloaded once and revalidated once; the
+	// others are downloaded each time
+	if full != 6 || conditional != 2 || cache.Revalidated() != 2 {
+		t.Errorf("%d full and %d conditional requests, %d served from disk; want 6, 2 and 2", full, conditional, cache.Revalidated())
+	}
+}
+
+func TestHTTPCacheDropsCredentials(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Header().Set("WWW-Authenticate", `Basic realm="x"`)
+		w.Header().Set("Connection", "X-Hop")
+		w.Header().Set("X-Hop", "1")
+		io.WriteString(w, "<html>members</html>")
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	client := &http.Client{Transport: common.NewHTTPCache(dir, 0, http.DefaultTransport)}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	for _, name := range []string{"Set-Cookie", "WWW-Authenticate", "X-Hop"} {
+		if v := resp.Header.Get(name); v != "" {
+			t.Errorf("revalidated page has %s: %q, want it left out of the cache", name, v)
+		}
+	}
+	if resp.Header.Get("Content-Type") != "text/html" {
+		t.Errorf("revalidated page lost its Content-Type")
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) == 0 {
+		t.Fatal("nothing was cached")
+	}
+	for _, e := range entries {
+		data, _ := os.ReadFile(filepath.Join(dir, e.Name()))
+		if strings.Contains(string(data), "secret") {
+			t.Errorf("%s holds the cookie", e.Name())
+		}
+		if runtime.GOOS == "windows" {
+			continue
+		}
+		if info, err := e.Info(); err == nil && info.Mode().Perm() != 0600 {
+			t.Errorf("%s has mode %v, want 0600", e.Name(), info.Mode().Perm())
+		}
+	}
+}
+
+func TestHTTPCachePrunes(t *testing.T) {
+	dir := t.TempDir()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		io.WriteString(w, "page")
+	}))
+	defer srv.Close()
+	client := &http.Client{Transport: common.NewHTTPCache(dir, time.Hour, http.DefaultTransport)}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.ReadAll(resp.Body)
+	resp.Body.Close()
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if len(files) != 2 {
+		t.Fatalf("cache holds %d file(s), want the page and its metadata", len(files))
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	for _, f := range files {
+		os.Chtimes(f, old, old)
+	}
+	common.NewHTTPCache(dir, time.Hour, http.DefaultTransport)
+	if files, _ := filepath.Glob(filepath.Join(dir, "*")); len(files) != 0 {
+		t.Errorf("pruning left %v, want nothing", files)
+	}
+}
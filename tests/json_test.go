@@ -0,0 +1,43 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/daite/tspider/common"
+)
+
+func TestWriteJSONSharesSchemaAcrossSites(t *testing.T) {
+	results := []common.SearchResult{
+		// Korean sites only provide a title and a magnet
+		{Site: "torrenttop", Title: "kr title", Magnet: "magnet:?xt=urn:btih:aaa"},
+		{Site: "nyaa", Title: "jp title", Magnet: "magnet:?xt=urn:btih:bbb", Uploader: "sub", Seeders: 12, Size: "1.2 GiB"},
+	}
+	var buf bytes.Buffer
+	if err := common.WriteJSON(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	var got []map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("WriteJSON() wrote invalid JSON: %v\n%s", err, buf.String())
+	}
+	if len(got) != 2 {
+		t.Fatalf("WriteJSON() wrote %d results, want 2", len(got))
+	}
+	for _, key := range []string{"site", "title", "magnet"} {
+		if got[0][key] == nil || got[1][key] == nil {
+			t.Errorf("WriteJSON() result without %q: %v", key, got)
+		}
+	}
+	if _, ok := got[0]["seeders"]; ok {
+		t.Errorf("WriteJSON() = %v, want seeders omitted for a site without them", got[0])
+	}
+	if got[1]["seeders"] != float64(12) || got[1]["size"] != "1.2 GiB" {
+		t.Errorf("WriteJSON() = %v, want seeders and size of the jp result", got[1])
+	}
+	if !strings.HasSuffix(buf.String(), "\n") {
+		t.Errorf("WriteJSON() output does not end with a newline")
+	}
+}
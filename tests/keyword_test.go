@@ -0,0 +1,56 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/daite/tspider/common"
+)
+
+func TestTransformKeyword(t *testing.T) {
+	tests := map[string]string{
+		"naruto":                  "naruto",
+		"  naruto   shippuden \t": "naruto shippuden",
+		"동상이몽2  너는 내운명":           "동상이몽2 너는 내운명",
+	}
+	for in, want := range tests {
+		if got := common.TransformKeyword(in); got != want {
+			t.Errorf("TransformKeyword(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestMatchPhrase(t *testing.T) {
+	tests := []struct {
+		title, phrase string
+		want          bool
+	}{
+		{"One.Piece.E1000.1080p", "one piece", true},
+		{"[Sub] One_Piece - 1000", "One Piece", true},
+		{"Piece of One", "one piece", false},
+		{"Someone Piece", "one piece", false},
+		{"anything", "", true},
+	}
+	for _, tc := range tests {
+		if got := common.MatchPhrase(tc.title, tc.phrase); got != tc.want {
+			t.Errorf("MatchPhrase(%q, %q) = %v, want %v", tc.title, tc.phrase, got, tc.want)
+		}
+	}
+}
+
+func TestFilterPhraseFallsBackLocally(t *testing.T) {
+	results := []common.SearchResult{
+		{Site: "torrenttop", Title: "One Piece 1000"},
+		{Site: "torrenttop", Title: "Piece One 1000"},
+		// Phrase sites already matched the phrase server side
+		{Site: "nyaa", Title: "OP - 1000"},
+	}
+	var got []string
+	for _, r := range common.FilterPhrase(results, "one piece") {
+		got = append(got, r.Title)
+	}
+	want := []string{"One Piece 1000", "OP - 1000"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("FilterPhrase() = %q, want %q", got, want)
+	}
+}
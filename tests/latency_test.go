@@ -0,0 +1,144 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/daite/tspider/common"
+)
+
+func TestLatencyHistoryTrends(t *testing.T) {
+	useTempHome(t)
+	if err := common.SaveConfig(common.DefaultConfig()); err != nil {
+		t.Fatal(err)
+	}
+	history, err := common.LoadLatencyHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	old := []time.Duration{100, 110, 0, 120, 130, 300, 320}
+	for i, ms := range old {
+		history.Record(start.Add(time.Duration(i)*time.Hour), []common.SiteStatus{
+			{Name: "qq", URL: "https://qq1.example", Available: ms > 0, Latency: ms * time.Millisecond},
+		})
+	}
+	// The site then moved to a new mirror
+	history.Record(start.Add(10*time.Hour), []common.SiteStatus{
+		{Name: "qq", URL: "https://qq2.example", Available: true, Latency: 50 * time.Millisecond},
+		{Name: "other", URL: "https://other.example", Available: false},
+	})
+	if err := history.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err = common.LoadLatencyHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	trends := history.Trends("qq")
+	if len(trends) != 2 {
+		t.Fatalf("Trends(qq) = %+v, want one per URL", trends)
+	}
+	if trends[0].URL != "https://qq2.example" {
+		t.Errorf("first trend is of %s, want the URL checked last", trends[0].URL)
+	}
+	old1 := trends[1]
+	if old1.Checks != 7 || old1.Up != 6 {
+		t.Errorf("checks/up = %d/%d, want 7/6", old1.Checks, old1.Up)
+	}
+	if old1.Median != 130*time.Millisecond || old1.LastLatency != 320*time.Millisecond {
+		t.Errorf("median/last = %s/%s, want 130ms/320ms", old1.Median, old1.LastLatency)
+	}
+	if old1.Direction != "slower" {
+		t.Errorf("direction = %q, want slower", old1.Direction)
+	}
+	if old1.Sparkline != "▁▁·▁▁▇█" {
+		t.Errorf("sparkline = %q", old1.Sparkline)
+	}
+	if n := len(history.Trends("")); n != 3 {
+		t.Errorf("Trends(\"\") returned %d rows, want 3", n)
+	}
+
+	var out bytes.Buffer
+	common.PrintLatencyTrends(&out, history.Trends(""))
+	for _, want := range []string{"https://qq1.example", "85%", "130ms", "slower", "other"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output lacks %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestRankSites(t *testing.T) {
+	useTempHome(t)
+	if err := common.SaveConfig(common.DefaultConfig()); err != nil {
+		t.Fatal(err)
+	}
+	history, err := common.LoadLatencyHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for i := 0; i < 10; i++ {
+		history.Record(now, []common.SiteStatus{
+			{Name: "slow", URL: "https://slow.example", Available: true, Latency: 900 * time.Millisecond},
+			{Name: "fast", URL: "https://fast.example", Available: true, Latency: 100 * time.Millisecond},
+			// Up half the time, however fast
+			{Name: "flaky", URL: "https://flaky.example", Available: i%2 == 0, Latency: 10 * time.Millisecond},
+		})
+	}
+	if err := history.Save(); err != nil {
+		t.Fatal(err)
+	}
+	got := common.RankSites([]string{"flaky", "new", "slow", "fast"})
+	want := []string{"fast", "slow", "new", "flaky"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("RankSites() = %v, want %v", got, want)
+	}
+}
+
+func TestGetAvailableSitesCapsSitesByRank(t *testing.T) {
+	useTempHome(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	c := common.DefaultConfig()
+	c.MaxSites = 2
+	c.Sites = map[string]common.SiteConfig{
+		"a": {URL: srv.URL + "/a", Language: "kr", Enabled: true},
+		"b": {URL: srv.URL + "/b", Language: "kr", Enabled: true},
+		"c": {URL: srv.URL + "/c", Language: "kr", Enabled: true},
+	}
+	if err := common.SaveConfig(c); err != nil {
+		t.Fatal(err)
+	}
+	history, err := common.LoadLatencyHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		history.Record(time.Now(), []common.SiteStatus{{Name: "a", URL: srv.URL + "/a", Available: i%2 == 0}})
+	}
+	if err := history.Save(); err != nil {
+		t.Fatal(err)
+	}
+	common.Availability().Reset()
+
+	sites, spinner := common.GetAvailableSites(context.Background(), map[string]common.Scraper{"a": fastSite{}, "b": fastSite{}, "c": fastSite{}})
+	spinner.Stop()
+	if _, ok := sites["a"]; ok || len(sites) != 2 {
+		t.Errorf("GetAvailableSites() = %v, want b and c, a being the least reliable", sites)
+	}
+	// The checks of the search are recorded
+	history, err = common.LoadLatencyHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(history.Sites["b"]); n != 1 {
+		t.Errorf("b has %d recorded checks, want 1", n)
+	}
+}
@@ -0,0 +1,24 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/daite/tspider/common"
+)
+
+func TestOpenLinkRejectsNonMagnets(t *testing.T) {
+	for _, link := range []string{
+		"file:///etc/passwd",
+		"/usr/bin/xterm",
+		"https://example.com/x.torrent",
+		"--help",
+		"magnet:?xt=urn:btih:",
+		"no magnet",
+	} {
+		err := common.OpenLink(link)
+		if err == nil || !strings.Contains(err.Error(), "not a magnet") {
+			t.Errorf("OpenLink(%q) = %v, want it refused", link, err)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+//go:build live
+
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/daite/tspider/common"
+	"github.com/daite/tspider/jtorrent"
+	"github.com/daite/tspider/ktorrent"
+)
+
+// Live tests hit the real sites with their health keyword. Run them with
+// go test -tags live ./tests
+func TestLiveSitesReturnResults(t *testing.T) {
+	sites := map[string]common.Scraper{"torrenttop": &ktorrent.TorrentTop{}, "nyaa": &jtorrent.Nyaa{}, "sukebe": &jtorrent.SuKeBe{}}
+	for name, s := range sites {
+		keyword := common.HealthKeywordFor(common.GetConfig().Sites[name].Language)
+		if len(s.Crawl(context.Background(), keyword)) == 0 {
+			t.Errorf("%s returned no results for %q", name, keyword)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/daite/tspider/common"
+)
+
+// privateTracker serves /search only to sessions logged in through /login
+func privateTracker(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login.php":
+			if r.Method == "POST" && r.FormValue("user") == "alice" && r.FormValue("pass") == "secret" {
+				http.SetCookie(w, &http.Cookie{Name: "session", Value: "alice", Path: "/"})
+				http.Redirect(w, r, "/index.php", http.StatusFound)
+				return
+			}
+			io.WriteString(w, "<form>wrong password</form>")
+		case "/search":
+			if c, err := r.Cookie("session"); err != nil || c.Value != "alice" {
+				http.Error(w, "log in first", http.StatusForbidden)
+				return
+			}
+			io.WriteString(w, "results")
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func useLoginSite(t *testing.T, site common.SiteConfig) {
+	useTempHome(t)
+	common.ResetLogins()
+	t.Cleanup(common.ResetLogins)
+	c := common.DefaultConfig()
+	site.Enabled, site.Language = true, "kr"
+	c.Sites["private"] = site
+	if err := common.SaveConfig(c); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoginSitePostsForm(t *testing.T) {
+	srv := privateTracker(t)
+	useLoginSite(t, common.SiteConfig{
+		URL: srv.URL, Username: "alice", Password: "secret",
+		LoginURL: "/login.php", UserField: "user", PasswordField: "pass",
+	})
+	if err := common.LoginSite(context.Background(), "private", nil); err != nil {
+		t.Fatalf("LoginSite() = %v", err)
+	}
+	resp, ok := common.GetResponseFromURL(context.Background(), srv.URL+"/search")
+	if !ok {
+		t.Fatalf("search after login failed")
+	}
+	resp.Body.Close()
+}
+
+func TestLoginSiteRejectsWrongPassword(t *testing.T) {
+	// A server of its own, so no session from another test is in the jar
+	srv := privateTracker(t)
+	useLoginSite(t, common.SiteConfig{
+		URL: srv.URL, Username: "alice", Password: "wrong",
+		LoginURL: "/login.php", UserField: "user", PasswordField: "pass",
+	})
+	err := common.LoginSite(context.Background(), "private", nil)
+	if err == nil || !strings.Contains(err.Error(), "check username and password") {
+		t.Errorf("LoginSite() = %v, want a failed login", err)
+	}
+}
+
+func TestLoginSiteUsesCookie(t *testing.T) {
+	srv := privateTracker(t)
+	useLoginSite(t, common.SiteConfig{URL: srv.URL, Cookie: "session=alice; theme=dark"})
+	if err := common.LoginSite(context.Background(), "private", nil); err != nil {
+		t.Fatalf("LoginSite() = %v", err)
+	}
+	resp, ok := common.GetResponseFromURL(context.Background(), srv.URL+"/search")
+	if !ok {
+		t.Fatalf("search with the stored cookie failed")
+	}
+	resp.Body.Close()
+}
+
+type authSite struct{ logins *int }
+
+func (s authSite) Login(ctx context.Context, client *http.Client, site common.SiteConfig) error {
+	*s.logins++
+	return nil
+}
+
+func (s authSite) Crawl(ctx context.Context, keyword string) []common.SearchResult {
+	return []common.SearchResult{{Title: keyword + " private", Magnet: "magnet:?xt=urn:btih:private"}}
+}
+
+func TestCollectDataLogsInOnce(t *testing.T) {
+	useLoginSite(t, common.SiteConfig{URL: "https://private.example", Username: "alice", Password: "secret"})
+	var logins int
+	sites := map[string]common.Scraper{"private": authSite{&logins}}
+	for i := 0; i < 2; i++ {
+		got, _ := common.CollectData(context.Background(), sites, "test", common.NewSpinner("test"))
+		want := []common.SearchResult{{Site: "private", Title: "test private", Magnet: "magnet:?xt=urn:btih:private"}}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("CollectData() = %+v, want %+v", got, want)
+		}
+	}
+	if logins != 1 {
+		t.Errorf("logged in %d times, want once per run", logins)
+	}
+}
+
+// flakyAuthSite fails its first login and succeeds afterwards
+type flakyAuthSite struct{ logins *int }
+
+func (s flakyAuthSite) Login(ctx context.Context, client *http.Client, site common.SiteConfig) error {
+	*s.logins++
+	if *s.logins == 1 {
+		return fmt.Errorf("tracker is down")
+	}
+	return nil
+}
+
+func (s flakyAuthSite) Crawl(ctx context.Context, keyword string) []common.SearchResult {
+	return nil
+}
+
+func TestLoginSiteRetriesFailedLogin(t *testing.T) {
+	useLoginSite(t, common.SiteConfig{URL: "https://private.example", Username: "alice", Password: "secret"})
+	var logins int
+	site := flakyAuthSite{&logins}
+	if err := common.LoginSite(context.Background(), "private", site); err == nil {
+		t.Fatal("first LoginSite() = nil, want the failed login")
+	}
+	for i := 0; i < 2; i++ {
+		if err := common.LoginSite(context.Background(), "private", site); err != nil {
+			t.Fatalf("LoginSite() after a failure = %v, want a new login", err)
+		}
+	}
+	if logins != 2 {
+		t.Errorf("logged in %d times, want the failure retried once", logins)
+	}
+}
+
+func TestCollectDataSkipsSiteFailingLogin(t *testing.T) {
+	useLoginSite(t, common.SiteConfig{URL: "https://private.example", Username: "alice", Password: "secret"})
+	sites := map[string]common.Scraper{"private": fastSite{}}
+	spinner := common.NewSpinner("test")
+	if got, _ := common.CollectData(context.Background(), sites, "test", spinner); len(got) != 0 {
+		t.Errorf("CollectData() = %+v, want no results without a login", got)
+	}
+	warnings := spinner.Warnings()
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "private: login failed: set login_url") {
+		t.Errorf("Warnings() = %q, want the failed login", warnings)
+	}
+}
+
+func TestReadPasswordFromPipe(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	io.WriteString(w, "s3cret pass\r\nnext line\n")
+	w.Close()
+	var out strings.Builder
+	got, err := common.ReadPassword("Password: ", r, &out)
+	if err != nil || got != "s3cret pass" {
+		t.Errorf("ReadPassword() = %q, %v, want the first line", got, err)
+	}
+	if out.String() != "Password: " {
+		t.Errorf("ReadPassword() printed %q, want only the prompt", out.String())
+	}
+
+	empty, w2, _ := os.Pipe()
+	defer empty.Close()
+	w2.Close()
+	if _, err := common.ReadPassword("Password: ", empty, io.Discard); err == nil {
+		t.Errorf("ReadPassword() from empty input = nil error, want one")
+	}
+}
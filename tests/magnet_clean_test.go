@@ -0,0 +1,26 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/daite/tspider/common"
+)
+
+func TestCleanMagnetForClipboard(t *testing.T) {
+	const m = "magnet:?xt=urn:btih:087858c2626987779f9a3e107e4d12607a6e66aa"
+	tests := map[string]string{
+		m:                                m,
+		m + "\n":                         m,
+		m + "\r\n":                       m,
+		"  " + m + " \t":                 m,
+		"\x1b[200~" + m + "\x1b[201~":    m,
+		"\x1b[200~" + m + "\n\x1b[201~ ": m,
+		"magnet:?xt=urn:btih:08\x0078":   "magnet:?xt=urn:btih:0878",
+		"":                               "",
+	}
+	for in, want := range tests {
+		if got := common.CleanMagnetForClipboard(in); got != want {
+			t.Errorf("CleanMagnetForClipboard(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
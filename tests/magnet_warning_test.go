@@ -0,0 +1,59 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/daite/tspider/common"
+)
+
+type brokenMagnetSite struct {
+	failed, ok int
+}
+
+func (b brokenMagnetSite) Crawl(ctx context.Context, keyword string) []common.SearchResult {
+	var results []common.SearchResult
+	for i := 0; i < b.failed; i++ {
+		results = append(results, common.SearchResult{Title: fmt.Sprintf("%s failed %d", keyword, i), Magnet: "failed to fetch magnet"})
+	}
+	for i := 0; i < b.ok; i++ {
+		results = append(results, common.SearchResult{Title: fmt.Sprintf("%s ok %d", keyword, i), Magnet: fmt.Sprintf("magnet:?xt=urn:btih:%040d", i)})
+	}
+	return results
+}
+
+func TestCollectDataWarnsOnFailedMagnets(t *testing.T) {
+	tests := []struct {
+		name     string
+		site     brokenMagnetSite
+		warnings int
+	}{
+		{"all failed", brokenMagnetSite{failed: 5}, 1},
+		{"mostly failed", brokenMagnetSite{failed: 9, ok: 1}, 1},
+		{"at threshold", brokenMagnetSite{failed: 8, ok: 2}, 0},
+		{"healthy", brokenMagnetSite{ok: 5}, 0},
+	}
+	for _, tt := range tests {
+		spinner := common.NewSpinner("test")
+		common.CollectData(context.Background(), map[string]common.Scraper{"broken": tt.site}, "test", spinner)
+		if got := len(spinner.Warnings()); got != tt.warnings {
+			t.Errorf("%s: CollectData() queued %d warning(s), want %d", tt.name, got, tt.warnings)
+		}
+	}
+}
+
+func TestIsValidMagnet(t *testing.T) {
+	tests := map[string]bool{
+		"magnet:?xt=urn:btih:6bb34701c93505114029e5c91a0e88a30c11703b": true,
+		"magnet:?xt=urn:btih:":   false,
+		"failed to fetch magnet": false,
+		"no magnet":              false,
+		"parse error: EOF":       false,
+	}
+	for m, want := range tests {
+		if got := common.IsValidMagnet(m); got != want {
+			t.Errorf("IsValidMagnet(%q) = %v, want %v", m, got, want)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestMain runs the tests in a temporary home directory, so that the config
+// common loads on first use is never the user's own ~/.tspider.json. Helper
+// processes started by a test inherit TSPIDER_TEST_HOME and keep the HOME
+// that test gave them.
+func TestMain(m *testing.M) {
+	if os.Getenv("TSPIDER_TEST_HOME") != "" {
+		os.Exit(m.Run())
+	}
+	// The go tool finds its caches through HOME, and tests build tspider
+	pinGoEnv("GOCACHE", os.UserCacheDir, "go-build")
+	pinGoEnv("GOPATH", os.UserHomeDir, "go")
+	home, err := os.MkdirTemp("", "tspider-tests-")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Setenv("TSPIDER_TEST_HOME", home)
+	os.Setenv("HOME", home)
+	os.Setenv("USERPROFILE", home)
+	os.Setenv("XDG_CACHE_HOME", filepath.Join(home, ".cache"))
+	code := m.Run()
+	os.RemoveAll(home)
+	os.Exit(code)
+}
+
+// pinGoEnv sets key, when unset, to its default of elem under dir, before
+// HOME changes it
+func pinGoEnv(key string, dir func() (string, error), elem string) {
+	if os.Getenv(key) != "" {
+		return
+	}
+	if d, err := dir(); err == nil {
+		os.Setenv(key, filepath.Join(d, elem))
+	}
+}
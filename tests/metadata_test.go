@@ -0,0 +1,261 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/daite/tspider/metadata"
+)
+
+func TestBencodeRoundTrip(t *testing.T) {
+	v := map[string]interface{}{
+		"name":  "[MagicStar] Nijiiro Karte EP01",
+		"files": []interface{}{map[string]interface{}{"length": int64(42), "path": []interface{}{"a", "b.mkv"}}},
+	}
+	data, err := metadata.Encode(v)
+	if err != nil {
+		t.Fatalf("Encode() = %v", err)
+	}
+	got, n, err := metadata.Decode(append(data, "trailing"...))
+	if err != nil {
+		t.Fatalf("Decode() = %v", err)
+	}
+	if n != len(data) {
+		t.Errorf("Decode() consumed %d bytes, want %d", n, len(data))
+	}
+	if !reflect.DeepEqual(got, v) {
+		t.Errorf("Decode(Encode(v)) = %v, want %v", got, v)
+	}
+}
+
+func TestBencodeRejectsHostileInput(t *testing.T) {
+	for _, data := range []string{
+		"9223372036854775807:abc",
+		"9223372036854775806:abc",
+		"5:abc",
+		"-1:abc",
+		strings.Repeat("l", 100000) + strings.Repeat("e", 100000),
+		strings.Repeat("d1:a", 1000) + "i1e" + strings.Repeat("e", 1000),
+	} {
+		if _, _, err := metadata.Decode([]byte(data)); err == nil {
+			t.Errorf("Decode(%.30q) = nil error, want one", data)
+		}
+	}
+}
+
+func FuzzBencodeDecode(f *testing.F) {
+	for _, seed := range []string{"i42e", "4:spam", "l4:spami42ee", "d3:bar4:spam3:fooi42ee", "9223372036854775807:abc"} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// Decoding untrusted data must fail cleanly rather than panic
+		metadata.Decode(data)
+	})
+}
+
+func TestParseMagnet(t *testing.T) {
+	hex := "magnet:?xt=urn:btih:087858c2626987779f9a3e107e4d12607a6e66aa&dn=test"
+	b32 := "magnet:?xt=urn:btih:BB4FRQTCNGDXPH42HYIH4TISMB5G4ZVK"
+	h1, err := metadata.ParseMagnet(hex)
+	if err != nil {
+		t.Fatalf("ParseMagnet(hex) = %v", err)
+	}
+	h2, err := metadata.ParseMagnet(b32)
+	if err != nil {
+		t.Fatalf("ParseMagnet(base32) = %v", err)
+	}
+	if h1 != h2 || h1.String() != "087858c2626987779f9a3e107e4d12607a6e66aa" {
+		t.Errorf("ParseMagnet() = %s and %s, want both 087858c2626987779f9a3e107e4d12607a6e66aa", h1, h2)
+	}
+	if _, err := metadata.ParseMagnet("no magnet"); err == nil {
+		t.Errorf("ParseMagnet(%q) = nil error, want error", "no magnet")
+	}
+}
+
+func TestMetadataMatches(t *testing.T) {
+	info := &metadata.Info{
+		Name:  "[MagicStar] Nijiiro Karte EP01 [WEBDL] [1080p]",
+		Files: []metadata.File{{Path: "Nijiiro Karte EP01.mkv"}},
+	}
+	if !metadata.Matches("[MagicStar] Nijiiro Karte EP01 [WEBDL] [1080p]", info) {
+		t.Errorf("Matches() = false for identical name")
+	}
+	if !metadata.Matches("nijiiro karte ep01", info) {
+		t.Errorf("Matches() = false for case-insensitive substring")
+	}
+	if metadata.Matches("Totally Different Show S02E05", info) {
+		t.Errorf("Matches() = true for unrelated title")
+	}
+}
+
+// servePeer answers one BEP 9 metadata exchange with info
+func servePeer(t *testing.T, ln net.Listener, h [20]byte, info []byte) {
+	servePeerPieces(t, ln, h, info, nil)
+}
+
+// servePeerPieces is servePeer with each piece passed through tamper first
+func servePeerPieces(t *testing.T, ln net.Listener, h [20]byte, info []byte, tamper func(piece int64, data []byte) []byte) {
+	conn, err := ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	hs := make([]byte, 68)
+	if _, err := io.ReadFull(conn, hs); err != nil {
+		t.Errorf("peer: reading handshake: %v", err)
+		return
+	}
+	copy(hs[48:], bytes.Repeat([]byte("p"), 20))
+	conn.Write(hs)
+
+	send := func(ext byte, dict map[string]interface{}, extra []byte) {
+		body, _ := metadata.Encode(dict)
+		body = append(body, extra...)
+		msg := make([]byte, 6)
+		binary.BigEndian.PutUint32(msg, uint32(2+len(body)))
+		msg[4], msg[5] = 20, ext
+		conn.Write(append(msg, body...))
+	}
+	send(0, map[string]interface{}{
+		"m":             map[string]interface{}{"ut_metadata": 3},
+		"metadata_size": len(info),
+	}, nil)
+
+	var clientID int64
+	for {
+		var length uint32
+		if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+			return
+		}
+		msg := make([]byte, length)
+		if _, err := io.ReadFull(conn, msg); err != nil {
+			return
+		}
+		v, _, _ := metadata.Decode(msg[2:])
+		dict, _ := v.(map[string]interface{})
+		switch msg[1] {
+		case 0:
+			m, _ := dict["m"].(map[string]interface{})
+			clientID, _ = m["ut_metadata"].(int64)
+		case 3:
+			piece, _ := dict["piece"].(int64)
+			start := int(piece) * 16384
+			end := start + 16384
+			if end > len(info) {
+				end = len(info)
+			}
+			data := info[start:end]
+			if tamper != nil {
+				data = tamper(piece, data)
+			}
+			send(byte(clientID), map[string]interface{}{
+				"msg_type":   1,
+				"piece":      int(piece),
+				"total_size": len(info),
+			}, data)
+		}
+	}
+}
+
+func TestFetchFromPeer(t *testing.T) {
+	info, err := metadata.Encode(map[string]interface{}{
+		"name":         "Nijiiro Karte EP01",
+		"length":       1 << 30,
+		"piece length": 1 << 18,
+		// Large enough to need two metadata pieces
+		"pieces": strings.Repeat("x", 20*1000),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := sha1.Sum(info)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go servePeer(t, ln, h, info)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	got, err := metadata.FetchFromPeer(ctx, ln.Addr().String(), metadata.InfoHash(h))
+	if err != nil {
+		t.Fatalf("FetchFromPeer() = %v", err)
+	}
+	if got.Name != "Nijiiro Karte EP01" || len(got.Files) != 1 || got.Files[0].Length != 1<<30 {
+		t.Errorf("FetchFromPeer() = %+v, want name Nijiiro Karte EP01 with one 1GiB file", got)
+	}
+}
+
+func TestFetchFromPeerRejectsBadPieceLengths(t *testing.T) {
+	info, err := metadata.Encode(map[string]interface{}{
+		"name":         "Nijiiro Karte EP01",
+		"length":       1 << 30,
+		"piece length": 1 << 18,
+		"pieces":       strings.Repeat("x", 20*1000),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := sha1.Sum(info)
+	tests := []struct {
+		name   string
+		tamper func(piece int64, data []byte) []byte
+	}{
+		{"oversized piece", func(piece int64, data []byte) []byte {
+			if piece == 0 {
+				return append(append([]byte(nil), data...), 'x')
+			}
+			return data
+		}},
+		{"short last piece", func(piece int64, data []byte) []byte {
+			if piece == 1 {
+				return data[:len(data)-1]
+			}
+			return data
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ln.Close()
+			go servePeerPieces(t, ln, h, info, tt.tamper)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_, err = metadata.FetchFromPeer(ctx, ln.Addr().String(), metadata.InfoHash(h))
+			if err == nil || !strings.Contains(err.Error(), "bytes for piece") {
+				t.Errorf("FetchFromPeer() error = %v, want a piece length error", err)
+			}
+		})
+	}
+}
+
+func TestVerifyKeepsSharedTitles(t *testing.T) {
+	results := metadata.Verify(context.Background(), []metadata.Torrent{
+		{Title: "Nijiiro Karte EP01", Magnet: "magnet:?xt=urn:btih:bad"},
+		{Title: "Nijiiro Karte EP01", Magnet: "magnet:?xt=urn:btih:worse"},
+	})
+	if len(results) != 2 {
+		t.Fatalf("Verify() returned %d results, want 2", len(results))
+	}
+	if results[0].Magnet == results[1].Magnet {
+		t.Errorf("Verify() results share magnet %q, want one per torrent", results[0].Magnet)
+	}
+	for _, r := range results {
+		if r.Err == nil {
+			t.Errorf("Verify(%q) error = nil, want invalid magnet error", r.Magnet)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/daite/tspider/common"
+	"github.com/daite/tspider/server"
+)
+
+type failingSite struct{}
+
+func (failingSite) Crawl(ctx context.Context, keyword string) []common.SearchResult {
+	return nil
+}
+
+func TestMetrics(t *testing.T) {
+	useRetries(t, -1)
+	common.Metrics().Reset()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	if err := common.AddSite("metered", srv.URL, "kr"); err != nil {
+		t.Fatal(err)
+	}
+	if resp, ok := common.GetResponseFromURL(context.Background(), srv.URL+"/search"); ok {
+		resp.Body.Close()
+	}
+	common.GetResponseFromURL(context.Background(), srv.URL+"/missing")
+	sites := map[string]common.Scraper{"fast": fastSite{}, "broken": failingSite{}}
+	common.CollectData(context.Background(), sites, "test", common.NewSpinner("test"))
+
+	rec := httptest.NewRecorder()
+	server.MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE tspider_requests_total counter\n",
+		`tspider_requests_total{site="metered"} 2`,
+		`tspider_request_errors_total{site="metered"} 1`,
+		"# TYPE tspider_request_duration_seconds histogram\n",
+		`tspider_request_duration_seconds_bucket{site="metered",le="+Inf"} 2`,
+		`tspider_request_duration_seconds_count{site="metered"} 2`,
+		`tspider_searches_total{site="broken"} 1`,
+		`tspider_search_failures_total{site="broken"} 1`,
+		`tspider_search_failures_total{site="fast"} 0`,
+		`tspider_search_results_total{site="fast"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %q:\n%s", want, body)
+		}
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q, want the Prometheus text format", ct)
+	}
+}
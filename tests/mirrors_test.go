@@ -0,0 +1,74 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/daite/tspider/common"
+)
+
+func TestGetAvailableSitesFailsOverToMirror(t *testing.T) {
+	useTempHome(t)
+	useRetries(t, 0)
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer down.Close()
+	var probed []string
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		probed = append(probed, r.URL.Path+" "+r.Header.Get("Referer"))
+	}))
+	defer up.Close()
+	unused := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("mirror after the first one up was probed")
+	}))
+	defer unused.Close()
+
+	c := common.DefaultConfig()
+	c.Sites = map[string]common.SiteConfig{
+		"rotating": {URL: down.URL, URLs: []string{up.URL, unused.URL}, Language: "kr", Enabled: true,
+			Headers: map[string]string{"Referer": "https://rotating.example/"}},
+		"gone": {URL: down.URL + "/gone", URLs: []string{down.URL + "/also-gone"}, Language: "kr", Enabled: true},
+	}
+	if err := common.SaveConfig(c); err != nil {
+		t.Fatal(err)
+	}
+	common.Availability().Reset()
+
+	sites, spinner := common.GetAvailableSites(context.Background(), map[string]common.Scraper{"rotating": fastSite{}, "gone": fastSite{}})
+	spinner.Stop()
+	if _, ok := sites["rotating"]; !ok || len(sites) != 1 {
+		t.Fatalf("GetAvailableSites() = %v, want only rotating up through its mirror", sites)
+	}
+	if got := common.SiteURL("rotating"); got != up.URL {
+		t.Errorf("SiteURL(rotating) = %s, want the mirror up %s", got, up.URL)
+	}
+	// Mirrors get the settings of their site, such as its headers
+	if want := []string{"/ https://rotating.example/"}; !reflect.DeepEqual(probed, want) {
+		t.Errorf("mirror requests = %q, want %q", probed, want)
+	}
+}
+
+func TestValidateConfigMirrors(t *testing.T) {
+	c := &common.Config{
+		Sites: map[string]common.SiteConfig{
+			"site": {URL: "https://example.com", URLs: []string{"https://example2.com", "example3.com"}, Language: "kr"},
+		},
+		Timeout: 10,
+	}
+	want := []string{`site site: invalid mirror URL "example3.com"`}
+	if problems := common.ValidateConfig(c); !reflect.DeepEqual(problems, want) {
+		t.Errorf("ValidateConfig() = %q, want %q", problems, want)
+	}
+}
+
+func TestSiteMirrors(t *testing.T) {
+	s := common.SiteConfig{URL: "https://a.example", URLs: []string{"https://b.example", "", "https://a.example", "https://c.example"}}
+	want := []string{"https://a.example", "https://b.example", "https://c.example"}
+	if got := s.Mirrors(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Mirrors() = %v, want %v", got, want)
+	}
+}
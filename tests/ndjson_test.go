@@ -0,0 +1,144 @@
+package tests
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/daite/tspider/common"
+)
+
+func decodeEnvelopes(t *testing.T, data []byte) []map[string]json.RawMessage {
+	var lines []map[string]json.RawMessage
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		var line map[string]json.RawMessage
+		if err := json.Unmarshal(sc.Bytes(), &line); err != nil {
+			t.Fatalf("line %q is not JSON: %v", sc.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestWriteEnvelopesAlwaysSetsSite(t *testing.T) {
+	var buf bytes.Buffer
+	results := []common.SearchResult{
+		{Site: "nyaa", Title: "a", Magnet: "magnet:?xt=urn:btih:a"},
+		{Title: "b", Magnet: "magnet:?xt=urn:btih:b"},
+	}
+	if err := common.WriteEnvelopes(&buf, "nyaa", results); err != nil {
+		t.Fatal(err)
+	}
+	lines := decodeEnvelopes(t, buf.Bytes())
+	if len(lines) != 2 {
+		t.Fatalf("WriteEnvelopes() wrote %d lines, want 2:\n%s", len(lines), buf.String())
+	}
+	for _, line := range lines {
+		if len(line) != 2 || string(line["site"]) != `"nyaa"` {
+			t.Errorf("line = %s, want exactly {site: nyaa, result}", line)
+		}
+		var r common.SearchResult
+		if err := json.Unmarshal(line["result"], &r); err != nil || r.Site != "nyaa" {
+			t.Errorf("result = %s (%v), want a result with site nyaa", line["result"], err)
+		}
+	}
+}
+
+func TestEnvelopesStreamPerSite(t *testing.T) {
+	var (
+		mu  sync.Mutex
+		buf bytes.Buffer
+	)
+	spinner := common.NewSpinner("test")
+	spinner.OnResults(func(site string, results []common.SearchResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		common.WriteEnvelopes(&buf, site, results)
+	})
+	sites := map[string]common.Scraper{"fast": fastSite{}, "other": fastSite{}}
+	common.CollectData(context.Background(), sites, "test", spinner)
+
+	seen := map[string]int{}
+	for _, line := range decodeEnvelopes(t, buf.Bytes()) {
+		var site string
+		json.Unmarshal(line["site"], &site)
+		seen[site]++
+	}
+	if seen["fast"] != 1 || seen["other"] != 1 {
+		t.Errorf("envelopes per site = %v, want one each for fast and other", seen)
+	}
+}
+
+func TestNDJSONStreamsBeforeCollectionEnds(t *testing.T) {
+	var (
+		mu  sync.Mutex
+		buf bytes.Buffer
+	)
+	lines := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(decodeEnvelopes(t, buf.Bytes()))
+	}
+	spinner := common.NewSpinner("test")
+	spinner.OnResults(func(site string, results []common.SearchResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		common.WriteNDJSON(&buf, results)
+	})
+	release := make(chan struct{})
+	sites := map[string]common.Scraper{"fast": fastSite{}, "hanging": hangingSite{release}}
+	done := make(chan struct{})
+	go func() {
+		common.CollectData(context.Background(), sites, "k", spinner)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for lines() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case <-done:
+		t.Fatalf("CollectData() returned before the hanging site was released")
+	default:
+	}
+	if n := lines(); n != 1 {
+		t.Fatalf("lines written while a site is still running = %d, want 1", n)
+	}
+	close(release)
+	<-done
+	if n := lines(); n != 2 {
+		t.Errorf("lines written = %d, want 2", n)
+	}
+	var r common.SearchResult
+	if err := json.Unmarshal(bytes.SplitN(buf.Bytes(), []byte("\n"), 2)[0], &r); err != nil || r.Site != "fast" {
+		t.Errorf("first line = %+v (%v), want the result of fast", r, err)
+	}
+}
+
+func TestStreamDedupAcrossSites(t *testing.T) {
+	var seen common.StreamDedup
+	first := seen.Fresh([]common.SearchResult{
+		{Site: "nyaa", Title: "a", Magnet: "magnet:?xt=urn:btih:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", Seeders: 1},
+		{Site: "nyaa", Title: "a (batch)", Magnet: "magnet:?xt=urn:btih:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA", Seeders: 5},
+		{Site: "nyaa", Title: "gone", Magnet: "no magnet"},
+	})
+	if len(first) != 1 || first[0].Seeders != 5 {
+		t.Fatalf("Fresh() of the first site = %+v, want the best-seeded copy of a only", first)
+	}
+	second := seen.Fresh([]common.SearchResult{
+		{Site: "sukebei", Title: "a", Magnet: "magnet:?xt=urn:btih:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", Seeders: 9},
+		{Site: "sukebei", Title: "b", Magnet: "magnet:?xt=urn:btih:bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"},
+	})
+	if len(second) != 1 || second[0].Title != "b" {
+		t.Errorf("Fresh() of the second site = %+v, want only b, as a was already written", second)
+	}
+	if again := seen.Fresh(second); len(again) != 0 {
+		t.Errorf("Fresh() of a repeated batch = %+v, want nothing", again)
+	}
+}
@@ -0,0 +1,68 @@
+package tests
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/daite/tspider/common"
+	"github.com/daite/tspider/jtorrent"
+)
+
+func TestParseNegatives(t *testing.T) {
+	tests := []struct {
+		keyword   string
+		positive  string
+		negatives []string
+	}{
+		{"naruto", "naruto", nil},
+		{"naruto -dub", "naruto", []string{"dub"}},
+		{"-dub naruto -480p", "naruto", []string{"dub", "480p"}},
+		{`naruto -"english dub"`, "naruto", []string{"english dub"}},
+		{`"one piece" -dub`, `"one piece"`, []string{"dub"}},
+		{`naruto "-dub"`, `naruto "-dub"`, nil},
+		{`"naruto -dub"`, `"naruto -dub"`, nil},
+		{"x-men - origins", "x-men - origins", nil},
+		{`naruto -""`, `naruto -""`, nil},
+		{`naruto -"english dub`, "naruto", []string{"english dub"}},
+		{"-dub", "", []string{"dub"}},
+	}
+	for _, tt := range tests {
+		positive, negatives := common.ParseNegatives(tt.keyword)
+		if positive != tt.positive || !reflect.DeepEqual(negatives, tt.negatives) {
+			t.Errorf("ParseNegatives(%q) = %q, %q, want %q, %q", tt.keyword, positive, negatives, tt.positive, tt.negatives)
+		}
+	}
+}
+
+func TestExcludeTerms(t *testing.T) {
+	results := []common.SearchResult{
+		{Site: "nyaa", Title: "Naruto 01 [Dub] 1080p"},
+		{Site: "torrenttop", Title: "Naruto.01.English.Dub.720p"},
+		{Site: "torrenttop", Title: "Naruto 01 Dubbed"},
+		{Site: "torrenttop", Title: "Naruto 01 English Sub"},
+	}
+	got := common.ExcludeTerms(results, []string{"dub"})
+	if len(got) != 2 || got[0].Title != "Naruto 01 Dubbed" || got[1].Title != "Naruto 01 English Sub" {
+		t.Errorf("ExcludeTerms(dub) = %v", got)
+	}
+	got = common.ExcludeTerms(results, []string{"english dub"})
+	if len(got) != 3 {
+		t.Errorf("ExcludeTerms(english dub) kept %d results, want 3: %v", len(got), got)
+	}
+	if got := common.ExcludeTerms(results, nil); len(got) != len(results) {
+		t.Errorf("ExcludeTerms(nil) kept %d results, want %d", len(got), len(results))
+	}
+}
+
+func TestSearchURLNegativeTerms(t *testing.T) {
+	got := jtorrent.SearchURL("https://nyaa.si", "", "naruto", false, []string{"dub", "english sub"})
+	want := "https://nyaa.si/?f=0&c=0_0&q=naruto+-dub"
+	if got != want {
+		t.Errorf("SearchURL(negatives) = %q, want %q", got, want)
+	}
+	got = jtorrent.SearchURL("https://nyaa.si", "Erai-raws", "", false, []string{"dub"})
+	want = "https://nyaa.si/user/Erai-raws?f=0&c=0_0&q=-dub"
+	if got != want {
+		t.Errorf("SearchURL(uploader, negatives only) = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,276 @@
+package tests
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/daite/tspider/common"
+	"github.com/daite/tspider/notifiers"
+)
+
+func TestWebhookNotifier(t *testing.T) {
+	useTempHome(t)
+	var got notifiers.Notification
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		if r.Method != "POST" || r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "want a JSON POST", http.StatusBadRequest)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	c := common.DefaultConfig()
+	c.Notifiers = map[string]common.NotifierConfig{
+		"n8n":    {Type: "webhook", URL: srv.URL, Headers: map[string]string{"Authorization": "Bearer s3cret"}},
+		"broken": {Type: "webhook", URL: failing.URL},
+	}
+	if err := common.SaveConfig(c); err != nil {
+		t.Fatal(err)
+	}
+	all, err := notifiers.Configured()
+	if err != nil {
+		t.Fatalf("Configured() = %v", err)
+	}
+	n := notifiers.Notification{
+		Search: "show", Keyword: "show name", Lang: "jp",
+		Time:    time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC),
+		Results: []common.SearchResult{{Title: "Show 05", Site: "nyaa", Magnet: "magnet:?xt=urn:btih:abc"}},
+	}
+	err = notifiers.NotifyAll(all, n)
+	if err == nil || !strings.Contains(err.Error(), "broken") || strings.Contains(err.Error(), "n8n") {
+		t.Errorf("NotifyAll() = %v, want only the broken webhook to fail", err)
+	}
+	if got.Search != "show" || got.Count != 1 || len(got.Results) != 1 || got.Results[0].Title != "Show 05" {
+		t.Errorf("webhook received %+v, want the notification with its result", got)
+	}
+	if auth != "Bearer s3cret" {
+		t.Errorf("Authorization = %q, want the configured header", auth)
+	}
+}
+
+func TestNotifierConfigErrors(t *testing.T) {
+	if _, err := notifiers.New("pigeon", common.NotifierConfig{Type: "pigeon"}); err == nil {
+		t.Errorf("New() of an unknown type = nil, want error")
+	}
+	if _, err := notifiers.New("hook", common.NotifierConfig{Type: "webhook"}); err == nil {
+		t.Errorf("New() of a webhook without a URL = nil, want error")
+	}
+	c := &common.Config{Timeout: 10, Notifiers: map[string]common.NotifierConfig{
+		"hook": {URL: "not a url"},
+	}}
+	problems := strings.Join(common.ValidateConfig(c), "\n")
+	for _, want := range []string{"notifier hook: missing type", `notifier hook: invalid URL "not a url"`} {
+		if !strings.Contains(problems, want) {
+			t.Errorf("ValidateConfig() = %q, want a problem containing %q", problems, want)
+		}
+	}
+}
+
+func TestDesktopNotifier(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("fakes notify-send")
+	}
+	dir := t.TempDir()
+	// notify-send records its arguments and picks the copy action;
+	// wl-copy records what it is given
+	script := "#!/bin/sh\nprintf '%s\\n' \"$@\" > " + dir + "/args\ncase \"$*\" in *--action*) echo copy;; esac\n"
+	if err := os.WriteFile(filepath.Join(dir, "notify-send"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "wl-copy"), []byte("#!/bin/sh\ncat > "+dir+"/clipboard\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	n := notifiers.Notification{Search: "show", Keyword: "show", Results: []common.SearchResult{
+		{Title: "Show 05", Site: "nyaa", Magnet: "magnet:?xt=urn:btih:aaa"},
+		{Title: "Show 06", Site: "nyaa", Magnet: "magnet:?xt=urn:btih:bbb"},
+	}}
+	if err := notifiers.NewDesktop(common.NotifierConfig{}).Notify(n); err != nil {
+		t.Fatalf("Notify() = %v", err)
+	}
+	args, _ := os.ReadFile(filepath.Join(dir, "args"))
+	if want := "--app-name=tspider\n--\ntspider: show\nShow 05 [nyaa]\nShow 06 [nyaa]\n"; string(args) != want {
+		t.Errorf("notify-send arguments = %q, want %q", args, want)
+	}
+
+	if err := notifiers.NewDesktop(common.NotifierConfig{CopyOnClick: true}).Notify(n); err != nil {
+		t.Fatalf("Notify() with copy on click = %v", err)
+	}
+	want := "magnet:?xt=urn:btih:aaa\nmagnet:?xt=urn:btih:bbb"
+	var copied []byte
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline) && string(copied) != want; time.Sleep(10 * time.Millisecond) {
+		copied, _ = os.ReadFile(filepath.Join(dir, "clipboard"))
+	}
+	if string(copied) != want {
+		t.Errorf("clicking copied %q, want the magnets %q", copied, want)
+	}
+}
+
+// fakeSMTP accepts mail without authentication or TLS and records the
+// recipients and the data of each message
+type fakeSMTP struct {
+	ln       net.Listener
+	mu       sync.Mutex
+	rcpts    []string
+	messages []string
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeSMTP{ln: ln}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	fmt.Fprint(conn, "220 fake ESMTP\r\n")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"):
+			fmt.Fprint(conn, "250-fake\r\n250 8BITMIME\r\n")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			f.mu.Lock()
+			f.rcpts = append(f.rcpts, strings.Trim(strings.TrimSpace(line)[len("RCPT TO:"):], "<>"))
+			f.mu.Unlock()
+			fmt.Fprint(conn, "250 OK\r\n")
+		case cmd == "DATA":
+			fmt.Fprint(conn, "354 go ahead\r\n")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil || l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			f.mu.Lock()
+			f.messages = append(f.messages, data.String())
+			f.mu.Unlock()
+			fmt.Fprint(conn, "250 queued\r\n")
+		case cmd == "QUIT":
+			fmt.Fprint(conn, "221 bye\r\n")
+			return
+		default:
+			fmt.Fprint(conn, "250 OK\r\n")
+		}
+	}
+}
+
+func (f *fakeSMTP) sent() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.messages...)
+}
+
+func TestEmailNotifier(t *testing.T) {
+	useTempHome(t)
+	srv := newFakeSMTP(t)
+	cfg := common.NotifierConfig{Type: "email", SMTPHost: srv.ln.Addr().String(), From: "tspider@nas", To: []string{"me@example.com", "you@example.com"}}
+	email, err := notifiers.New("mail", cfg)
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+	n := notifiers.Notification{
+		Search: "show", Keyword: "show name", Lang: "jp",
+		Time:    time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC),
+		Results: []common.SearchResult{{Title: "Show 05", Site: "nyaa", Size: "1.2 GiB", Magnet: "magnet:?xt=urn:btih:abc"}},
+	}
+	if err := email.Notify(n); err != nil {
+		t.Fatalf("Notify() = %v", err)
+	}
+	sent := srv.sent()
+	if len(sent) != 1 {
+		t.Fatalf("sent %d email(s), want 1", len(sent))
+	}
+	for _, want := range []string{"Subject: tspider: 1 new result(s) for show", "To: me@example.com, you@example.com", "Show 05 [nyaa] 1.2 GiB", "magnet:?xt=3Durn:btih:abc"} {
+		if !strings.Contains(sent[0], want) {
+			t.Errorf("email lacks %q:\n%s", want, sent[0])
+		}
+	}
+	if len(srv.rcpts) != 2 {
+		t.Errorf("recipients = %v, want both", srv.rcpts)
+	}
+}
+
+func TestEmailDigest(t *testing.T) {
+	useTempHome(t)
+	srv := newFakeSMTP(t)
+	cfg := common.NotifierConfig{Type: "email", SMTPHost: srv.ln.Addr().String(), From: "tspider@nas", To: []string{"me@example.com"}, Digest: "@daily"}
+	email, err := notifiers.New("digest", cfg)
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+	all := map[string]notifiers.Notifier{"digest": email}
+	start := time.Date(2026, 10, 16, 9, 0, 0, 0, time.Local)
+	for i, search := range []string{"show", "drama", "show"} {
+		n := notifiers.Notification{Search: search, Keyword: search, Lang: "jp", Time: start.Add(time.Duration(i) * time.Hour),
+			Results: []common.SearchResult{{Title: fmt.Sprintf("%s %d", search, i), Site: "nyaa", Magnet: "magnet:?xt=urn:btih:abc"}}}
+		if err := email.Notify(n); err != nil {
+			t.Fatalf("Notify() = %v", err)
+		}
+	}
+	if len(srv.sent()) != 0 {
+		t.Fatalf("digest mailed before it was due")
+	}
+	midnight := time.Date(2026, 10, 17, 0, 0, 0, 0, time.Local)
+	if got := notifiers.NextDigest(all); !got.Equal(midnight) {
+		t.Errorf("NextDigest() = %v, want %v", got, midnight)
+	}
+	if err := notifiers.SendDueDigests(all, midnight.Add(-time.Minute)); err != nil || len(srv.sent()) != 0 {
+		t.Fatalf("SendDueDigests() before midnight = %v, sent %d", err, len(srv.sent()))
+	}
+	if err := notifiers.SendDueDigests(all, midnight); err != nil {
+		t.Fatalf("SendDueDigests() = %v", err)
+	}
+	sent := srv.sent()
+	if len(sent) != 1 {
+		t.Fatalf("sent %d digest(s), want 1", len(sent))
+	}
+	for _, want := range []string{"Subject: tspider digest: 3 new result(s) from 2 search(es)", "show 0 [nyaa]", "drama 1 [nyaa]", "show 2 [nyaa]"} {
+		if !strings.Contains(sent[0], want) {
+			t.Errorf("digest lacks %q:\n%s", want, sent[0])
+		}
+	}
+	// The next period starts empty
+	if err := notifiers.FlushDigests(all, midnight.Add(time.Hour)); err != nil || len(srv.sent()) != 1 {
+		t.Errorf("FlushDigests() of an empty digest = %v, sent %d email(s), want none more", err, len(srv.sent())-1)
+	}
+}
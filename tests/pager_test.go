@@ -0,0 +1,32 @@
+package tests
+
+import (
+	"bytes"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/daite/tspider/common"
+)
+
+func TestPagerSurvivesEarlyExit(t *testing.T) {
+	if _, err := exec.LookPath("true"); err != nil {
+		t.Skip("true(1) not available")
+	}
+	t.Setenv("PAGER", "true")
+	p, err := common.NewPager()
+	if err != nil {
+		t.Fatalf("NewPager() = %v", err)
+	}
+	// Give the pager time to exit so later writes hit a closed pipe
+	time.Sleep(100 * time.Millisecond)
+	chunk := bytes.Repeat([]byte("x"), 64*1024)
+	for i := 0; i < 16; i++ {
+		if n, err := p.Write(chunk); err != nil || n != len(chunk) {
+			t.Fatalf("Write() = %d, %v, want %d, nil", n, err, len(chunk))
+		}
+	}
+	if err := p.Close(); err != nil {
+		t.Errorf("Close() = %v", err)
+	}
+}
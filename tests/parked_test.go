@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/daite/tspider/common"
+)
+
+func TestParkedDomainsAreDown(t *testing.T) {
+	useTempHome(t)
+	// Sites are told apart by host and port, so each page gets a server
+	serve := func(page string) string {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, page)
+		}))
+		t.Cleanup(srv.Close)
+		return srv.URL
+	}
+	parked := serve(`<html><body><h1>example.com</h1><p>This domain may be for sale!</p></body></html>`)
+	plain := serve(`<html><body><p>Welcome to nginx!</p></body></html>`)
+	site := serve(`<html><body><div class="topic-item"><a href="/view/1">Ubuntu</a></div></body></html>`)
+
+	c := common.DefaultConfig()
+	c.Sites = map[string]common.SiteConfig{
+		"parked":   {URL: parked, Language: "kr", Enabled: true},
+		"plain":    {URL: plain, Language: "kr", Enabled: true},
+		"selector": {URL: site, Language: "jp", Enabled: true, ExpectSelector: ".topic-item a"},
+		"missing":  {URL: plain + "/index", Language: "jp", Enabled: true, ExpectSelector: ".topic-item a"},
+	}
+	if err := common.SaveConfig(c); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if common.CheckNetWorkFromURL(ctx, parked) {
+		t.Errorf("CheckNetWorkFromURL(parked page) = true")
+	}
+	if !common.CheckNetWorkFromURL(ctx, site) {
+		t.Errorf("CheckNetWorkFromURL(plain page) = false")
+	}
+
+	got := map[string]string{}
+	for _, s := range common.Doctor(ctx, "") {
+		if s.Available {
+			got[s.Name] = "OK"
+		} else {
+			got[s.Name] = s.Error
+		}
+	}
+	want := map[string]string{"parked": "parked domain", "plain": "OK", "selector": "OK", "missing": "page lacks .topic-item a"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Doctor() = %v, want %v", got, want)
+	}
+
+	// The same page without the expected text is not the site
+	c.Sites["plain"] = common.SiteConfig{URL: plain, Language: "kr", Enabled: true, ExpectText: "TorrentQQ"}
+	if err := common.SaveConfig(c); err != nil {
+		t.Fatal(err)
+	}
+	d, err := common.DiagnoseSite(ctx, "plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Available || d.Error != `page lacks "TorrentQQ"` {
+		t.Errorf("DiagnoseSite() = %+v, want the missing text reported", d)
+	}
+}
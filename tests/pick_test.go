@@ -0,0 +1,68 @@
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/daite/tspider/common"
+)
+
+func TestFuzzyScore(t *testing.T) {
+	tests := []struct {
+		pattern, text string
+		match         bool
+	}{
+		{"", "anything", true},
+		{"shw", "Show 01", true},
+		{"s01", "Show 01", true},
+		{"1080 show", "Show 01 [1080p]", false},
+		{"show 1080", "Show 01 [1080p]", true},
+		{"xyz", "Show 01", false},
+	}
+	for _, tt := range tests {
+		if _, got := common.FuzzyScore(tt.pattern, tt.text); got != tt.match {
+			t.Errorf("FuzzyScore(%q, %q) match = %v, want %v", tt.pattern, tt.text, got, tt.match)
+		}
+	}
+	// Consecutive matches at word starts rank first
+	prefix, _ := common.FuzzyScore("show", "Show 01")
+	scattered, _ := common.FuzzyScore("show", "Sh Other Words")
+	if prefix <= scattered {
+		t.Errorf("FuzzyScore() prefix = %d, scattered = %d, want prefix higher", prefix, scattered)
+	}
+}
+
+func TestFuzzyFilterRanksBestFirst(t *testing.T) {
+	results := []common.SearchResult{
+		{Title: "Some Hidden Other Word"},
+		{Title: "Nothing"},
+		{Title: "Show 01"},
+	}
+	got := common.FuzzyFilter(results, "show")
+	if len(got) != 2 || got[0].Title != "Show 01" {
+		t.Errorf("FuzzyFilter() = %v, want Show 01 first and Nothing dropped", got)
+	}
+}
+
+func TestPickPrompt(t *testing.T) {
+	results := browseResults(7)
+	var out bytes.Buffer
+	got, err := common.PickPrompt(results, strings.NewReader("special\n\n"), &out)
+	if err != nil {
+		t.Fatalf("PickPrompt() error = %v", err)
+	}
+	if got.Title != "Special 05 [1080p]" {
+		t.Errorf("PickPrompt() = %q, want the only match after narrowing", got.Title)
+	}
+
+	got, err = common.PickPrompt(results, strings.NewReader("3\n"), &out)
+	if err != nil || got.Title != "Show 03" {
+		t.Errorf("PickPrompt() = %q, %v, want Show 03 by number", got.Title, err)
+	}
+
+	if _, err := common.PickPrompt(results, strings.NewReader("q\n"), &out); !errors.Is(err, common.ErrNothingPicked) {
+		t.Errorf("PickPrompt() after q error = %v, want ErrNothingPicked", err)
+	}
+}
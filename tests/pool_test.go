@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/daite/tspider/common"
+)
+
+func TestWorkerPoolBoundsConcurrency(t *testing.T) {
+	pool := common.NewWorkerPool(3)
+	var running, peak, done int32
+	// Two groups share the pool, like two scrapers
+	var wg sync.WaitGroup
+	for g := 0; g < 2; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			group := pool.Group()
+			for i := 0; i < 20; i++ {
+				group.Go(func() {
+					n := atomic.AddInt32(&running, 1)
+					for {
+						p := atomic.LoadInt32(&peak)
+						if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+							break
+						}
+					}
+					time.Sleep(time.Millisecond)
+					atomic.AddInt32(&running, -1)
+					atomic.AddInt32(&done, 1)
+				})
+			}
+			group.Wait()
+		}()
+	}
+	wg.Wait()
+	if done != 40 {
+		t.Errorf("ran %d tasks, want 40", done)
+	}
+	if peak > 3 {
+		t.Errorf("%d tasks ran at once, want at most 3", peak)
+	}
+}
+
+func TestTaskGroupWaitsForItsTasks(t *testing.T) {
+	group := common.NewWorkerPool(2).Group()
+	var done int32
+	for i := 0; i < 5; i++ {
+		group.Go(func() {
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&done, 1)
+		})
+	}
+	group.Wait()
+	if done != 5 {
+		t.Errorf("Wait() returned after %d of 5 tasks", done)
+	}
+}
@@ -0,0 +1,60 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/daite/tspider/common"
+)
+
+// useTempHome points the config file at a temporary home directory
+func useTempHome(t *testing.T) string {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("USERPROFILE", dir)
+	return dir
+}
+
+func TestAddProfileRejectsUnknownSite(t *testing.T) {
+	useTempHome(t)
+	if err := common.AddProfile("broken", []string{"nyaa", "no-such-site"}); err == nil {
+		t.Errorf("AddProfile() with unknown site = nil, want error")
+	}
+	if _, exists := common.GetConfig().Profiles["broken"]; exists {
+		t.Errorf("AddProfile() saved a profile with an unknown site")
+	}
+}
+
+// keepSiteURLs restores the active sites when t ends, for tests that change
+// them for the run as UseProfile does
+func keepSiteURLs(t *testing.T) {
+	saved := common.SiteURLs()
+	t.Cleanup(func() {
+		for name := range common.SiteURLs() {
+			common.StopSite(name)
+		}
+		for name, url := range saved {
+			common.UseSiteURL(name, url)
+		}
+	})
+}
+
+func TestUseProfileOverridesEnabledSites(t *testing.T) {
+	useTempHome(t)
+	keepSiteURLs(t)
+	if err := common.AddProfile("fast", []string{"torrentqq", "nyaa"}); err != nil {
+		t.Fatalf("AddProfile() = %v", err)
+	}
+	defer common.RemoveProfile("fast")
+	if err := common.UseProfile("fast"); err != nil {
+		t.Fatalf("UseProfile() = %v", err)
+	}
+	if len(common.SiteURLs()) != 2 {
+		t.Errorf("UseProfile() activated %d sites, want 2", len(common.SiteURLs()))
+	}
+	if _, ok := common.LookupSiteURL("torrentqq"); !ok {
+		t.Errorf("UseProfile() did not activate disabled site torrentqq")
+	}
+	if _, ok := common.LookupSiteURL("sukebe"); ok {
+		t.Errorf("UseProfile() activated sukebe, which is not in the profile")
+	}
+}
@@ -0,0 +1,51 @@
+package tests
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/daite/tspider/common"
+)
+
+func TestPrunePlanNeverTouchesReachableSites(t *testing.T) {
+	statuses := []common.SiteStatus{
+		{Name: "up", Available: true, Enabled: true},
+		{Name: "up-disabled", Available: true},
+		{Name: "dead", Enabled: true, Error: "HTTP 503"},
+		{Name: "dead-disabled", Error: "no such host"},
+	}
+	if got, want := common.PrunePlan(statuses, false), []string{"dead"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("PrunePlan(disable) = %q, want %q", got, want)
+	}
+	if got, want := common.PrunePlan(statuses, true), []string{"dead", "dead-disabled"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("PrunePlan(remove) = %q, want %q", got, want)
+	}
+}
+
+func TestPruneSites(t *testing.T) {
+	useTempHome(t)
+	if err := common.SaveConfig(common.DefaultConfig()); err != nil {
+		t.Fatal(err)
+	}
+	if err := common.PruneSites([]string{"nyaa", "no-such-site"}, true); err == nil {
+		t.Errorf("PruneSites() with unknown site = nil, want error")
+	}
+	if _, ok := common.GetConfig().Sites["nyaa"]; !ok {
+		t.Errorf("PruneSites() removed nyaa although the prune failed")
+	}
+
+	if err := common.PruneSites([]string{"nyaa"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if common.GetConfig().Sites["nyaa"].Enabled {
+		t.Errorf("PruneSites(disable) left nyaa enabled")
+	}
+	if err := common.PruneSites([]string{"nyaa", "sukebe"}, true); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"nyaa", "sukebe"} {
+		if _, ok := common.GetConfig().Sites[name]; ok {
+			t.Errorf("PruneSites(remove) kept %s", name)
+		}
+	}
+}
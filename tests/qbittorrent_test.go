@@ -0,0 +1,128 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/daite/tspider/clients"
+	"github.com/daite/tspider/common"
+)
+
+// fakeQBittorrent emulates the login, add, version and list methods of the
+// WebUI API
+type fakeQBittorrent struct {
+	added                    []string
+	category, savePath       string
+	missingReferer, noCookie bool
+}
+
+func (f *fakeQBittorrent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Referer") == "" {
+		f.missingReferer = true
+	}
+	r.ParseForm()
+	switch r.URL.Path {
+	case "/api/v2/auth/login":
+		if r.Form.Get("username") != "admin" || r.Form.Get("password") != "secret" {
+			w.Write([]byte("Fails."))
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "SID", Value: "session", Path: "/"})
+		w.Write([]byte("Ok."))
+	case "/api/v2/torrents/add":
+		if c, err := r.Cookie("SID"); err != nil || c.Value != "session" {
+			f.noCookie = true
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		f.added = append(f.added, strings.Split(r.Form.Get("urls"), "\n")...)
+		f.category = r.Form.Get("category")
+		f.savePath = r.Form.Get("savepath")
+		w.Write([]byte("Ok."))
+	case "/api/v2/app/version":
+		w.Write([]byte("v4.6.2"))
+	case "/api/v2/torrents/info":
+		if r.Form.Get("filter") != "active" {
+			w.Write([]byte("[]"))
+			return
+		}
+		w.Write([]byte(`[{"name": "Ubuntu", "hash": "abc", "progress": 0.5, "state": "downloading", "dlspeed": 1024}]`))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func useQBittorrent(t *testing.T, url, username, password string) {
+	useTempHome(t)
+	c := common.DefaultConfig()
+	c.Clients = map[string]common.ClientConfig{
+		"qbittorrent": {URL: url, Username: username, Password: password},
+	}
+	if err := common.SaveConfig(c); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQBittorrentAddMagnets(t *testing.T) {
+	fake := &fakeQBittorrent{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	useQBittorrent(t, srv.URL+"/", "admin", "secret")
+
+	magnets := []string{"magnet:?xt=urn:btih:a", "magnet:?xt=urn:btih:b"}
+	client, err := clients.New("qbittorrent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := clients.AddMagnets(client, magnets, clients.AddOptions{Category: "tv", SavePath: "/data/tv"}); err != nil {
+		t.Fatalf("AddMagnets() error = %v", err)
+	}
+	if strings.Join(fake.added, " ") != strings.Join(magnets, " ") {
+		t.Errorf("added = %q, want %q", fake.added, magnets)
+	}
+	if fake.category != "tv" || fake.savePath != "/data/tv" {
+		t.Errorf("category, savepath = %q, %q, want tv, /data/tv", fake.category, fake.savePath)
+	}
+	if fake.missingReferer || fake.noCookie {
+		t.Errorf("requests missing Referer = %v, session cookie = %v", fake.missingReferer, fake.noCookie)
+	}
+}
+
+func TestQBittorrentBadLogin(t *testing.T) {
+	fake := &fakeQBittorrent{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	useQBittorrent(t, srv.URL, "admin", "wrong")
+
+	err := clients.NewQBittorrent(common.ClientConfig{URL: srv.URL, Username: "admin", Password: "wrong"}).AddMagnet("magnet:?xt=urn:btih:a", clients.AddOptions{})
+	if err == nil || !strings.Contains(err.Error(), "login failed") {
+		t.Errorf("AddMagnet() error = %v, want a login failure", err)
+	}
+	if len(fake.added) != 0 {
+		t.Errorf("added = %q after a failed login, want nothing", fake.added)
+	}
+}
+
+func TestQBittorrentListActive(t *testing.T) {
+	srv := httptest.NewServer(&fakeQBittorrent{})
+	defer srv.Close()
+	useQBittorrent(t, srv.URL, "admin", "secret")
+
+	client, err := clients.New("qbittorrent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.TestConnection(); err != nil {
+		t.Errorf("TestConnection() error = %v", err)
+	}
+	got, err := client.ListActive()
+	if err != nil {
+		t.Fatalf("ListActive() error = %v", err)
+	}
+	want := clients.Torrent{Name: "Ubuntu", Hash: "abc", Progress: 0.5, State: "downloading", DownloadRate: 1024}
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("ListActive() = %+v, want [%+v]", got, want)
+	}
+}
@@ -0,0 +1,67 @@
+package tests
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/daite/tspider/common"
+)
+
+func TestRecommendMirror(t *testing.T) {
+	statuses := []common.SiteStatus{
+		{Name: "slow", Language: "kr", Enabled: true, Available: true, Latency: 900 * time.Millisecond},
+		{Name: "fast", Language: "kr", Enabled: true, Available: true, Latency: 210 * time.Millisecond},
+		{Name: "disabled", Language: "kr", Enabled: false, Available: true, Latency: 10 * time.Millisecond},
+		{Name: "down", Language: "kr", Enabled: true, Available: false, Latency: 5 * time.Millisecond},
+		{Name: "nyaa", Language: "jp", Enabled: true, Available: true, Latency: 50 * time.Millisecond},
+	}
+	if got := common.RecommendMirror(statuses, "kr"); got == nil || got.Name != "fast" {
+		t.Errorf("RecommendMirror(kr) = %v, want fast", got)
+	}
+	if got := common.RecommendMirror(statuses, "jp"); got == nil || got.Name != "nyaa" {
+		t.Errorf("RecommendMirror(jp) = %v, want nyaa", got)
+	}
+	if got := common.RecommendMirror(statuses[2:4], "kr"); got != nil {
+		t.Errorf("RecommendMirror() with only disabled and down sites = %v, want nil", got)
+	}
+}
+
+func TestRecommendMirrorPrefersResults(t *testing.T) {
+	statuses := []common.SiteStatus{
+		{Name: "fast", Language: "kr", Enabled: true, Available: true, Latency: 100 * time.Millisecond, Results: 3},
+		{Name: "rich", Language: "kr", Enabled: true, Available: true, Latency: 400 * time.Millisecond, Results: 30},
+		{Name: "degraded", Language: "kr", Enabled: true, Available: true, Latency: 10 * time.Millisecond, Degraded: true},
+	}
+	if got := common.RecommendMirror(statuses, "kr"); got == nil || got.Name != "rich" {
+		t.Errorf("RecommendMirror() = %v, want rich", got)
+	}
+}
+
+func TestPrintRecommendations(t *testing.T) {
+	statuses := []common.SiteStatus{
+		{Name: "torrenttop", Language: "kr", Enabled: true, Available: true, Latency: 210 * time.Millisecond},
+	}
+	var buf bytes.Buffer
+	common.PrintRecommendations(&buf, statuses, []string{"kr", "jp"})
+	want := "Recommended KR: torrenttop (OK, 210ms)\nRecommended JP: none (no enabled site is up)\n"
+	if buf.String() != want {
+		t.Errorf("PrintRecommendations() = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestDownLanguages(t *testing.T) {
+	statuses := []common.SiteStatus{
+		{Name: "torrenttop", Language: "kr", Enabled: true, Available: false},
+		{Name: "torrentqq", Language: "kr", Enabled: true, Available: true, Degraded: true},
+		{Name: "tshare", Language: "kr", Enabled: false, Available: true},
+		{Name: "nyaa", Language: "jp", Enabled: true, Available: true},
+	}
+	if got := common.DownLanguages(statuses, []string{"kr", "jp"}); !reflect.DeepEqual(got, []string{"kr"}) {
+		t.Errorf("DownLanguages() = %v, want [kr]", got)
+	}
+	if got := common.DownLanguages(statuses[2:], []string{"kr", "jp"}); got != nil {
+		t.Errorf("DownLanguages() without enabled kr sites = %v, want none", got)
+	}
+}
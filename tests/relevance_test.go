@@ -0,0 +1,62 @@
+package tests
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/daite/tspider/common"
+)
+
+func TestRelevanceScoreRanksMatchQuality(t *testing.T) {
+	keyword := "one piece"
+	ranked := []string{
+		"One Piece",                     // exact
+		"One.Piece.E1000.1080p",         // prefix
+		"[SubsPlease] One Piece - 1000", // whole words
+		"Someone Pieces",                // substring
+		"Piece of One",                  // both words, not adjacent
+		"One Punch Man",                 // one word
+		"Naruto",                        // nothing
+	}
+	for i := 1; i < len(ranked); i++ {
+		hi := common.RelevanceScore(ranked[i-1], keyword)
+		lo := common.RelevanceScore(ranked[i], keyword)
+		if hi <= lo {
+			t.Errorf("RelevanceScore(%q) = %d, want more than RelevanceScore(%q) = %d", ranked[i-1], hi, ranked[i], lo)
+		}
+	}
+}
+
+func TestRelevanceScoreUnicode(t *testing.T) {
+	if got := common.RelevanceScore("동상이몽2 너는 내운명.E177.201228.720p-NEXT", "동상이몽2"); got <= common.RelevanceScore("너는 내운명 동상이몽2", "동상이몽2") {
+		t.Errorf("prefix match on a Korean title does not outrank a later whole-word match")
+	}
+	if got := common.RelevanceScore("ÉCOLE Spéciale", "école"); got == 0 {
+		t.Errorf("RelevanceScore() is case-sensitive for non-ASCII letters")
+	}
+}
+
+func TestSortResults(t *testing.T) {
+	results := []common.SearchResult{
+		{Title: "Piece of One", Seeders: 50},
+		{Title: "One Piece", Seeders: 1},
+		{Title: "One.Piece.E1000", Seeders: 7},
+	}
+	if err := common.SortResults(results, "relevance", "one piece"); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range results {
+		got = append(got, r.Title)
+	}
+	if want := []string{"One Piece", "One.Piece.E1000", "Piece of One"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("SortResults(relevance) = %q, want %q", got, want)
+	}
+	common.SortResults(results, "seeders", "")
+	if results[0].Seeders != 50 {
+		t.Errorf("SortResults(seeders) put %+v first", results[0])
+	}
+	if err := common.SortResults(results, "size", ""); err == nil {
+		t.Errorf("SortResults() with unknown order = nil, want error")
+	}
+}
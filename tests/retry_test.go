@@ -0,0 +1,185 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/daite/tspider/common"
+)
+
+// flakyServer answers the first failures requests with status, then 200
+func flakyServer(t *testing.T, status int, failures int32) (*httptest.Server, *int32) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) <= failures {
+			w.WriteHeader(status)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &hits
+}
+
+func useRetries(t *testing.T, maxRetries int) {
+	useTempHome(t)
+	c := common.DefaultConfig()
+	c.MaxRetries = maxRetries
+	if err := common.SaveConfig(c); err != nil {
+		t.Fatal(err)
+	}
+	backoff := common.RetryBackoff
+	common.RetryBackoff = time.Millisecond
+	t.Cleanup(func() { common.RetryBackoff = backoff })
+	common.Retries().Reset()
+}
+
+func TestRetryRecoversFromServerError(t *testing.T) {
+	useRetries(t, 2)
+	srv, hits := flakyServer(t, http.StatusServiceUnavailable, 1)
+	if err := common.AddSite("flaky", srv.URL, "kr"); err != nil {
+		t.Fatal(err)
+	}
+	resp, ok := common.GetResponseFromURL(context.Background(), srv.URL+"/search")
+	if !ok {
+		t.Fatalf("GetResponseFromURL() ok = false, want the retry to succeed")
+	}
+	resp.Body.Close()
+	if *hits != 2 {
+		t.Errorf("server hits = %d, want 2", *hits)
+	}
+	report := common.Retries().Report()
+	if len(report) != 1 || report[0].Site != "flaky" {
+		t.Fatalf("Report() = %+v, want one entry for flaky", report)
+	}
+	if got := report[0]; got.Retries[common.RetryServerError] != 1 || got.Recovered != 1 || got.Failed != 0 {
+		t.Errorf("Report()[0] = %+v, want one 5xx retry, recovered", got)
+	}
+}
+
+func TestRetryGivesUpAfterMaxRetries(t *testing.T) {
+	useRetries(t, 3)
+	srv, hits := flakyServer(t, http.StatusTooManyRequests, 100)
+	if _, ok := common.GetResponseFromURL(context.Background(), srv.URL); ok {
+		t.Fatalf("GetResponseFromURL() ok = true, want failure")
+	}
+	if *hits != 4 {
+		t.Errorf("server hits = %d, want 4 (1 + 3 retries)", *hits)
+	}
+	report := common.Retries().Report()
+	if len(report) != 1 {
+		t.Fatalf("Report() = %+v, want one entry", report)
+	}
+	if got := report[0]; got.Retries[common.RetryRateLimited] != 3 || got.Failed != 1 || got.Recovered != 0 {
+		t.Errorf("Report()[0] = %+v, want three 429 retries, failed", got)
+	}
+}
+
+func TestRetrySkipsClientErrors(t *testing.T) {
+	useRetries(t, 2)
+	srv, hits := flakyServer(t, http.StatusNotFound, 100)
+	if _, ok := common.GetResponseFromURL(context.Background(), srv.URL); ok {
+		t.Fatalf("GetResponseFromURL() ok = true, want failure")
+	}
+	if *hits != 1 {
+		t.Errorf("server hits = %d, want a 404 not to be retried", *hits)
+	}
+	if report := common.Retries().Report(); len(report) != 0 {
+		t.Errorf("Report() = %+v, want no retries", report)
+	}
+}
+
+func TestRetryDisabled(t *testing.T) {
+	for _, n := range []int{0, -1} {
+		useRetries(t, n)
+		srv, hits := flakyServer(t, http.StatusBadGateway, 100)
+		common.GetResponseFromURL(context.Background(), srv.URL)
+		if *hits != 1 {
+			t.Errorf("server hits = %d, want no retries with max_retries %d", *hits, n)
+		}
+	}
+}
+
+func TestRetryHonoursRetryAfter(t *testing.T) {
+	useRetries(t, 1)
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	start := time.Now()
+	resp, ok := common.GetResponseFromURL(context.Background(), srv.URL)
+	if !ok {
+		t.Fatalf("GetResponseFromURL() ok = false, want the retry to succeed")
+	}
+	resp.Body.Close()
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %s, want the 1s the site asked for", elapsed)
+	}
+}
+
+type fetchSite struct{ url string }
+
+func (s fetchSite) Crawl(ctx context.Context, keyword string) []common.SearchResult {
+	resp, ok := common.GetResponseFromURL(ctx, s.url)
+	if !ok {
+		return nil
+	}
+	resp.Body.Close()
+	return []common.SearchResult{{Title: keyword, Magnet: "magnet:?xt=urn:btih:fetched"}}
+}
+
+func TestCollectDataReportsFailedRequests(t *testing.T) {
+	useRetries(t, 2)
+	srv, _ := flakyServer(t, http.StatusServiceUnavailable, 100)
+	if err := common.AddSite("down", srv.URL, "kr"); err != nil {
+		t.Fatal(err)
+	}
+	spinner := common.NewSpinner("test")
+	sites := map[string]common.Scraper{"down": fetchSite{srv.URL + "/search"}}
+	common.CollectData(context.Background(), sites, "test", spinner)
+	want := "down: search failed: " + srv.URL + "/search answered 503 Service Unavailable (after 2 retries)"
+	if got := spinner.Warnings(); len(got) != 1 || got[0] != want {
+		t.Errorf("Warnings() = %q, want %q", got, want)
+	}
+}
+
+func TestValidateConfigRetries(t *testing.T) {
+	c := common.DefaultConfig()
+	c.RetryDelay = -1
+	c.RetryJitter = 1.5
+	want := []string{"retry_delay_ms is -1, want a positive number", "retry_jitter is 1.5, want at most 1"}
+	if got := common.ValidateConfig(c); !reflect.DeepEqual(got, want) {
+		t.Errorf("ValidateConfig() = %q, want %q", got, want)
+	}
+}
+
+func TestPrintRetryReport(t *testing.T) {
+	var buf bytes.Buffer
+	common.PrintRetryReport(&buf, nil)
+	if buf.Len() != 0 {
+		t.Errorf("PrintRetryReport(nil) = %q, want nothing", buf.String())
+	}
+	common.PrintRetryReport(&buf, []common.SiteRetries{{
+		Site:      "nyaa",
+		Retries:   map[string]int{common.RetryTimeout: 1, common.RetryServerError: 2},
+		Recovered: 1,
+		Failed:    1,
+	}})
+	want := "[*] Retries: 3\n    nyaa: 3 (timeout 1, 5xx 2), 1 recovered, 1 failed\n"
+	if got := buf.String(); !strings.Contains(got, want) {
+		t.Errorf("PrintRetryReport() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,50 @@
+package tests
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/daite/tspider/common"
+)
+
+// TestSharedClientReusesConnections checks that page fetches, site probes and
+// doctor go through the one pooled client, keeping a connection alive
+func TestSharedClientReusesConnections(t *testing.T) {
+	useTempHome(t)
+	var conns int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(strings.Repeat("x", 64<<10)))
+	}))
+	srv.Config.ConnState = func(c net.Conn, s http.ConnState) {
+		if s == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+	c := common.DefaultConfig()
+	c.Sites = map[string]common.SiteConfig{"local": {URL: srv.URL, Enabled: true, Language: "kr"}}
+	if err := common.SaveConfig(c); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		if !common.CheckNetWorkFromURL(ctx, srv.URL) {
+			t.Fatalf("CheckNetWorkFromURL() = false")
+		}
+		common.Doctor(ctx, "kr")
+		common.GetResponseFromURL(ctx, srv.URL+"/missing")
+	}
+	if conns != 1 {
+		t.Errorf("opened %d connections, want the shared client to reuse one", conns)
+	}
+}
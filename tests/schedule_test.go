@@ -0,0 +1,122 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/daite/tspider/common"
+)
+
+func TestCronNext(t *testing.T) {
+	from := time.Date(2026, 10, 16, 10, 7, 30, 0, time.UTC) // a Friday
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2026, 10, 16, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 10, 16, 10, 15, 0, 0, time.UTC)},
+		{"30 9 * * *", time.Date(2026, 10, 17, 9, 30, 0, 0, time.UTC)},
+		{"0 8-18/4 * * *", time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)},
+		{"0 0 * * 1,3", time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Both day fields restricted: either one matches
+		{"0 0 20 * 6", time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, 10, 16, 11, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)},
+		{"@every 30m", from.Add(30 * time.Minute)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		cron, err := common.ParseCron(tt.spec)
+		if err != nil {
+			t.Errorf("ParseCron(%q) = %v", tt.spec, err)
+			continue
+		}
+		if got := cron.Next(from); !got.Equal(tt.want) {
+			t.Errorf("ParseCron(%q).Next() = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestParseCronRejectsInvalid(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "0 0 0 * *", "5-1 * * * *", "*/0 * * * *", "a * * * *", "@every 10s", "@yearly"} {
+		if _, err := common.ParseCron(spec); err == nil {
+			t.Errorf("ParseCron(%q) = nil, want error", spec)
+		}
+	}
+}
+
+func TestScheduleConfig(t *testing.T) {
+	useTempHome(t)
+	if err := common.AddSchedule("bad", common.Schedule{Keyword: "show", Cron: "every day"}); err == nil {
+		t.Errorf("AddSchedule() with an invalid cron = nil, want error")
+	}
+	if err := common.AddSchedule("show", common.Schedule{Keyword: "show", Cron: "@daily"}); err != nil {
+		t.Fatalf("AddSchedule() = %v", err)
+	}
+	s, ok := common.GetConfig().Schedules["show"]
+	if !ok || s.Language() != "jp" {
+		t.Errorf("Schedules[show] = %+v, %v; want the schedule, searching jp sites", s, ok)
+	}
+	if err := common.RemoveSchedule("show"); err != nil {
+		t.Errorf("RemoveSchedule() = %v", err)
+	}
+	if err := common.RemoveSchedule("show"); err == nil {
+		t.Errorf("RemoveSchedule() of a removed schedule = nil, want error")
+	}
+
+	c := &common.Config{Timeout: 10, Schedules: map[string]common.Schedule{
+		"nightly": {Keyword: "show", Lang: "fr", Cron: "0 3 * *"},
+	}}
+	problems := strings.Join(common.ValidateConfig(c), "\n")
+	for _, want := range []string{`schedule nightly: language "fr"`} {
+		if !strings.Contains(problems, want) {
+			t.Errorf("ValidateConfig() = %q, want a problem containing %q", problems, want)
+		}
+	}
+}
+
+func TestSeenStore(t *testing.T) {
+	useTempHome(t)
+	store, err := common.LoadSeen()
+	if err != nil {
+		t.Fatalf("LoadSeen() = %v", err)
+	}
+	if store.Known("show") {
+		t.Errorf("Known() of a new search = true, want false")
+	}
+	first := []common.SearchResult{
+		{Title: "Show 01", Site: "nyaa", Magnet: "magnet:?xt=urn:btih:0123456789abcdef0123456789abcdef01234567"},
+		{Title: "Show 01 mirror", Site: "sukebe", Magnet: "magnet:?xt=urn:btih:0123456789ABCDEF0123456789ABCDEF01234567"},
+	}
+	now := time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC)
+	if added := store.Add("show", first, now); len(added) != 1 {
+		t.Errorf("Add() = %d new result(s), want 1: the mirror has the same info hash", len(added))
+	}
+	if err := store.Save(); err != nil {
+		t.Fatalf("Save() = %v", err)
+	}
+
+	store, err = common.LoadSeen()
+	if err != nil {
+		t.Fatalf("LoadSeen() after Save() = %v", err)
+	}
+	if !store.Known("show") {
+		t.Errorf("Known() after Save() = false, want true")
+	}
+	second := append(first, common.SearchResult{Title: "Show 02", Site: "nyaa", Magnet: "magnet:?xt=urn:btih:89abcdef0123456789abcdef0123456789abcdef"})
+	added := store.Add("show", second, now.Add(time.Hour))
+	if len(added) != 1 || added[0].Title != "Show 02" {
+		t.Errorf("Add() = %+v, want only Show 02", added)
+	}
+	if added := store.Add("other", second, now); len(added) != 2 {
+		t.Errorf("Add() for another search = %d new result(s), want 2", len(added))
+	}
+	store.Forget("show")
+	if store.Known("show") {
+		t.Errorf("Known() after Forget() = true, want false")
+	}
+}
@@ -0,0 +1,57 @@
+package tests
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/daite/tspider/common"
+)
+
+// detailSite returns one result with a detail page and a magnet that needs cleaning
+type detailSite struct{}
+
+func (detailSite) Crawl(ctx context.Context, keyword string) []common.SearchResult {
+	return []common.SearchResult{{
+		Title:     keyword,
+		Magnet:    " magnet:?xt=urn:btih:0123456789abcdef0123456789abcdef01234567 ",
+		Seeders:   7,
+		DetailURL: "https://example.com/view/1",
+	}}
+}
+
+func TestCollectDataKeepsScraperFields(t *testing.T) {
+	spinner := common.NewSpinner("test")
+	sites := map[string]common.Scraper{"detail": detailSite{}}
+	got, _ := common.CollectData(context.Background(), sites, "title", spinner)
+	if len(got) != 1 {
+		t.Fatalf("CollectData() = %v, want one result", got)
+	}
+	r := got[0]
+	if r.Site != "detail" {
+		t.Errorf("CollectData() Site = %q, want the site name", r.Site)
+	}
+	if r.Magnet != "magnet:?xt=urn:btih:0123456789abcdef0123456789abcdef01234567" {
+		t.Errorf("CollectData() Magnet = %q, want it cleaned", r.Magnet)
+	}
+	if r.Seeders != 7 || r.DetailURL != "https://example.com/view/1" {
+		t.Errorf("CollectData() = %+v, want seeders and detail URL kept", r)
+	}
+}
+
+func TestResultsFromMap(t *testing.T) {
+	m := &sync.Map{}
+	m.Store("a", common.SearchResult{Title: "a", Magnet: "magnet:?xt=urn:btih:a"})
+	m.Store("b", common.SearchResult{Title: "b", Magnet: "magnet:?xt=urn:btih:b"})
+	got := common.ResultsFromMap(m)
+	if len(got) != 2 {
+		t.Fatalf("ResultsFromMap() = %v, want 2 results", got)
+	}
+	titles := map[string]bool{}
+	for _, r := range got {
+		titles[r.Title] = true
+	}
+	if !titles["a"] || !titles["b"] {
+		t.Errorf("ResultsFromMap() = %v, want results a and b", got)
+	}
+}
@@ -0,0 +1,51 @@
+package tests
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/daite/tspider/common"
+)
+
+func TestParseSelection(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []int
+	}{
+		{"1", []int{0}},
+		{"1,3,5-7", []int{0, 2, 4, 5, 6}},
+		{" 3 , 1 ", []int{2, 0}},
+		{"2-4,3,4-5", []int{1, 2, 3, 4}},
+		{"1 2,,3", []int{0, 1, 2}},
+		{"6-6", []int{5}},
+		{"all", []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}},
+	}
+	for _, tt := range tests {
+		got, err := common.ParseSelection(tt.in, 10)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseSelection(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseSelectionErrors(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", "empty selection"},
+		{"0", "row 0 is out of range 1-10"},
+		{"11", "row 11 is out of range 1-10"},
+		{"3-12", "row 12 is out of range 1-10"},
+		{"7-5", `invalid range "7-5": 7 comes after 5`},
+		{"a", `"a" is not a row number`},
+		{"1-", `"" is not a row number`},
+		{"1-2-3", `"2-3" is not a row number`},
+	}
+	for _, tt := range tests {
+		_, err := common.ParseSelection(tt.in, 10)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ParseSelection(%q) error = %v, want it to contain %q", tt.in, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/daite/tspider/common"
+)
+
+func TestSetSelectorValidatesAndFallsBack(t *testing.T) {
+	useTempHome(t)
+	if err := common.SaveConfig(common.DefaultConfig()); err != nil {
+		t.Fatal(err)
+	}
+	const def = ".topic-item a"
+	if got := common.ListSelector("torrenttop", def); got != def {
+		t.Errorf("ListSelector() without override = %q, want %q", got, def)
+	}
+	if err := common.SetSelector("torrenttop", "list", "div[class="); err == nil {
+		t.Errorf("SetSelector() with unparseable selector = nil, want error")
+	}
+	if err := common.SetSelector("torrenttop", "title", ".x"); err == nil {
+		t.Errorf("SetSelector() with unknown key = nil, want error")
+	}
+	if err := common.SetSelector("torrenttop", "list", ".topic-row a.title"); err != nil {
+		t.Fatal(err)
+	}
+	if got := common.ListSelector("torrenttop", def); got != ".topic-row a.title" {
+		t.Errorf("ListSelector() = %q, want the override", got)
+	}
+	if got := common.MagnetSelector("torrenttop", "i.fas.fa-magnet"); got != "i.fas.fa-magnet" {
+		t.Errorf("MagnetSelector() = %q, want the default", got)
+	}
+	if err := common.SetSelector("torrenttop", "list", ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := common.ListSelector("torrenttop", def); got != def {
+		t.Errorf("ListSelector() after clearing = %q, want %q", got, def)
+	}
+}
@@ -0,0 +1,252 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/daite/tspider/common"
+	"github.com/daite/tspider/server"
+	"golang.org/x/net/websocket"
+)
+
+func newTestAPI(t *testing.T) (*httptest.Server, *[]string) {
+	var searches []string
+	api := &server.API{
+		APIKey: "k3y",
+		Lang:   "jp",
+		Search: func(ctx context.Context, keyword, lang string) ([]common.SearchResult, error) {
+			searches = append(searches, keyword+"|"+lang)
+			return []common.SearchResult{
+				{Title: "B few seeders", Magnet: "magnet:?xt=urn:btih:b", Seeders: 1},
+				{Title: "A many seeders", Magnet: "magnet:?xt=urn:btih:a", Seeders: 50},
+			}, nil
+		},
+		Stream: func(ctx context.Context, keyword, lang string, send func(server.Event)) ([]common.SearchResult, error) {
+			if keyword == "fail" {
+				return nil, errors.New("no available sites")
+			}
+			a := []common.SearchResult{{Site: "nyaa", Title: keyword + " 1080p", Magnet: "magnet:?xt=urn:btih:a"}}
+			send(server.Event{Type: "progress", Site: "nyaa", Done: 1, Total: 2})
+			send(server.Event{Type: "results", Site: "nyaa", Results: a})
+			send(server.Event{Type: "progress", Site: "sukebe", Done: 2, Total: 2})
+			return a, nil
+		},
+		Doctor: func(ctx context.Context, lang string, deep bool) []common.SiteStatus {
+			return []common.SiteStatus{{Name: "nyaa", Language: lang, Available: true, Degraded: deep}}
+		},
+	}
+	srv := httptest.NewServer(api.Handler())
+	t.Cleanup(srv.Close)
+	return srv, &searches
+}
+
+// getJSON fetches path with the API key header and decodes the reply into v
+func getJSON(t *testing.T, url string, v interface{}) int {
+	t.Helper()
+	req, _ := http.NewRequest("GET", url, nil)
+	req.Header.Set("X-Api-Key", "k3y")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	return resp.StatusCode
+}
+
+func TestAPISearch(t *testing.T) {
+	srv, searches := newTestAPI(t)
+	var got server.SearchResponse
+	if status := getJSON(t, srv.URL+"/api/search?q=ubuntu&lang=kr&sort=seeders&limit=1", &got); status != http.StatusOK {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	if len(*searches) != 1 || (*searches)[0] != "ubuntu|kr" {
+		t.Errorf("searches = %q, want ubuntu on kr sites", *searches)
+	}
+	if got.Count != 1 || got.Results[0].Title != "A many seeders" {
+		t.Errorf("reply = %+v, want the most seeded result only", got)
+	}
+}
+
+func TestAPIClearsUnsafeLinks(t *testing.T) {
+	hostile := []common.SearchResult{
+		{Title: "hostile", DetailURL: "javascript:alert(localStorage['tspider-api-key'])", Magnet: "javascript:alert(1)"},
+		{Title: "fine", DetailURL: "https://nyaa.si/view/1", Magnet: "magnet:?xt=urn:btih:a"},
+	}
+	api := &server.API{
+		Lang: "jp",
+		Search: func(ctx context.Context, keyword, lang string) ([]common.SearchResult, error) {
+			return hostile, nil
+		},
+		Stream: func(ctx context.Context, keyword, lang string, send func(server.Event)) ([]common.SearchResult, error) {
+			send(server.Event{Type: "results", Site: "nyaa", Results: hostile})
+			return hostile, nil
+		},
+	}
+	srv := httptest.NewServer(api.Handler())
+	defer srv.Close()
+
+	check := func(how string, results []common.SearchResult) {
+		if len(results) != 2 {
+			t.Fatalf("%s returned %d results, want 2", how, len(results))
+		}
+		if r := results[0]; r.DetailURL != "" || r.Magnet != "" {
+			t.Errorf("%s hostile result = %+v, want its links cleared", how, r)
+		}
+		if r := results[1]; r.DetailURL != "https://nyaa.si/view/1" || r.Magnet != "magnet:?xt=urn:btih:a" {
+			t.Errorf("%s fine result = %+v, want its links kept", how, r)
+		}
+	}
+	var got server.SearchResponse
+	getJSON(t, srv.URL+"/api/search?q=x", &got)
+	check("search", got.Results)
+	events := streamEvents(t, srv, "q=x")
+	if len(events) == 0 || events[0].Type != "results" {
+		t.Fatalf("events = %+v, want results first", events)
+	}
+	check("stream", events[0].Results)
+	if hostile[0].DetailURL == "" {
+		t.Errorf("the searched results were modified")
+	}
+}
+
+func TestAPISearchEnglishSites(t *testing.T) {
+	srv, searches := newTestAPI(t)
+	var got server.SearchResponse
+	if status := getJSON(t, srv.URL+"/api/search?q=ubuntu&lang=en", &got); status != http.StatusOK {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	if len(*searches) != 1 || (*searches)[0] != "ubuntu|en" {
+		t.Errorf("searches = %q, want ubuntu on en sites", *searches)
+	}
+}
+
+func TestAPISearchErrors(t *testing.T) {
+	srv, searches := newTestAPI(t)
+	for _, tc := range []struct {
+		query  string
+		status int
+	}{
+		{"", http.StatusBadRequest},
+		{"?q=x&lang=fr", http.StatusBadRequest},
+		{"?q=x&sort=size", http.StatusBadRequest},
+	} {
+		var reply map[string]string
+		if status := getJSON(t, srv.URL+"/api/search"+tc.query, &reply); status != tc.status || reply["error"] == "" {
+			t.Errorf("search%s = %d %v, want %d with an error", tc.query, status, reply, tc.status)
+		}
+	}
+	if len(*searches) != 0 {
+		t.Errorf("searches = %q, want none for invalid requests", *searches)
+	}
+
+	// Outside the WebSocket the key is only read from the header
+	for _, query := range []string{"", "?apikey=k3y", "?apikey=wrong"} {
+		resp, err := http.Get(srv.URL + "/api/sites" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("sites%s without the key header = %d, want 401", query, resp.StatusCode)
+		}
+	}
+}
+
+func TestAPIDoctorAndSites(t *testing.T) {
+	useTempHome(t)
+	if err := common.SaveConfig(common.DefaultConfig()); err != nil {
+		t.Fatal(err)
+	}
+	srv, _ := newTestAPI(t)
+	var statuses []common.SiteStatus
+	getJSON(t, srv.URL+"/api/doctor?lang=jp&deep=1", &statuses)
+	if len(statuses) != 1 || statuses[0].Language != "jp" || !statuses[0].Degraded {
+		t.Errorf("doctor = %+v, want the jp deep check", statuses)
+	}
+	var sites []server.Site
+	getJSON(t, srv.URL+"/api/sites", &sites)
+	if len(sites) != len(common.DefaultConfig().Sites) || sites[0].Name > sites[1].Name {
+		t.Errorf("sites = %+v, want every configured site sorted by name", sites)
+	}
+}
+
+func TestWebUI(t *testing.T) {
+	srv := httptest.NewServer(server.WebHandler())
+	defer srv.Close()
+	for path, want := range map[string]string{
+		"/":          `<script src="app.js">`,
+		"/app.js":    "api/search?",
+		"/style.css": "table",
+	} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), want) {
+			t.Errorf("GET %s = %d, want 200 containing %q", path, resp.StatusCode, want)
+		}
+	}
+}
+
+// streamEvents runs a /api/stream search and returns its events
+func streamEvents(t *testing.T, srv *httptest.Server, query string) []server.Event {
+	t.Helper()
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/api/stream?"+query, "", srv.URL)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer ws.Close()
+	var events []server.Event
+	for {
+		var e server.Event
+		if err := websocket.JSON.Receive(ws, &e); err != nil {
+			return events
+		}
+		events = append(events, e)
+	}
+}
+
+func TestAPIStream(t *testing.T) {
+	srv, _ := newTestAPI(t)
+	events := streamEvents(t, srv, "q=ubuntu&apikey=k3y")
+	var types []string
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	if got := strings.Join(types, ","); got != "progress,results,progress,done" {
+		t.Fatalf("events = %s, want progress,results,progress,done", got)
+	}
+	if e := events[1]; e.Site != "nyaa" || len(e.Results) != 1 || e.Results[0].Title != "ubuntu 1080p" {
+		t.Errorf("results event = %+v", e)
+	}
+	if e := events[2]; e.Done != 2 || e.Total != 2 {
+		t.Errorf("progress event = %+v, want 2 of 2", e)
+	}
+	if e := events[3]; e.Count != 1 {
+		t.Errorf("done event = %+v, want 1 result", e)
+	}
+
+	events = streamEvents(t, srv, "q=fail&apikey=k3y")
+	if len(events) != 1 || events[0].Type != "error" || events[0].Error == "" {
+		t.Errorf("events of a failed search = %+v, want one error", events)
+	}
+
+	ws := "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/stream?q=ubuntu"
+	if _, err := websocket.Dial(ws, "", srv.URL); err == nil {
+		t.Errorf("Dial() without the API key succeeded, want it refused")
+	}
+	if _, err := websocket.Dial(ws+"&apikey=k3y", "", "https://evil.example"); err == nil {
+		t.Errorf("Dial() from another origin succeeded, want it refused")
+	}
+}
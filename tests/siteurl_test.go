@@ -0,0 +1,59 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/daite/tspider/common"
+)
+
+// urlSite searches the URL its site is active on, as the scrapers do
+type urlSite struct{ name string }
+
+func (u urlSite) Crawl(ctx context.Context, keyword string) []common.SearchResult {
+	resp, ok := common.GetResponseFromURL(ctx, common.SiteURL(u.name)+"/search?q="+keyword)
+	if !ok {
+		return nil
+	}
+	resp.Body.Close()
+	return []common.SearchResult{{Title: u.name + " " + keyword, Magnet: "magnet:?xt=urn:btih:" + u.name}}
+}
+
+// TestConcurrentSearches runs overlapping searches, as serve does for
+// concurrent requests; run with -race to catch unguarded site URLs
+func TestConcurrentSearches(t *testing.T) {
+	useTempHome(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html><body>ok</body></html>"))
+	}))
+	defer srv.Close()
+	c := common.DefaultConfig()
+	c.Sites = map[string]common.SiteConfig{
+		"alpha": {URL: srv.URL, Enabled: true, Language: "jp"},
+		"beta":  {URL: srv.URL + "/", URLs: []string{srv.URL}, Enabled: true, Language: "jp"},
+	}
+	c.MaxRetries = -1
+	if err := common.SaveConfig(c); err != nil {
+		t.Fatal(err)
+	}
+	common.Availability().Reset()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			scrapers := map[string]common.Scraper{"alpha": urlSite{"alpha"}, "beta": urlSite{"beta"}}
+			sites, spinner := common.GetAvailableSites(context.Background(), scrapers)
+			results, _ := common.CollectData(context.Background(), sites, "x", spinner)
+			spinner.Stop()
+			if len(results) != 2 {
+				t.Errorf("CollectData() = %d results, want 2", len(results))
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/daite/tspider/common"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+		ok   bool
+	}{
+		{"1.5 GiB", 3 << 29, true},
+		{"700MB", 700 << 20, true},
+		{"512 KiB", 512 << 10, true},
+		{"2 TiB", 2 << 40, true},
+		{"1,5 GB", 3 << 29, true},
+		{"1234", 1234, true},
+		{"100 Bytes", 100, true},
+		{"", 0, false},
+		{"n/a", 0, false},
+		{"1.4 XB", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := common.ParseSize(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ParseSize(%q) = %d, %v, want %d, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestSizeBucketLabels(t *testing.T) {
+	got := common.SizeBucketLabels([]int64{2 << 30, 500 << 20})
+	want := []string{"<500MiB", "500MiB–2GiB", ">2GiB", "unknown"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SizeBucketLabels() = %q, want %q", got, want)
+	}
+}
+
+func TestBucketBySize(t *testing.T) {
+	results := []common.SearchResult{
+		{Title: "small", Size: "350 MiB"},
+		{Title: "edge", Size: "500 MiB"},
+		{Title: "medium", Size: "1.4 GiB"},
+		{Title: "large", Size: "4.3 GiB"},
+		{Title: "nosize"},
+		{Title: "small2", Size: "120 MiB"},
+	}
+	buckets := common.BucketBySize(results, common.DefaultSizeBuckets)
+	titles := func(label string) []string {
+		var ts []string
+		for _, r := range buckets[label] {
+			ts = append(ts, r.Title)
+		}
+		return ts
+	}
+	want := map[string][]string{
+		"<500MiB":     {"small", "small2"},
+		"500MiB–2GiB": {"edge", "medium"},
+		">2GiB":       {"large"},
+		"unknown":     {"nosize"},
+	}
+	for label, w := range want {
+		if got := titles(label); !reflect.DeepEqual(got, w) {
+			t.Errorf("bucket %s = %q, want %q", label, got, w)
+		}
+	}
+	if len(buckets) != len(want) {
+		t.Errorf("BucketBySize() returned %d buckets, want %d", len(buckets), len(want))
+	}
+}
+
+func TestPrintBySizeOrdersBuckets(t *testing.T) {
+	results := []common.SearchResult{
+		{Site: "nyaa", Title: "large", Size: "4 GiB"},
+		{Site: "nyaa", Title: "small", Size: "100 MiB"},
+	}
+	var buf bytes.Buffer
+	common.PrintBySize(&buf, common.DataExColumns, results, common.DefaultSizeBuckets)
+	out := buf.String()
+	small, large := strings.Index(out, "== <500MiB (1) =="), strings.Index(out, "== >2GiB (1) ==")
+	if small < 0 || large < 0 || small > large {
+		t.Errorf("PrintBySize() headers missing or out of order:\n%s", out)
+	}
+	if strings.Contains(out, "500MiB–2GiB") || strings.Contains(out, "unknown") {
+		t.Errorf("PrintBySize() printed an empty bucket:\n%s", out)
+	}
+}
+
+func TestValidateConfigSizeBuckets(t *testing.T) {
+	c := common.DefaultConfig()
+	c.SizeBuckets = []string{"500MiB", "lots"}
+	problems := common.ValidateConfig(c)
+	if len(problems) != 1 || !strings.Contains(problems[0], "size_buckets") {
+		t.Errorf("ValidateConfig() = %q, want one size_buckets problem", problems)
+	}
+}
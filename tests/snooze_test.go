@@ -0,0 +1,97 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/daite/tspider/common"
+)
+
+func TestGetAvailableSitesSnoozesFailingSite(t *testing.T) {
+	useTempHome(t)
+	useRetries(t, 0)
+	probes := 0
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		probes++
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer down.Close()
+
+	c := common.DefaultConfig()
+	c.SnoozeAfter = 2
+	c.SnoozeHours = 1
+	c.Sites = map[string]common.SiteConfig{
+		"flaky": {URL: down.URL, Language: "kr", Enabled: true},
+	}
+	if err := common.SaveConfig(c); err != nil {
+		t.Fatal(err)
+	}
+	check := func() {
+		t.Helper()
+		common.Availability().Reset()
+		sites, spinner := common.GetAvailableSites(context.Background(), map[string]common.Scraper{"flaky": fastSite{}})
+		spinner.Stop()
+		if len(sites) != 0 {
+			t.Fatalf("GetAvailableSites() = %v, want no site up", sites)
+		}
+	}
+
+	check()
+	check()
+	if probes != 2 {
+		t.Fatalf("probes = %d after two checks, want 2", probes)
+	}
+	state, err := common.LoadSiteState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !state.Snoozed("flaky", time.Now()) {
+		t.Fatalf("flaky not snoozed after 2 failed checks: %+v", state.Sites)
+	}
+	check()
+	if probes != 2 {
+		t.Errorf("snoozed site was probed")
+	}
+
+	state.Reset("flaky")
+	if err := state.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(common.GetSiteStatePath()); !os.IsNotExist(err) {
+		t.Errorf("state file left after reset: %v", err)
+	}
+	check()
+	if probes != 3 {
+		t.Errorf("probes = %d after reset, want the site checked again", probes)
+	}
+}
+
+func TestSiteStateSuccessClearsFailures(t *testing.T) {
+	useTempHome(t)
+	c := common.DefaultConfig()
+	if err := common.SaveConfig(c); err != nil {
+		t.Fatal(err)
+	}
+	state, err := common.LoadSiteState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	state.RecordFailure("site", now)
+	state.RecordFailure("site", now)
+	state.RecordSuccess("site")
+	if state.RecordFailure("site", now) {
+		t.Errorf("site snoozed after one failure following a success")
+	}
+	state.RecordFailure("site", now)
+	if !state.RecordFailure("site", now) {
+		t.Errorf("site not snoozed after 3 failures in a row")
+	}
+	if state.Snoozed("site", now.Add(25*time.Hour)) {
+		t.Errorf("site still snoozed past the default snooze_hours")
+	}
+}
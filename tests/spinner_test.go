@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/daite/tspider/common"
+)
+
+func TestSpinnerPrintAboveKeepsLinesIntact(t *testing.T) {
+	var buf bytes.Buffer
+	spinner := common.NewSpinner("Searching")
+	spinner.SetOutput(&buf)
+	spinner.Start()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			time.Sleep(time.Duration(i) * 10 * time.Millisecond)
+			spinner.PrintAbove(fmt.Sprintf("site-%02d result line", i))
+		}(i)
+	}
+	wg.Wait()
+	spinner.Stop()
+
+	out := buf.String()
+	for i := 0; i < 20; i++ {
+		line := fmt.Sprintf("\r\033[Ksite-%02d result line\n", i)
+		if !strings.Contains(out, line) {
+			t.Errorf("output is missing an intact %q", line)
+		}
+	}
+}
+
+func TestSpinnerDropsResultsAfterStop(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		sites []string
+	)
+	spinner := common.NewSpinner("Searching")
+	spinner.SetOutput(&bytes.Buffer{})
+	spinner.OnResults(func(site string, results []common.SearchResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		sites = append(sites, site)
+	})
+	spinner.Start()
+
+	release := make(chan struct{})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		common.CollectData(context.Background(), map[string]common.Scraper{"fast": fastSite{}, "late": lateSite{ctx, release}}, "k", spinner)
+		close(done)
+	}()
+	<-ctx.Done()
+	spinner.Stop()
+	close(release)
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(sites) != 1 || sites[0] != "fast" {
+		t.Errorf("OnResults() called for %q, want only fast, as late finished after Stop", sites)
+	}
+}
+
+// lateSite finishes once both its deadline has passed and it is released
+type lateSite struct {
+	deadline context.Context
+	release  chan struct{}
+}
+
+func (l lateSite) Crawl(ctx context.Context, keyword string) []common.SearchResult {
+	<-l.deadline.Done()
+	<-l.release
+	return []common.SearchResult{{Title: keyword + " late", Magnet: "magnet:?xt=urn:btih:late"}}
+}
@@ -0,0 +1,110 @@
+package tests
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/daite/tspider/common"
+	"github.com/daite/tspider/jtorrent"
+	"github.com/daite/tspider/ktorrent"
+)
+
+// goqueryLinks extracts links the way the scrapers do by default
+func goqueryLinks(t testing.TB, page []byte, selector string, fromText bool) []common.Link {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var links []common.Link
+	doc.Find(selector).Each(func(i int, s *goquery.Selection) {
+		title, ok := s.Attr("title")
+		if fromText {
+			title, ok = s.Text(), true
+		}
+		href, linkOk := s.Attr("href")
+		if !ok || !linkOk {
+			return
+		}
+		links = append(links, common.Link{Title: strings.TrimSpace(title), Href: href})
+	})
+	return links
+}
+
+func readFixture(t testing.TB, name string) []byte {
+	page, err := os.ReadFile("../resources/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return page
+}
+
+func TestStreamLinksMatchesGoquery(t *testing.T) {
+	tests := []struct {
+		fixture  string
+		selector string
+		rule     common.LinkRule
+	}{
+		{"nyaa_search.html", "a[href*=view]:last-child", jtorrent.ListLinks},
+		{"torrenttop_search.html", ".topic-item a", ktorrent.TorrentTopLinks},
+	}
+	for _, tt := range tests {
+		page := readFixture(t, tt.fixture)
+		want := goqueryLinks(t, page, tt.selector, tt.rule.TitleFromText)
+		got, err := common.StreamLinks(bytes.NewReader(page), tt.rule)
+		if err != nil {
+			t.Fatalf("StreamLinks(%s) error: %v", tt.fixture, err)
+		}
+		if len(want) == 0 {
+			t.Fatalf("goquery found no links in %s", tt.fixture)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("StreamLinks(%s) = %q, want %q", tt.fixture, got, want)
+		}
+	}
+}
+
+func TestStreamLinksNestedContainers(t *testing.T) {
+	page := `<div class="topic-item"><div><br><img src="x"><a href="/a" title="A">a</a></div></div>
+<a href="/outside" title="Outside">o</a>
+<div class="item topic-item"><a href="/b"><span>B</span> text</a></div>`
+	rule := common.LinkRule{Within: "topic-item", Href: func(h string) bool { return true }, TitleFromText: true}
+	got, err := common.StreamLinks(strings.NewReader(page), rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []common.Link{{Title: "a", Href: "/a"}, {Title: "B text", Href: "/b"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("StreamLinks() = %q, want %q", got, want)
+	}
+}
+
+// largePage repeats the TorrentTop fixture's result rows to emulate a very
+// large search page
+func largePage(b *testing.B) []byte {
+	page := readFixture(b, "torrenttop_search.html")
+	return bytes.Repeat(page, 50)
+}
+
+func BenchmarkListGoquery(b *testing.B) {
+	page := largePage(b)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(page)))
+	for i := 0; i < b.N; i++ {
+		goqueryLinks(b, page, ".topic-item a", false)
+	}
+}
+
+func BenchmarkListStream(b *testing.B) {
+	page := largePage(b)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(page)))
+	for i := 0; i < b.N; i++ {
+		if _, err := common.StreamLinks(bytes.NewReader(page), ktorrent.TorrentTopLinks); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package tests
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/daite/tspider/common"
+)
+
+// suggestSite suggests the prefix followed by each of its endings
+type suggestSite struct{ endings []string }
+
+func (s suggestSite) Crawl(ctx context.Context, keyword string) []common.SearchResult {
+	return nil
+}
+
+func (s suggestSite) Suggest(ctx context.Context, prefix string) ([]string, error) {
+	var suggestions []string
+	for _, ending := range s.endings {
+		suggestions = append(suggestions, prefix+ending)
+	}
+	return suggestions, nil
+}
+
+// hangingSuggestSite answers only when its context is done
+type hangingSuggestSite struct{}
+
+func (hangingSuggestSite) Crawl(ctx context.Context, keyword string) []common.SearchResult {
+	return nil
+}
+
+func (hangingSuggestSite) Suggest(ctx context.Context, prefix string) ([]string, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestSuggest(t *testing.T) {
+	useTempHome(t)
+	c := common.DefaultConfig()
+	c.Timeout = 1
+	c.Sites = map[string]common.SiteConfig{
+		"titles":   {URL: "https://titles.example", Language: "jp", Enabled: true},
+		"slow":     {URL: "https://slow.example", Language: "jp", Enabled: true},
+		"plain":    {URL: "https://plain.example", Language: "jp", Enabled: true},
+		"disabled": {URL: "https://disabled.example", Language: "jp"},
+	}
+	if err := common.SaveConfig(c); err != nil {
+		t.Fatal(err)
+	}
+	sites := map[string]common.Scraper{
+		"titles":   suggestSite{[]string{" 01", " 02"}},
+		"slow":     hangingSuggestSite{},
+		"plain":    fastSite{},
+		"disabled": suggestSite{[]string{" 03"}},
+	}
+
+	start := time.Now()
+	got := common.Suggest(context.Background(), sites, "Show")
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("Suggest() took %v, want the timeout of 1s to stop slow", elapsed)
+	}
+	if len(got) != 2 || got[0].Site != "slow" || got[1].Site != "titles" {
+		t.Fatalf("Suggest() = %+v, want slow and titles only", got)
+	}
+	if got[0].Error == "" {
+		t.Errorf("Suggest() of a site past the timeout = %+v, want an error", got[0])
+	}
+	if want := []string{"Show 01", "Show 02"}; !reflect.DeepEqual(got[1].Suggestions, want) {
+		t.Errorf("Suggest() suggestions of titles = %q, want %q", got[1].Suggestions, want)
+	}
+}
+
+func TestSuggestWithoutSuggesters(t *testing.T) {
+	bin := buildTspider(t)
+	home := useTempHome(t)
+	_, stderr, err := runTspider(bin, home, "suggest", "--lang", "en", "show")
+	if err == nil || !strings.Contains(stderr, "no en site offers search suggestions") {
+		t.Errorf("suggest on sites without suggestions = %v, %q; want an error saying so", err, stderr)
+	}
+}
@@ -0,0 +1,36 @@
+package tests
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/daite/tspider/common"
+)
+
+func TestWriteTemplate(t *testing.T) {
+	results := []common.SearchResult{
+		{Site: "nyaa", Title: "a", Magnet: "magnet:?xt=urn:btih:a", Seeders: 3},
+		{Site: "torrenttop", Title: "b", Magnet: "magnet:?xt=urn:btih:b"},
+	}
+	tmpl, err := common.ParseResultTemplate(`{{.Site}}\t{{.Title}}\t{{.Seeders}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := common.WriteTemplate(&buf, tmpl, results); err != nil {
+		t.Fatal(err)
+	}
+	want := "nyaa\ta\t3\ntorrenttop\tb\t0\n"
+	if got := buf.String(); got != want {
+		t.Errorf("WriteTemplate() = %q, want %q", got, want)
+	}
+}
+
+func TestParseResultTemplateRejectsBadTemplates(t *testing.T) {
+	if _, err := common.ParseResultTemplate("{{.Title"); err == nil {
+		t.Errorf("ParseResultTemplate() with unclosed action = nil, want error")
+	}
+	if _, err := common.ParseResultTemplate("{{.NoSuchField}}"); err == nil {
+		t.Errorf("ParseResultTemplate() with unknown field = nil, want error")
+	}
+}
@@ -0,0 +1,91 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/daite/tspider/common"
+	"github.com/daite/tspider/jtorrent"
+)
+
+func tokyoToshoWant(base string) []common.SearchResult {
+	return []common.SearchResult{
+		{
+			Title:     "[SubsPlease] Nijiiro Days - 01 (1080p) [3A5B7C9D].mkv",
+			Magnet:    "magnet:?xt=urn:btih:6KLGXYCJU4ZCB6TKG3VGG3Y5SAOIWKFB&tr=http%3A%2F%2Fnyaa.tracker.wf%3A7777%2Fannounce",
+			Uploader:  "SubsPlease",
+			Seeders:   214,
+			Leechers:  12,
+			Snatches:  3120,
+			Size:      "1.37GB",
+			Category:  "Anime",
+			DetailURL: base + "/details.php?id=1598765",
+			Date:      time.Date(2026, 10, 15, 18, 2, 0, 0, time.UTC),
+		},
+		{
+			Title:     "Nijiiro Days 01 RAW (BS11 1280x720 x264 AAC).mp4",
+			Magnet:    "magnet:?xt=urn:btih:2f1b0c6e8a7d4e3f9b5a1c0d2e4f6a8b0c1d3e5f&tr=udp%3A%2F%2Ftracker.opentrackr.org%3A1337%2Fannounce",
+			Uploader:  "Anonymous",
+			Leechers:  1,
+			Snatches:  45,
+			Size:      "612.4MB",
+			Category:  "Raws",
+			DetailURL: base + "/details.php?id=1598701",
+			Date:      time.Date(2026, 10, 15, 16, 40, 0, 0, time.UTC),
+		},
+	}
+}
+
+func TestTokyoToshoResults(t *testing.T) {
+	useTempHome(t)
+	f, err := os.Open("../resources/tokyotosho_search.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	doc, err := goquery.NewDocumentFromReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := jtorrent.TokyoToshoResults(doc, "https://www.tokyotosho.info/search.php?terms=nijiiro&type=0")
+	if want := tokyoToshoWant("https://www.tokyotosho.info"); !reflect.DeepEqual(got, want) {
+		t.Errorf("TokyoToshoResults() = %+v, want %+v", got, want)
+	}
+}
+
+func TestTokyoToshoCrawl(t *testing.T) {
+	useTempHome(t)
+	page, err := os.ReadFile("../resources/tokyotosho_search.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var terms string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/search.php" {
+			http.NotFound(w, r)
+			return
+		}
+		terms = r.URL.Query().Get("terms")
+		w.Write(page)
+	}))
+	defer srv.Close()
+	c := common.DefaultConfig()
+	c.Sites["tokyotosho"] = common.SiteConfig{URL: srv.URL, Language: "jp", Enabled: true}
+	if err := common.SaveConfig(c); err != nil {
+		t.Fatal(err)
+	}
+
+	got := (&jtorrent.TokyoTosho{}).Crawl(context.Background(), "nijiiro days")
+	if terms != "nijiiro days" {
+		t.Errorf("searched for %q, want %q", terms, "nijiiro days")
+	}
+	if want := tokyoToshoWant(srv.URL); !reflect.DeepEqual(got, want) {
+		t.Errorf("Crawl() = %+v, want %+v", got, want)
+	}
+}
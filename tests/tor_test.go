@@ -0,0 +1,260 @@
+package tests
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/daite/tspider/common"
+)
+
+// fakeTorControl answers like a Tor control port and records the commands
+func fakeTorControl(t *testing.T, password string) (string, <-chan []string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	commands := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var got []string
+		defer func() { commands <- got }()
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			got = append(got, line)
+			if strings.HasPrefix(line, "AUTHENTICATE") && line != `AUTHENTICATE "`+password+`"` {
+				conn.Write([]byte("515 Authentication failed\r\n"))
+				return
+			}
+			conn.Write([]byte("250 OK\r\n"))
+		}
+	}()
+	return ln.Addr().String(), commands
+}
+
+func TestNewTorCircuit(t *testing.T) {
+	useTempHome(t)
+	addr, commands := fakeTorControl(t, "secret")
+	c := common.DefaultConfig()
+	c.TorControlAddr = addr
+	c.TorControlPassword = "secret"
+	if err := common.SaveConfig(c); err != nil {
+		t.Fatal(err)
+	}
+	if err := common.NewTorCircuit(); err != nil {
+		t.Fatalf("NewTorCircuit() = %v", err)
+	}
+	want := []string{`AUTHENTICATE "secret"`, "SIGNAL NEWNYM"}
+	if got := <-commands; !reflect.DeepEqual(got, want) {
+		t.Errorf("control port got %q, want %q", got, want)
+	}
+}
+
+func TestNewTorCircuitReportsAuthFailure(t *testing.T) {
+	useTempHome(t)
+	addr, commands := fakeTorControl(t, "secret")
+	c := common.DefaultConfig()
+	c.TorControlAddr = addr
+	c.TorControlPassword = "wrong"
+	if err := common.SaveConfig(c); err != nil {
+		t.Fatal(err)
+	}
+	err := common.NewTorCircuit()
+	if err == nil || !strings.Contains(err.Error(), "515") {
+		t.Errorf("NewTorCircuit() with wrong password = %v, want the 515 reply", err)
+	}
+	if got := <-commands; len(got) != 1 {
+		t.Errorf("control port got %q, want only AUTHENTICATE", got)
+	}
+}
+
+func TestUseTorFailsWithoutTor(t *testing.T) {
+	useTempHome(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	c := common.DefaultConfig()
+	c.TorSocksAddr = addr
+	if err := common.SaveConfig(c); err != nil {
+		t.Fatal(err)
+	}
+	if err := common.UseTor(false); err == nil || !strings.Contains(err.Error(), "Tor does not seem to be running") {
+		t.Errorf("UseTor() without Tor = %v, want a clear error", err)
+	}
+}
+
+// fakeSOCKS5 is a SOCKS5 proxy requiring username/password authentication
+// that records the username of each connection and relays it
+func fakeSOCKS5(t *testing.T) (string, func() []string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	var mu sync.Mutex
+	var users []string
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				// Greeting: version, methods; choose username/password
+				head := make([]byte, 2)
+				io.ReadFull(r, head)
+				io.ReadFull(r, make([]byte, head[1]))
+				conn.Write([]byte{5, 2})
+				// Auth: version, user, password
+				ver, _ := r.ReadByte()
+				n, _ := r.ReadByte()
+				user := make([]byte, n)
+				io.ReadFull(r, user)
+				n, _ = r.ReadByte()
+				io.ReadFull(r, make([]byte, n))
+				mu.Lock()
+				users = append(users, string(user))
+				mu.Unlock()
+				conn.Write([]byte{ver, 0})
+				// Request: version, CONNECT, reserved, address type, address, port
+				req := make([]byte, 4)
+				io.ReadFull(r, req)
+				var host string
+				switch req[3] {
+				case 1:
+					ip := make([]byte, 4)
+					io.ReadFull(r, ip)
+					host = net.IP(ip).String()
+				case 3:
+					n, _ := r.ReadByte()
+					name := make([]byte, n)
+					io.ReadFull(r, name)
+					host = string(name)
+				}
+				port := make([]byte, 2)
+				io.ReadFull(r, port)
+				target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(port[0])<<8|int(port[1]))))
+				if err != nil {
+					conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+					return
+				}
+				defer target.Close()
+				conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+				go io.Copy(target, r)
+				io.Copy(conn, target)
+			}()
+		}
+	}()
+	return ln.Addr().String(), func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), users...)
+	}
+}
+
+func TestTorIsolateSites(t *testing.T) {
+	useTempHome(t)
+	addr, users := fakeSOCKS5(t)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, "ok") })
+	site1 := httptest.NewServer(handler)
+	defer site1.Close()
+	site2 := httptest.NewServer(handler)
+	defer site2.Close()
+
+	client := common.NewHTTPClient(&common.Config{Timeout: 10, Proxy: "socks5://" + addr, TorIsolateSites: true})
+	for _, u := range []string{site1.URL, site2.URL, site1.URL + "/again"} {
+		resp, err := client.Get(u)
+		if err != nil {
+			t.Fatalf("GET %s through the proxy: %v", u, err)
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	host := func(s *httptest.Server) string { return strings.TrimPrefix(s.URL, "http://") }
+	// The second request to site1 reuses its connection
+	want := []string{host(site1), host(site2)}
+	if got := users(); !reflect.DeepEqual(got, want) {
+		t.Errorf("SOCKS usernames = %q, want one per site %q", got, want)
+	}
+}
+
+// TestHelperTorSave is run as a subprocess by TestTorIsNotSaved
+func TestHelperTorSave(t *testing.T) {
+	site := os.Getenv("TSPIDER_HELPER_TOR_SITE")
+	if site == "" {
+		t.Skip("helper process only")
+	}
+	if err := common.UseTor(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := common.SaveConfig(common.GetConfig()); err != nil {
+		t.Fatal(err)
+	}
+	if err := common.AddBlock("saved"); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := common.HTTPClient().Get(site)
+	if err != nil {
+		t.Fatalf("GET %s after saving the config: %v", site, err)
+	}
+	resp.Body.Close()
+}
+
+func TestTorIsNotSaved(t *testing.T) {
+	home := useTempHome(t)
+	addr, users := fakeSOCKS5(t)
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, "ok") }))
+	defer site.Close()
+	c := common.DefaultConfig()
+	c.TorSocksAddr = addr
+	if err := common.SaveConfig(c); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperTorSave$")
+	cmd.Env = append(os.Environ(), "HOME="+home, "USERPROFILE="+home, "TSPIDER_HELPER_TOR_SITE="+site.URL)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("helper: %v\n%s", err, out)
+	}
+
+	saved, err := common.ReadConfigFile(common.GetConfigPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Proxy != "" || saved.TorIsolateSites {
+		t.Errorf("saved config has proxy %q, tor_isolate_sites %v; want --tor and --tor-isolate left out", saved.Proxy, saved.TorIsolateSites)
+	}
+	if len(saved.Blocklist) != 1 {
+		t.Errorf("saved blocklist = %q, want the helper's change", saved.Blocklist)
+	}
+	// The run kept going through Tor, isolated, after the saves; the first
+	// connection is UseTor checking that the proxy listens
+	want := strings.TrimPrefix(site.URL, "http://")
+	if got := users(); len(got) != 2 || got[1] != want {
+		t.Errorf("SOCKS usernames = %q, want the request to %s through Tor", got, want)
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"testing"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/daite/tspider/common"
+	"github.com/daite/tspider/ktorrent"
 )
 
 func TestGetDataFuncForTorrentMax(t *testing.T) {
@@ -58,3 +60,59 @@ func TestGetMagnetFuncForTorrentMax(t *testing.T) {
 		t.Errorf("GetMagnet() for TorrentMax = %q, want %q", got, want)
 	}
 }
+
+func TestTorrentMaxLinks(t *testing.T) {
+	f, err := os.Open("../resources/torrentmax_search.html")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	doc, err := goquery.NewDocumentFromReader(f)
+	if err != nil {
+		log.Fatal(err)
+	}
+	links := ktorrent.TorrentMaxLinks(doc, "https://torrentmax15.com/search?stx=x")
+	if len(links) != 10 {
+		t.Fatalf("TorrentMaxLinks() found %d links, want 10", len(links))
+	}
+	want := common.Link{Title: "동상이몽2 너는 내운명.E183.210208.720p-NEXT", Href: "https://torrentmax15.com/max/VARIETY/23713"}
+	if links[0] != want {
+		t.Errorf("TorrentMaxLinks()[0] = %q, want %q", links[0], want)
+	}
+}
+
+func TestTorrentMaxMagnet(t *testing.T) {
+	f, err := os.Open("../resources/torrentmax_bbs.html")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	doc, err := goquery.NewDocumentFromReader(f)
+	if err != nil {
+		log.Fatal(err)
+	}
+	got := ktorrent.TorrentMaxMagnet(doc)
+	want := "magnet:?xt=urn:btih:cbed3a226963bba284cc056a4ee2e1257ff71725"
+	if got != want {
+		t.Errorf("TorrentMaxMagnet() = %q, want %q", got, want)
+	}
+}
+
+func TestURLJoin(t *testing.T) {
+	tests := []struct {
+		base, rel, want string
+	}{
+		{"https://torrentmax15.com/search?stx=x", "/max/VARIETY/1", "https://torrentmax15.com/max/VARIETY/1"},
+		{"https://torrentmax15.com/search?stx=x", "https://other.com/a", "https://other.com/a"},
+		{"https://site.com/bbs/", "./board.php?wr_id=1", "https://site.com/bbs/board.php?wr_id=1"},
+		{"https://site.com", "/torrent/a.html", "https://site.com/torrent/a.html"},
+	}
+	for _, tt := range tests {
+		if got, err := common.URLJoin(tt.base, tt.rel); err != nil || got != tt.want {
+			t.Errorf("URLJoin(%q, %q) = %q, %v, want %q", tt.base, tt.rel, got, err, tt.want)
+		}
+	}
+	if got, err := common.URLJoin("https://site.com/bbs/", "http://[::1"); err == nil {
+		t.Errorf("URLJoin() = %q, want an error for a malformed href", got)
+	}
+}
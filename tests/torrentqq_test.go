@@ -1,13 +1,19 @@
 package tests
 
 import (
+	"context"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"reflect"
 	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/daite/tspider/common"
+	"github.com/daite/tspider/ktorrent"
 )
 
 func TestGetDataFuncForTorrentQQ(t *testing.T) {
@@ -56,3 +62,82 @@ func TestGetMagnetFuncForTorrentQQ(t *testing.T) {
 		t.Errorf("GetMagnet() for TorrentQQ = %q, want %q", got, want)
 	}
 }
+
+func TestTorrentQQResults(t *testing.T) {
+	f, err := os.Open("../resources/torrentqq_search.html")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	doc, err := goquery.NewDocumentFromReader(f)
+	if err != nil {
+		log.Fatal(err)
+	}
+	got := ktorrent.TorrentQQResults(doc, "https://torrentqq78.com/search?q=x")
+	want := []common.SearchResult{{
+		Title:     "온앤오프.E36.210316.720p-NEXT",
+		DetailURL: "https://torrentqq78.com/torrent/med/417306.html",
+		Size:      "1.91G",
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TorrentQQResults() = %+v, want %+v", got, want)
+	}
+}
+
+func TestTorrentQQMagnet(t *testing.T) {
+	f, err := os.Open("../resources/torrentqq_bbs.html")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	doc, err := goquery.NewDocumentFromReader(f)
+	if err != nil {
+		log.Fatal(err)
+	}
+	got := ktorrent.TorrentQQMagnet(doc)
+	want := "magnet:?xt=urn:btih:e9322c31da47494a31c7f8312c92e7a50a973759"
+	if got != want {
+		t.Errorf("TorrentQQMagnet() = %q, want %q", got, want)
+	}
+}
+
+func TestTorrentQQCrawl(t *testing.T) {
+	useTempHome(t)
+	search, err := os.ReadFile("../resources/torrentqq_search.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	detail, err := os.ReadFile("../resources/torrentqq_bbs.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/search":
+			// Point the result at this server, as the fixture links to the live site
+			w.Write([]byte(strings.ReplaceAll(string(search), "https://torrentqq78.com", srv.URL)))
+		case "/torrent/med/417306.html":
+			w.Write(detail)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	c := common.DefaultConfig()
+	c.Sites["torrentqq"] = common.SiteConfig{URL: srv.URL, Language: "kr", Enabled: true}
+	if err := common.SaveConfig(c); err != nil {
+		t.Fatal(err)
+	}
+
+	got := (&ktorrent.TorrentQQ{}).Crawl(context.Background(), "온앤오프")
+	want := []common.SearchResult{{
+		Title:     "온앤오프.E36.210316.720p-NEXT",
+		Magnet:    "magnet:?xt=urn:btih:e9322c31da47494a31c7f8312c92e7a50a973759",
+		DetailURL: srv.URL + "/torrent/med/417306.html",
+		Size:      "1.91G",
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Crawl() = %+v, want %+v", got, want)
+	}
+}
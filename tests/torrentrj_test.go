@@ -3,10 +3,13 @@ package tests
 import (
 	"log"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/daite/tspider/common"
+	"github.com/daite/tspider/ktorrent"
 )
 
 func TestGetDataFuncForTorrentRJ(t *testing.T) {
@@ -49,3 +52,41 @@ func TestGetMagnetFuncForTorrentRJ(t *testing.T) {
 		t.Errorf("GetMagnet() for TorrentRJ = %q, want %q", got, want)
 	}
 }
+
+func TestTorrentRJResults(t *testing.T) {
+	f, err := os.Open("../resources/torrentrj_search.html")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	doc, err := goquery.NewDocumentFromReader(f)
+	if err != nil {
+		log.Fatal(err)
+	}
+	got := ktorrent.TopicBoard.Results(doc, "torrentrj", "https://torrentrj28.com/search/index?keywords=x")
+	want := []common.SearchResult{{
+		Title:     "광서열차 2021.1080p.KOR.FHDRip.H264.AAC-JTC",
+		DetailURL: "https://torrentrj28.com/v/106444",
+		Size:      "3,467.1M",
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Results() = %+v, want %+v", got, want)
+	}
+}
+
+func TestTorrentRJMagnet(t *testing.T) {
+	f, err := os.Open("../resources/torrentrj_bbs.html")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	doc, err := goquery.NewDocumentFromReader(f)
+	if err != nil {
+		log.Fatal(err)
+	}
+	got := ktorrent.TopicBoard.MagnetOf(doc, "torrentrj")
+	want := "magnet:?xt=urn:btih:80788dd173e48e5eb139758c165a89c3c048d458"
+	if got != want {
+		t.Errorf("MagnetOf() = %q, want %q", got, want)
+	}
+}
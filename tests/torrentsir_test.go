@@ -6,8 +6,10 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/daite/tspider/ktorrent"
 )
 
 func TestGetDataFuncForTorrentSir(t *testing.T) {
@@ -49,3 +51,45 @@ func TestGetMagnetFuncForTorrentSir(t *testing.T) {
 		t.Errorf("GetMagnet() for TorrentSir = %q, want %q", got, want)
 	}
 }
+
+func TestTorrentSirResults(t *testing.T) {
+	f, err := os.Open("../resources/torrentsir_search.html")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	doc, err := goquery.NewDocumentFromReader(f)
+	if err != nil {
+		log.Fatal(err)
+	}
+	got := ktorrent.Gnuboard.Results(doc, "torrentsir", "https://torrentsir31.com/bbs/search.php?&stx=x")
+	if len(got) != 1 {
+		t.Fatalf("Results() = %+v, want 1 result", got)
+	}
+	if want := "핫바디 처제 2020.1080p.FHDRip.H264.AAC"; got[0].Title != want {
+		t.Errorf("title = %q, want %q", got[0].Title, want)
+	}
+	if want := "https://torrentsir31.com/bbs/board.php?bo_table=movie&wr_id=15338"; got[0].DetailURL != want {
+		t.Errorf("detail URL = %q, want %q", got[0].DetailURL, want)
+	}
+	if want := "2020-12-06T06:36:12Z"; got[0].Date.UTC().Format(time.RFC3339) != want {
+		t.Errorf("date = %s, want %s", got[0].Date.UTC().Format(time.RFC3339), want)
+	}
+}
+
+func TestTorrentSirMagnet(t *testing.T) {
+	f, err := os.Open("../resources/torrentsir_bbs.html")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	doc, err := goquery.NewDocumentFromReader(f)
+	if err != nil {
+		log.Fatal(err)
+	}
+	got := ktorrent.Gnuboard.MagnetOf(doc, "torrentsir")
+	want := "magnet:?xt=urn:btih:1cc7a302e8402c48a76962d6b8f15fa4aab70381"
+	if got != want {
+		t.Errorf("MagnetOf() = %q, want %q", got, want)
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"testing"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/daite/tspider/common"
+	"github.com/daite/tspider/ktorrent"
 )
 
 func TestGetDataFuncForTorrentSome(t *testing.T) {
@@ -53,3 +55,44 @@ func TestGetMagnetFuncForTorrentSome(t *testing.T) {
 		t.Errorf("GetMagnet() for TorrentSome = %q, want %q", got, want)
 	}
 }
+
+func TestTorrentSomeResults(t *testing.T) {
+	f, err := os.Open("../resources/torrentsome_search.html")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	doc, err := goquery.NewDocumentFromReader(f)
+	if err != nil {
+		log.Fatal(err)
+	}
+	got := ktorrent.TopicBoard.Results(doc, "torrentsome", "https://torrentsome99.com/search/index?keywords=x")
+	if len(got) < 2 {
+		t.Fatalf("Results() found %d results, want the whole list", len(got))
+	}
+	want := common.SearchResult{
+		Title:     "동상이몽2 너는 내운명_E186_210301",
+		DetailURL: "https://torrentsome99.com/v/123335",
+		Size:      "2.33 GB",
+	}
+	if got[0] != want {
+		t.Errorf("Results()[0] = %+v, want %+v", got[0], want)
+	}
+}
+
+func TestTorrentSomeMagnet(t *testing.T) {
+	f, err := os.Open("../resources/torrentsome_bbs.html")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	doc, err := goquery.NewDocumentFromReader(f)
+	if err != nil {
+		log.Fatal(err)
+	}
+	got := ktorrent.TopicBoard.MagnetOf(doc, "torrentsome")
+	want := "magnet:?xt=urn:btih:08a1b53bcb809a94c2ce9582bb4d70b6a4ad4460"
+	if got != want {
+		t.Errorf("MagnetOf() = %q, want %q", got, want)
+	}
+}
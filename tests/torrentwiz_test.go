@@ -1,13 +1,19 @@
 package tests
 
 import (
+	"context"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/daite/tspider/common"
+	"github.com/daite/tspider/ktorrent"
 )
 
 func TestGetDataFuncForTorrentWiz(t *testing.T) {
@@ -49,3 +55,80 @@ func TestGetMagnetFuncForTorrentWiz(t *testing.T) {
 		t.Errorf("GetMagnet() for torrentwiz = %q, want %q", got, want)
 	}
 }
+
+func TestTorrentWizResults(t *testing.T) {
+	f, err := os.Open("../resources/torrentwiz_search.html")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	doc, err := goquery.NewDocumentFromReader(f)
+	if err != nil {
+		log.Fatal(err)
+	}
+	got := ktorrent.Gnuboard.Results(doc, "torrentwiz", "https://torrentwiz27.me/bbs/search.php?&stx=x")
+	want := []common.SearchResult{{
+		Title:     "핫바디 처제 2020.1080p.FHDRip.H264.AAC.mp4",
+		DetailURL: "https://torrentwiz27.me/bbs/board.php?bo_table=mov&wr_id=16948",
+		Date:      time.Date(2020, 12, 6, 15, 25, 1, 0, time.FixedZone("", 9*60*60)),
+	}}
+	if len(got) != 1 || got[0].Title != want[0].Title || got[0].DetailURL != want[0].DetailURL || !got[0].Date.Equal(want[0].Date) {
+		t.Errorf("Results() = %+v, want %+v", got, want)
+	}
+}
+
+func TestTorrentWizMagnet(t *testing.T) {
+	f, err := os.Open("../resources/torrentwiz_bbs.html")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	doc, err := goquery.NewDocumentFromReader(f)
+	if err != nil {
+		log.Fatal(err)
+	}
+	got := ktorrent.Gnuboard.MagnetOf(doc, "torrentwiz")
+	want := "magnet:?xt=urn:btih:1cc7a302e8402c48a76962d6b8f15fa4aab70381"
+	if got != want {
+		t.Errorf("MagnetOf() = %q, want %q", got, want)
+	}
+}
+
+func TestTorrentWizCrawl(t *testing.T) {
+	useTempHome(t)
+	search, err := os.ReadFile("../resources/torrentwiz_search.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	detail, err := os.ReadFile("../resources/torrentwiz_bbs.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/bbs/search.php" && r.URL.Query().Get("stx") == "핫바디":
+			w.Write(search)
+		case r.URL.Path == "/bbs/board.php" && r.URL.Query().Get("wr_id") == "16948":
+			w.Write(detail)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	c := common.DefaultConfig()
+	c.Sites["torrentwiz"] = common.SiteConfig{URL: srv.URL, Language: "kr", Enabled: true}
+	if err := common.SaveConfig(c); err != nil {
+		t.Fatal(err)
+	}
+
+	got := (&ktorrent.TorrentWiz{}).Crawl(context.Background(), "핫바디")
+	if len(got) != 1 {
+		t.Fatalf("Crawl() = %+v, want 1 result", got)
+	}
+	if want := "magnet:?xt=urn:btih:1cc7a302e8402c48a76962d6b8f15fa4aab70381"; got[0].Magnet != want {
+		t.Errorf("Crawl() magnet = %q, want %q", got[0].Magnet, want)
+	}
+	if want := srv.URL + "/bbs/board.php?bo_table=mov&wr_id=16948"; got[0].DetailURL != want {
+		t.Errorf("Crawl() detail URL = %q, want %q", got[0].DetailURL, want)
+	}
+}
@@ -0,0 +1,169 @@
+package tests
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/daite/tspider/common"
+	"github.com/daite/tspider/torznab"
+)
+
+const torznabFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:torznab="http://torznab.com/schemas/2015/feed">
+<channel>
+  <item>
+    <title>Ubuntu 24.04 Desktop</title>
+    <guid>https://indexer.example/details/1</guid>
+    <comments>https://indexer.example/details/1</comments>
+    <pubDate>Tue, 02 Jan 2024 15:04:05 +0000</pubDate>
+    <size>1610612736</size>
+    <link>https://jackett.example/dl/1.torrent</link>
+    <torznab:attr name="seeders" value="10"/>
+    <torznab:attr name="peers" value="15"/>
+    <torznab:attr name="grabs" value="42"/>
+    <torznab:attr name="magneturl" value="magnet:?xt=urn:btih:aaaa"/>
+  </item>
+  <item>
+    <title>Debian 12</title>
+    <link>https://jackett.example/dl/2.torrent</link>
+    <torznab:attr name="infohash" value="bbbb"/>
+  </item>
+  <item>
+    <title>Torrent file only</title>
+    <link>https://jackett.example/dl/3.torrent</link>
+  </item>
+</channel>
+</rss>`
+
+func TestTorznabParse(t *testing.T) {
+	results, err := torznab.Parse(strings.NewReader(torznabFeed))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Parse() = %d results, want 2 (the item without magnet or hash dropped)", len(results))
+	}
+	r := results[0]
+	if r.Title != "Ubuntu 24.04 Desktop" || r.Magnet != "magnet:?xt=urn:btih:aaaa" {
+		t.Errorf("result = %q %q, want the title and magneturl", r.Title, r.Magnet)
+	}
+	if r.Seeders != 10 || r.Leechers != 5 || r.Snatches != 42 {
+		t.Errorf("peers = %d/%d/%d, want 10 seeders, 5 leechers, 42 snatches", r.Seeders, r.Leechers, r.Snatches)
+	}
+	if r.Size != "1.5 GiB" || r.DetailURL != "https://indexer.example/details/1" {
+		t.Errorf("size, page = %q, %q", r.Size, r.DetailURL)
+	}
+	if r.Date.Year() != 2024 {
+		t.Errorf("date = %v, want the pubDate", r.Date)
+	}
+	if got := results[1].Magnet; got != "magnet:?xt=urn:btih:bbbb&dn=Debian+12" {
+		t.Errorf("magnet from infohash = %q", got)
+	}
+}
+
+func TestTorznabParseError(t *testing.T) {
+	_, err := torznab.Parse(strings.NewReader(`<error code="100" description="Invalid API Key"/>`))
+	if err == nil || !strings.Contains(err.Error(), "Invalid API Key") {
+		t.Errorf("Parse() error = %v, want the Torznab error", err)
+	}
+}
+
+func TestTorznabIndexerCrawl(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("apikey") != "k3y" {
+			w.Write([]byte(`<error code="100" description="Invalid API Key"/>`))
+			return
+		}
+		switch q.Get("t") {
+		case "caps":
+			w.Write([]byte(`<caps><searching><search available="yes"/></searching></caps>`))
+		case "search":
+			if q.Get("q") != "ubuntu" {
+				w.Write([]byte(`<rss><channel></channel></rss>`))
+				return
+			}
+			w.Write([]byte(torznabFeed))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	useTempHome(t)
+	if err := common.AddTorznabSite("jackett", srv.URL+"/api?cat=5000", "jp", "k3y"); err != nil {
+		t.Fatal(err)
+	}
+
+	indexer := &torznab.Indexer{Name: "jackett"}
+	if u := indexer.SearchURL("ubuntu"); !strings.Contains(u, "cat=5000") || !strings.Contains(u, "t=search") {
+		t.Errorf("SearchURL() = %s, want the endpoint's own parameters kept", u)
+	}
+	results := indexer.Crawl(context.Background(), "ubuntu")
+	if len(results) != 2 {
+		t.Fatalf("Crawl() = %d results, want 2", len(results))
+	}
+
+	common.Availability().Reset()
+	sites, spinner := common.GetAvailableSites(context.Background(), map[string]common.Scraper{"jackett": indexer})
+	spinner.Stop()
+	if _, ok := sites["jackett"]; !ok {
+		t.Errorf("GetAvailableSites() = %v, want jackett up through its caps URL", sites)
+	}
+}
+
+func TestTorznabHandler(t *testing.T) {
+	var searched string
+	handler := &torznab.Handler{
+		APIKey: "k3y",
+		Lang:   "jp",
+		Search: func(ctx context.Context, keyword, lang string) ([]common.SearchResult, error) {
+			searched = keyword + "|" + lang
+			return []common.SearchResult{
+				{Title: "Show S01E02 1080p", Magnet: "magnet:?xt=urn:btih:0123456789abcdef0123456789abcdef01234567", Size: "1.5 GiB", Seeders: 7, Leechers: 3},
+				{Title: "Show S01E02 720p", Magnet: "magnet:?xt=urn:btih:aaaa", Size: "700 MiB"},
+			}, nil
+		},
+	}
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+	get := func(query string) string {
+		resp, err := http.Get(srv.URL + "/torznab/api?" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var b strings.Builder
+		io.Copy(&b, resp.Body)
+		return b.String()
+	}
+
+	if body := get("t=caps"); !strings.Contains(body, `code="100"`) {
+		t.Errorf("caps without key = %s, want error 100", body)
+	}
+	if body := get("t=caps&apikey=k3y"); !strings.Contains(body, "<tv-search available=\"yes\" supportedParams=\"q,season,ep\">") {
+		t.Errorf("caps = %s, want tv-search", body)
+	}
+
+	body := get("t=tvsearch&apikey=k3y&q=Show&season=1&ep=2&cat=5000&limit=1")
+	if searched != "Show S01E02|jp" {
+		t.Errorf("searched %q, want the episode in the keyword", searched)
+	}
+	results, err := torznab.Parse(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Parse(feed) error = %v\n%s", err, body)
+	}
+	if len(results) != 1 {
+		t.Fatalf("feed has %d items, want limit=1 applied", len(results))
+	}
+	r := results[0]
+	if r.Title != "Show S01E02 1080p" || r.Seeders != 7 || r.Leechers != 3 || r.Size != "1.5 GiB" {
+		t.Errorf("feed item = %+v", r)
+	}
+	if !strings.Contains(body, `<torznab:attr name="category" value="5000">`) {
+		t.Errorf("feed = %s, want the requested category", body)
+	}
+}
@@ -0,0 +1,137 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/daite/tspider/clients"
+	"github.com/daite/tspider/common"
+)
+
+// fakeTransmission emulates torrent-add, session-get and torrent-get of the
+// Transmission RPC API,
+// including the 409 session id handshake and basic authentication
+type fakeTransmission struct {
+	conflicts int
+	added     []map[string]interface{}
+}
+
+func (f *fakeTransmission) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if user, pass, ok := r.BasicAuth(); !ok || user != "admin" || pass != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if r.Header.Get("X-Transmission-Session-Id") != "token" {
+		f.conflicts++
+		w.Header().Set("X-Transmission-Session-Id", "token")
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+	var req struct {
+		Method    string                 `json:"method"`
+		Arguments map[string]interface{} `json:"arguments"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	switch req.Method {
+	case "session-get":
+		w.Write([]byte(`{"result": "success", "arguments": {"version": "4.0.5"}}`))
+		return
+	case "torrent-get":
+		w.Write([]byte(`{"result": "success", "arguments": {"torrents": [
+			{"name": "Paused", "hashString": "aaa", "percentDone": 0.1, "status": 0, "rateDownload": 0},
+			{"name": "Ubuntu", "hashString": "abc", "percentDone": 0.5, "status": 4, "rateDownload": 1024}]}}`))
+		return
+	case "torrent-add":
+	default:
+		w.Write([]byte(`{"result": "method name not recognized"}`))
+		return
+	}
+	f.added = append(f.added, req.Arguments)
+	w.Write([]byte(`{"result": "success", "arguments": {"torrent-added": {"id": 1}}}`))
+}
+
+func useTransmission(t *testing.T, url, password string) {
+	useTempHome(t)
+	c := common.DefaultConfig()
+	c.Clients = map[string]common.ClientConfig{
+		"transmission": {URL: url, Username: "admin", Password: password},
+	}
+	if err := common.SaveConfig(c); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTransmissionAddMagnets(t *testing.T) {
+	fake := &fakeTransmission{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	useTransmission(t, srv.URL, "secret")
+
+	client, err := clients.New("transmission")
+	if err != nil {
+		t.Fatal(err)
+	}
+	magnets := []string{"magnet:?xt=urn:btih:a", "magnet:?xt=urn:btih:b"}
+	if err := clients.AddMagnets(client, magnets, clients.AddOptions{Category: "tv", SavePath: "/data/tv"}); err != nil {
+		t.Fatalf("AddMagnets() error = %v", err)
+	}
+	if fake.conflicts != 1 {
+		t.Errorf("409 replies = %d, want the session id fetched once", fake.conflicts)
+	}
+	if len(fake.added) != 2 {
+		t.Fatalf("added %d torrents, want 2", len(fake.added))
+	}
+	got := fake.added[1]
+	if got["filename"] != magnets[1] || got["download-dir"] != "/data/tv" {
+		t.Errorf("torrent-add arguments = %v, want the magnet and download-dir", got)
+	}
+	if labels, _ := got["labels"].([]interface{}); len(labels) != 1 || labels[0] != "tv" {
+		t.Errorf("torrent-add labels = %v, want [tv]", got["labels"])
+	}
+}
+
+func TestTransmissionBadCredentials(t *testing.T) {
+	srv := httptest.NewServer(&fakeTransmission{})
+	defer srv.Close()
+	useTransmission(t, srv.URL, "wrong")
+
+	client, err := clients.New("transmission")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.TestConnection()
+	if err == nil || !strings.Contains(err.Error(), "credentials") {
+		t.Errorf("TestConnection() error = %v, want a credentials error", err)
+	}
+}
+
+func TestTransmissionListActive(t *testing.T) {
+	srv := httptest.NewServer(&fakeTransmission{})
+	defer srv.Close()
+	useTransmission(t, srv.URL, "secret")
+
+	got, err := clients.NewTransmission(common.ClientConfig{URL: srv.URL, Username: "admin", Password: "secret"}).ListActive()
+	if err != nil {
+		t.Fatalf("ListActive() error = %v", err)
+	}
+	want := clients.Torrent{Name: "Ubuntu", Hash: "abc", Progress: 0.5, State: "downloading", DownloadRate: 1024}
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("ListActive() = %+v, want only the unstopped %+v", got, want)
+	}
+}
+
+func TestNewClientRejectsUnknown(t *testing.T) {
+	if _, err := clients.New("utorrent"); err == nil {
+		t.Errorf("New(utorrent) = nil error, want unknown client")
+	}
+}
+
+func TestClientNames(t *testing.T) {
+	got := strings.Join(clients.Names(), ",")
+	if got != "aria2,qbittorrent,transmission" {
+		t.Errorf("Names() = %s, want every registered client", got)
+	}
+}
@@ -0,0 +1,31 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/daite/tspider/jtorrent"
+)
+
+func TestSearchURLWithUploader(t *testing.T) {
+	cases := []struct {
+		uploader, keyword, want string
+	}{
+		{"", "one piece", "https://nyaa.si/?f=0&c=0_0&q=one+piece"},
+		{"Erai-raws", "one piece", "https://nyaa.si/user/Erai-raws?f=0&c=0_0&q=one+piece"},
+		{"Erai-raws", "", "https://nyaa.si/user/Erai-raws?f=0&c=0_0&q="},
+		{"a b/c", "x", "https://nyaa.si/user/a%20b%2Fc?f=0&c=0_0&q=x"},
+	}
+	for _, tc := range cases {
+		if got := jtorrent.SearchURL("https://nyaa.si", tc.uploader, tc.keyword, false, nil); got != tc.want {
+			t.Errorf("SearchURL(%q, %q) = %q, want %q", tc.uploader, tc.keyword, got, tc.want)
+		}
+	}
+}
+
+func TestSearchURLExactQuotesKeyword(t *testing.T) {
+	got := jtorrent.SearchURL("https://nyaa.si", "", "one piece", true, nil)
+	want := "https://nyaa.si/?f=0&c=0_0&q=%22one+piece%22"
+	if got != want {
+		t.Errorf("SearchURL(exact) = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,129 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/daite/tspider/common"
+)
+
+var uaPool = []string{"agent-a", "agent-b", "agent-c"}
+
+func TestNextUserAgentRoundRobinCoversPool(t *testing.T) {
+	useTempHome(t)
+	c := common.DefaultConfig()
+	c.UserAgents = uaPool
+	common.SaveConfig(c)
+
+	const perAgent = 50
+	var (
+		mu   sync.Mutex
+		seen = map[string]int{}
+		wg   sync.WaitGroup
+	)
+	for i := 0; i < perAgent*len(uaPool); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ua := common.NextUserAgent()
+			mu.Lock()
+			seen[ua]++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	for _, ua := range uaPool {
+		if seen[ua] != perAgent {
+			t.Errorf("%s used %d times, want %d (round-robin): %v", ua, seen[ua], perAgent, seen)
+		}
+	}
+	if len(seen) != len(uaPool) {
+		t.Errorf("NextUserAgent() returned agents outside the pool: %v", seen)
+	}
+}
+
+func TestNextUserAgentRandomStaysInPool(t *testing.T) {
+	useTempHome(t)
+	c := common.DefaultConfig()
+	c.UserAgents = uaPool
+	c.UserAgentRotation = "random"
+	common.SaveConfig(c)
+
+	seen := map[string]bool{}
+	for i := 0; i < 300; i++ {
+		seen[common.NextUserAgent()] = true
+	}
+	for ua := range seen {
+		if !containsUA(uaPool, ua) {
+			t.Errorf("NextUserAgent() = %q, not in the pool", ua)
+		}
+	}
+	if len(seen) != len(uaPool) {
+		t.Errorf("random rotation used %d of %d agents in 300 requests", len(seen), len(uaPool))
+	}
+}
+
+func TestNextUserAgentFallsBackToSingle(t *testing.T) {
+	useTempHome(t)
+	c := common.DefaultConfig()
+	c.UserAgent = "single"
+	common.SaveConfig(c)
+	if got := common.NextUserAgent(); got != "single" {
+		t.Errorf("NextUserAgent() with an empty pool = %q, want %q", got, "single")
+	}
+}
+
+func TestRequestsRotateUserAgent(t *testing.T) {
+	useTempHome(t)
+	c := common.DefaultConfig()
+	c.UserAgents = uaPool
+	common.SaveConfig(c)
+
+	var (
+		mu   sync.Mutex
+		seen = map[string]bool{}
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen[r.UserAgent()] = true
+		mu.Unlock()
+	}))
+	defer server.Close()
+	for range uaPool {
+		if resp, ok := common.GetResponseFromURL(context.Background(), server.URL); ok {
+			resp.Body.Close()
+		}
+	}
+	if len(seen) != len(uaPool) {
+		t.Errorf("requests used User-Agents %v, want each of %v", seen, uaPool)
+	}
+}
+
+func TestAddUserAgent(t *testing.T) {
+	useTempHome(t)
+	common.SaveConfig(common.DefaultConfig())
+	if err := common.AddUserAgent("agent-a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := common.AddUserAgent("agent-a"); err == nil {
+		t.Error("AddUserAgent() with a duplicate = nil, want error")
+	}
+	if err := common.AddUserAgent("  "); err == nil {
+		t.Error("AddUserAgent() with an empty string = nil, want error")
+	}
+	if got := common.GetConfig().UserAgents; len(got) != 1 || got[0] != "agent-a" {
+		t.Errorf("UserAgents = %q, want [agent-a]", got)
+	}
+}
+
+func containsUA(pool []string, ua string) bool {
+	for _, p := range pool {
+		if p == ua {
+			return true
+		}
+	}
+	return false
+}
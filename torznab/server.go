@@ -0,0 +1,256 @@
+package torznab
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/daite/tspider/common"
+)
+
+// Torznab error codes, from the Newznab API specification
+const (
+	errIncorrectKey     = 100
+	errMissingParameter = 200
+	errNoFunction       = 202
+	errUnknown          = 900
+)
+
+// Newznab categories offered by the caps reply. tspider cannot tell what a
+// result is, so each gets the first category the client asked for.
+var categories = []struct {
+	ID   int
+	Name string
+}{
+	{2000, "Movies"},
+	{5000, "TV"},
+	{5070, "TV/Anime"},
+	{8000, "Other"},
+}
+
+// defaultCategory is the category of results when the client asked for none
+const defaultCategory = 8000
+
+// SearchFunc returns the results the sites of lang list for keyword
+type SearchFunc func(ctx context.Context, keyword, lang string) ([]common.SearchResult, error)
+
+// Handler serves the Torznab API (t=caps, search and tvsearch) over the
+// sites tspider scrapes, so that Sonarr, Radarr and other *arr applications
+// can use them as an indexer
+type Handler struct {
+	Search SearchFunc
+	// APIKey, when set, must be sent as the apikey parameter
+	APIKey string
+	// Lang is the language of the sites searched when the request has no
+	// lang parameter
+	Lang string
+}
+
+// ServeHTTP answers one Torznab request
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if h.APIKey != "" && subtle.ConstantTimeCompare([]byte(q.Get("apikey")), []byte(h.APIKey)) != 1 {
+		writeError(w, errIncorrectKey, "Incorrect user credentials")
+		return
+	}
+	switch q.Get("t") {
+	case "caps":
+		writeCaps(w)
+	case "search", "tvsearch", "tv-search", "movie":
+		h.search(w, r)
+	case "":
+		writeError(w, errMissingParameter, "Missing parameter (t)")
+	default:
+		writeError(w, errNoFunction, "No such function ("+q.Get("t")+")")
+	}
+}
+
+// search answers a search, tvsearch or movie request
+func (h *Handler) search(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	keyword := strings.TrimSpace(q.Get("q"))
+	if keyword != "" {
+		keyword = strings.TrimSpace(keyword + " " + episodeTerm(q.Get("season"), q.Get("ep")))
+	}
+	category := defaultCategory
+	if cats := strings.Split(q.Get("cat"), ","); cats[0] != "" {
+		if n, err := strconv.Atoi(cats[0]); err == nil {
+			category = n
+		}
+	}
+	lang := q.Get("lang")
+	if lang == "" {
+		lang = h.Lang
+	}
+	// Without a query *arr applications want the latest releases, which
+	// tspider has no way to list; an empty feed tells them the indexer works
+	var results []common.SearchResult
+	if keyword != "" {
+		var err error
+		results, err = h.Search(r.Context(), keyword, lang)
+		if err != nil {
+			writeError(w, errUnknown, err.Error())
+			return
+		}
+	}
+	offset, _ := strconv.Atoi(q.Get("offset"))
+	if offset > len(results) {
+		offset = len(results)
+	}
+	results = results[offset:]
+	if limit, err := strconv.Atoi(q.Get("limit")); err == nil && limit >= 0 && limit < len(results) {
+		results = results[:limit]
+	}
+	writeFeed(w, results, category)
+}
+
+// episodeTerm returns the S01E02 style term of a tvsearch season and
+// episode, or "" without a season
+func episodeTerm(season, ep string) string {
+	s, err := strconv.Atoi(season)
+	if err != nil {
+		return ""
+	}
+	if e, err := strconv.Atoi(ep); err == nil {
+		return fmt.Sprintf("S%02dE%02d", s, e)
+	}
+	return fmt.Sprintf("S%02d", s)
+}
+
+// writeXML writes v as an XML document
+func writeXML(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	fmt.Fprint(w, xml.Header)
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	enc.Encode(v)
+}
+
+// writeError writes a Torznab error element
+func writeError(w http.ResponseWriter, code int, description string) {
+	writeXML(w, struct {
+		XMLName     xml.Name `xml:"error"`
+		Code        int      `xml:"code,attr"`
+		Description string   `xml:"description,attr"`
+	}{Code: code, Description: description})
+}
+
+// writeCaps writes the capabilities of the Handler
+func writeCaps(w http.ResponseWriter) {
+	type search struct {
+		Available       string `xml:"available,attr"`
+		SupportedParams string `xml:"supportedParams,attr"`
+	}
+	type category struct {
+		ID   int    `xml:"id,attr"`
+		Name string `xml:"name,attr"`
+	}
+	caps := struct {
+		XMLName xml.Name `xml:"caps"`
+		Server  struct {
+			Title string `xml:"title,attr"`
+		} `xml:"server"`
+		Limits struct {
+			Max     int `xml:"max,attr"`
+			Default int `xml:"default,attr"`
+		} `xml:"limits"`
+		Searching struct {
+			Search      search `xml:"search"`
+			TVSearch    search `xml:"tv-search"`
+			MovieSearch search `xml:"movie-search"`
+		} `xml:"searching"`
+		Categories []category `xml:"categories>category"`
+	}{}
+	caps.Server.Title = "tspider"
+	caps.Limits.Max = 100
+	caps.Limits.Default = 100
+	caps.Searching.Search = search{"yes", "q"}
+	caps.Searching.TVSearch = search{"yes", "q,season,ep"}
+	caps.Searching.MovieSearch = search{"yes", "q"}
+	for _, c := range categories {
+		caps.Categories = append(caps.Categories, category{c.ID, c.Name})
+	}
+	writeXML(w, caps)
+}
+
+type rssAttr struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type rssItem struct {
+	Title     string `xml:"title"`
+	GUID      string `xml:"guid"`
+	Link      string `xml:"link"`
+	Comments  string `xml:"comments,omitempty"`
+	PubDate   string `xml:"pubDate,omitempty"`
+	Size      int64  `xml:"size,omitempty"`
+	Category  int    `xml:"category"`
+	Enclosure struct {
+		URL    string `xml:"url,attr"`
+		Length int64  `xml:"length,attr"`
+		Type   string `xml:"type,attr"`
+	} `xml:"enclosure"`
+	Attrs []rssAttr `xml:"torznab:attr"`
+}
+
+// writeFeed writes results as a Torznab RSS feed, each in category
+func writeFeed(w http.ResponseWriter, results []common.SearchResult, category int) {
+	feed := struct {
+		XMLName xml.Name `xml:"rss"`
+		Version string   `xml:"version,attr"`
+		NS      string   `xml:"xmlns:torznab,attr"`
+		Channel struct {
+			Title string    `xml:"title"`
+			Items []rssItem `xml:"item"`
+		} `xml:"channel"`
+	}{Version: "2.0", NS: "http://torznab.com/schemas/2015/feed"}
+	feed.Channel.Title = "tspider"
+	for _, r := range results {
+		feed.Channel.Items = append(feed.Channel.Items, feedItem(r, category))
+	}
+	writeXML(w, feed)
+}
+
+// feedItem returns r as a Torznab item
+func feedItem(r common.SearchResult, category int) rssItem {
+	it := rssItem{
+		Title:    r.Title,
+		GUID:     r.Magnet,
+		Link:     r.Magnet,
+		Comments: r.DetailURL,
+		Category: category,
+	}
+	if !r.Date.IsZero() {
+		it.PubDate = r.Date.Format(time.RFC1123Z)
+	}
+	if n, ok := common.ParseSize(r.Size); ok {
+		it.Size = n
+	}
+	it.Enclosure.URL = r.Magnet
+	it.Enclosure.Length = it.Size
+	it.Enclosure.Type = "application/x-bittorrent"
+	attr := func(name string, value interface{}) {
+		it.Attrs = append(it.Attrs, rssAttr{name, fmt.Sprint(value)})
+	}
+	attr("category", category)
+	attr("magneturl", r.Magnet)
+	if h := common.InfoHash(r.Magnet); h != "" {
+		it.GUID = h
+		attr("infohash", h)
+	}
+	if r.Size != "" {
+		attr("size", it.Size)
+	}
+	attr("seeders", r.Seeders)
+	attr("peers", r.Seeders+r.Leechers)
+	if r.Snatches > 0 {
+		attr("grabs", r.Snatches)
+	}
+	return it
+}
@@ -0,0 +1,202 @@
+package torznab
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/daite/tspider/common"
+)
+
+// Indexer searches a Torznab endpoint, such as a Jackett or Prowlarr
+// indexer, configured as the site Name with type "torznab". The site URL is
+// the endpoint, e.g.
+// http://127.0.0.1:9117/api/v2.0/indexers/all/results/torznab, and its
+// api_key the key of the Jackett or Prowlarr instance.
+type Indexer struct {
+	Name string
+}
+
+// apiURL returns the endpoint of the indexer with the query parameters of
+// the Torznab function t
+func (i *Indexer) apiURL(t string, params url.Values) string {
+	u, err := url.Parse(common.SiteURL(i.Name))
+	if err != nil {
+		return common.SiteURL(i.Name)
+	}
+	q := u.Query()
+	for k, v := range params {
+		q[k] = v
+	}
+	q.Set("t", t)
+	if key := common.GetConfig().Sites[i.Name].APIKey; key != "" {
+		q.Set("apikey", key)
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// SearchURL returns the Torznab search URL for keyword
+func (i *Indexer) SearchURL(keyword string) string {
+	return i.apiURL("search", url.Values{"q": {keyword}})
+}
+
+// ProbeURL returns the capabilities URL, which answers only when the
+// endpoint is up and accepts the API key
+func (i *Indexer) ProbeURL() string {
+	return i.apiURL("caps", nil)
+}
+
+// Crawl returns the results the indexer finds for keyword
+func (i *Indexer) Crawl(ctx context.Context, keyword string) []common.SearchResult {
+	resp, ok := common.GetResponseFromURL(ctx, i.SearchURL(keyword))
+	if !ok {
+		return nil
+	}
+	defer resp.Body.Close()
+	results, err := Parse(resp.Body)
+	if err != nil {
+		return nil
+	}
+	return results
+}
+
+// feed is a Torznab response: an RSS channel of items, or an error element
+type feed struct {
+	XMLName     xml.Name
+	Code        string `xml:"code,attr"`
+	Description string `xml:"description,attr"`
+	Items       []item `xml:"channel>item"`
+}
+
+type item struct {
+	Title     string `xml:"title"`
+	GUID      string `xml:"guid"`
+	Link      string `xml:"link"`
+	Comments  string `xml:"comments"`
+	PubDate   string `xml:"pubDate"`
+	Size      string `xml:"size"`
+	Enclosure struct {
+		URL string `xml:"url,attr"`
+	} `xml:"enclosure"`
+	// Attrs are the torznab:attr elements; any namespace prefix is accepted
+	Attrs []struct {
+		Name  string `xml:"name,attr"`
+		Value string `xml:"value,attr"`
+	} `xml:"attr"`
+}
+
+// attr returns the value of the torznab:attr called name
+func (it *item) attr(name string) string {
+	for _, a := range it.Attrs {
+		if a.Name == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// Parse reads a Torznab search response. Items without a magnet link or
+// info hash, which only a .torrent download could fetch, are left out.
+func Parse(r io.Reader) ([]common.SearchResult, error) {
+	var f feed
+	if err := xml.NewDecoder(r).Decode(&f); err != nil {
+		return nil, fmt.Errorf("torznab: %w", err)
+	}
+	if f.XMLName.Local == "error" {
+		return nil, fmt.Errorf("torznab error %s: %s", f.Code, f.Description)
+	}
+	results := []common.SearchResult{}
+	for _, it := range f.Items {
+		magnet := magnetOf(&it)
+		if magnet == "" {
+			continue
+		}
+		seeders, _ := strconv.Atoi(it.attr("seeders"))
+		peers, _ := strconv.Atoi(it.attr("peers"))
+		// peers counts the seeders too
+		leechers := peers - seeders
+		if v := it.attr("leechers"); v != "" {
+			leechers, _ = strconv.Atoi(v)
+		}
+		if leechers < 0 {
+			leechers = 0
+		}
+		snatches, _ := strconv.Atoi(it.attr("grabs"))
+		size := it.Size
+		if size == "" {
+			size = it.attr("size")
+		}
+		results = append(results, common.SearchResult{
+			Title:     strings.TrimSpace(it.Title),
+			Magnet:    magnet,
+			Seeders:   seeders,
+			Leechers:  leechers,
+			Snatches:  snatches,
+			Size:      formatSize(size),
+			DetailURL: detailURL(&it),
+			Date:      parseDate(it.PubDate),
+		})
+	}
+	return results, nil
+}
+
+// magnetOf returns the magnet of it from the magneturl attribute, a magnet
+// link or enclosure, or the info hash, in that order
+func magnetOf(it *item) string {
+	for _, link := range []string{it.attr("magneturl"), it.Link, it.Enclosure.URL} {
+		if strings.HasPrefix(link, "magnet:") {
+			return link
+		}
+	}
+	if hash := it.attr("infohash"); hash != "" {
+		return "magnet:?xt=urn:btih:" + hash + "&dn=" + url.QueryEscape(strings.TrimSpace(it.Title))
+	}
+	return ""
+}
+
+// detailURL returns the indexer page of it, which Torznab puts in comments
+// and often in guid
+func detailURL(it *item) string {
+	for _, u := range []string{it.Comments, it.GUID} {
+		if strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://") {
+			return u
+		}
+	}
+	return ""
+}
+
+// formatSize shows a size in bytes as sites do, e.g. "1.4 GiB"; sizes that
+// are not a byte count are kept as they are
+func formatSize(bytes string) string {
+	n, err := strconv.ParseInt(strings.TrimSpace(bytes), 10, 64)
+	if err != nil || n <= 0 {
+		return strings.TrimSpace(bytes)
+	}
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	v, u := float64(n), 0
+	for v >= 1024 && u < len(units)-1 {
+		v /= 1024
+		u++
+	}
+	if u == 0 {
+		return fmt.Sprintf("%d B", n)
+	}
+	return fmt.Sprintf("%.1f %s", v, units[u])
+}
+
+// parseDate parses an RSS pubDate, returning the zero time when it is
+// missing or malformed
+func parseDate(s string) time.Time {
+	for _, layout := range []string{time.RFC1123Z, time.RFC1123} {
+		if t, err := time.Parse(layout, strings.TrimSpace(s)); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}